@@ -0,0 +1,39 @@
+package main
+
+import (
+	"flag"
+	"log/slog"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/kelein/micro-device-plugin/pkg/aggregator"
+)
+
+// aggregate serves the merged metrics of the node plugins
+func aggregate(args []string) {
+	fs := flag.NewFlagSet("aggregate", flag.ExitOnError)
+	nodes := fs.String("nodes", "", "comma separated node metrics addresses, e.g. node1:9090,node2:9090")
+	listen := fs.String("listen", ":9091", "HTTP address serving the aggregated metrics")
+	retries := fs.Int("retries", 2, "number of retries of a failed node query")
+	retryInterval := fs.Duration("retry-interval", time.Second, "delay between the retries of a node query")
+	fs.Parse(args)
+
+	if *nodes == "" {
+		slog.Error("no nodes to aggregate, set --nodes")
+		os.Exit(1)
+		return
+	}
+	agg := aggregator.NewAggregator(strings.Split(*nodes, ","))
+	agg.Retries = *retries
+	agg.RetryInterval = *retryInterval
+
+	mux := http.NewServeMux()
+	mux.Handle("GET /metrics", agg.Handler())
+	slog.Info("metrics aggregator listening", "addr", *listen, "nodes", agg.Nodes)
+	if err := http.ListenAndServe(*listen, mux); err != nil {
+		slog.Error("metrics aggregator exited", "err", err)
+		os.Exit(1)
+	}
+}
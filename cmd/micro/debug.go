@@ -0,0 +1,6 @@
+//go:build debug
+
+package main
+
+// debugBuild turns on debugging features by default in debug builds
+const debugBuild = true
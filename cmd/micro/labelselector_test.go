@@ -0,0 +1,40 @@
+package main
+
+import (
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestNodeSelected(t *testing.T) {
+	client := fake.NewClientset(&corev1.Node{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   "node1",
+			Labels: map[string]string{"tier": "premium", "zone": "us-east-1a"},
+		},
+	})
+	tests := []struct {
+		name     string
+		node     string
+		selector string
+		wantRun  bool
+		wantCode int
+	}{
+		{name: "matching node proceeds", node: "node1", selector: "tier=premium,zone=us-east-1a", wantRun: true},
+		{name: "non-matching node exits cleanly", node: "node1", selector: "tier=standard"},
+		{name: "missing label exits cleanly", node: "node1", selector: "gpu"},
+		{name: "unknown node", node: "node2", selector: "tier=premium", wantCode: 1},
+		{name: "invalid selector", node: "node1", selector: "tier==,", wantCode: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			run, code := nodeSelected(client, tt.node, tt.selector)
+			if run != tt.wantRun || code != tt.wantCode {
+				t.Errorf("nodeSelected(%s, %q) = %v, %d, want %v, %d",
+					tt.node, tt.selector, run, code, tt.wantRun, tt.wantCode)
+			}
+		})
+	}
+}
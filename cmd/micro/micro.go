@@ -1,108 +1,758 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"fmt"
 	"log/slog"
 	"os"
+	"os/signal"
 	"path/filepath"
+	"regexp"
+	"strings"
+	"syscall"
 	"time"
 
 	"github.com/fsnotify/fsnotify"
-	"github.com/prometheus/client_golang/prometheus"
+	"k8s.io/client-go/kubernetes"
 
+	"github.com/kelein/micro-device-plugin/pkg/config"
+	"github.com/kelein/micro-device-plugin/pkg/discovery"
 	"github.com/kelein/micro-device-plugin/pkg/server"
+	"github.com/kelein/micro-device-plugin/pkg/state"
 	"github.com/kelein/micro-device-plugin/pkg/version"
 )
 
 var (
 	v   = flag.Bool("v", false, "show the binary build version")
 	ver = flag.Bool("version", false, "show the binary build version")
-)
 
-type logFmt uint32
+	listen     = flag.String("listen", ":9090", "HTTP address serving metrics and plugin status")
+	checkVer   = flag.Bool("check-version", false, "warn on startup if a newer plugin release is available")
+	restAPI    = flag.Bool("enable-rest-api", true, "serve the device inventory at /api/v1/devices on the HTTP server")
+	kubeconfig = flag.String("kubeconfig", "", "kubeconfig file path, in-cluster config is used if empty")
+	configFile = flag.String("config", "", "YAML or JSON config file, explicitly set flags override its values")
 
-// Log Output Format
-const (
-	JSON logFmt = iota
-	TEXT
-)
+	logLevel        = flag.String("log-level", "info", "log level: debug, info, warn or error")
+	logFormat       = flag.String("log-format", "text", "log format: text or json")
+	logFile         = flag.String("log-file", "", "write the logs to the rotated file instead of stdout")
+	maxLogFileSize  = flag.Int("max-log-file-size", 100, "log file size in megabytes triggering the rotation")
+	maxLogBackups   = flag.Int("max-log-backups", 3, "number of rotated log files kept")
+	logSampleRate   = flag.Int("log-sample-rate", 0, "number of log records of the same message logged per sample window, 0 disables the sampling")
+	logSampleWindow = flag.Duration("log-sample-window", server.DefaultLogSampleWindow, "window of the log sampling, the suppressed records are summarized at its end")
+	pprofListen     = flag.String("pprof-listen", "", "HTTP address serving the pprof profiles, disabled if empty")
+	tracingURL      = flag.String("tracing-endpoint", "", "OTLP/HTTP collector URL receiving the spans of the plugin RPCs, /v1/traces is appended if it has no path, disabled if empty")
 
-func init() {
-	initLogger(TEXT)
+	labelSelector = flag.String("label-selector", "", "only register devices if the node labels match the selector, e.g. tier=premium")
 
-	// * Register Prometheus Metrics Collector
-	prometheus.MustRegister(version.NewCollector())
-}
+	socketBacklog  = flag.Int("socket-backlog", 128, "listen backlog of the plugin socket, the kernel caps it at net.core.somaxconn")
+	socketName     = flag.String("plugin-socket-name", "micro.sock", "plugin socket file name in the plugin path, must end with .sock")
+	namespace      = flag.String("namespace", "default", "isolation namespace prefixing the plugin socket, lock and pid files, naming the state subdirectory and labeling the metrics")
+	resourceName   = flag.String("resource-name", config.DefaultResourceName, "extended resource name advertised to kubelet")
+	versionCheck   = flag.Bool("version-check-on-start", false, "check kubelet supports one of the supported device plugin API versions before registering")
+	strictVersion  = flag.Bool("strict-version-check", false, "fail the registration instead of warning if the kubelet API version is not supported, implies version-check-on-start")
+	priorityQueue  = flag.Int("allocation-priority-queue", 0, "size per priority of the queue serving high priority allocations first, 0 disables the queue; kubelet allocations are normal priority, only other callers set it with the micro-plugin-priority gRPC metadata")
+	registerJitter = flag.Int("register-jitter-max-ms", 5000, "maximum random delay in milliseconds before the first kubelet registration, 0 registers immediately")
+	resourceAlias  = flag.String("resource-aliases", "", "comma separated additional resource names advertising the same devices, e.g. the legacy name during a rename, requires deallocate-hook or state-gc-interval")
+	resourceFile   = flag.String("resource-name-file", "", "file whose first line is the resource name, overrides --resource-name and the config file")
+	devicePath     = flag.String("device-path", config.DefaultDevicePath, "directory of the micro device files")
+	startupFile    = flag.String("startup-probe-delay-file", "", "wait for the file to exist before discovering the devices")
+	startupURL     = flag.String("startup-probe-url", "", "wait for the URL to answer 200 OK before discovering the devices")
+	startupWait    = flag.Duration("startup-probe-timeout", 0, "give up waiting for the startup probe after the timeout, 0 to wait forever")
+	createDevPath  = flag.Bool("device-path-create-if-missing", false, "create the device directory on startup if it does not exist")
+	pluginPath     = flag.String("plugin-path", config.DefaultPluginPath, "kubelet device plugin directory")
+
+	featureGates    = flag.String("feature-gates", "", "comma separated Key=true|false feature gates, e.g. XattrMetadata=false")
+	archDevicePaths = flag.String("arch-device-paths", "", "per architecture device directories overriding device-path, e.g. arm64=/etc/micro-arm")
+	staticDevices   = flag.String("static-devices-file", "", "YAML file declaring the devices used when no device files are discovered")
+	devicePipe      = flag.String("device-pipe", "", "named pipe of JSON lines adding and removing devices, replaces the device-path discovery if set")
+	recursiveWatch  = flag.Bool("device-path-watch-recursive", false, "discover and watch the device files of the device-path subdirectories")
+
+	leaseName          = flag.String("lease-name", "", "heartbeat lease name, heartbeat is disabled if empty")
+	leaseNamespace     = flag.String("lease-namespace", "kube-system", "heartbeat lease namespace")
+	leaseRenewInterval = flag.Duration("lease-renew-interval", 10*time.Second, "heartbeat lease renew interval")
+
+	nodeCondition      = flag.Bool("node-condition", false, "report the plugin readiness as a node condition")
+	conditionType      = flag.String("condition-type", server.DefaultConditionType, "node condition type reporting the plugin readiness")
+	nodeLabelPrefix    = flag.String("node-label-prefix", "", "expose the device inventory as node labels with the prefix, e.g. "+server.DefaultNodeLabelPrefix+", disabled if empty")
+	labelReconcile     = flag.Duration("label-reconcile-interval", 60*time.Second, "interval of reconciling the device inventory node labels")
+	deallocateHook     = flag.Bool("deallocate-hook", false, "watch pod deletions on the node to release allocated devices")
+	admissionMode      = flag.Bool("admission-mode", false, "serve a validating admission webhook for the pods requesting the plugin resource")
+	admissionListen    = flag.String("admission-listen", ":8443", "HTTPS address of the admission webhook")
+	admissionCert      = flag.String("admission-tls-cert-file", "", "TLS certificate file of the admission webhook")
+	admissionKey       = flag.String("admission-tls-key-file", "", "TLS key file of the admission webhook")
+	allowedNamespaces  = flag.String("allowed-namespaces", "", "comma separated namespaces allowed to request devices, all if empty")
+	requiredSALabel    = flag.String("required-service-account-label", "", "label selector the pod service account must match to request devices, e.g. micro.example.com/device-access=true")
+	enableDRA          = flag.Bool("enable-dra", false, "fulfill the DRA resource claims requesting the plugin device class")
+	kubeletHealthzURL  = flag.String("kubelet-healthz-url", "", "kubelet healthz endpoint detecting the kubelet version if the plugin path has no kubelet-version file")
+	podResourcesSocket = flag.String("pod-resources-socket", server.PodResourcesSocket, "kubelet pod resources API socket")
+
+	enableReflection = flag.Bool("enable-grpc-reflection", debugBuild, "enable gRPC server reflection for grpcurl debugging")
+	recoverPanics    = flag.Bool("recover-panics", false, "restart the plugin goroutines with a back-off after a recovered panic")
+	logDeviceIDs     = flag.Bool("log-device-ids", true, "include device ids in log messages, they are redacted if false")
+	allowUnsafeIDs   = flag.Bool("allow-unsafe-ids", false, "only warn about device ids kubelet may reject instead of skipping the devices")
+	devicesRegex     = flag.String("devices-regex", "", "only include devices whose filename matches the regular expression")
+	deviceLabels     = flag.String("device-label-selector", "", "only include devices whose discovered annotations match the label selector, e.g. tier=fast")
+	deviceSelectCmd  = flag.String("device-selector-command", "", "shell command run per discovered device, only devices it exits 0 for are included")
+	injectDownward   = flag.Bool("inject-downward-api", false, "add the node name as MICRO_NODE_NAME to the container env vars of the allocations")
+	seccompDir       = flag.String("seccomp-profile-dir", "", "kubelet seccomp root holding the <device-name>.json seccomp profiles annotated on the allocations, disabled if empty")
+	runtimeType      = flag.String("runtime-type", "", "container runtime of the node adapting Allocate responses: docker, containerd or cri-o")
+	grpcHealth       = flag.Bool("enable-grpc-health", true, "serve the grpc.health.v1 health service on the plugin socket")
+	attestationKey   = flag.String("attestation-key-file", "", "HMAC key file verifying the device file signatures of the .sig sidecar files")
+	requireAttest    = flag.Bool("require-attestation", false, "mark the devices without a signature file unhealthy, requires attestation-key-file")
+	webhookURL       = flag.String("allocation-webhook-url", "", "URL of the webhook approving the Allocate requests, disabled if empty")
+	webhookTimeout   = flag.Duration("allocation-webhook-timeout", 5*time.Second, "timeout of the allocation webhook review")
+	standalone       = flag.Bool("standalone", false, "run the device discovery and health checks without kubelet, allocating devices with the REST API")
+	maxIdleTime      = flag.Duration("max-idle-time", 0, "exit with code 2 if no devices are discovered within the idle time, 0 to disable")
+	notifyBuffer     = flag.Int("notify-buffer-size", 10, "size of the device change notification buffer, notifications over a full buffer are dropped")
+	usePoll          = flag.Bool("use-poll", false, "poll the kubelet socket, plugin socket and device directory instead of watching them with fsnotify")
+	socketPoll       = flag.Duration("kubelet-socket-poll-interval", 5*time.Second, "interval of polling the sockets and device directory with use-poll")
+	useUdev          = flag.Bool("use-udev", false, "discover devices from udev netlink events in addition to fsnotify")
+	udevSubsystem    = flag.String("udev-subsystem", "micro", "udev subsystem of the micro devices")
+	claimTokens      = flag.Bool("claim-tokens", false, "inject a one-time device claim token into allocated containers")
+	claimTokenTTL    = flag.Duration("claim-token-ttl", time.Hour, "expiry of the device claim tokens")
+	validateReconn   = flag.Bool("validate-on-reconnect", false, "rescan the devices when kubelet reconnects ListAndWatch")
+	deltaListWatch   = flag.Bool("delta-list-and-watch", false, "stream only the changed devices after the first ListAndWatch list, kubelet is registered with a delta proxy socket merging them into full lists")
+	maxDevices       = flag.Int("max-devices", 0, "maximum number of advertised devices, 0 for no limit")
+	resourceCount    = flag.Int("resource-count", 0, "expected number of discovered devices checked by the startup quota, 0 to skip the check")
+	lockTimeout      = flag.Duration("lock-timeout", 10*time.Second, "wait timeout for the plugin instance lock")
+	initTimeout      = flag.Duration("init-timeout", 30*time.Second, "abort startup if the initial device discovery takes longer, 0 to wait forever")
+	watchdogTimeout  = flag.Duration("watchdog-timeout", time.Minute, "re-register if kubelet does not call ListAndWatch in time, 0 to disable")
+	healthInterval   = flag.Duration("health-check-interval", 10*time.Second, "device health check interval, 0 to disable")
+	deviceCountFile  = flag.String("device-count-file", "", "file receiving the healthy device count")
+	metricsFile      = flag.String("metrics-file", "", "file receiving the metrics in Prometheus text format")
+	allocateWindow   = flag.Duration("allocate-idempotency-window", 10*time.Second, "return the cached response for identical Allocate requests within the window, 0 to disable")
+	shardCount       = flag.Int("shard-count", 1, "number of plugin sockets the devices are sharded across")
+	devicesMin       = flag.Int("devices-min", 1, "minimum number of healthy devices for the liveness probe")
+	strictQuota      = flag.Bool("strict-quota", false, "fail startup instead of warning if fewer devices are discovered than max-devices or devices-min, or not resource-count devices")
+	reserveDevices   = flag.Int("reserve-devices", 0, "number of devices reserved from allocation")
+	reserveSystem    = flag.Int("reserve-for-system", 0, "number of devices reserved for the system daemons and hidden from kubelet")
+	updateChecksum   = flag.String("update-checksum", "", "SHA-256 checksum of the binary accepted by POST /update, the endpoint is disabled if empty")
+	updateHosts      = flag.String("update-allowed-hosts", "", "comma separated hosts, with an optional port, POST /update downloads the binary from over http or https")
+	pidFile          = flag.String("pid-file", "", "write the plugin process id to the file")
+	stateDir         = flag.String("state-dir", "", "directory of the plugin state write-ahead log, each namespace keeps its state in a subdirectory, state is not persisted if empty")
+	stateCompact     = flag.Duration("state-compact-interval", 5*time.Minute, "interval of compacting the state write-ahead log to a snapshot")
+	stateFormat      = flag.String("state-format", "json", "format of the plugin state file saved on compaction and loaded on start if the write-ahead log is empty: json or gob")
+	eventLogFile     = flag.String("event-log-file", "", "JSON lines file the plugin events are appended to and replayed from on start, events are not logged if empty")
+	stateGC          = flag.Duration("state-gc-interval", 0, "interval of removing the allocations of deleted pods from the state, 0 to disable")
+	healthPolicy     = flag.String("health-policy", "file-exist", "device health policy: file-exist, file-readable, command or always-healthy")
+	healthCommand    = flag.String("health-command", "", "shell command of the command health policy, exit code 0 reports the device healthy")
+	deviceScorer     = flag.String("device-scorer", "", "preferred allocation scorer: numa, pcie, random or round-robin")
+	affinityMapFile  = flag.String("affinity-map-file", "", "JSON file listing the devices of every affinity group, preferred allocations stay within one group")
+	thermalZoneMap   = flag.String("thermal-zone-map", "", "JSON file mapping the device names to sysfs thermal zones, prefers the coolest devices, overrides device-scorer")
+	allocStrategy    = flag.String("allocation-strategy", "", "preferred allocation strategy: random, round-robin or lru, overrides device-scorer")
+	preferredCPUs    = flag.String("preferred-cpus", "", "prefer devices co-located with the CPU list, e.g. 0-3")
+
+	grpcMaxRecvMsgSize = flag.Int("grpc-max-recv-msg-size", 0, "gRPC server max receive message size in bytes, 0 for library default")
+	grpcMaxSendMsgSize = flag.Int("grpc-max-send-msg-size", 0, "gRPC server max send message size in bytes, 0 for library default")
+	grpcKeepaliveTime  = flag.Duration("grpc-keepalive-time", 0, "gRPC server keepalive ping interval, 0 for library default")
+	grpcKeepaliveTTL   = flag.Duration("grpc-keepalive-timeout", 0, "gRPC server keepalive ping timeout, 0 for library default")
+	grpcMaxStreams     = flag.Int("max-concurrent-streams", 1, "maximum number of concurrent ListAndWatch streams, 0 for no limit")
+)
+
+func main() {
+	flag.Parse()
+	showVersion()
 
-func initLogger(f logFmt) {
-	replace := func(groups []string, a slog.Attr) slog.Attr {
-		// Use short source filename
-		if a.Key == slog.SourceKey {
-			source := a.Value.Any().(*slog.Source)
-			source.File = filepath.Base(source.File)
+	// subcommands serve no plugin metrics
+	metricsAddr := *listen
+	if flag.NArg() > 0 {
+		metricsAddr = ""
+	}
+	telemetry := server.NewTelemetry()
+	err := telemetry.Setup(server.TelemetryConfig{
+		MetricsAddr:     metricsAddr,
+		TracingEndpoint: *tracingURL,
+		LogFormat:       *logFormat,
+		LogLevel:        *logLevel,
+		LogFile:         *logFile,
+		LogMaxSize:      *maxLogFileSize,
+		LogMaxBackups:   *maxLogBackups,
+		LogSampleRate:   *logSampleRate,
+		LogSampleWindow: *logSampleWindow,
+		PprofAddr:       *pprofListen,
+	})
+	if err != nil {
+		slog.Error("invalid telemetry flags", "err", err)
+		os.Exit(1)
+		return
+	}
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := telemetry.Shutdown(ctx); err != nil {
+			slog.Error("telemetry shutdown failed", "err", err)
 		}
-		return a
+	}()
+
+	if flag.Arg(0) == "aggregate" {
+		aggregate(flag.Args()[1:])
+		return
 	}
 
-	var h slog.Handler
-	opts := slog.HandlerOptions{
-		AddSource:   true,
-		ReplaceAttr: replace,
+	cfg, err := loadConfig()
+	if err != nil {
+		slog.Error("load micro device plugin config failed", "err", err)
+		os.Exit(1)
+		return
 	}
-	switch f {
-	case JSON:
-		h = slog.NewJSONHandler(os.Stdout, &opts)
-	case TEXT:
-		h = slog.NewTextHandler(os.Stdout, &opts)
+	if err := cfg.Validate(); err != nil {
+		slog.Error("invalid micro device plugin config", "err", err)
+		os.Exit(1)
+		return
+	}
+	if err := server.ValidateSocketName(*socketName); err != nil {
+		slog.Error("invalid plugin socket name", "err", err)
+		os.Exit(1)
+		return
 	}
-	slog.SetDefault(slog.New(h))
-}
 
-func main() {
-	flag.Parse()
-	showVersion()
+	if len(cfg.ResourceAliases) > 0 && !*deallocateHook && (*stateDir == "" || *stateGC <= 0) {
+		// a device stays refused under the other names until the
+		// allocation through one name is released
+		slog.Error("resource-aliases needs deallocate-hook or state-dir with state-gc-interval to release the allocations")
+		os.Exit(1)
+		return
+	}
+
+	if flag.Arg(0) == "validate" {
+		if code := validate(cfg); code != 0 {
+			os.Exit(code)
+		}
+		return
+	}
+
+	if *labelSelector != "" {
+		client, err := server.NewKubeClient(*kubeconfig)
+		if err != nil {
+			slog.Error("create kubernetes client failed", "err", err)
+			os.Exit(1)
+			return
+		}
+		if run, code := nodeSelected(client, server.NodeName(), *labelSelector); !run {
+			if code != 0 {
+				os.Exit(code)
+			}
+			return
+		}
+	}
 
 	slog.Info("staring micro device plugin ...")
-	micro := server.NewMicroDeviceServer()
-	go micro.Run()
+	if *checkVer {
+		go checkLatestVersion()
+	}
+	if *resourceFile != "" {
+		go watchResourceNameFile(*resourceFile, cfg.ResourceName)
+	}
 
-	if err := micro.RegisterToKubelet(); err != nil {
-		slog.Error("micro device plugin register failed", "err", err)
+	opts := []server.Option{
+		server.WithNamespace(*namespace),
+		server.WithSocketName(*socketName),
+		server.WithReflection(*enableReflection),
+		server.WithRESTAPI(*restAPI),
+		server.WithDeltaListAndWatch(*deltaListWatch),
+		server.WithValidateOnReconnect(*validateReconn),
+		server.WithAllowUnsafeIDs(*allowUnsafeIDs),
+		server.WithLogDeviceIDs(*logDeviceIDs),
+		server.WithRecoverPanics(*recoverPanics),
+		server.WithGRPCHealth(*grpcHealth),
+		server.WithNotifyBufferSize(*notifyBuffer),
+		server.WithStandalone(*standalone),
+		server.WithMaxIdleTime(*maxIdleTime),
+		server.WithConfig(cfg),
+		server.WithDevicePathWatchRecursive(*recursiveWatch),
+		server.WithLockTimeout(*lockTimeout),
+		server.WithInitTimeout(*initTimeout),
+		server.WithPIDFile(*pidFile),
+		server.WithReserveDevices(*reserveDevices),
+		server.WithReserveForSystem(*reserveSystem),
+		server.WithCreateDevicePath(*createDevPath),
+		server.WithDevicesMin(*devicesMin),
+		server.WithStrictQuota(*strictQuota),
+		server.WithWatchdogTimeout(*watchdogTimeout),
+		server.WithHealthInterval(*healthInterval),
+		server.WithDeviceCountFile(*deviceCountFile),
+		server.WithMetricsFile(*metricsFile),
+		server.WithAllocateIdempotencyWindow(*allocateWindow),
+		server.WithMaxConcurrentStreams(*grpcMaxStreams),
+		server.WithGRPCOptions(server.GRPCServerOptions(
+			*grpcMaxRecvMsgSize, *grpcMaxSendMsgSize,
+			*grpcKeepaliveTime, *grpcKeepaliveTTL,
+		)...),
+	}
+	if *devicesRegex != "" {
+		re, err := regexp.Compile(*devicesRegex)
+		if err != nil {
+			slog.Error("invalid devices regex", "regex", *devicesRegex, "err", err)
+			os.Exit(1)
+			return
+		}
+		opts = append(opts, server.WithDevicesRegex(re))
+	}
+	if *startupFile != "" || *startupURL != "" {
+		opts = append(opts, server.WithStartupProbe(&server.StartupProbe{
+			File:    *startupFile,
+			URL:     *startupURL,
+			Timeout: *startupWait,
+		}))
+	}
+	var selectors server.ChainSelector
+	if *deviceLabels != "" {
+		sel, err := server.NewLabelSelector(*deviceLabels)
+		if err != nil {
+			slog.Error("invalid device label selector", "err", err)
+			os.Exit(1)
+			return
+		}
+		selectors = append(selectors, sel)
+	}
+	if *deviceSelectCmd != "" {
+		selectors = append(selectors, server.ScriptSelector{Command: *deviceSelectCmd, Timeout: 10 * time.Second})
+	}
+	if len(selectors) > 0 {
+		opts = append(opts, server.WithDeviceSelector(selectors))
+	}
+	if *preferredCPUs != "" {
+		cpus, err := server.ParseCPUList(*preferredCPUs)
+		if err != nil {
+			slog.Error("invalid preferred cpus", "cpus", *preferredCPUs, "err", err)
+			os.Exit(1)
+			return
+		}
+		opts = append(opts, server.WithCPUAffinity(server.SysfsCPUAffinity{}, cpus))
+	}
+	if *deviceScorer != "" {
+		scorer, err := server.NewScorer(*deviceScorer)
+		if err != nil {
+			slog.Error("invalid device scorer", "err", err)
+			os.Exit(1)
+			return
+		}
+		opts = append(opts, server.WithScorer(scorer))
+	}
+	if *affinityMapFile != "" {
+		groups, err := server.LoadAffinityMap(*affinityMapFile)
+		if err != nil {
+			slog.Error("load affinity map failed", "err", err)
+			os.Exit(1)
+			return
+		}
+		opts = append(opts, server.WithAffinityMap(groups))
+	}
+	if *thermalZoneMap != "" {
+		zones, err := server.LoadThermalZoneMap(*thermalZoneMap)
+		if err != nil {
+			slog.Error("load thermal zone map failed", "err", err)
+			os.Exit(1)
+			return
+		}
+		opts = append(opts, server.WithScorer(server.NewThermalScorer(zones)))
+	}
+	if *versionCheck || *strictVersion {
+		opts = append(opts, server.WithVersionCheck(*strictVersion))
+	}
+	if *registerJitter < 0 {
+		slog.Error("register-jitter-max-ms must not be negative", "value", *registerJitter)
 		os.Exit(1)
 		return
 	}
-	slog.Error("micro device plugin register successfully")
-
-	sock := filepath.Join(server.PluginPath, server.KubeSocket)
-	slog.Info("device plugin socket", "name", sock)
-	w, err := fsnotify.NewWatcher()
+	if *socketBacklog <= 0 {
+		slog.Error("socket-backlog must be positive", "value", *socketBacklog)
+		os.Exit(1)
+		return
+	}
+	opts = append(opts, server.WithSocketBacklog(*socketBacklog))
+	if *priorityQueue > 0 {
+		opts = append(opts, server.WithPriorityAllocator(*priorityQueue))
+	}
+	opts = append(opts, server.WithRegisterJitter(time.Duration(*registerJitter)*time.Millisecond))
+	if *devicePipe != "" {
+		if *shardCount > 1 {
+			slog.Error("device-pipe does not support more than one shard", "shards", *shardCount)
+			os.Exit(1)
+			return
+		}
+		opts = append(opts, server.WithDiscoverer(discovery.NewPipeDiscoverer(*devicePipe)))
+	}
+	if *staticDevices != "" {
+		opts = append(opts, server.WithStaticDevicesFile(*staticDevices))
+	}
+	if *eventLogFile != "" {
+		store, err := state.OpenEventStore(*eventLogFile)
+		if err != nil {
+			slog.Error("open event log failed", "path", *eventLogFile, "err", err)
+			os.Exit(1)
+			return
+		}
+		defer store.Close()
+		opts = append(opts, server.WithEventStore(store))
+	}
+	if *stateDir != "" {
+		dir := server.NamespacedStateDir(*stateDir, *namespace)
+		if err := os.MkdirAll(dir, 0700); err != nil {
+			slog.Error("create plugin state directory failed", "dir", dir, "err", err)
+			os.Exit(1)
+			return
+		}
+		wal, err := state.OpenWAL(dir)
+		if err != nil {
+			slog.Error("open plugin state failed", "dir", dir, "err", err)
+			os.Exit(1)
+			return
+		}
+		defer wal.Close()
+		opts = append(opts, server.WithStateWAL(wal, *stateCompact))
+		store, err := state.NewStateStore(*stateFormat, dir)
+		if err != nil {
+			slog.Error("invalid plugin state format", "err", err)
+			os.Exit(1)
+			return
+		}
+		opts = append(opts, server.WithStateStore(store))
+		if *stateGC > 0 {
+			client, err := server.NewKubeClient(*kubeconfig)
+			if err != nil {
+				slog.Error("create kubernetes client failed", "err", err)
+				os.Exit(1)
+				return
+			}
+			opts = append(opts, server.WithStateGC(client, *stateGC))
+		}
+	}
+	policy, err := server.NewHealthPolicy(*healthPolicy, *healthCommand)
 	if err != nil {
-		slog.Error("create fsnotify watcher failed", "err", err)
+		slog.Error("invalid health policy", "err", err)
 		os.Exit(1)
 		return
 	}
-	defer w.Close()
+	opts = append(opts, server.WithHealthPolicy(policy))
+	if *allocStrategy != "" {
+		strategy, err := server.NewAllocationStrategy(*allocStrategy)
+		if err != nil {
+			slog.Error("invalid allocation strategy", "err", err)
+			os.Exit(1)
+			return
+		}
+		opts = append(opts, server.WithAllocationStrategy(strategy))
+	}
+	if *claimTokens {
+		opts = append(opts, server.WithClaimTokens(*claimTokenTTL))
+	}
+	if *leaseName != "" {
+		client, err := server.NewKubeClient(*kubeconfig)
+		if err != nil {
+			slog.Error("create kubernetes client failed", "err", err)
+			os.Exit(1)
+			return
+		}
+		opts = append(opts, server.WithLeaseHeartbeat(server.NewLeaseHeartbeat(
+			client, *leaseNamespace, *leaseName, server.NodeName(), *leaseRenewInterval,
+		)))
+	}
+	if *nodeCondition {
+		client, err := server.NewKubeClient(*kubeconfig)
+		if err != nil {
+			slog.Error("create kubernetes client failed", "err", err)
+			os.Exit(1)
+			return
+		}
+		reporter := server.NewNodeConditionReporter(client, server.NodeName(), *conditionType)
+		opts = append(opts, server.WithNodeConditions(reporter))
+	}
+	if *nodeLabelPrefix != "" {
+		client, err := server.NewKubeClient(*kubeconfig)
+		if err != nil {
+			slog.Error("create kubernetes client failed", "err", err)
+			os.Exit(1)
+			return
+		}
+		manager := server.NewNodeLabelManager(client, server.NodeName(), *nodeLabelPrefix, *labelReconcile)
+		opts = append(opts, server.WithNodeLabels(manager))
+	}
+	if *deallocateHook {
+		client, err := server.NewKubeClient(*kubeconfig)
+		if err != nil {
+			slog.Error("create kubernetes client failed", "err", err)
+			os.Exit(1)
+			return
+		}
+		lookup := server.PodResourcesLookup{Socket: *podResourcesSocket, Timeout: 10 * time.Second}
+		opts = append(opts, server.WithDeallocateHook(client, server.NodeName(), lookup))
+	}
+	if *kubeletHealthzURL != "" {
+		detector := server.NewKubeletVersionDetector(cfg.PluginPath, *kubeletHealthzURL)
+		opts = append(opts, server.WithKubeletVersionDetector(detector))
+	}
+	if *enableDRA {
+		client, err := server.NewKubeClient(*kubeconfig)
+		if err != nil {
+			slog.Error("create kubernetes client failed", "err", err)
+			os.Exit(1)
+			return
+		}
+		opts = append(opts, server.WithDRA(client, server.NodeName()))
+	}
+	if *admissionMode {
+		client, err := server.NewKubeClient(*kubeconfig)
+		if err != nil {
+			slog.Error("create kubernetes client failed", "err", err)
+			os.Exit(1)
+			return
+		}
+		selector, err := server.ParseServiceAccountSelector(*requiredSALabel)
+		if err != nil {
+			slog.Error("invalid required service account label", "err", err)
+			os.Exit(1)
+			return
+		}
+		admission := server.NewAdmissionController(client, *admissionListen, cfg.AdmissionTLSCertFile, cfg.AdmissionTLSKeyFile)
+		admission.ServiceAccountSelector = selector
+		if *allowedNamespaces != "" {
+			admission.AllowedNamespaces = strings.Split(*allowedNamespaces, ",")
+		}
+		opts = append(opts, server.WithAdmission(admission))
+	}
+	if *useUdev {
+		opts = append(opts, server.WithUdev(*udevSubsystem))
+	}
+	if *usePoll {
+		opts = append(opts, server.WithPollInterval(*socketPoll))
+	}
+	if *injectDownward {
+		opts = append(opts, server.WithDownwardAPIInjector(server.NewDownwardAPIInjector("")))
+	}
+	if *seccompDir != "" {
+		opts = append(opts, server.WithSeccompProfileManager(server.NewSeccompProfileManager(*seccompDir)))
+	}
+	if *runtimeType != "" {
+		adapter, err := server.NewRuntimeAdapter(*runtimeType, cfg.DevicePath)
+		if err != nil {
+			slog.Error("invalid runtime type", "err", err)
+			os.Exit(1)
+			return
+		}
+		opts = append(opts, server.WithRuntimeAdapter(adapter))
+	}
+	if *webhookURL != "" {
+		opts = append(opts, server.WithAllocationWebhook(server.NewAllocationWebhook(*webhookURL, *webhookTimeout)))
+	}
+	if *requireAttest && cfg.AttestationKeyFile == "" {
+		slog.Error("require-attestation needs an attestation-key-file")
+		os.Exit(1)
+		return
+	}
+	if cfg.AttestationKeyFile != "" {
+		key, err := server.LoadAttestationKey(cfg.AttestationKeyFile)
+		if err != nil {
+			slog.Error("load attestation key failed", "err", err)
+			os.Exit(1)
+			return
+		}
+		opts = append(opts, server.WithAttestor(server.NewHMACFileAttestor(key, *requireAttest)))
+	}
+	var updater *server.Updater
+	if *updateChecksum != "" {
+		updater = server.NewUpdater(*updateChecksum)
+		if *updateHosts != "" {
+			updater.AllowedHosts = strings.Split(*updateHosts, ",")
+		}
+		opts = append(opts, server.WithUpdater(updater))
+	}
+	micro, err := server.NewPluginManager(*shardCount, opts...)
+	if err != nil {
+		slog.Error("micro device plugin create failed", "err", err)
+		os.Exit(1)
+		return
+	}
+	if updater != nil {
+		updater.BeforeExec = micro.Stop
+	}
+	if err := micro.Run(); err != nil {
+		slog.Error("micro device plugin run failed", "err", err)
+		os.Exit(1)
+		return
+	}
+	defer micro.Stop()
+
+	telemetry.SetHandler(micro.Handler())
+	idle := micro.Idle()
+
+	if *standalone {
+		sig := make(chan os.Signal, 1)
+		signal.Notify(sig, syscall.SIGINT, syscall.SIGTERM)
+		slog.Info("micro device plugin running standalone without kubelet")
+		select {
+		case s := <-sig:
+			slog.Info("received signal, shutting down", "signal", s.String())
+		case <-idle:
+			exitIdle(micro)
+		}
+		return
+	}
 
-	if err := w.Add(server.PluginPath); err != nil {
-		slog.Error("watch kublet failed", "err", err)
+	if err := micro.RegisterToKubelet(); err != nil {
+		slog.Error("micro device plugin register failed", "err", err)
+		os.Exit(1)
 		return
 	}
+	slog.Error("micro device plugin register successfully")
+
+	sock := filepath.Join(cfg.PluginPath, server.KubeSocket)
+	slog.Info("device plugin socket", "name", sock)
+	var (
+		events  <-chan fsnotify.Event
+		errs    <-chan error
+		created <-chan struct{}
+	)
+	if *usePoll {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		created = server.PollSocket(ctx, sock, *socketPoll)
+	} else {
+		w, err := fsnotify.NewWatcher()
+		if err != nil {
+			slog.Error("create fsnotify watcher failed", "err", err)
+			os.Exit(1)
+			return
+		}
+		defer w.Close()
+
+		if err := w.Add(cfg.PluginPath); err != nil {
+			slog.Error("watch kublet failed", "err", err)
+			return
+		}
+		events, errs = w.Events, w.Errors
+	}
+
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, syscall.SIGINT, syscall.SIGTERM)
 
 	slog.Info("watching kubelet.sock ...")
 	for {
 		select {
-		case event := <-w.Events:
+		case s := <-sig:
+			slog.Info("received signal, shutting down", "signal", s.String())
+			return
+		case <-idle:
+			exitIdle(micro)
+		case event := <-events:
 			if event.Name == sock && event.Op&fsnotify.Create == fsnotify.Create {
 				time.Sleep(time.Second)
 				slog.Error("[fsnotify] socket file created kubelet may restarting", "name", sock)
 			}
-		case err := <-w.Errors:
+		case <-created:
+			time.Sleep(time.Second)
+			slog.Error("[poll] socket file created kubelet may restarting", "name", sock)
+		case err := <-errs:
 			slog.Error("fsnotify", "err", err)
 		}
 	}
 }
 
+// exitIdle stops the plugin and exits with code 2 on nodes without
+// devices
+func exitIdle(micro server.DevicePlugin) {
+	slog.Error("no micro devices discovered, exiting", "maxIdleTime", *maxIdleTime)
+	micro.Stop()
+	os.Exit(2)
+}
+
+// loadConfig reads the config file if given, the explicitly set flags
+// take precedence over the file values
+func loadConfig() (*config.Config, error) {
+	cfg := config.Default()
+	if *configFile != "" {
+		var err error
+		if cfg, err = config.LoadFile(*configFile); err != nil {
+			return nil, err
+		}
+	}
+
+	var err error
+	flag.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "resource-name":
+			cfg.ResourceName = *resourceName
+		case "device-path":
+			cfg.DevicePath = *devicePath
+		case "plugin-path":
+			cfg.PluginPath = *pluginPath
+		case "max-devices":
+			cfg.MaxDevices = *maxDevices
+		case "resource-count":
+			cfg.ResourceCount = *resourceCount
+		case "arch-device-paths":
+			cfg.ArchDevicePaths, err = config.ParseArchDevicePaths(*archDevicePaths)
+		case "feature-gates":
+			cfg.FeatureGates, err = config.ParseFeatureGates(*featureGates)
+		case "attestation-key-file":
+			cfg.AttestationKeyFile = *attestationKey
+		case "admission-tls-cert-file":
+			cfg.AdmissionTLSCertFile = *admissionCert
+		case "admission-tls-key-file":
+			cfg.AdmissionTLSKeyFile = *admissionKey
+		case "resource-aliases":
+			cfg.ResourceAliases = config.ParseResourceAliases(*resourceAlias)
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if *resourceFile != "" {
+		if cfg.ResourceName, err = config.ReadResourceNameFile(*resourceFile); err != nil {
+			return nil, err
+		}
+	}
+	return cfg, nil
+}
+
+// nodeSelected evaluates the label selector against the node, the plugin
+// exits with the returned code if the node is not selected
+func nodeSelected(client kubernetes.Interface, nodeName, selector string) (bool, int) {
+	matched, err := server.NodeMatchesSelector(context.Background(), client, nodeName, selector)
+	if err != nil {
+		slog.Error("evaluate node label selector failed", "selector", selector, "err", err)
+		return false, 1
+	}
+	if !matched {
+		slog.Info("node does not match label selector, no devices will be registered", "selector", selector)
+		return false, 0
+	}
+	return true, 0
+}
+
+// validate checks the kubelet connection without starting the plugin,
+// it returns the exit code, 2 if kubelet cannot be reached
+func validate(cfg *config.Config) int {
+	micro, err := server.NewMicroDeviceServer(server.WithConfig(cfg))
+	if err != nil {
+		slog.Error("micro device plugin create failed", "err", err)
+		return 2
+	}
+	defer micro.Stop()
+	if err := micro.ValidateKubelet(); err != nil {
+		slog.Error("micro device plugin validate failed", "err", err)
+		return 2
+	}
+	slog.Info("micro device plugin validate successfully")
+	return 0
+}
+
+// checkLatestVersion warns if a newer plugin release is available
+func checkLatestVersion() {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	latest, upToDate, err := version.NewVersionChecker().CheckLatest(ctx)
+	if err != nil {
+		slog.Warn("check latest plugin version failed", "err", err)
+		return
+	}
+	if !upToDate {
+		slog.Warn("newer plugin version available", "current", version.Version, "latest", latest)
+	}
+}
+
 func showVersion() {
 	if *v || *ver {
 		fmt.Println(version.String())
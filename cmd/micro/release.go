@@ -0,0 +1,7 @@
+//go:build !debug
+
+package main
+
+// debugBuild is false in release builds, the debugging features such as
+// gRPC reflection are off unless enabled by their flags
+const debugBuild = false
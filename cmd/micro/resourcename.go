@@ -0,0 +1,55 @@
+package main
+
+import (
+	"log/slog"
+	"path/filepath"
+
+	"github.com/fsnotify/fsnotify"
+
+	"github.com/kelein/micro-device-plugin/pkg/config"
+)
+
+// watchResourceNameFile warns when the resource name file changes, the
+// plugin is registered once so the new name needs a restart
+func watchResourceNameFile(path, current string) {
+	w, err := fsnotify.NewWatcher()
+	if err != nil {
+		slog.Error("create resource name file watcher failed", "err", err)
+		return
+	}
+	defer w.Close()
+
+	// watch the directory since editors and config maps replace the file
+	if err := w.Add(filepath.Dir(path)); err != nil {
+		slog.Error("watch resource name file failed", "path", path, "err", err)
+		return
+	}
+
+	path = filepath.Clean(path)
+	for {
+		select {
+		case event, ok := <-w.Events:
+			if !ok {
+				return
+			}
+			if filepath.Clean(event.Name) != path || event.Op == fsnotify.Chmod {
+				continue
+			}
+			name, err := config.ReadResourceNameFile(path)
+			if err != nil {
+				slog.Warn("resource name file changed and is invalid", "path", path, "err", err)
+				continue
+			}
+			if name != current {
+				slog.Warn("resource name file changed, restart the plugin to apply the new resource name",
+					"path", path, "current", current, "new", name)
+			}
+
+		case err, ok := <-w.Errors:
+			if !ok {
+				return
+			}
+			slog.Error("resource name file watcher", "err", err)
+		}
+	}
+}
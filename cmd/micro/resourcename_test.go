@@ -0,0 +1,52 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/kelein/micro-device-plugin/pkg/server"
+	"github.com/kelein/micro-device-plugin/pkg/testutil"
+)
+
+func TestResourceNameFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "resource-name")
+	if err := os.WriteFile(path, []byte(" example.com/micro \n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	*resourceFile = path
+	t.Cleanup(func() { *resourceFile = "" })
+
+	cfg, err := loadConfig()
+	if err != nil {
+		t.Fatalf("loadConfig() = %v", err)
+	}
+	if cfg.ResourceName != "example.com/micro" {
+		t.Fatalf("resource name = %q, want example.com/micro", cfg.ResourceName)
+	}
+
+	dir := t.TempDir()
+	cfg.PluginPath = dir
+	cfg.DevicePath = t.TempDir()
+	kubelet, err := testutil.NewFakeKubelet(dir)
+	if err != nil {
+		t.Fatalf("start fake kubelet: %v", err)
+	}
+	t.Cleanup(kubelet.Stop)
+
+	s, err := server.NewMicroDeviceServer(server.WithConfig(cfg), server.WithWatchdogTimeout(0))
+	if err != nil {
+		t.Fatalf("NewMicroDeviceServer() error = %v", err)
+	}
+	t.Cleanup(s.Stop)
+	if err := s.RegisterToKubelet(); err != nil {
+		t.Fatalf("RegisterToKubelet() = %v", err)
+	}
+	reqs := kubelet.Requests()
+	if len(reqs) != 1 {
+		t.Fatalf("kubelet received %d register requests, want 1", len(reqs))
+	}
+	if reqs[0].ResourceName != "example.com/micro" {
+		t.Errorf("registered resource name = %q, want example.com/micro", reqs[0].ResourceName)
+	}
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	deviceapi "k8s.io/kubelet/pkg/apis/deviceplugin/v1beta1"
+
+	"github.com/kelein/micro-device-plugin/pkg/config"
+	"github.com/kelein/micro-device-plugin/pkg/testutil"
+)
+
+func TestValidate(t *testing.T) {
+	dir := t.TempDir()
+	kubelet, err := testutil.NewFakeKubelet(dir)
+	if err != nil {
+		t.Fatalf("start fake kubelet: %v", err)
+	}
+	t.Cleanup(kubelet.Stop)
+	kubelet.SetSupportedVersions(deviceapi.Version)
+
+	cfg := config.Default()
+	cfg.PluginPath = dir
+	cfg.DevicePath = t.TempDir()
+	if code := validate(cfg); code != 0 {
+		t.Fatalf("validate() = %d, want 0", code)
+	}
+
+	// kubelet only saw the sentinel, the plugin was not started
+	reqs := kubelet.Requests()
+	if len(reqs) != 1 || reqs[0].Version != "validate" || reqs[0].ResourceName != cfg.ResourceName {
+		t.Errorf("kubelet requests = %v, want one validate sentinel for %s", reqs, cfg.ResourceName)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "micro.sock")); !os.IsNotExist(err) {
+		t.Errorf("plugin socket stat = %v, want not created", err)
+	}
+}
+
+func TestValidateUnreachable(t *testing.T) {
+	cfg := config.Default()
+	cfg.PluginPath = t.TempDir()
+	cfg.DevicePath = t.TempDir()
+	if code := validate(cfg); code != 2 {
+		t.Errorf("validate() without kubelet = %d, want 2", code)
+	}
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+
+	"github.com/kelein/micro-device-plugin/pkg/state"
+)
+
+var format = flag.String("format", state.FormatGob, "format of the written state file: gob or json")
+
+func init() {
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "usage: %s [flags] state.json state.gob\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+}
+
+// migrate-state converts a JSON plugin state file of any schema version
+// to the gob state format
+func main() {
+	flag.Parse()
+	if flag.NArg() != 2 {
+		flag.Usage()
+		os.Exit(2)
+		return
+	}
+
+	in := &state.JSONStateStore{Path: flag.Arg(0)}
+	ps, err := in.Load()
+	if err != nil {
+		slog.Error("load state file failed", "path", in.Path, "err", err)
+		os.Exit(1)
+		return
+	}
+
+	var out state.StateStore
+	switch *format {
+	case state.FormatGob:
+		out = &state.GobStateStore{Path: flag.Arg(1)}
+	case state.FormatJSON:
+		out = &state.JSONStateStore{Path: flag.Arg(1)}
+	default:
+		slog.Error("unknown state format", "format", *format)
+		os.Exit(2)
+		return
+	}
+	if err := out.Save(ps); err != nil {
+		slog.Error("write state file failed", "path", flag.Arg(1), "err", err)
+		os.Exit(1)
+		return
+	}
+	slog.Info("state file migrated", "from", in.Path, "to", flag.Arg(1), "format", *format, "devices", len(ps.DeviceIDs))
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/exec"
+	"syscall"
+	"time"
+
+	"github.com/kelein/micro-device-plugin/pkg/testutil"
+)
+
+var (
+	plugin  = flag.String("plugin", "bin/micro", "micro device plugin binary replayed against")
+	verbose = flag.Bool("verbose", false, "show the plugin output")
+)
+
+func init() {
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "usage: %s [flags] scenario.yaml...\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+}
+
+func main() {
+	flag.Parse()
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+		return
+	}
+
+	failed := false
+	for _, path := range flag.Args() {
+		sc, err := testutil.LoadScenario(path)
+		if err != nil {
+			slog.Error("load scenario failed", "err", err)
+			os.Exit(2)
+			return
+		}
+		if err := replay(sc); err != nil {
+			slog.Error("scenario failed", "name", sc.Name, "err", err)
+			failed = true
+			continue
+		}
+		slog.Info("scenario passed", "name", sc.Name)
+	}
+	if failed {
+		os.Exit(1)
+	}
+}
+
+// replay runs the plugin binary in temporary plugin and device
+// directories and replays the scenario against it
+func replay(sc *testutil.Scenario) error {
+	pluginPath, err := os.MkdirTemp("", "replay-plugin-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(pluginPath)
+	devicePath, err := os.MkdirTemp("", "replay-device-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(devicePath)
+
+	cmd := exec.Command(*plugin,
+		"--plugin-path", pluginPath,
+		"--device-path", devicePath,
+		"--listen", "127.0.0.1:0",
+	)
+	cmd.Stdout, cmd.Stderr = io.Discard, io.Discard
+	if *verbose {
+		cmd.Stdout, cmd.Stderr = os.Stderr, os.Stderr
+	}
+	defer func() {
+		if cmd.Process == nil {
+			return
+		}
+		cmd.Process.Signal(syscall.SIGTERM)
+		done := make(chan struct{})
+		go func() {
+			cmd.Wait()
+			close(done)
+		}()
+		select {
+		case <-done:
+		case <-time.After(5 * time.Second):
+			cmd.Process.Kill()
+		}
+	}()
+
+	r := testutil.NewReplayer(pluginPath, devicePath)
+	return r.Replay(context.Background(), sc, cmd.Start)
+}
@@ -0,0 +1,186 @@
+package aggregator
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
+	"google.golang.org/protobuf/proto"
+)
+
+// NodeLabel is the label identifying the node of the aggregated metrics
+const NodeLabel = "node"
+
+// Aggregator merges the metrics of the plugins of several nodes into a
+// cluster-wide view, each metric gets a node label with its node address
+type Aggregator struct {
+	// Nodes are the node metrics endpoints, host:port addresses are
+	// scraped at http://host:port/metrics
+	Nodes []string
+
+	// Retries is the number of retries of a failed node query
+	Retries int
+
+	// RetryInterval is the delay between the retries of a node query
+	RetryInterval time.Duration
+
+	client       *http.Client
+	registry     *prometheus.Registry
+	scrapeErrors *prometheus.CounterVec
+}
+
+// NewAggregator creates an aggregator of the node metrics endpoints
+func NewAggregator(nodes []string) *Aggregator {
+	a := &Aggregator{
+		Nodes:         nodes,
+		Retries:       2,
+		RetryInterval: time.Second,
+		client:        &http.Client{Timeout: 10 * time.Second},
+		registry:      prometheus.NewRegistry(),
+		scrapeErrors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "micro",
+			Subsystem: "aggregator",
+			Name:      "scrape_errors_total",
+			Help:      "Total number of failed node metrics queries",
+		}, []string{NodeLabel}),
+	}
+	a.registry.MustRegister(a.scrapeErrors)
+	return a
+}
+
+// Handler serves the aggregated node metrics and the aggregator metrics
+func (a *Aggregator) Handler() http.Handler {
+	return promhttp.HandlerFor(prometheus.Gatherers{a, a.registry}, promhttp.HandlerOpts{})
+}
+
+// Gather queries the metrics of all nodes and merges the families, a
+// node failing all retries is left out of the result
+func (a *Aggregator) Gather() ([]*dto.MetricFamily, error) {
+	results := make([]map[string]*dto.MetricFamily, len(a.Nodes))
+	var wg sync.WaitGroup
+	for i, node := range a.Nodes {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			families, err := a.scrapeRetry(context.Background(), node)
+			if err != nil {
+				a.scrapeErrors.WithLabelValues(node).Inc()
+				slog.Error("scrape node metrics failed", "node", node, "err", err)
+				return
+			}
+			results[i] = families
+		}()
+	}
+	wg.Wait()
+
+	merged := make(map[string]*dto.MetricFamily)
+	for i, families := range results {
+		for name, family := range families {
+			m, ok := merged[name]
+			if !ok {
+				m = &dto.MetricFamily{Name: family.Name, Help: family.Help, Type: family.Type}
+				merged[name] = m
+			}
+			if m.GetType() != family.GetType() {
+				slog.Warn("skip metric family of conflicting type", "name", name, "node", a.Nodes[i])
+				continue
+			}
+			for _, metric := range family.Metric {
+				m.Metric = append(m.Metric, withNode(metric, a.Nodes[i]))
+			}
+		}
+	}
+
+	names := make([]string, 0, len(merged))
+	for name := range merged {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	out := make([]*dto.MetricFamily, 0, len(names))
+	for _, name := range names {
+		family := merged[name]
+		sort.SliceStable(family.Metric, func(i, j int) bool {
+			return labelString(family.Metric[i]) < labelString(family.Metric[j])
+		})
+		out = append(out, family)
+	}
+	return out, nil
+}
+
+// scrapeRetry scrapes the node, retrying failed queries
+func (a *Aggregator) scrapeRetry(ctx context.Context, node string) (map[string]*dto.MetricFamily, error) {
+	var err error
+	for attempt := 0; attempt <= a.Retries; attempt++ {
+		if attempt > 0 {
+			time.Sleep(a.RetryInterval)
+		}
+		var families map[string]*dto.MetricFamily
+		if families, err = a.scrape(ctx, node); err == nil {
+			return families, nil
+		}
+		slog.Warn("query node metrics failed", "node", node, "attempt", attempt+1, "err", err)
+	}
+	return nil, err
+}
+
+// scrape queries and parses the metrics of the node
+func (a *Aggregator) scrape(ctx context.Context, node string) (map[string]*dto.MetricFamily, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, metricsURL(node), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", string(expfmt.NewFormat(expfmt.TypeTextPlain)))
+	resp, err := a.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+
+	var parser expfmt.TextParser
+	return parser.TextToMetricFamilies(resp.Body)
+}
+
+// metricsURL returns the metrics endpoint URL of the node address
+func metricsURL(node string) string {
+	if strings.Contains(node, "://") {
+		return node
+	}
+	return "http://" + node + "/metrics"
+}
+
+// withNode returns a copy of the metric labeled with the node, replacing
+// an existing node label
+func withNode(metric *dto.Metric, node string) *dto.Metric {
+	m := proto.Clone(metric).(*dto.Metric)
+	labels := make([]*dto.LabelPair, 0, len(m.Label)+1)
+	for _, label := range m.Label {
+		if label.GetName() != NodeLabel {
+			labels = append(labels, label)
+		}
+	}
+	labels = append(labels, &dto.LabelPair{Name: proto.String(NodeLabel), Value: proto.String(node)})
+	sort.Slice(labels, func(i, j int) bool { return labels[i].GetName() < labels[j].GetName() })
+	m.Label = labels
+	return m
+}
+
+// labelString joins the label values of the metric in label name order
+func labelString(metric *dto.Metric) string {
+	values := make([]string, 0, len(metric.Label))
+	for _, label := range metric.Label {
+		values = append(values, label.GetValue())
+	}
+	return strings.Join(values, "\xff")
+}
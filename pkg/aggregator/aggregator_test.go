@@ -0,0 +1,67 @@
+package aggregator
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+
+	"github.com/kelein/micro-device-plugin/pkg/testing/assert"
+)
+
+func nodeStub(t *testing.T, devices int) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `# HELP micro_device_plugin_devices Number of devices
+# TYPE micro_device_plugin_devices gauge
+micro_device_plugin_devices{health="Healthy"} %d
+`, devices)
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestAggregatorGather(t *testing.T) {
+	node1 := nodeStub(t, 2)
+	node2 := nodeStub(t, 3)
+	down := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+	}))
+	t.Cleanup(down.Close)
+
+	addr1 := strings.TrimPrefix(node1.URL, "http://")
+	addr2 := strings.TrimPrefix(node2.URL, "http://")
+	addrDown := strings.TrimPrefix(down.URL, "http://")
+	agg := NewAggregator([]string{addr1, addr2, addrDown})
+	agg.RetryInterval = 0
+
+	samples := []string{
+		fmt.Sprintf(`micro_device_plugin_devices{health="Healthy",node=%q} 2`, addr1),
+		fmt.Sprintf(`micro_device_plugin_devices{health="Healthy",node=%q} 3`, addr2),
+	}
+	slices.Sort(samples)
+	want := `# HELP micro_device_plugin_devices Number of devices
+# TYPE micro_device_plugin_devices gauge
+` + strings.Join(samples, "\n") + "\n"
+	if err := testutil.GatherAndCompare(agg, strings.NewReader(want), "micro_device_plugin_devices"); err != nil {
+		t.Error(err)
+	}
+
+	assert.AssertMetricValue(t, agg.registry, "micro_aggregator_scrape_errors_total", map[string]string{NodeLabel: addrDown}, 1)
+	if got := testutil.ToFloat64(agg.scrapeErrors.WithLabelValues(addr1)); got != 0 {
+		t.Errorf("scrape errors of %s = %v, want 0", addr1, got)
+	}
+
+	rec := httptest.NewRecorder()
+	agg.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	body := rec.Body.String()
+	for _, s := range []string{`node="` + addr2 + `"`, "micro_aggregator_scrape_errors_total"} {
+		if !strings.Contains(body, s) {
+			t.Errorf("aggregated metrics do not contain %s", s)
+		}
+	}
+}
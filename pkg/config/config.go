@@ -0,0 +1,229 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"sigs.k8s.io/yaml"
+)
+
+// Default configuration values
+const (
+	DefaultResourceName = "micro.plugin"
+	DefaultDevicePath   = "/etc/micro"
+	DefaultPluginPath   = "/var/lib/kubelet/device-plugins/"
+	DefaultAPIVersion   = "v1beta1"
+)
+
+// Config is the micro device plugin configuration
+type Config struct {
+	// ResourceName is the extended resource name advertised to kubelet
+	ResourceName string `json:"resourceName"`
+
+	// DevicePath is the directory of the micro device files
+	DevicePath string `json:"devicePath"`
+
+	// PluginPath is the kubelet device plugin directory
+	PluginPath string `json:"pluginPath"`
+
+	// MaxDevices limits the number of advertised devices, 0 for no limit
+	MaxDevices int `json:"maxDevices,omitempty"`
+
+	// ResourceCount is the number of devices the node is expected to
+	// discover, the startup quota check compares it with the discovered
+	// devices, 0 to skip the check
+	ResourceCount int `json:"resourceCount,omitempty"`
+
+	// ArchDevicePaths overrides DevicePath per node architecture
+	ArchDevicePaths map[string]string `json:"archDevicePaths,omitempty"`
+
+	// FeatureGates enables or disables features per deployment
+	FeatureGates FeatureGates `json:"featureGates,omitempty"`
+
+	// AttestationKeyFile is the HMAC key file verifying the device file
+	// signatures, attestation is disabled if empty
+	AttestationKeyFile string `json:"attestationKeyFile,omitempty"`
+
+	// AdmissionTLSCertFile is the TLS certificate file of the admission
+	// webhook
+	AdmissionTLSCertFile string `json:"admissionTLSCertFile,omitempty"`
+
+	// AdmissionTLSKeyFile is the TLS key file of the admission webhook
+	AdmissionTLSKeyFile string `json:"admissionTLSKeyFile,omitempty"`
+
+	// ResourceAliases are additional resource names advertising the same
+	// devices, e.g. the legacy name during a resource rename
+	ResourceAliases []string `json:"resourceAliases,omitempty"`
+
+	// SupportedVersions are the device plugin API versions the kubelet
+	// version check accepts
+	SupportedVersions []string `json:"supportedVersions,omitempty"`
+}
+
+// Redacted replaces the sensitive values in MarshalSafeJSON
+const Redacted = "REDACTED"
+
+// Default returns the default configuration
+func Default() *Config {
+	return &Config{
+		ResourceName: DefaultResourceName,
+		DevicePath:   DefaultDevicePath,
+		PluginPath:   DefaultPluginPath,
+
+		SupportedVersions: []string{DefaultAPIVersion},
+	}
+}
+
+// LoadFile reads a YAML or JSON configuration file, the fields missing
+// from the file keep their default values
+func LoadFile(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	cfg := Default()
+	if err := yaml.UnmarshalStrict(data, cfg); err != nil {
+		return nil, fmt.Errorf("parse config file %s: %w", path, err)
+	}
+	return cfg, nil
+}
+
+// MarshalSafeJSON encodes the configuration as JSON with the key file
+// paths redacted, for introspection endpoints and logs
+func (c *Config) MarshalSafeJSON() ([]byte, error) {
+	safe := *c
+	for _, field := range []*string{&safe.AttestationKeyFile, &safe.AdmissionTLSKeyFile} {
+		if *field != "" {
+			*field = Redacted
+		}
+	}
+	return json.Marshal(safe)
+}
+
+// IsEnabled reports whether the feature gate is enabled
+func (c *Config) IsEnabled(gate string) bool {
+	return c.FeatureGates.IsEnabled(gate)
+}
+
+// Validate checks the configuration values
+func (c *Config) Validate() error {
+	if c.ResourceName == "" {
+		return errors.New("resource name is required")
+	}
+	if err := ValidateResourceName(c.ResourceName); err != nil {
+		return err
+	}
+	if c.DevicePath == "" {
+		return errors.New("device path is required")
+	}
+	if c.PluginPath == "" {
+		return errors.New("plugin path is required")
+	}
+	if err := c.FeatureGates.Validate(); err != nil {
+		return err
+	}
+	names := map[string]bool{c.ResourceName: true}
+	for _, alias := range c.ResourceAliases {
+		if err := ValidateResourceName(alias); err != nil {
+			return fmt.Errorf("invalid resource alias: %w", err)
+		}
+		if names[alias] {
+			return fmt.Errorf("duplicate resource name %q", alias)
+		}
+		names[alias] = true
+	}
+	if c.MaxDevices != 0 {
+		if err := validateCount(c.MaxDevices); err != nil {
+			return fmt.Errorf("invalid max devices: %w", err)
+		}
+	}
+	if c.ResourceCount != 0 {
+		if err := validateCount(c.ResourceCount); err != nil {
+			return fmt.Errorf("invalid resource count: %w", err)
+		}
+	}
+	return nil
+}
+
+// ValidateResourceName checks name is a valid extended resource name, a
+// qualified name with an optional DNS subdomain prefix
+func ValidateResourceName(name string) error {
+	if errs := validation.IsQualifiedName(name); len(errs) > 0 {
+		return fmt.Errorf("invalid resource name %q: %s", name, strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// ReadResourceNameFile reads the resource name from the first line of the
+// file, the surrounding whitespace is trimmed
+func ReadResourceNameFile(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	line, _, _ := strings.Cut(string(data), "\n")
+	name := strings.TrimSpace(line)
+	if name == "" {
+		return "", fmt.Errorf("resource name file %s is empty", path)
+	}
+	if err := ValidateResourceName(name); err != nil {
+		return "", fmt.Errorf("resource name file %s: %w", path, err)
+	}
+	return name, nil
+}
+
+// ParseArchDevicePaths parses comma separated arch=path pairs,
+// e.g. arm64=/etc/micro-arm,amd64=/etc/micro
+func ParseArchDevicePaths(s string) (map[string]string, error) {
+	paths := make(map[string]string)
+	if s == "" {
+		return paths, nil
+	}
+	for _, pair := range strings.Split(s, ",") {
+		arch, path, ok := strings.Cut(pair, "=")
+		if !ok || arch == "" || path == "" {
+			return nil, fmt.Errorf("invalid arch device path %q", pair)
+		}
+		paths[arch] = path
+	}
+	return paths, nil
+}
+
+// ParseResourceAliases parses the comma separated resource aliases
+func ParseResourceAliases(s string) []string {
+	var aliases []string
+	for _, alias := range strings.Split(s, ",") {
+		if alias = strings.TrimSpace(alias); alias != "" {
+			aliases = append(aliases, alias)
+		}
+	}
+	return aliases
+}
+
+// maxDeviceCount bounds the device counts, the ListAndWatch response of
+// more devices exceeds the default 4 MiB gRPC message size
+const maxDeviceCount = 1 << 16
+
+// validateCount checks count is a kubernetes compatible device quantity
+func validateCount(count int) error {
+	if count <= 0 {
+		return errors.New("device count must be a positive integer")
+	}
+	if count > maxDeviceCount {
+		return fmt.Errorf("device count %d exceeds the maximum %d", count, maxDeviceCount)
+	}
+	q, err := resource.ParseQuantity(strconv.Itoa(count))
+	if err != nil {
+		return err
+	}
+	if v, ok := q.AsInt64(); !ok || v != int64(count) {
+		return fmt.Errorf("device count %d is not an integer quantity", count)
+	}
+	return nil
+}
@@ -0,0 +1,197 @@
+package config
+
+import (
+	"encoding/json"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func FuzzLoadConfig(f *testing.F) {
+	seeds := []string{
+		"",
+		"{}",
+		"resourceName: micro.plugin\ndevicePath: /etc/micro\n",
+		`{"resourceName": "micro.plugin", "maxDevices": 8}`,
+		"devicePath: ../../../etc\n",
+		"archDevicePaths:\n  arm64: /etc/micro-arm\n",
+		"maxDevices: -1\n",
+		"maxDevices: 1e40\n",
+		"unknown: field\n",
+		"resourceName: " + strings.Repeat("a", 255) + "\n",
+		"\x00\xff",
+		"- [",
+	}
+	for _, seed := range seeds {
+		f.Add([]byte(seed))
+	}
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		path := filepath.Join(t.TempDir(), "config.yaml")
+		if err := os.WriteFile(path, data, 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		cfg, err := LoadFile(path)
+		if err != nil {
+			return
+		}
+		if cfg == nil {
+			t.Fatal("LoadFile returned nil config without error")
+		}
+		if cfg.Validate() != nil {
+			return
+		}
+
+		// a valid config must survive a round trip unchanged
+		out, err := json.Marshal(cfg)
+		if err != nil {
+			t.Fatalf("marshal config: %v", err)
+		}
+		if err := os.WriteFile(path, out, 0644); err != nil {
+			t.Fatal(err)
+		}
+		again, err := LoadFile(path)
+		if err != nil {
+			t.Fatalf("reload config %s: %v", out, err)
+		}
+		reloaded, err := json.Marshal(again)
+		if err != nil {
+			t.Fatalf("marshal reloaded config: %v", err)
+		}
+		if string(out) != string(reloaded) {
+			t.Fatalf("config changed on reload: %s != %s", out, reloaded)
+		}
+	})
+}
+
+func TestReadResourceNameFile(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    string
+		wantErr bool
+	}{
+		{name: "name", content: "example.com/micro\n", want: "example.com/micro"},
+		{name: "whitespace", content: "  example.com/micro \t\r\nignored\n", want: "example.com/micro"},
+		{name: "empty", content: "\n", wantErr: true},
+		{name: "invalid", content: "example.com/micro/device\n", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "resource-name")
+			if err := os.WriteFile(path, []byte(tt.content), 0644); err != nil {
+				t.Fatal(err)
+			}
+			got, err := ReadResourceNameFile(path)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ReadResourceNameFile() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("ReadResourceNameFile() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMarshalSafeJSON(t *testing.T) {
+	cfg := Default()
+	cfg.AdmissionTLSKeyFile = "/etc/micro/tls.key"
+
+	data, err := cfg.MarshalSafeJSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got map[string]any
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatal(err)
+	}
+	if got["admissionTLSKeyFile"] != Redacted {
+		t.Errorf("admissionTLSKeyFile = %v, want %s", got["admissionTLSKeyFile"], Redacted)
+	}
+	if _, ok := got["attestationKeyFile"]; ok {
+		t.Error("empty attestationKeyFile redacted, want it omitted")
+	}
+	if got["resourceName"] != DefaultResourceName {
+		t.Errorf("resourceName = %v, want %s", got["resourceName"], DefaultResourceName)
+	}
+}
+
+func TestValidateResourceAliases(t *testing.T) {
+	cfg := Default()
+	cfg.ResourceAliases = ParseResourceAliases(" vendor.com/old-device, ,vendor.com/legacy")
+	if len(cfg.ResourceAliases) != 2 || cfg.ResourceAliases[0] != "vendor.com/old-device" {
+		t.Fatalf("ParseResourceAliases() = %q", cfg.ResourceAliases)
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("Validate() = %v", err)
+	}
+
+	for _, aliases := range [][]string{{DefaultResourceName}, {"vendor.com/a", "vendor.com/a"}, {"not a name!"}} {
+		cfg.ResourceAliases = aliases
+		if err := cfg.Validate(); err == nil {
+			t.Errorf("Validate() with aliases %q succeeded, want error", aliases)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		modify  func(c *Config) // precondition: changes of the default config
+		wantErr string          // expected: substring of the error, empty if valid
+	}{
+		{name: "default", modify: func(c *Config) {}},
+		{name: "max devices", modify: func(c *Config) { c.MaxDevices = 8 }},
+		{name: "known feature gate", modify: func(c *Config) { c.FeatureGates = FeatureGates{CDIDeviceSpecs: true} }},
+		{name: "no resource name", modify: func(c *Config) { c.ResourceName = "" }, wantErr: "resource name is required"},
+		{name: "invalid resource name", modify: func(c *Config) { c.ResourceName = "micro/device/0" }, wantErr: "invalid resource name"},
+		{name: "no device path", modify: func(c *Config) { c.DevicePath = "" }, wantErr: "device path is required"},
+		{name: "no plugin path", modify: func(c *Config) { c.PluginPath = "" }, wantErr: "plugin path is required"},
+		{name: "unknown feature gate", modify: func(c *Config) { c.FeatureGates = FeatureGates{"Unknown": true} }, wantErr: "unknown feature gate"},
+		{name: "negative max devices", modify: func(c *Config) { c.MaxDevices = -1 }, wantErr: "invalid max devices"},
+		{name: "resource count", modify: func(c *Config) { c.ResourceCount = 8 }},
+		{name: "largest resource count", modify: func(c *Config) { c.ResourceCount = maxDeviceCount }},
+		{name: "negative resource count", modify: func(c *Config) { c.ResourceCount = -1 }, wantErr: "invalid resource count"},
+		{name: "very large resource count", modify: func(c *Config) { c.ResourceCount = math.MaxInt }, wantErr: "invalid resource count"},
+		{name: "very large max devices", modify: func(c *Config) { c.MaxDevices = maxDeviceCount + 1 }, wantErr: "exceeds the maximum"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := Default()
+			tt.modify(cfg)
+
+			err := cfg.Validate()
+			switch {
+			case tt.wantErr == "" && err != nil:
+				t.Errorf("Validate() = %v, want nil", err)
+			case tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)):
+				t.Errorf("Validate() = %v, want error containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateCount(t *testing.T) {
+	tests := []struct {
+		count   int
+		wantErr bool
+	}{
+		{count: 1},
+		{count: 1000},
+		{count: maxDeviceCount},
+		{count: 0, wantErr: true},
+		{count: -1, wantErr: true},
+		{count: math.MinInt, wantErr: true},
+		{count: maxDeviceCount + 1, wantErr: true},
+		{count: math.MaxInt32, wantErr: true},
+		{count: math.MaxInt, wantErr: true},
+	}
+	for _, tt := range tests {
+		if err := validateCount(tt.count); (err != nil) != tt.wantErr {
+			t.Errorf("validateCount(%d) = %v, want error %v", tt.count, err, tt.wantErr)
+		}
+	}
+}
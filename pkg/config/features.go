@@ -0,0 +1,85 @@
+package config
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Feature gate names
+const (
+	CDIDeviceSpecs = "CDIDeviceSpecs"
+	NUMATopology   = "NUMATopology"
+	XattrMetadata  = "XattrMetadata"
+	ClaimTokens    = "ClaimTokens"
+)
+
+// defaultFeatureGates holds the known gates and their default state
+var defaultFeatureGates = map[string]bool{
+	CDIDeviceSpecs: false,
+	NUMATopology:   true,
+	XattrMetadata:  true,
+	ClaimTokens:    true,
+}
+
+// FeatureGates enables or disables features, gates missing from the
+// map keep their default state
+type FeatureGates map[string]bool
+
+// IsEnabled reports whether the feature gate is enabled
+func (g FeatureGates) IsEnabled(gate string) bool {
+	if enabled, ok := g[gate]; ok {
+		return enabled
+	}
+	return defaultFeatureGates[gate]
+}
+
+// Effective returns the state of every known gate
+func (g FeatureGates) Effective() map[string]bool {
+	gates := make(map[string]bool, len(defaultFeatureGates))
+	for gate := range defaultFeatureGates {
+		gates[gate] = g.IsEnabled(gate)
+	}
+	return gates
+}
+
+// Validate checks all gates are known
+func (g FeatureGates) Validate() error {
+	for gate := range g {
+		if _, ok := defaultFeatureGates[gate]; !ok {
+			return fmt.Errorf("unknown feature gate %q, known gates: %s", gate, knownGates())
+		}
+	}
+	return nil
+}
+
+// ParseFeatureGates parses a comma separated Key=true|false list,
+// e.g. CDIDeviceSpecs=true,XattrMetadata=false
+func ParseFeatureGates(s string) (FeatureGates, error) {
+	gates := make(FeatureGates)
+	if s == "" {
+		return gates, nil
+	}
+	for _, pair := range strings.Split(s, ",") {
+		gate, value, ok := strings.Cut(strings.TrimSpace(pair), "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid feature gate %q, want Key=true|false", pair)
+		}
+		enabled, err := strconv.ParseBool(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid feature gate %q: %w", pair, err)
+		}
+		gates[gate] = enabled
+	}
+	return gates, gates.Validate()
+}
+
+func knownGates() string {
+	gates := make([]string, 0, len(defaultFeatureGates))
+	for gate := range defaultFeatureGates {
+		gates = append(gates, gate)
+	}
+	sort.Strings(gates)
+	return strings.Join(gates, ", ")
+}
@@ -0,0 +1,53 @@
+package config
+
+import (
+	"maps"
+	"strings"
+	"testing"
+)
+
+func TestParseFeatureGates(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    FeatureGates
+		wantErr string
+	}{
+		{in: "", want: FeatureGates{}},
+		{in: "CDIDeviceSpecs=true", want: FeatureGates{CDIDeviceSpecs: true}},
+		{in: "CDIDeviceSpecs=true, XattrMetadata=false", want: FeatureGates{CDIDeviceSpecs: true, XattrMetadata: false}},
+		{in: "ClaimTokens", wantErr: "want Key=true|false"},
+		{in: "ClaimTokens=maybe", wantErr: "invalid feature gate"},
+		{in: "Unknown=true", wantErr: "unknown feature gate"},
+	}
+	for _, tt := range tests {
+		got, err := ParseFeatureGates(tt.in)
+		if tt.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("ParseFeatureGates(%q) error = %v, want %q", tt.in, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil || !maps.Equal(got, tt.want) {
+			t.Errorf("ParseFeatureGates(%q) = %v, %v, want %v", tt.in, got, err, tt.want)
+		}
+	}
+}
+
+func TestFeatureGatesIsEnabled(t *testing.T) {
+	gates := FeatureGates{CDIDeviceSpecs: true, ClaimTokens: false}
+	want := map[string]bool{
+		CDIDeviceSpecs: true,  // enabled, disabled by default
+		NUMATopology:   true,  // default
+		XattrMetadata:  true,  // default
+		ClaimTokens:    false, // disabled, enabled by default
+		"Unknown":      false,
+	}
+	for gate, enabled := range want {
+		if got := gates.IsEnabled(gate); got != enabled {
+			t.Errorf("IsEnabled(%s) = %v, want %v", gate, got, enabled)
+		}
+	}
+	if got := FeatureGates(nil).IsEnabled(NUMATopology); !got {
+		t.Error("nil gates disable the default NUMATopology gate")
+	}
+}
@@ -0,0 +1,41 @@
+package discovery
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+)
+
+// CapabilitiesSuffix is the suffix of the device capability sidecar
+// files, they are not advertised as devices
+const CapabilitiesSuffix = ".capabilities.json"
+
+// DeviceCapabilities describes the hardware capabilities of a device,
+// devices of one resource may differ in capabilities
+type DeviceCapabilities struct {
+	MemoryMB      int     `json:"memoryMB,omitempty"`
+	ComputeUnits  int     `json:"computeUnits,omitempty"`
+	BandwidthGbps float64 `json:"bandwidthGbps,omitempty"`
+}
+
+// LoadCapabilities reads the capabilities of the device file path from
+// its `<path>.capabilities.json` sidecar file, nil if the sidecar does
+// not exist
+func LoadCapabilities(path string) (*DeviceCapabilities, error) {
+	data, err := os.ReadFile(path + CapabilitiesSuffix)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read device capabilities: %w", err)
+	}
+	var caps DeviceCapabilities
+	if err := json.Unmarshal(data, &caps); err != nil {
+		return nil, fmt.Errorf("decode device capabilities: %w", err)
+	}
+	if caps.MemoryMB < 0 || caps.ComputeUnits < 0 || caps.BandwidthGbps < 0 {
+		return nil, fmt.Errorf("device capabilities must not be negative")
+	}
+	return &caps, nil
+}
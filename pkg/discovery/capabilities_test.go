@@ -0,0 +1,39 @@
+package discovery
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoadCapabilities(t *testing.T) {
+	tests := []struct {
+		name    string
+		sidecar string // precondition: sidecar content, no sidecar if empty
+		want    *DeviceCapabilities
+		wantErr bool
+	}{
+		{name: "no sidecar"},
+		{name: "valid", sidecar: `{"memoryMB": 8192, "computeUnits": 16, "bandwidthGbps": 50.5}`,
+			want: &DeviceCapabilities{MemoryMB: 8192, ComputeUnits: 16, BandwidthGbps: 50.5}},
+		{name: "invalid json", sidecar: `{"memoryMB":`, wantErr: true},
+		{name: "negative", sidecar: `{"memoryMB": -1}`, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "micro0")
+			if tt.sidecar != "" {
+				if err := os.WriteFile(path+CapabilitiesSuffix, []byte(tt.sidecar), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			got, err := LoadCapabilities(path)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("LoadCapabilities() error = %v, want error %v", err, tt.wantErr)
+			}
+			if (got == nil) != (tt.want == nil) || got != nil && *got != *tt.want {
+				t.Errorf("LoadCapabilities() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,81 @@
+package discovery
+
+import (
+	"context"
+
+	deviceapi "k8s.io/kubelet/pkg/apis/deviceplugin/v1beta1"
+)
+
+// MicroDevice is a discovered micro device
+type MicroDevice struct {
+	Name        string
+	Path        string
+	ID          string
+	Health      string
+	Annotations map[string]string
+
+	// Capabilities are loaded from the capability sidecar file, nil if
+	// the device has none
+	Capabilities *DeviceCapabilities `json:",omitempty"`
+}
+
+// APIDevice converts the device to the kubelet device plugin type
+func (d *MicroDevice) APIDevice() *deviceapi.Device {
+	return &deviceapi.Device{
+		ID:     d.ID,
+		Health: d.Health,
+	}
+}
+
+// EventType is the kind of a discovery event
+type EventType int
+
+// Discovery event types
+const (
+	DeviceCreated EventType = iota
+	DeviceRemoved
+)
+
+func (t EventType) String() string {
+	switch t {
+	case DeviceCreated:
+		return "created"
+	case DeviceRemoved:
+		return "removed"
+	default:
+		return "unknown"
+	}
+}
+
+// DiscoveryEvent notifies a device was created or removed
+type DiscoveryEvent struct {
+	Type   EventType
+	Device *MicroDevice
+}
+
+// Discoverer discovers micro devices and watches their changes
+type Discoverer interface {
+	// Discover returns the devices currently present
+	Discover() ([]*MicroDevice, error)
+
+	// Watch sends device changes to events until ctx is done
+	Watch(ctx context.Context, events chan<- DiscoveryEvent) error
+}
+
+// Compile-time checks of the Discoverer implementations
+var (
+	_ Discoverer = (*FilesystemDiscoverer)(nil)
+	_ Discoverer = (*StaticDiscoverer)(nil)
+	_ Discoverer = (*SimulatedDiscoverer)(nil)
+	_ Discoverer = (*PipeDiscoverer)(nil)
+)
+
+// send delivers the event unless ctx is done
+func send(ctx context.Context, events chan<- DiscoveryEvent, event DiscoveryEvent) bool {
+	select {
+	case events <- event:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
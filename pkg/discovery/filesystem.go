@@ -0,0 +1,214 @@
+package discovery
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	deviceapi "k8s.io/kubelet/pkg/apis/deviceplugin/v1beta1"
+)
+
+// FilesystemDiscoverer discovers devices as files of a directory
+type FilesystemDiscoverer struct {
+	Path string
+
+	// Recursive discovers the device files of all subdirectories, the
+	// device names are their relative paths with `/` replaced by `-`
+	Recursive bool
+
+	// Poll lists the directory every Poll interval instead of watching
+	// it with fsnotify when set, for environments without inotify
+	Poll time.Duration
+}
+
+// NewFilesystemDiscoverer creates a discoverer of the directory path
+func NewFilesystemDiscoverer(path string) *FilesystemDiscoverer {
+	return &FilesystemDiscoverer{Path: path}
+}
+
+// Discover lists the device files of the directory
+func (d *FilesystemDiscoverer) Discover() ([]*MicroDevice, error) {
+	if d.Recursive {
+		return d.walk(d.Path, nil)
+	}
+
+	dir, err := os.ReadDir(d.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	var devices []*MicroDevice
+	for _, f := range dir {
+		if f.IsDir() {
+			continue
+		}
+		devices = append(devices, d.device(f.Name()))
+	}
+	return devices, nil
+}
+
+// walk lists the device files under root and calls addDir with every
+// directory if not nil
+func (d *FilesystemDiscoverer) walk(root string, addDir func(string) error) ([]*MicroDevice, error) {
+	var devices []*MicroDevice
+	err := filepath.WalkDir(root, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if entry.IsDir() {
+			if addDir != nil {
+				return addDir(path)
+			}
+			return nil
+		}
+		rel, err := filepath.Rel(d.Path, path)
+		if err != nil {
+			return err
+		}
+		devices = append(devices, d.device(rel))
+		return nil
+	})
+	return devices, err
+}
+
+// Watch watches the directory for created and removed device files
+func (d *FilesystemDiscoverer) Watch(ctx context.Context, events chan<- DiscoveryEvent) error {
+	if d.Poll > 0 {
+		return d.poll(ctx, events)
+	}
+
+	w, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("fsnotify NewWatcher error: %w", err)
+	}
+	defer w.Close()
+
+	if d.Recursive {
+		_, err = d.walk(d.Path, w.Add)
+	} else {
+		err = w.Add(d.Path)
+	}
+	if err != nil {
+		return fmt.Errorf("watch device error: %w", err)
+	}
+
+	for {
+		select {
+		case event, ok := <-w.Events:
+			if !ok {
+				return nil
+			}
+			slog.Info("device event", "kind", event.Op.String(), "name", event.Name)
+
+			name := filepath.Base(event.Name)
+			if d.Recursive {
+				if name, err = filepath.Rel(d.Path, event.Name); err != nil {
+					slog.Error("resolve device path failed", "path", event.Name, "err", err)
+					continue
+				}
+			}
+			if event.Op&fsnotify.Create == fsnotify.Create {
+				if d.Recursive {
+					if info, err := os.Stat(event.Name); err == nil && info.IsDir() {
+						// watch the new subdirectory and add the device
+						// files created before the watch
+						devices, err := d.walk(event.Name, w.Add)
+						if err != nil {
+							slog.Error("watch device directory failed", "path", event.Name, "err", err)
+						}
+						for _, dev := range devices {
+							if !send(ctx, events, DiscoveryEvent{Type: DeviceCreated, Device: dev}) {
+								return nil
+							}
+						}
+						continue
+					}
+				}
+				if !send(ctx, events, DiscoveryEvent{Type: DeviceCreated, Device: d.device(name)}) {
+					return nil
+				}
+			}
+			if event.Op&fsnotify.Remove == fsnotify.Remove {
+				if !send(ctx, events, DiscoveryEvent{Type: DeviceRemoved, Device: d.device(name)}) {
+					return nil
+				}
+			}
+
+		case err, ok := <-w.Errors:
+			if !ok {
+				return nil
+			}
+			slog.Error("watcher", "err", err)
+
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+// poll lists the directory every poll interval and sends the created and
+// removed device files
+func (d *FilesystemDiscoverer) poll(ctx context.Context, events chan<- DiscoveryEvent) error {
+	known := make(map[string]*MicroDevice)
+	if devices, err := d.Discover(); err == nil {
+		for _, dev := range devices {
+			known[dev.Name] = dev
+		}
+	}
+
+	ticker := time.NewTicker(d.Poll)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return nil
+		}
+
+		devices, err := d.Discover()
+		if err != nil && !os.IsNotExist(err) {
+			slog.Error("poll device directory failed", "path", d.Path, "err", err)
+			continue
+		}
+		current := make(map[string]*MicroDevice, len(devices))
+		for _, dev := range devices {
+			current[dev.Name] = dev
+			if _, ok := known[dev.Name]; !ok {
+				slog.Info("device event", "kind", "CREATE", "name", dev.Path)
+				if !send(ctx, events, DiscoveryEvent{Type: DeviceCreated, Device: dev}) {
+					return nil
+				}
+			}
+		}
+		for name, dev := range known {
+			if _, ok := current[name]; !ok {
+				slog.Info("device event", "kind", "REMOVE", "name", dev.Path)
+				if !send(ctx, events, DiscoveryEvent{Type: DeviceRemoved, Device: dev}) {
+					return nil
+				}
+			}
+		}
+		known = current
+	}
+}
+
+// device returns the device of the file at the relative path rel
+func (d *FilesystemDiscoverer) device(rel string) *MicroDevice {
+	return &MicroDevice{
+		Name:   DeviceName(rel),
+		Path:   filepath.Join(d.Path, rel),
+		Health: deviceapi.Healthy,
+	}
+}
+
+// DeviceName returns the device name of the file at the relative path
+// rel, the path separators are replaced by `-` to keep names unique
+func DeviceName(rel string) string {
+	return strings.ReplaceAll(filepath.ToSlash(rel), "/", "-")
+}
@@ -0,0 +1,120 @@
+package discovery
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+func deviceNames(devices []*MicroDevice) []string {
+	var names []string
+	for _, dev := range devices {
+		names = append(names, dev.Name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+func createFiles(t *testing.T, root string, paths ...string) {
+	t.Helper()
+	for _, path := range paths {
+		path = filepath.Join(root, path)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func expectEvent(t *testing.T, events <-chan DiscoveryEvent, typ EventType, name string) {
+	t.Helper()
+	select {
+	case event := <-events:
+		if event.Type != typ || event.Device.Name != name {
+			t.Fatalf("event = %s %s, want %s %s", event.Type, event.Device.Name, typ, name)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("no %s event for %s", typ, name)
+	}
+}
+
+func TestFilesystemDiscoverRecursive(t *testing.T) {
+	root := t.TempDir()
+	createFiles(t, root, "micro0", "bus0/micro0", "bus0/slot1/micro1")
+
+	d := &FilesystemDiscoverer{Path: root}
+	devices, err := d.Discover()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := deviceNames(devices), []string{"micro0"}; !slices.Equal(got, want) {
+		t.Errorf("Discover() = %v, want %v", got, want)
+	}
+
+	d.Recursive = true
+	devices, err = d.Discover()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"bus0-micro0", "bus0-slot1-micro1", "micro0"}
+	if got := deviceNames(devices); !slices.Equal(got, want) {
+		t.Errorf("recursive Discover() = %v, want %v", got, want)
+	}
+	for _, dev := range devices {
+		if dev.Name == "bus0-slot1-micro1" && dev.Path != filepath.Join(root, "bus0", "slot1", "micro1") {
+			t.Errorf("device %s path = %s", dev.Name, dev.Path)
+		}
+	}
+}
+
+func TestFilesystemWatchRecursive(t *testing.T) {
+	root := t.TempDir()
+	createFiles(t, root, "bus0/micro0")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	events := make(chan DiscoveryEvent)
+	d := &FilesystemDiscoverer{Path: root, Recursive: true}
+	go d.Watch(ctx, events)
+	// give the watcher time to add the directories
+	time.Sleep(100 * time.Millisecond)
+
+	createFiles(t, root, "bus0/micro1")
+	expectEvent(t, events, DeviceCreated, "bus0-micro1")
+
+	if err := os.Remove(filepath.Join(root, "bus0", "micro0")); err != nil {
+		t.Fatal(err)
+	}
+	expectEvent(t, events, DeviceRemoved, "bus0-micro0")
+
+	if err := os.Mkdir(filepath.Join(root, "bus1"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(100 * time.Millisecond)
+	createFiles(t, root, "bus1/micro2")
+	expectEvent(t, events, DeviceCreated, "bus1-micro2")
+}
+
+func TestFilesystemWatchPoll(t *testing.T) {
+	root := t.TempDir()
+	createFiles(t, root, "micro0")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	events := make(chan DiscoveryEvent)
+	d := &FilesystemDiscoverer{Path: root, Poll: 10 * time.Millisecond}
+	go d.Watch(ctx, events)
+	time.Sleep(50 * time.Millisecond)
+
+	createFiles(t, root, "micro1")
+	expectEvent(t, events, DeviceCreated, "micro1")
+	if err := os.Remove(filepath.Join(root, "micro0")); err != nil {
+		t.Fatal(err)
+	}
+	expectEvent(t, events, DeviceRemoved, "micro0")
+}
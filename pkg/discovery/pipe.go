@@ -0,0 +1,189 @@
+package discovery
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"sort"
+	"sync"
+	"syscall"
+	"time"
+
+	deviceapi "k8s.io/kubelet/pkg/apis/deviceplugin/v1beta1"
+)
+
+// Operations of the device pipe messages
+const (
+	PipeOpAdd    = "add"
+	PipeOpRemove = "remove"
+)
+
+// PipeMessage is a JSON line written to the device pipe by an external
+// hardware manager, Path is the optional device file of the device
+type PipeMessage struct {
+	Op   string `json:"op"`
+	Name string `json:"name"`
+	Path string `json:"path,omitempty"`
+}
+
+// PipeDiscoverer discovers the devices announced on a named pipe, the
+// pipe is reopened whenever its last writer closes it
+type PipeDiscoverer struct {
+	Path string
+
+	// Retry is the interval of reopening the pipe after an open error
+	Retry time.Duration
+
+	mu      sync.Mutex
+	devices map[string]*MicroDevice
+}
+
+// NewPipeDiscoverer creates a discoverer of the named pipe path
+func NewPipeDiscoverer(path string) *PipeDiscoverer {
+	return &PipeDiscoverer{Path: path, Retry: time.Second}
+}
+
+// Discover returns the devices added on the pipe and not removed since,
+// sorted by name
+func (d *PipeDiscoverer) Discover() ([]*MicroDevice, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	devices := make([]*MicroDevice, 0, len(d.devices))
+	for _, dev := range d.devices {
+		devices = append(devices, copyDevice(dev))
+	}
+	sort.Slice(devices, func(i, j int) bool {
+		return devices[i].Name < devices[j].Name
+	})
+	return devices, nil
+}
+
+// Watch reads the messages of the pipe and sends their device changes
+// until ctx is done
+func (d *PipeDiscoverer) Watch(ctx context.Context, events chan<- DiscoveryEvent) error {
+	for {
+		f, err := d.open(ctx)
+		if ctx.Err() != nil {
+			return nil
+		}
+		if err != nil {
+			slog.Error("open device pipe failed", "path", d.Path, "err", err)
+			select {
+			case <-time.After(d.Retry):
+				continue
+			case <-ctx.Done():
+				return nil
+			}
+		}
+
+		err = d.read(ctx, f, events)
+		f.Close()
+		if ctx.Err() != nil {
+			return nil
+		}
+		if err != nil {
+			slog.Error("read device pipe failed", "path", d.Path, "err", err)
+		}
+		slog.Info("device pipe closed by writer, reopening", "path", d.Path)
+	}
+}
+
+// open opens the pipe for reading, blocking until a writer opens it or
+// ctx is done
+func (d *PipeDiscoverer) open(ctx context.Context) (*os.File, error) {
+	type result struct {
+		f   *os.File
+		err error
+	}
+	opened := make(chan result, 1)
+	go func() {
+		f, err := os.OpenFile(d.Path, os.O_RDONLY, 0)
+		opened <- result{f, err}
+	}()
+
+	select {
+	case r := <-opened:
+		return r.f, r.err
+	case <-ctx.Done():
+	}
+	for {
+		// open the write end to release the pending open, it fails
+		// until the reader is waiting
+		if w, err := os.OpenFile(d.Path, os.O_WRONLY|syscall.O_NONBLOCK, 0); err == nil {
+			w.Close()
+		}
+		select {
+		case r := <-opened:
+			if r.f != nil {
+				r.f.Close()
+			}
+			return nil, ctx.Err()
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+}
+
+// read sends the device changes of the messages of f until the writers
+// close the pipe
+func (d *PipeDiscoverer) read(ctx context.Context, f *os.File, events chan<- DiscoveryEvent) error {
+	// closing the pipe releases the pending read
+	stop := context.AfterFunc(ctx, func() { f.Close() })
+	defer stop()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if len(line) == 0 {
+			continue
+		}
+		event, err := d.apply(line)
+		if err != nil {
+			slog.Error("invalid device pipe message", "message", string(line), "err", err)
+			continue
+		}
+		if event == nil {
+			continue
+		}
+		slog.Info("device event", "kind", event.Type.String(), "name", event.Device.Name)
+		if !send(ctx, events, *event) {
+			return nil
+		}
+	}
+	return scanner.Err()
+}
+
+// apply decodes the message and updates the known devices, the returned
+// event is nil if the message changes no device
+func (d *PipeDiscoverer) apply(line []byte) (*DiscoveryEvent, error) {
+	var msg PipeMessage
+	if err := json.Unmarshal(line, &msg); err != nil {
+		return nil, err
+	}
+	if msg.Name == "" {
+		return nil, fmt.Errorf("message has no device name")
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.devices == nil {
+		d.devices = make(map[string]*MicroDevice)
+	}
+	switch msg.Op {
+	case PipeOpAdd:
+		dev := &MicroDevice{Name: msg.Name, Path: msg.Path, Health: deviceapi.Healthy}
+		d.devices[msg.Name] = dev
+		return &DiscoveryEvent{Type: DeviceCreated, Device: copyDevice(dev)}, nil
+	case PipeOpRemove:
+		dev, ok := d.devices[msg.Name]
+		if !ok {
+			return nil, nil
+		}
+		delete(d.devices, msg.Name)
+		return &DiscoveryEvent{Type: DeviceRemoved, Device: dev}, nil
+	default:
+		return nil, fmt.Errorf("unknown operation %q, must be %s or %s", msg.Op, PipeOpAdd, PipeOpRemove)
+	}
+}
@@ -0,0 +1,91 @@
+package discovery
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// makePipe creates a named pipe in a temporary directory
+func makePipe(t *testing.T) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "devices.pipe")
+	if err := syscall.Mkfifo(path, 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// writePipe opens the pipe for writing and writes the messages
+func writePipe(t *testing.T, path string, messages ...string) {
+	t.Helper()
+	f, err := os.OpenFile(path, os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	for _, msg := range messages {
+		if _, err := f.WriteString(msg + "\n"); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestPipeDiscovererWatch(t *testing.T) {
+	path := makePipe(t)
+	d := NewPipeDiscoverer(path)
+	ctx, cancel := context.WithCancel(context.Background())
+	events := make(chan DiscoveryEvent)
+	done := make(chan error, 1)
+	go func() { done <- d.Watch(ctx, events) }()
+
+	writePipe(t, path,
+		`{"op":"add","name":"micro0"}`,
+		`not json`,
+		`{"op":"attach","name":"micro1"}`,
+		`{"op":"add","name":"micro1","path":"/dev/micro1"}`,
+		`{"op":"remove","name":"micro0"}`,
+	)
+	expectEvent(t, events, DeviceCreated, "micro0")
+	expectEvent(t, events, DeviceCreated, "micro1")
+	expectEvent(t, events, DeviceRemoved, "micro0")
+
+	// the writer closed the pipe, the discoverer reopens it
+	writePipe(t, path, `{"op":"add","name":"micro2"}`)
+	expectEvent(t, events, DeviceCreated, "micro2")
+
+	devices, err := d.Discover()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := deviceNames(devices), []string{"micro1", "micro2"}; !slices.Equal(got, want) {
+		t.Errorf("Discover() = %v, want %v", got, want)
+	}
+	if devices[0].Path != "/dev/micro1" {
+		t.Errorf("micro1 path = %q, want /dev/micro1", devices[0].Path)
+	}
+
+	// Watch exits while waiting for the next writer
+	cancel()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("Watch() = %v, want nil", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Watch did not exit on cancel")
+	}
+}
+
+func TestPipeDiscovererMissingPipe(t *testing.T) {
+	d := &PipeDiscoverer{Path: filepath.Join(t.TempDir(), "missing.pipe"), Retry: 10 * time.Millisecond}
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	if err := d.Watch(ctx, make(chan DiscoveryEvent)); err != nil {
+		t.Errorf("Watch() with missing pipe = %v, want nil", err)
+	}
+}
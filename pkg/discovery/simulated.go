@@ -0,0 +1,109 @@
+package discovery
+
+import (
+	"context"
+	"log/slog"
+	"slices"
+	"sort"
+	"sync"
+
+	deviceapi "k8s.io/kubelet/pkg/apis/deviceplugin/v1beta1"
+)
+
+// SimulatedDiscoverer is an in-memory discoverer for testing, devices
+// are added and removed programmatically
+type SimulatedDiscoverer struct {
+	mu       sync.Mutex
+	devices  map[string]*MicroDevice
+	watchers []chan DiscoveryEvent
+}
+
+// NewSimulatedDiscoverer creates a simulated discoverer with devices
+func NewSimulatedDiscoverer(names ...string) *SimulatedDiscoverer {
+	d := &SimulatedDiscoverer{devices: make(map[string]*MicroDevice)}
+	for _, name := range names {
+		d.devices[name] = simulatedDevice(name)
+	}
+	return d
+}
+
+// Discover returns the simulated devices sorted by name
+func (d *SimulatedDiscoverer) Discover() ([]*MicroDevice, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	devices := make([]*MicroDevice, 0, len(d.devices))
+	for _, dev := range d.devices {
+		devices = append(devices, dev)
+	}
+	sort.Slice(devices, func(i, j int) bool {
+		return devices[i].Name < devices[j].Name
+	})
+	return devices, nil
+}
+
+// Watch forwards simulated device changes until ctx is done
+func (d *SimulatedDiscoverer) Watch(ctx context.Context, events chan<- DiscoveryEvent) error {
+	ch := make(chan DiscoveryEvent, 16)
+	d.mu.Lock()
+	d.watchers = append(d.watchers, ch)
+	d.mu.Unlock()
+	defer d.unwatch(ch)
+
+	for {
+		select {
+		case event := <-ch:
+			if !send(ctx, events, event) {
+				return nil
+			}
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+// Add simulates a new device
+func (d *SimulatedDiscoverer) Add(name string) {
+	dev := simulatedDevice(name)
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.devices[name] = dev
+	d.publish(DiscoveryEvent{Type: DeviceCreated, Device: dev})
+}
+
+// Remove simulates a device removal
+func (d *SimulatedDiscoverer) Remove(name string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	dev, ok := d.devices[name]
+	if !ok {
+		return
+	}
+	delete(d.devices, name)
+	d.publish(DiscoveryEvent{Type: DeviceRemoved, Device: dev})
+}
+
+// unwatch removes the watcher channel of a returned Watch
+func (d *SimulatedDiscoverer) unwatch(ch chan DiscoveryEvent) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.watchers = slices.DeleteFunc(d.watchers, func(w chan DiscoveryEvent) bool {
+		return w == ch
+	})
+}
+
+// publish sends the event to all watchers without blocking, the event is
+// dropped for a watcher with a full buffer, must be called with d.mu held
+func (d *SimulatedDiscoverer) publish(event DiscoveryEvent) {
+	for _, ch := range d.watchers {
+		select {
+		case ch <- event:
+		default:
+			slog.Warn("simulated discovery event dropped", "type", event.Type, "name", event.Device.Name)
+		}
+	}
+}
+
+func simulatedDevice(name string) *MicroDevice {
+	return &MicroDevice{Name: name, Health: deviceapi.Healthy}
+}
@@ -0,0 +1,82 @@
+package discovery
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestSimulatedDiscoverer(t *testing.T) {
+	d := NewSimulatedDiscoverer("micro1", "micro0")
+	devices, err := d.Discover()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := []string{devices[0].Name, devices[1].Name}; !slices.Equal(got, []string{"micro0", "micro1"}) {
+		t.Errorf("Discover() = %v, want sorted [micro0 micro1]", got)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	events := make(chan DiscoveryEvent)
+	done := make(chan error, 1)
+	go func() { done <- d.Watch(ctx, events) }()
+	waitWatchers(t, d, 1)
+
+	d.Add("micro2")
+	expectEvent(t, events, DeviceCreated, "micro2")
+	d.Remove("micro0")
+	expectEvent(t, events, DeviceRemoved, "micro0")
+	d.Remove("micro0")
+
+	devices, _ = d.Discover()
+	if got := deviceNames(devices); !slices.Equal(got, []string{"micro1", "micro2"}) {
+		t.Errorf("Discover() = %v, want [micro1 micro2]", got)
+	}
+
+	cancel()
+	if err := <-done; err != nil {
+		t.Errorf("Watch() = %v", err)
+	}
+	waitWatchers(t, d, 0)
+}
+
+func TestSimulatedDiscovererSlowWatcher(t *testing.T) {
+	d := NewSimulatedDiscoverer()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	// nobody reads the events, the watcher buffer fills up
+	go d.Watch(ctx, make(chan DiscoveryEvent))
+	waitWatchers(t, d, 1)
+
+	added := make(chan struct{})
+	go func() {
+		for i := 0; i < 32; i++ {
+			d.Add("micro0")
+		}
+		close(added)
+	}()
+	select {
+	case <-added:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Add blocked on a watcher not reading its events")
+	}
+}
+
+// waitWatchers waits until the discoverer has n watchers
+func waitWatchers(t *testing.T, d *SimulatedDiscoverer, n int) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		d.mu.Lock()
+		got := len(d.watchers)
+		d.mu.Unlock()
+		if got == n {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("discoverer has %d watchers, want %d", got, n)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
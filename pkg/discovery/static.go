@@ -0,0 +1,222 @@
+package discovery
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"sync"
+
+	"github.com/fsnotify/fsnotify"
+	deviceapi "k8s.io/kubelet/pkg/apis/deviceplugin/v1beta1"
+	"sigs.k8s.io/yaml"
+)
+
+// NUMAAnnotation is the device annotation holding its NUMA node
+const NUMAAnnotation = "micro.xattr/numa"
+
+// StaticDevice is a device declared in the static devices file
+type StaticDevice struct {
+	Name        string            `json:"name"`
+	ID          string            `json:"id,omitempty"`
+	Health      string            `json:"health,omitempty"`
+	NUMANode    *int              `json:"numaNode,omitempty"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+}
+
+// StaticDevicesFile is the static devices file declaring the devices of
+// nodes without device files to probe
+type StaticDevicesFile struct {
+	Devices []StaticDevice `json:"devices"`
+}
+
+// LoadStaticDevices reads the devices of the YAML or JSON static
+// devices file
+func LoadStaticDevices(path string) ([]*MicroDevice, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var file StaticDevicesFile
+	if err := yaml.UnmarshalStrict(data, &file); err != nil {
+		return nil, fmt.Errorf("decode static devices file %s: %w", path, err)
+	}
+
+	names := make(map[string]bool, len(file.Devices))
+	devices := make([]*MicroDevice, 0, len(file.Devices))
+	for i, sd := range file.Devices {
+		if sd.Name == "" {
+			return nil, fmt.Errorf("static device %d has no name", i)
+		}
+		if names[sd.Name] {
+			return nil, fmt.Errorf("duplicate static device %s", sd.Name)
+		}
+		names[sd.Name] = true
+
+		dev := &MicroDevice{
+			Name:        sd.Name,
+			ID:          sd.ID,
+			Health:      sd.Health,
+			Annotations: make(map[string]string, len(sd.Annotations)+1),
+		}
+		switch dev.Health {
+		case "":
+			dev.Health = deviceapi.Healthy
+		case deviceapi.Healthy, deviceapi.Unhealthy:
+		default:
+			return nil, fmt.Errorf("static device %s has invalid health %q", sd.Name, sd.Health)
+		}
+		for k, v := range sd.Annotations {
+			dev.Annotations[k] = v
+		}
+		if sd.NUMANode != nil {
+			dev.Annotations[NUMAAnnotation] = strconv.Itoa(*sd.NUMANode)
+		}
+		devices = append(devices, dev)
+	}
+	return devices, nil
+}
+
+// StaticDiscoverer discovers a fixed list of devices from configuration
+// or the devices declared in a static devices file
+type StaticDiscoverer struct {
+	mu      sync.Mutex
+	path    string
+	devices []*MicroDevice
+}
+
+// NewStaticDiscoverer creates a discoverer of the fixed devices
+func NewStaticDiscoverer(devices []*MicroDevice) *StaticDiscoverer {
+	return &StaticDiscoverer{devices: devices}
+}
+
+// NewStaticFileDiscoverer creates a discoverer of the devices declared
+// in the static devices file path
+func NewStaticFileDiscoverer(path string) *StaticDiscoverer {
+	return &StaticDiscoverer{path: path}
+}
+
+// Discover returns the configured devices, reading the static devices
+// file if set
+func (d *StaticDiscoverer) Discover() ([]*MicroDevice, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.path != "" {
+		devices, err := LoadStaticDevices(d.path)
+		if err != nil {
+			return nil, err
+		}
+		d.devices = devices
+	}
+	return copyDevices(d.devices), nil
+}
+
+// Watch sends the device changes of the static devices file until ctx is
+// done, fixed devices never change
+func (d *StaticDiscoverer) Watch(ctx context.Context, events chan<- DiscoveryEvent) error {
+	if d.path == "" {
+		<-ctx.Done()
+		return nil
+	}
+
+	w, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("fsnotify NewWatcher error: %w", err)
+	}
+	defer w.Close()
+
+	// watch the directory since editors and config maps replace the file
+	if err := w.Add(filepath.Dir(d.path)); err != nil {
+		return fmt.Errorf("watch static devices file error: %w", err)
+	}
+
+	path := filepath.Clean(d.path)
+	for {
+		select {
+		case event, ok := <-w.Events:
+			if !ok {
+				return nil
+			}
+			if filepath.Clean(event.Name) != path || event.Op == fsnotify.Chmod {
+				continue
+			}
+			slog.Info("static devices file event", "kind", event.Op.String(), "name", event.Name)
+			for _, e := range d.reload() {
+				if !send(ctx, events, e) {
+					return nil
+				}
+			}
+
+		case err, ok := <-w.Errors:
+			if !ok {
+				return nil
+			}
+			slog.Error("watcher", "err", err)
+
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+// reload reads the static devices file and returns the events of the
+// added, changed and removed devices, the devices are kept if the file
+// is invalid
+func (d *StaticDiscoverer) reload() []DiscoveryEvent {
+	devices, err := LoadStaticDevices(d.path)
+	if os.IsNotExist(err) {
+		devices, err = nil, nil
+	}
+	if err != nil {
+		slog.Error("reload static devices file failed", "path", d.path, "err", err)
+		return nil
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	previous := make(map[string]*MicroDevice, len(d.devices))
+	for _, dev := range d.devices {
+		previous[dev.Name] = dev
+	}
+
+	var events []DiscoveryEvent
+	for _, dev := range devices {
+		if old, ok := previous[dev.Name]; !ok || !reflect.DeepEqual(old, dev) {
+			events = append(events, DiscoveryEvent{Type: DeviceCreated, Device: copyDevice(dev)})
+		}
+		delete(previous, dev.Name)
+	}
+	for _, dev := range previous {
+		events = append(events, DiscoveryEvent{Type: DeviceRemoved, Device: copyDevice(dev)})
+	}
+	d.devices = devices
+	return events
+}
+
+// copyDevices returns copies of the devices, the server mutates the
+// devices it adds
+func copyDevices(devices []*MicroDevice) []*MicroDevice {
+	copies := make([]*MicroDevice, len(devices))
+	for i, dev := range devices {
+		copies[i] = copyDevice(dev)
+	}
+	return copies
+}
+
+func copyDevice(dev *MicroDevice) *MicroDevice {
+	c := *dev
+	if dev.Annotations != nil {
+		c.Annotations = make(map[string]string, len(dev.Annotations))
+		for k, v := range dev.Annotations {
+			c.Annotations[k] = v
+		}
+	}
+	if dev.Capabilities != nil {
+		caps := *dev.Capabilities
+		c.Capabilities = &caps
+	}
+	return &c
+}
@@ -0,0 +1,112 @@
+package discovery
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	deviceapi "k8s.io/kubelet/pkg/apis/deviceplugin/v1beta1"
+)
+
+func TestStaticDiscoverer(t *testing.T) {
+	d := NewStaticDiscoverer([]*MicroDevice{{Name: "micro0", Annotations: map[string]string{"a": "1"}}})
+	devices, err := d.Discover()
+	if err != nil {
+		t.Fatal(err)
+	}
+	devices[0].Annotations["a"] = "2"
+
+	devices, _ = d.Discover()
+	if len(devices) != 1 || devices[0].Annotations["a"] != "1" {
+		t.Errorf("Discover() returned the configured devices instead of copies")
+	}
+}
+
+func TestLoadStaticDevices(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		wantErr string
+	}{
+		{"valid", "devices:\n- name: micro0\n  numaNode: 1\n- name: micro1\n  health: Unhealthy\n", ""},
+		{"no name", "devices:\n- id: abc\n", "has no name"},
+		{"duplicate", "devices:\n- name: micro0\n- name: micro0\n", "duplicate"},
+		{"invalid health", "devices:\n- name: micro0\n  health: Broken\n", "invalid health"},
+		{"unknown field", "devices:\n- name: micro0\n  color: red\n", "decode"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "devices.yaml")
+			if err := os.WriteFile(path, []byte(tt.content), 0644); err != nil {
+				t.Fatal(err)
+			}
+			devices, err := LoadStaticDevices(path)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("LoadStaticDevices() = %v, want error containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if devices[0].Health != deviceapi.Healthy || devices[0].Annotations[NUMAAnnotation] != "1" {
+				t.Errorf("micro0 health = %s, numa = %s, want Healthy and 1", devices[0].Health, devices[0].Annotations[NUMAAnnotation])
+			}
+			if devices[1].Health != deviceapi.Unhealthy {
+				t.Errorf("micro1 health = %s, want Unhealthy", devices[1].Health)
+			}
+		})
+	}
+}
+
+func TestStaticFileDiscovererWatch(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "devices.yaml")
+	// replace the file atomically like config maps so that the watcher
+	// never reads a truncated file
+	writeStatic := func(content string) {
+		t.Helper()
+		tmp := path + ".tmp"
+		if err := os.WriteFile(tmp, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Rename(tmp, path); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writeStatic("devices:\n- name: micro0\n- name: micro1\n")
+
+	d := NewStaticFileDiscoverer(path)
+	devices, err := d.Discover()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := deviceNames(devices); !slices.Equal(got, []string{"micro0", "micro1"}) {
+		t.Fatalf("Discover() = %v, want [micro0 micro1]", got)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	events := make(chan DiscoveryEvent)
+	go d.Watch(ctx, events)
+	// the watcher starts asynchronously, rewrite until it sees the change
+	deadline := time.After(5 * time.Second)
+	for changed := false; !changed; {
+		writeStatic("devices:\n- name: micro1\n- name: micro2\n")
+		select {
+		case event := <-events:
+			if event.Type != DeviceCreated || event.Device.Name != "micro2" {
+				t.Fatalf("event = %s %s, want %s micro2", event.Type, event.Device.Name, DeviceCreated)
+			}
+			changed = true
+		case <-time.After(100 * time.Millisecond):
+		case <-deadline:
+			t.Fatal("no event for the rewritten static devices file")
+		}
+	}
+	expectEvent(t, events, DeviceRemoved, "micro0")
+}
@@ -0,0 +1,214 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/kubernetes"
+	deviceapi "k8s.io/kubelet/pkg/apis/deviceplugin/v1beta1"
+)
+
+// AdmissionPath is the path of the pod validating admission webhook
+const AdmissionPath = "/validate"
+
+// AdmissionController is a validating admission webhook rejecting the
+// pods requesting the plugin resources that can not be fulfilled: more
+// devices than available, a namespace not allowed or a service account
+// without the required labels
+type AdmissionController struct {
+	// Addr is the HTTPS address of the webhook server
+	Addr     string
+	CertFile string
+	KeyFile  string
+
+	// AllowedNamespaces are the namespaces allowed to request devices,
+	// all namespaces are allowed if empty
+	AllowedNamespaces []string
+
+	// ServiceAccountSelector is the label selector the service account
+	// of the pod must match, e.g. micro.example.com/device-access=true
+	ServiceAccountSelector labels.Selector
+
+	client  kubernetes.Interface
+	mu      sync.Mutex
+	servers []*MicroDeviceServer
+	once    sync.Once
+}
+
+// NewAdmissionController creates an admission webhook served at addr
+// with the TLS certificate and key files
+func NewAdmissionController(client kubernetes.Interface, addr, certFile, keyFile string) *AdmissionController {
+	return &AdmissionController{
+		Addr:     addr,
+		CertFile: certFile,
+		KeyFile:  keyFile,
+		client:   client,
+	}
+}
+
+// ParseServiceAccountSelector parses the required service account label
+// selector, an empty selector matches every service account
+func ParseServiceAccountSelector(selector string) (labels.Selector, error) {
+	if selector == "" {
+		return labels.Everything(), nil
+	}
+	return labels.Parse(selector)
+}
+
+// attach validates the resource of the server, plugin shards share the
+// controller
+func (a *AdmissionController) attach(s *MicroDeviceServer) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.servers = append(a.servers, s)
+}
+
+// Handler serves the AdmissionReview requests
+func (a *AdmissionController) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST "+AdmissionPath, a.handleReview)
+	return mux
+}
+
+// Serve runs the HTTPS webhook server until ctx is done, the server is
+// started once for all the plugin shards
+func (a *AdmissionController) Serve(ctx context.Context, logger *slog.Logger) {
+	a.once.Do(func() {
+		srv := &http.Server{Addr: a.Addr, Handler: a.Handler(), ReadHeaderTimeout: 10 * time.Second}
+		go func() {
+			<-ctx.Done()
+			srv.Close()
+		}()
+		logger.Info("admission webhook listening", "addr", a.Addr)
+		if err := srv.ListenAndServeTLS(a.CertFile, a.KeyFile); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			logger.Error("admission webhook server failed", "err", err)
+		}
+	})
+}
+
+func (a *AdmissionController) handleReview(w http.ResponseWriter, r *http.Request) {
+	var review admissionv1.AdmissionReview
+	if err := json.NewDecoder(r.Body).Decode(&review); err != nil {
+		http.Error(w, "invalid admission review: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if review.Request == nil {
+		http.Error(w, "admission review has no request", http.StatusBadRequest)
+		return
+	}
+
+	resp := &admissionv1.AdmissionResponse{UID: review.Request.UID, Allowed: true}
+	if err := a.Validate(r.Context(), review.Request); err != nil {
+		resp.Allowed = false
+		resp.Result = &metav1.Status{
+			Status:  metav1.StatusFailure,
+			Message: err.Error(),
+			Reason:  metav1.StatusReasonForbidden,
+			Code:    http.StatusForbidden,
+		}
+	}
+	review.Response = resp
+	review.Request = nil
+	writeJSON(w, http.StatusOK, &review)
+}
+
+// Validate checks the pod of the admission request, pods not requesting
+// the plugin resources are allowed
+func (a *AdmissionController) Validate(ctx context.Context, req *admissionv1.AdmissionRequest) error {
+	if req.Kind.Kind != "Pod" {
+		return nil
+	}
+	var pod corev1.Pod
+	if err := json.Unmarshal(req.Object.Raw, &pod); err != nil {
+		return fmt.Errorf("decode pod: %w", err)
+	}
+	namespace := pod.Namespace
+	if namespace == "" {
+		namespace = req.Namespace
+	}
+
+	a.mu.Lock()
+	servers := slices.Clone(a.servers)
+	a.mu.Unlock()
+	requested := false
+	for _, s := range servers {
+		count := requestedDevices(&pod, s.resourceName)
+		if count == 0 {
+			continue
+		}
+		requested = true
+		if available := s.availableCount(); count > available {
+			return fmt.Errorf("pod requests %d %s devices, only %d available", count, s.resourceName, available)
+		}
+	}
+	if !requested {
+		return nil
+	}
+
+	if len(a.AllowedNamespaces) > 0 && !slices.Contains(a.AllowedNamespaces, namespace) {
+		return fmt.Errorf("namespace %s is not allowed to request devices, allowed namespaces: %s",
+			namespace, strings.Join(a.AllowedNamespaces, ", "))
+	}
+	if a.ServiceAccountSelector != nil && !a.ServiceAccountSelector.Empty() {
+		name := pod.Spec.ServiceAccountName
+		if name == "" {
+			name = "default"
+		}
+		sa, err := a.client.CoreV1().ServiceAccounts(namespace).Get(ctx, name, metav1.GetOptions{})
+		if err != nil {
+			return fmt.Errorf("get service account %s/%s: %w", namespace, name, err)
+		}
+		if !a.ServiceAccountSelector.Matches(labels.Set(sa.Labels)) {
+			return fmt.Errorf("service account %s/%s does not match the required labels %s",
+				namespace, name, a.ServiceAccountSelector)
+		}
+	}
+	return nil
+}
+
+// requestedDevices returns the number of resource devices requested by
+// the pod, the largest init container request counts if it exceeds the
+// sum of the container requests
+func requestedDevices(pod *corev1.Pod, resource string) int {
+	name := corev1.ResourceName(resource)
+	count := 0
+	for _, c := range pod.Spec.Containers {
+		if q, ok := c.Resources.Limits[name]; ok {
+			count += int(q.Value())
+		}
+	}
+	for _, c := range pod.Spec.InitContainers {
+		if q, ok := c.Resources.Limits[name]; ok {
+			count = max(count, int(q.Value()))
+		}
+	}
+	return count
+}
+
+// availableCount returns the number of healthy devices neither reserved
+// nor allocated
+func (s *MicroDeviceServer) availableCount() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	s.allocMu.Lock()
+	defer s.allocMu.Unlock()
+	n := 0
+	for _, dev := range s.devices {
+		if dev.Health == deviceapi.Healthy && !isReserved(dev) && !s.allocated[dev.ID] && !s.draining {
+			n++
+		}
+	}
+	return n
+}
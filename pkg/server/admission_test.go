@@ -0,0 +1,120 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+const admissionResource = "micro.example.com/device"
+
+func admissionPod(namespace, serviceAccount string, count int64) *corev1.Pod {
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "app", Namespace: namespace},
+		Spec: corev1.PodSpec{
+			ServiceAccountName: serviceAccount,
+			Containers: []corev1.Container{{
+				Name: "app",
+				Resources: corev1.ResourceRequirements{Limits: corev1.ResourceList{
+					admissionResource: *resource.NewQuantity(count, resource.DecimalSI),
+				}},
+			}},
+		},
+	}
+}
+
+func TestAdmissionController(t *testing.T) {
+	client := fake.NewClientset(
+		&corev1.ServiceAccount{ObjectMeta: metav1.ObjectMeta{
+			Name: "default", Namespace: "team-a",
+			Labels: map[string]string{"micro.example.com/device-access": "true"},
+		}},
+		&corev1.ServiceAccount{ObjectMeta: metav1.ObjectMeta{Name: "restricted", Namespace: "team-a"}},
+		&corev1.ServiceAccount{ObjectMeta: metav1.ObjectMeta{
+			Name: "default", Namespace: "team-b",
+			Labels: map[string]string{"micro.example.com/device-access": "true"},
+		}},
+	)
+	selector, err := ParseServiceAccountSelector("micro.example.com/device-access=true")
+	if err != nil {
+		t.Fatal(err)
+	}
+	a := NewAdmissionController(client, "", "", "")
+	a.AllowedNamespaces = []string{"team-a"}
+	a.ServiceAccountSelector = selector
+
+	s, _ := newTestServer(t, WithResourceName(admissionResource), WithAdmission(a))
+	s.addDevice(&MicroDevice{Name: "micro0"})
+	s.addDevice(&MicroDevice{Name: "micro1"})
+
+	srv := httptest.NewTLSServer(a.Handler())
+	t.Cleanup(srv.Close)
+
+	tests := []struct {
+		name    string
+		pod     *corev1.Pod
+		allowed bool
+		message string
+	}{
+		{name: "allowed", pod: admissionPod("team-a", "", 2), allowed: true},
+		{name: "no devices", pod: admissionPod("team-b", "restricted", 0), allowed: true},
+		{name: "over available", pod: admissionPod("team-a", "", 3), message: "pod requests 3 micro.example.com/device devices, only 2 available"},
+		{name: "namespace not allowed", pod: admissionPod("team-b", "", 1), message: "namespace team-b is not allowed"},
+		{name: "service account label", pod: admissionPod("team-a", "restricted", 1), message: "service account team-a/restricted does not match the required labels"},
+		{name: "service account missing", pod: admissionPod("team-a", "missing", 1), message: "get service account team-a/missing"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			raw, err := json.Marshal(tt.pod)
+			if err != nil {
+				t.Fatal(err)
+			}
+			review := admissionv1.AdmissionReview{
+				TypeMeta: metav1.TypeMeta{APIVersion: "admission.k8s.io/v1", Kind: "AdmissionReview"},
+				Request: &admissionv1.AdmissionRequest{
+					UID:       types.UID("uid-" + tt.name),
+					Kind:      metav1.GroupVersionKind{Version: "v1", Kind: "Pod"},
+					Namespace: tt.pod.Namespace,
+					Object:    runtime.RawExtension{Raw: raw},
+				},
+			}
+			body, err := json.Marshal(review)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp, err := srv.Client().Post(srv.URL+AdmissionPath, "application/json", bytes.NewReader(body))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+			if resp.StatusCode != http.StatusOK {
+				t.Fatalf("POST %s = %s", AdmissionPath, resp.Status)
+			}
+
+			var got admissionv1.AdmissionReview
+			if err := json.NewDecoder(resp.Body).Decode(&got); err != nil {
+				t.Fatal(err)
+			}
+			if got.Response == nil || got.Response.UID != review.Request.UID {
+				t.Fatalf("admission response = %+v, want UID %s", got.Response, review.Request.UID)
+			}
+			if got.Response.Allowed != tt.allowed {
+				t.Errorf("allowed = %v, want %v (%v)", got.Response.Allowed, tt.allowed, got.Response.Result)
+			}
+			if tt.message != "" && (got.Response.Result == nil || !strings.Contains(got.Response.Result.Message, tt.message)) {
+				t.Errorf("result = %v, want message containing %q", got.Response.Result, tt.message)
+			}
+		})
+	}
+}
@@ -0,0 +1,218 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	deviceapi "k8s.io/kubelet/pkg/apis/deviceplugin/v1beta1"
+
+	"github.com/kelein/micro-device-plugin/pkg/config"
+)
+
+// cpuAnnotation is the device annotation holding its local CPU, it is
+// populated from the `user.cpu` extended attribute of the device file
+const cpuAnnotation = xattrAnnotationPrefix + "cpu"
+
+// CPUAffinityReader reads the CPUs co-located with a device
+type CPUAffinityReader interface {
+	DeviceCPUs(dev *MicroDevice) ([]int, error)
+}
+
+// SysfsCPUAffinity resolves the cores sharing the device local CPU
+// from `<root>/devices/system/cpu/cpu<N>/topology/core_cpus_list`
+type SysfsCPUAffinity struct {
+	Root string
+}
+
+// DeviceCPUs returns the CPUs co-located with the device local CPU
+func (a SysfsCPUAffinity) DeviceCPUs(dev *MicroDevice) ([]int, error) {
+	cpu, ok := dev.Annotations[cpuAnnotation]
+	if !ok {
+		return nil, nil
+	}
+	if _, err := strconv.Atoi(cpu); err != nil {
+		return nil, fmt.Errorf("invalid device cpu %q: %w", cpu, err)
+	}
+
+	root := a.Root
+	if root == "" {
+		root = "/sys"
+	}
+	file := filepath.Join(root, "devices/system/cpu", "cpu"+cpu, "topology/core_cpus_list")
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	return ParseCPUList(string(data))
+}
+
+// ParseCPUList parses a kernel CPU list such as `0-3,8,10-11`
+func ParseCPUList(list string) ([]int, error) {
+	var cpus []int
+	for _, part := range strings.Split(strings.TrimSpace(list), ",") {
+		if part == "" {
+			continue
+		}
+		lo, hi, isRange := strings.Cut(part, "-")
+		start, err := strconv.Atoi(lo)
+		if err != nil {
+			return nil, fmt.Errorf("invalid cpu list %q: %w", list, err)
+		}
+		end := start
+		if isRange {
+			if end, err = strconv.Atoi(hi); err != nil {
+				return nil, fmt.Errorf("invalid cpu list %q: %w", list, err)
+			}
+		}
+		if end < start {
+			return nil, fmt.Errorf("invalid cpu range %q", part)
+		}
+		for cpu := start; cpu <= end; cpu++ {
+			cpus = append(cpus, cpu)
+		}
+	}
+	return cpus, nil
+}
+
+// affinityScore counts the device CPUs overlapping the preferred CPUs
+func (s *MicroDeviceServer) affinityScore(dev *MicroDevice) int {
+	if s.affinity == nil || len(s.preferredCPUs) == 0 {
+		return 0
+	}
+	cpus, err := s.affinity.DeviceCPUs(dev)
+	if err != nil {
+		return 0
+	}
+	score := 0
+	for _, cpu := range cpus {
+		if s.preferredCPUs[cpu] {
+			score++
+		}
+	}
+	return score
+}
+
+// preferredDevices chooses the devices of a container allocation, the
+// must-include devices first, then the available devices selected by the
+// allocation strategy or with the best device scorer score
+func (s *MicroDeviceServer) preferredDevices(ctx context.Context, req *deviceapi.ContainerPreferredAllocationRequest) []string {
+	size := int(req.AllocationSize)
+	chosen := make([]string, 0, size)
+	picked := make(map[string]bool)
+	for _, id := range req.MustIncludeDeviceIDs {
+		if len(chosen) >= size {
+			break
+		}
+		chosen = append(chosen, id)
+		picked[id] = true
+	}
+
+	s.mu.RLock()
+	byID := make(map[string]*MicroDevice, len(s.devices))
+	for _, dev := range s.devices {
+		byID[dev.ID] = dev
+	}
+	s.mu.RUnlock()
+
+	filter, err := CapabilityFilterFromContext(ctx)
+	if err != nil {
+		s.requestLogger(ctx).Warn("ignore invalid capability constraints", "err", err)
+	}
+
+	// the must-include devices stay candidates so that scorers can
+	// prefer devices close to them
+	var candidates []*MicroDevice
+	for _, id := range req.AvailableDeviceIDs {
+		dev, ok := byID[id]
+		if !ok {
+			dev = &MicroDevice{ID: id}
+		}
+		if !picked[id] && !filter.Match(dev.Capabilities) {
+			continue
+		}
+		candidates = append(candidates, dev)
+	}
+	if s.affinityMap != nil {
+		candidates = affinityCandidates(candidates, req.MustIncludeDeviceIDs, size)
+	}
+
+	if s.strategy != nil {
+		return s.selectDevices(ctx, chosen, picked, candidates, size)
+	}
+
+	scores := make([]float64, len(candidates))
+	if scorer := s.deviceScorer(); scorer != nil {
+		containerReq := &deviceapi.PreferredAllocationRequest{
+			ContainerRequests: []*deviceapi.ContainerPreferredAllocationRequest{req},
+		}
+		got, err := scorer.Score(candidates, containerReq)
+		switch {
+		case err != nil:
+			s.requestLogger(ctx).Error("score preferred devices failed", "err", err)
+		case len(got) != len(candidates):
+			s.requestLogger(ctx).Error("device scorer returned wrong number of scores", "scores", len(got), "candidates", len(candidates))
+		default:
+			scores = got
+		}
+	}
+
+	order := make([]int, len(candidates))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		return scores[order[i]] > scores[order[j]]
+	})
+
+	for _, i := range order {
+		if len(chosen) >= size {
+			break
+		}
+		if id := candidates[i].ID; !picked[id] {
+			chosen = append(chosen, id)
+			picked[id] = true
+		}
+	}
+	return chosen
+}
+
+// selectDevices completes the chosen devices with the devices selected by
+// the allocation strategy
+func (s *MicroDeviceServer) selectDevices(ctx context.Context, chosen []string, picked map[string]bool, candidates []*MicroDevice, size int) []string {
+	var remaining []*MicroDevice
+	for _, dev := range candidates {
+		if !picked[dev.ID] {
+			remaining = append(remaining, dev)
+		}
+	}
+
+	selected, err := s.strategy.Select(remaining, min(size-len(chosen), len(remaining)))
+	if err != nil {
+		s.requestLogger(ctx).Error("select preferred devices failed", "err", err)
+		return chosen
+	}
+	for _, dev := range selected {
+		chosen = append(chosen, dev.ID)
+	}
+	return chosen
+}
+
+// deviceScorer returns the configured scorer, falling back to the CPU
+// affinity scorer when CPU affinity is enabled
+func (s *MicroDeviceServer) deviceScorer() DeviceScorer {
+	if _, ok := s.scorer.(NUMAScorer); ok && !s.featureGates.IsEnabled(config.NUMATopology) {
+		return nil
+	}
+	if s.scorer != nil {
+		return s.scorer
+	}
+	if s.affinity != nil {
+		return cpuAffinityScorer{s: s}
+	}
+	return nil
+}
@@ -0,0 +1,118 @@
+package server
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"testing"
+
+	deviceapi "k8s.io/kubelet/pkg/apis/deviceplugin/v1beta1"
+)
+
+// sysfsRoot creates a sysfs tree of 8 CPUs on two dies, the cores 0-3
+// and 4-7 are co-located
+func sysfsRoot(t *testing.T) string {
+	t.Helper()
+	root := t.TempDir()
+	for cpu := range 8 {
+		list := "0-3"
+		if cpu >= 4 {
+			list = "4-7"
+		}
+		dir := filepath.Join(root, "devices/system/cpu", "cpu"+strconv.Itoa(cpu), "topology")
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, "core_cpus_list"), []byte(list+"\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return root
+}
+
+func TestParseCPUList(t *testing.T) {
+	tests := []struct {
+		list    string
+		want    []int
+		wantErr bool
+	}{
+		{list: "0-3\n", want: []int{0, 1, 2, 3}},
+		{list: "0-1,8,10-11", want: []int{0, 1, 8, 10, 11}},
+		{list: "5", want: []int{5}},
+		{list: "", want: nil},
+		{list: "3-1", wantErr: true},
+		{list: "a-b", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := ParseCPUList(tt.list)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseCPUList(%q) error = %v, wantErr %v", tt.list, err, tt.wantErr)
+			continue
+		}
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("ParseCPUList(%q) = %v, want %v", tt.list, got, tt.want)
+		}
+	}
+}
+
+func TestSysfsCPUAffinity(t *testing.T) {
+	reader := SysfsCPUAffinity{Root: sysfsRoot(t)}
+	tests := []struct {
+		name    string
+		cpu     string // precondition: local CPU annotation, empty for none
+		want    []int
+		wantErr bool
+	}{
+		{name: "first die", cpu: "2", want: []int{0, 1, 2, 3}},
+		{name: "second die", cpu: "5", want: []int{4, 5, 6, 7}},
+		{name: "no local cpu"},
+		{name: "invalid cpu", cpu: "x", wantErr: true},
+		{name: "unknown cpu", cpu: "64", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dev := &MicroDevice{Name: "micro0", Annotations: map[string]string{}}
+			if tt.cpu != "" {
+				dev.Annotations[cpuAnnotation] = tt.cpu
+			}
+			got, err := reader.DeviceCPUs(dev)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("DeviceCPUs() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("DeviceCPUs() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCPUAffinityPreferredAllocation(t *testing.T) {
+	// precondition: the request prefers the CPUs 0-3, micro1 and micro3
+	// are co-located with them
+	s, _ := newTestServer(t, WithCPUAffinity(SysfsCPUAffinity{Root: sysfsRoot(t)}, []int{0, 1, 2, 3}))
+	var ids []string
+	for i, cpu := range []string{"4", "0", "6", "3"} {
+		ids = append(ids, s.addDevice(&MicroDevice{
+			Name:        "micro" + strconv.Itoa(i),
+			Annotations: map[string]string{cpuAnnotation: cpu},
+		}))
+	}
+
+	req := &deviceapi.PreferredAllocationRequest{
+		ContainerRequests: []*deviceapi.ContainerPreferredAllocationRequest{{
+			AvailableDeviceIDs: ids,
+			AllocationSize:     2,
+		}},
+	}
+	resp, err := newPluginClient(t, s).GetPreferredAllocation(context.Background(), req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := slices.Sorted(slices.Values(resp.ContainerResponses[0].DeviceIDs))
+	want := slices.Sorted(slices.Values([]string{ids[1], ids[3]}))
+	if !slices.Equal(got, want) {
+		t.Errorf("preferred devices = %v, want the co-located devices %v", got, want)
+	}
+}
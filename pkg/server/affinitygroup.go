@@ -0,0 +1,120 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+)
+
+// affinityGroupAnnotation holds the affinity group of a device
+const affinityGroupAnnotation = "micro.plugin/affinity-group"
+
+// AffinityGroup is a set of devices sharing an interconnect, such as
+// NVLink or a PCIe switch, preferred together for batch allocations
+type AffinityGroup struct {
+	ID        string   `json:"id"`
+	DeviceIDs []string `json:"deviceIDs"`
+}
+
+// affinityGroupSpec is a group of the affinity map file, the devices are
+// listed by name since their IDs are derived
+type affinityGroupSpec struct {
+	ID      string   `json:"id"`
+	Devices []string `json:"devices"`
+}
+
+// LoadAffinityMap reads the JSON affinity map file listing the device
+// names of every group, e.g. [{"id": "nvlink0", "devices": ["micro0",
+// "micro1"]}], and returns the group of every device name
+func LoadAffinityMap(path string) (map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var specs []affinityGroupSpec
+	if err := json.Unmarshal(data, &specs); err != nil {
+		return nil, fmt.Errorf("decode affinity map %s: %w", path, err)
+	}
+	groups := make(map[string]string)
+	for i, spec := range specs {
+		if spec.ID == "" {
+			return nil, fmt.Errorf("affinity group %d has no id", i)
+		}
+		for _, name := range spec.Devices {
+			if g, ok := groups[name]; ok {
+				return nil, fmt.Errorf("device %s is in affinity groups %s and %s", name, g, spec.ID)
+			}
+			groups[name] = spec.ID
+		}
+	}
+	return groups, nil
+}
+
+// applyAffinityGroup annotates the device with its affinity group
+func (s *MicroDeviceServer) applyAffinityGroup(dev *MicroDevice) {
+	if g, ok := s.affinityMap[dev.Name]; ok {
+		dev.Annotations[affinityGroupAnnotation] = g
+	}
+}
+
+// AffinityGroups returns the affinity groups of the known devices sorted
+// by group ID
+func (s *MicroDeviceServer) AffinityGroups() []AffinityGroup {
+	s.mu.RLock()
+	members := make(map[string][]string)
+	for _, dev := range s.devices {
+		if g := dev.Annotations[affinityGroupAnnotation]; g != "" {
+			members[g] = append(members[g], dev.ID)
+		}
+	}
+	s.mu.RUnlock()
+
+	groups := make([]AffinityGroup, 0, len(members))
+	for id, ids := range members {
+		sort.Strings(ids)
+		groups = append(groups, AffinityGroup{ID: id, DeviceIDs: ids})
+	}
+	sort.Slice(groups, func(i, j int) bool { return groups[i].ID < groups[j].ID })
+	return groups
+}
+
+// affinityCandidates narrows the candidates to the smallest affinity
+// group holding size of them and the must-include devices, the
+// candidates are kept if no group fits
+func affinityCandidates(candidates []*MicroDevice, must []string, size int) []*MicroDevice {
+	members := make(map[string][]*MicroDevice)
+	for _, dev := range candidates {
+		if g := dev.Annotations[affinityGroupAnnotation]; g != "" {
+			members[g] = append(members[g], dev)
+		}
+	}
+
+	best := ""
+	for g, devs := range members {
+		if len(devs) < size || !containsAll(devs, must) {
+			continue
+		}
+		if best == "" || len(devs) < len(members[best]) || len(devs) == len(members[best]) && g < best {
+			best = g
+		}
+	}
+	if best == "" {
+		return candidates
+	}
+	return members[best]
+}
+
+// containsAll reports whether the devices include all ids
+func containsAll(devices []*MicroDevice, ids []string) bool {
+	have := make(map[string]bool, len(devices))
+	for _, dev := range devices {
+		have[dev.ID] = true
+	}
+	for _, id := range ids {
+		if !have[id] {
+			return false
+		}
+	}
+	return true
+}
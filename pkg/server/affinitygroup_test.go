@@ -0,0 +1,120 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+
+	deviceapi "k8s.io/kubelet/pkg/apis/deviceplugin/v1beta1"
+)
+
+func writeAffinityMap(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "affinity.json")
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestAffinityGroupPreferredAllocation(t *testing.T) {
+	groups, err := LoadAffinityMap(writeAffinityMap(t, `[
+		{"id": "nvlink0", "devices": ["micro2", "micro3", "micro4", "micro5"]},
+		{"id": "nvlink1", "devices": ["micro6"]}
+	]`))
+	if err != nil {
+		t.Fatalf("LoadAffinityMap() = %v", err)
+	}
+	s, _ := newTestServer(t, WithAffinityMap(groups), WithRESTAPI(true))
+	names := []string{"micro0", "micro1", "micro2", "micro3", "micro4", "micro5", "micro6"}
+	var available []string
+	for _, name := range names {
+		s.addDevice(&MicroDevice{Name: name})
+		available = append(available, deviceID(name))
+	}
+
+	group := map[string]bool{}
+	for _, name := range []string{"micro2", "micro3", "micro4", "micro5"} {
+		group[deviceID(name)] = true
+	}
+	for _, tt := range []struct {
+		name string
+		must []string
+	}{
+		{name: "no must include"},
+		{name: "must include group member", must: []string{deviceID("micro4")}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := s.GetPreferredAllocation(context.Background(), &deviceapi.PreferredAllocationRequest{
+				ContainerRequests: []*deviceapi.ContainerPreferredAllocationRequest{{
+					AvailableDeviceIDs:   available,
+					MustIncludeDeviceIDs: tt.must,
+					AllocationSize:       2,
+				}},
+			})
+			if err != nil {
+				t.Fatalf("GetPreferredAllocation() = %v", err)
+			}
+			got := resp.ContainerResponses[0].DeviceIDs
+			if len(got) != 2 || !group[got[0]] || !group[got[1]] {
+				t.Errorf("preferred devices = %v, want a pair of affinity group nvlink0", got)
+			}
+			for _, id := range tt.must {
+				if got[0] != id {
+					t.Errorf("preferred devices = %v, want must include %s first", got, id)
+				}
+			}
+		})
+	}
+
+	want := []AffinityGroup{
+		{ID: "nvlink0", DeviceIDs: sortedIDs("micro2", "micro3", "micro4", "micro5")},
+		{ID: "nvlink1", DeviceIDs: []string{deviceID("micro6")}},
+	}
+	if got := s.AffinityGroups(); !reflect.DeepEqual(got, want) {
+		t.Errorf("AffinityGroups() = %v, want %v", got, want)
+	}
+
+	rec := httptest.NewRecorder()
+	s.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/devices?name=micro3", nil))
+	var infos []DeviceInfo
+	if err := json.Unmarshal(rec.Body.Bytes(), &infos); err != nil {
+		t.Fatalf("decode devices: %v", err)
+	}
+	if len(infos) != 1 || infos[0].AffinityGroup != "nvlink0" {
+		t.Errorf("GET /api/v1/devices = %+v, want micro3 in affinity group nvlink0", infos)
+	}
+}
+
+func sortedIDs(names ...string) []string {
+	ids := make([]string, len(names))
+	for i, name := range names {
+		ids[i] = deviceID(name)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+func TestAffinityGroupTooSmall(t *testing.T) {
+	candidates := []*MicroDevice{
+		{ID: "a", Annotations: map[string]string{affinityGroupAnnotation: "g0"}},
+		{ID: "b"},
+		{ID: "c"},
+	}
+	if got := affinityCandidates(candidates, nil, 2); len(got) != 3 {
+		t.Errorf("affinityCandidates() kept %d candidates, want all 3 without a fitting group", len(got))
+	}
+}
+
+func TestLoadAffinityMapRejectsDuplicates(t *testing.T) {
+	path := writeAffinityMap(t, `[{"id": "g0", "devices": ["micro0"]}, {"id": "g1", "devices": ["micro0"]}]`)
+	if _, err := LoadAffinityMap(path); err == nil {
+		t.Error("LoadAffinityMap() error = nil, want error for a device in two groups")
+	}
+}
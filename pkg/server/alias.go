@@ -0,0 +1,81 @@
+package server
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	deviceapi "k8s.io/kubelet/pkg/apis/deviceplugin/v1beta1"
+)
+
+// AllocationRegistry records the resource name each device is allocated
+// through, the servers of a resource and its aliases share one registry
+// so that a device is never allocated through two names at once
+type AllocationRegistry struct {
+	mu     sync.Mutex
+	owners map[string]string
+}
+
+// NewAllocationRegistry creates an empty allocation registry
+func NewAllocationRegistry() *AllocationRegistry {
+	return &AllocationRegistry{owners: make(map[string]string)}
+}
+
+// Acquire records the devices allocated through resource, no device is
+// recorded if one of them is allocated through another resource name
+func (r *AllocationRegistry) Acquire(resource string, ids []string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, id := range ids {
+		if owner, ok := r.owners[id]; ok && owner != resource {
+			return fmt.Errorf("device %s is allocated to resource %s", id, owner)
+		}
+	}
+	for _, id := range ids {
+		r.owners[id] = resource
+	}
+	return nil
+}
+
+// Release drops the devices allocated through resource
+func (r *AllocationRegistry) Release(resource string, ids []string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, id := range ids {
+		if r.owners[id] == resource {
+			delete(r.owners, id)
+		}
+	}
+}
+
+// Owner returns the resource name the device is allocated through, empty
+// if the device is not allocated
+func (r *AllocationRegistry) Owner(id string) string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.owners[id]
+}
+
+// withAlias turns the server into the server of alias i of its resource,
+// listening on `micro-alias-<i>.sock` and sharing the allocations of reg
+func withAlias(i int, alias string, reg *AllocationRegistry) Option {
+	return func(s *MicroDeviceServer) {
+		s.socketName = fmt.Sprintf("%s-alias-%d.sock", strings.TrimSuffix(s.socketName, ".sock"), i)
+		s.resourceName = alias
+		s.resourceAliases = nil
+		s.allocRegistry = reg
+	}
+}
+
+// acquireDevices records the devices of the allocation in the registry
+// shared with the resource aliases
+func (s *MicroDeviceServer) acquireDevices(reqs []*deviceapi.ContainerAllocateRequest) error {
+	if s.allocRegistry == nil {
+		return nil
+	}
+	var ids []string
+	for _, req := range reqs {
+		ids = append(ids, req.DevicesIDs...)
+	}
+	return s.allocRegistry.Acquire(s.resourceName, ids)
+}
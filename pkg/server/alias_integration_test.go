@@ -0,0 +1,82 @@
+//go:build integration
+
+package server
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	deviceapi "k8s.io/kubelet/pkg/apis/deviceplugin/v1beta1"
+
+	"github.com/kelein/micro-device-plugin/pkg/testutil"
+)
+
+// allocateOn calls Allocate with the device through the plugin socket
+func allocateOn(t *testing.T, socket, id string) error {
+	t.Helper()
+	conn, err := dialUnix(socket, 5*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	req := testutil.NewMockAllocateRequest().WithDeviceIDs(id).Build()
+	_, err = deviceapi.NewDevicePluginClient(conn).Allocate(context.Background(), req)
+	return err
+}
+
+func TestResourceAliasSharesAllocations(t *testing.T) {
+	dir := t.TempDir()
+	kubelet := startFakeKubelet(t, dir)
+	m, err := NewPluginManager(1,
+		WithPluginPath(dir),
+		WithDevicePath(deviceDir(t, 2)),
+		WithWatchdogTimeout(0),
+		WithHealthInterval(0),
+		WithResourceName("vendor.com/new-device"),
+		WithResourceAliases("vendor.com/old-device"),
+	)
+	if err != nil {
+		t.Fatalf("NewPluginManager() error = %v", err)
+	}
+	t.Cleanup(m.Stop)
+	if err := m.Run(); err != nil {
+		t.Fatalf("Run() = %v", err)
+	}
+	if err := m.RegisterToKubelet(); err != nil {
+		t.Fatalf("RegisterToKubelet() = %v", err)
+	}
+
+	got := make(map[string]string)
+	for _, req := range kubelet.Requests() {
+		got[req.Endpoint] = req.ResourceName
+	}
+	if got["micro.sock"] != "vendor.com/new-device" || got["micro-alias-0.sock"] != "vendor.com/old-device" {
+		t.Fatalf("registered endpoints = %v, want the resource and its alias", got)
+	}
+
+	servers := m.Servers()
+	id := deviceID("micro0")
+	if err := allocateOn(t, filepath.Join(dir, "micro-alias-0.sock"), id); err != nil {
+		t.Fatalf("Allocate() through the alias = %v", err)
+	}
+	if owner := servers[0].allocRegistry.Owner(id); owner != "vendor.com/old-device" {
+		t.Errorf("registry owner of %s = %q, want the alias", id, owner)
+	}
+
+	err = allocateOn(t, filepath.Join(dir, "micro.sock"), id)
+	if status.Code(err) != codes.FailedPrecondition {
+		t.Errorf("Allocate() of the alias device through the resource = %v, want FailedPrecondition", err)
+	}
+	if err := allocateOn(t, filepath.Join(dir, "micro.sock"), deviceID("micro1")); err != nil {
+		t.Errorf("Allocate() of a free device = %v", err)
+	}
+
+	servers[1].releaseDevices([]string{id}, "pod")
+	if err := allocateOn(t, filepath.Join(dir, "micro.sock"), id); err != nil {
+		t.Errorf("Allocate() after the alias released the device = %v", err)
+	}
+}
@@ -0,0 +1,127 @@
+package server
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"github.com/kelein/micro-device-plugin/pkg/testutil"
+)
+
+func TestAllocationRegistry(t *testing.T) {
+	r := NewAllocationRegistry()
+	if err := r.Acquire("vendor.com/old", []string{"a", "b"}); err != nil {
+		t.Fatalf("Acquire() = %v", err)
+	}
+	if err := r.Acquire("vendor.com/old", []string{"a"}); err != nil {
+		t.Errorf("Acquire() through the owning resource = %v", err)
+	}
+	if err := r.Acquire("vendor.com/new", []string{"c", "b"}); err == nil {
+		t.Error("Acquire() of a device held by another resource succeeded")
+	}
+	if owner := r.Owner("c"); owner != "" {
+		t.Errorf("rejected Acquire() recorded c for %q", owner)
+	}
+
+	r.Release("vendor.com/new", []string{"a"})
+	if owner := r.Owner("a"); owner != "vendor.com/old" {
+		t.Errorf("Release() by another resource dropped a, owner = %q", owner)
+	}
+	r.Release("vendor.com/old", []string{"a", "b"})
+	if err := r.Acquire("vendor.com/new", []string{"a", "b"}); err != nil {
+		t.Errorf("Acquire() after Release() = %v", err)
+	}
+}
+
+func TestPluginManagerResourceAliases(t *testing.T) {
+	m, err := NewPluginManager(2, WithWatchdogTimeout(0), WithMetrics(prometheus.NewRegistry()),
+		WithPluginPath(t.TempDir()), WithResourceName("vendor.com/new"), WithResourceAliases("vendor.com/old"))
+	if err != nil {
+		t.Fatalf("NewPluginManager() error = %v", err)
+	}
+	t.Cleanup(m.Stop)
+
+	want := []struct{ resource, socket string }{
+		{"vendor.com/new-0", "micro-0.sock"},
+		{"vendor.com/old-0", "micro-alias-0-0.sock"},
+		{"vendor.com/new-1", "micro-1.sock"},
+		{"vendor.com/old-1", "micro-alias-0-1.sock"},
+	}
+	servers := m.Servers()
+	if len(servers) != len(want) {
+		t.Fatalf("manager has %d servers, want %d", len(servers), len(want))
+	}
+	for i, s := range servers {
+		if s.resourceName != want[i].resource || s.socketName != want[i].socket {
+			t.Errorf("server %d = %s on %s, want %s on %s", i, s.resourceName, s.socketName, want[i].resource, want[i].socket)
+		}
+	}
+	if servers[0].allocRegistry == nil || servers[0].allocRegistry != servers[1].allocRegistry {
+		t.Error("shard 0 and its alias do not share an allocation registry")
+	}
+	if servers[0].allocRegistry == servers[2].allocRegistry {
+		t.Error("shards share an allocation registry")
+	}
+}
+
+func TestResourceAliasReleaseOnPodDeletion(t *testing.T) {
+	client := fake.NewClientset(devicePod("app", "vendor.com/new"))
+	id := deviceID("micro0")
+	m, err := NewPluginManager(1, WithWatchdogTimeout(0), WithMetrics(prometheus.NewRegistry()),
+		WithPluginPath(t.TempDir()), WithResourceName("vendor.com/new"), WithResourceAliases("vendor.com/old"),
+		WithDeallocateHook(client, "node1", podLookup{"app": {id}}))
+	if err != nil {
+		t.Fatalf("NewPluginManager() error = %v", err)
+	}
+	t.Cleanup(m.Stop)
+	servers := m.Servers()
+	for _, s := range servers {
+		s.addDevice(&MicroDevice{Name: "micro0"})
+	}
+	req := testutil.NewMockAllocateRequest().WithDeviceIDs(id).Build()
+
+	// precondition: the device is allocated through the resource name
+	if _, err := servers[0].Allocate(context.Background(), req); err != nil {
+		t.Fatalf("Allocate() through the resource = %v", err)
+	}
+	if _, err := servers[1].Allocate(context.Background(), req); err == nil {
+		t.Fatal("Allocate() through the alias of a device held by the resource succeeded")
+	}
+
+	// action: the pod holding the device is deleted
+	servers[0].watchPods()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		servers[0].allocMu.Lock()
+		_, tracked := servers[0].podDevices["app-uid"]
+		servers[0].allocMu.Unlock()
+		if tracked {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("pod informer did not track the pod devices")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if err := client.CoreV1().Pods("default").Delete(context.Background(), "app", metav1.DeleteOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	for servers[0].allocRegistry.Owner(id) != "" {
+		if time.Now().After(deadline) {
+			t.Fatal("pod deletion did not release the device ownership")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// expected: the released device is allocated through the alias
+	if _, err := servers[1].Allocate(context.Background(), req); err != nil {
+		t.Errorf("Allocate() through the alias after the release = %v", err)
+	}
+	if owner := servers[1].allocRegistry.Owner(id); owner != "vendor.com/old" {
+		t.Errorf("registry owner of %s = %q, want the alias", id, owner)
+	}
+}
@@ -0,0 +1,80 @@
+package server
+
+import (
+	"bufio"
+	"os"
+	"runtime"
+	"strings"
+	"sync"
+)
+
+// CPUInfoPath is the kernel cpuinfo file
+const CPUInfoPath = "/proc/cpuinfo"
+
+// ArchDetector detects the node architecture from the cpuinfo file,
+// falling back to the architecture the plugin was built for
+type ArchDetector struct {
+	path string
+	once sync.Once
+	arch string
+}
+
+// NewArchDetector creates an architecture detector reading path
+func NewArchDetector(path string) *ArchDetector {
+	return &ArchDetector{path: path}
+}
+
+// Architecture returns the node architecture in GOARCH notation,
+// the cpuinfo file is only read once
+func (d *ArchDetector) Architecture() string {
+	d.once.Do(func() {
+		d.arch = d.detect()
+	})
+	return d.arch
+}
+
+func (d *ArchDetector) detect() string {
+	f, err := os.Open(d.path)
+	if err != nil {
+		return runtime.GOARCH
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		key, value, ok := strings.Cut(scanner.Text(), ":")
+		if !ok {
+			continue
+		}
+		if arch := cpuinfoArch(strings.TrimSpace(key), strings.TrimSpace(value)); arch != "" {
+			return arch
+		}
+	}
+	return runtime.GOARCH
+}
+
+// cpuinfoArch maps an identifying cpuinfo field to the architecture,
+// it returns empty for the fields without architecture hints
+func cpuinfoArch(key, value string) string {
+	switch key {
+	case "vendor_id":
+		if strings.HasPrefix(value, "IBM/S390") {
+			return "s390x"
+		}
+		return "amd64"
+	case "CPU architecture":
+		if value == "8" || strings.HasPrefix(value, "AArch64") {
+			return "arm64"
+		}
+		return "arm"
+	case "isa":
+		if strings.HasPrefix(value, "rv64") {
+			return "riscv64"
+		}
+	case "cpu":
+		if strings.HasPrefix(value, "POWER") {
+			return "ppc64le"
+		}
+	}
+	return ""
+}
@@ -0,0 +1,72 @@
+package server
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/kelein/micro-device-plugin/pkg/config"
+	"github.com/kelein/micro-device-plugin/pkg/testing/assert"
+	"github.com/kelein/micro-device-plugin/pkg/testutil"
+)
+
+// cpuinfo writes a mock cpuinfo file with content
+func cpuinfo(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "cpuinfo")
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+const arm64CPUInfo = "processor\t: 0\nBogoMIPS\t: 50.00\nCPU implementer\t: 0x41\nCPU architecture: 8\n"
+
+func TestArchDetector(t *testing.T) {
+	tests := []struct {
+		name    string
+		cpuinfo string // precondition: cpuinfo content, empty for no file
+		want    string
+	}{
+		{name: "amd64", cpuinfo: "processor\t: 0\nvendor_id\t: GenuineIntel\ncpu family\t: 6\n", want: "amd64"},
+		{name: "arm64", cpuinfo: arm64CPUInfo, want: "arm64"},
+		{name: "arm", cpuinfo: "processor\t: 0\nCPU architecture: 7\n", want: "arm"},
+		{name: "s390x", cpuinfo: "vendor_id       : IBM/S390\n", want: "s390x"},
+		{name: "riscv64", cpuinfo: "processor\t: 0\nisa\t\t: rv64imafdc\n", want: "riscv64"},
+		{name: "ppc64le", cpuinfo: "processor\t: 0\ncpu\t\t: POWER9 (architected)\n", want: "ppc64le"},
+		{name: "no hints", cpuinfo: "processor\t: 0\n", want: runtime.GOARCH},
+		{name: "no cpuinfo", want: runtime.GOARCH},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "missing")
+			if tt.cpuinfo != "" {
+				path = cpuinfo(t, tt.cpuinfo)
+			}
+			if got := NewArchDetector(path).Architecture(); got != tt.want {
+				t.Errorf("Architecture() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestArchDevicePath(t *testing.T) {
+	armPath := t.TempDir()
+	cfg := config.Default()
+	cfg.PluginPath = t.TempDir()
+	cfg.DevicePath = t.TempDir()
+	cfg.ArchDevicePaths = map[string]string{"arm64": armPath}
+	s, _ := newTestServer(t, WithConfig(cfg), WithArchDetector(NewArchDetector(cpuinfo(t, arm64CPUInfo))))
+
+	if s.devicePath != armPath {
+		t.Errorf("device path = %s, want the arm64 path %s", s.devicePath, armPath)
+	}
+	id := s.addDevice(&MicroDevice{Name: "micro0"})
+	resp, err := s.Allocate(context.Background(), testutil.NewMockAllocateRequest().WithDeviceIDs(id).Build())
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.AssertAllocateResponse(t, resp, map[string]string{"MICRO_NODE_ARCH": "arm64"}, nil)
+}
@@ -0,0 +1,130 @@
+package server
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	deviceapi "k8s.io/kubelet/pkg/apis/deviceplugin/v1beta1"
+)
+
+const (
+	// attestationFailedAnnotation marks a device failing the attestation
+	attestationFailedAnnotation = "micro.plugin/attestation-failed"
+
+	// attestedByAnnotation holds the attestation method of a device
+	attestedByAnnotation = "micro.plugin/attested-by"
+
+	// SignatureSuffix is the suffix of the device signature sidecar files,
+	// they are not advertised as devices
+	SignatureSuffix = ".sig"
+)
+
+// Attestor verifies the authenticity of a device file before the device
+// is advertised to kubelet, the metadata is added to the device
+// annotations
+type Attestor interface {
+	Attest(path string) (valid bool, metadata map[string]string, err error)
+}
+
+// HMACFileAttestor verifies the hex encoded HMAC-SHA256 signature of the
+// device file content stored in the `<path>.sig` sidecar file, the
+// signature must exist before the device file is discovered
+type HMACFileAttestor struct {
+	Key []byte
+
+	// Require fails the devices without a signature file, they pass
+	// otherwise and only wrong signatures fail
+	Require bool
+}
+
+// NewHMACFileAttestor creates an HMAC attestor with the signing key
+func NewHMACFileAttestor(key []byte, require bool) *HMACFileAttestor {
+	return &HMACFileAttestor{Key: key, Require: require}
+}
+
+// LoadAttestationKey reads the HMAC key of the key file, the trailing
+// whitespace is trimmed
+func LoadAttestationKey(path string) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	key := []byte(strings.TrimRight(string(data), " \t\r\n"))
+	if len(key) == 0 {
+		return nil, fmt.Errorf("attestation key file %s is empty", path)
+	}
+	return key, nil
+}
+
+// Attest compares the HMAC of the device file with its signature file
+func (a *HMACFileAttestor) Attest(path string) (bool, map[string]string, error) {
+	data, err := os.ReadFile(path + SignatureSuffix)
+	if errors.Is(err, os.ErrNotExist) && !a.Require {
+		return true, nil, nil
+	}
+	if err != nil {
+		return false, nil, fmt.Errorf("read device signature: %w", err)
+	}
+	sig, err := hex.DecodeString(strings.TrimSpace(string(data)))
+	if err != nil {
+		return false, nil, fmt.Errorf("decode device signature: %w", err)
+	}
+
+	mac, err := a.Sign(path)
+	if err != nil {
+		return false, nil, err
+	}
+	if !hmac.Equal(sig, mac) {
+		return false, nil, nil
+	}
+	return true, map[string]string{attestedByAnnotation: "hmac-sha256"}, nil
+}
+
+// Sign returns the HMAC-SHA256 of the device file content
+func (a *HMACFileAttestor) Sign(path string) ([]byte, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	h := hmac.New(sha256.New, a.Key)
+	if _, err := io.Copy(h, f); err != nil {
+		return nil, fmt.Errorf("read device file: %w", err)
+	}
+	return h.Sum(nil), nil
+}
+
+// attest verifies the device with the attestor, a failing device is
+// marked unhealthy and annotated
+func (s *MicroDeviceServer) attest(dev *MicroDevice) {
+	if s.attestor == nil || dev.Path == "" {
+		return
+	}
+
+	valid, metadata, err := s.attestor.Attest(dev.Path)
+	if err != nil {
+		s.logger.Warn("device attestation failed", "name", dev.Name, "err", err)
+	}
+	if err != nil || !valid {
+		s.logger.Warn("device failed attestation, mark unhealthy", "name", dev.Name)
+		dev.Health = deviceapi.Unhealthy
+		dev.Annotations[attestationFailedAnnotation] = "true"
+		return
+	}
+	delete(dev.Annotations, attestationFailedAnnotation)
+	for k, v := range metadata {
+		dev.Annotations[k] = v
+	}
+}
+
+// attestationFailed reports whether the device failed the attestation
+func attestationFailed(dev *MicroDevice) bool {
+	return dev.Annotations[attestationFailedAnnotation] == "true"
+}
@@ -0,0 +1,100 @@
+package server
+
+import (
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/kelein/micro-device-plugin/pkg/testing/assert"
+)
+
+// writeDevice creates the device file name in dir with its signature
+// file holding sig if not empty
+func writeDevice(t *testing.T, dir, name, sig string) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, []byte("device "+name), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if sig != "" {
+		if err := os.WriteFile(path+SignatureSuffix, []byte(sig+"\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return path
+}
+
+func TestHMACFileAttestor(t *testing.T) {
+	dir := t.TempDir()
+	a := NewHMACFileAttestor([]byte("secret"), false)
+	signed := writeDevice(t, dir, "signed", "")
+	mac, err := a.Sign(signed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeDevice(t, dir, "signed", hex.EncodeToString(mac))
+	writeDevice(t, dir, "forged", hex.EncodeToString(make([]byte, len(mac))))
+	writeDevice(t, dir, "garbled", "not-hex")
+	writeDevice(t, dir, "unsigned", "")
+
+	tests := []struct {
+		name    string
+		require bool
+		valid   bool
+		wantErr bool
+	}{
+		{name: "signed", valid: true},
+		{name: "forged"},
+		{name: "garbled", wantErr: true},
+		{name: "unsigned", valid: true},
+		{name: "unsigned", require: true, wantErr: true},
+	}
+	for _, tt := range tests {
+		a.Require = tt.require
+		valid, metadata, err := a.Attest(filepath.Join(dir, tt.name))
+		if (err != nil) != tt.wantErr {
+			t.Errorf("Attest(%s, require=%v) error = %v, wantErr %v", tt.name, tt.require, err, tt.wantErr)
+		}
+		if valid != tt.valid {
+			t.Errorf("Attest(%s, require=%v) = %v, want %v", tt.name, tt.require, valid, tt.valid)
+		}
+		if tt.name == "signed" && metadata[attestedByAnnotation] != "hmac-sha256" {
+			t.Errorf("Attest(%s) metadata = %v, want %s annotation", tt.name, metadata, attestedByAnnotation)
+		}
+	}
+}
+
+func TestAttestDevices(t *testing.T) {
+	dir := t.TempDir()
+	a := NewHMACFileAttestor([]byte("secret"), true)
+	signed := writeDevice(t, dir, "micro0", "")
+	mac, err := a.Sign(signed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeDevice(t, dir, "micro0", hex.EncodeToString(mac))
+	writeDevice(t, dir, "micro1", "00")
+
+	s, _ := newTestServer(t, WithDevicePath(dir), WithAttestor(a))
+	if err := s.findDevice(); err != nil {
+		t.Fatalf("findDevice() = %v", err)
+	}
+
+	devices := s.deviceList()
+	if len(devices) != 2 {
+		t.Fatalf("server has %d devices, want 2 without the signature files", len(devices))
+	}
+	assert.AssertDeviceHealthy(t, s, "micro0")
+	assert.AssertDeviceUnhealthy(t, s, "micro1")
+
+	// the health check must not revive the device failing attestation
+	s.checkHealth()
+	assert.AssertDeviceUnhealthy(t, s, "micro1")
+	s.mu.RLock()
+	failed := s.devices["micro1"].Annotations[attestationFailedAnnotation]
+	s.mu.RUnlock()
+	if failed != "true" {
+		t.Errorf("forged device annotation %s = %q, want true", attestationFailedAnnotation, failed)
+	}
+}
@@ -0,0 +1,126 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"google.golang.org/grpc/metadata"
+
+	"github.com/kelein/micro-device-plugin/pkg/discovery"
+)
+
+// DeviceCapabilities describes the hardware capabilities of a device
+type DeviceCapabilities = discovery.DeviceCapabilities
+
+// Annotations of the minimum device capabilities of a preferred
+// allocation request, the device plugin API carries no request
+// annotations so they are read from the gRPC metadata of the call with
+// the slash replaced by a dash, e.g. `micro-plugin-min-memory-mb`
+const (
+	MinMemoryAnnotation       = "micro.plugin/min-memory-mb"
+	MinComputeUnitsAnnotation = "micro.plugin/min-compute-units"
+	MinBandwidthAnnotation    = "micro.plugin/min-bandwidth-gbps"
+)
+
+// CapabilityFilter selects the devices reaching minimum capabilities, a
+// zero threshold is not checked
+type CapabilityFilter struct {
+	MinMemoryMB      int
+	MinComputeUnits  int
+	MinBandwidthGbps float64
+}
+
+// metadataKey returns the gRPC metadata key of the annotation
+func metadataKey(annotation string) string {
+	key := []byte(annotation)
+	for i, c := range key {
+		if c == '.' || c == '/' {
+			key[i] = '-'
+		}
+	}
+	return string(key)
+}
+
+// CapabilityFilterFromContext returns the capability filter annotated on
+// the RPC call of ctx, a zero filter if none is annotated
+func CapabilityFilterFromContext(ctx context.Context) (CapabilityFilter, error) {
+	var f CapabilityFilter
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return f, nil
+	}
+	value := func(annotation string) string {
+		if values := md.Get(metadataKey(annotation)); len(values) > 0 {
+			return values[0]
+		}
+		return ""
+	}
+
+	var err error
+	if v := value(MinMemoryAnnotation); v != "" {
+		if f.MinMemoryMB, err = strconv.Atoi(v); err != nil {
+			return CapabilityFilter{}, fmt.Errorf("invalid %s %q: %w", MinMemoryAnnotation, v, err)
+		}
+	}
+	if v := value(MinComputeUnitsAnnotation); v != "" {
+		if f.MinComputeUnits, err = strconv.Atoi(v); err != nil {
+			return CapabilityFilter{}, fmt.Errorf("invalid %s %q: %w", MinComputeUnitsAnnotation, v, err)
+		}
+	}
+	if v := value(MinBandwidthAnnotation); v != "" {
+		if f.MinBandwidthGbps, err = strconv.ParseFloat(v, 64); err != nil {
+			return CapabilityFilter{}, fmt.Errorf("invalid %s %q: %w", MinBandwidthAnnotation, v, err)
+		}
+	}
+	return f, nil
+}
+
+// IsZero reports whether the filter has no threshold
+func (f CapabilityFilter) IsZero() bool {
+	return f == CapabilityFilter{}
+}
+
+// Match reports whether the capabilities reach the thresholds, devices
+// without capabilities only match the zero filter
+func (f CapabilityFilter) Match(caps *DeviceCapabilities) bool {
+	if f.IsZero() {
+		return true
+	}
+	if caps == nil {
+		return false
+	}
+	return caps.MemoryMB >= f.MinMemoryMB &&
+		caps.ComputeUnits >= f.MinComputeUnits &&
+		caps.BandwidthGbps >= f.MinBandwidthGbps
+}
+
+// Filter returns the devices matching the filter
+func (f CapabilityFilter) Filter(devices []*MicroDevice) []*MicroDevice {
+	if f.IsZero() {
+		return devices
+	}
+	var matched []*MicroDevice
+	for _, dev := range devices {
+		if f.Match(dev.Capabilities) {
+			matched = append(matched, dev)
+		}
+	}
+	return matched
+}
+
+// loadCapabilities loads the capabilities of the device from its sidecar
+// file, a device with an invalid sidecar has no capabilities
+func (s *MicroDeviceServer) loadCapabilities(dev *MicroDevice) {
+	if dev.Path == "" {
+		return
+	}
+	caps, err := discovery.LoadCapabilities(dev.Path)
+	if err != nil {
+		s.logger.Warn("load device capabilities failed", "name", dev.Name, "err", err)
+		return
+	}
+	if caps != nil {
+		dev.Capabilities = caps
+	}
+}
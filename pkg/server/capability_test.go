@@ -0,0 +1,124 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"google.golang.org/grpc/metadata"
+	deviceapi "k8s.io/kubelet/pkg/apis/deviceplugin/v1beta1"
+
+	"github.com/kelein/micro-device-plugin/pkg/discovery"
+)
+
+// newCapabilityServer creates a server of the devices micro0 with 4096 MB,
+// micro1 with 16384 MB and micro2 without capabilities
+func newCapabilityServer(t *testing.T) *MicroDeviceServer {
+	t.Helper()
+	dir := t.TempDir()
+	createDevices(t, dir, "micro0", "micro1", "micro2")
+	sidecars := map[string]string{
+		"micro0": `{"memoryMB": 4096, "computeUnits": 8, "bandwidthGbps": 25}`,
+		"micro1": `{"memoryMB": 16384, "computeUnits": 32, "bandwidthGbps": 100}`,
+	}
+	for name, data := range sidecars {
+		if err := os.WriteFile(filepath.Join(dir, name+discovery.CapabilitiesSuffix), []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	s, _ := newTestServer(t, WithDevicePath(dir), WithRESTAPI(true))
+	if err := s.findDevice(); err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func TestCapabilitiesDiscovered(t *testing.T) {
+	s := newCapabilityServer(t)
+
+	rec := httptest.NewRecorder()
+	s.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/devices", nil))
+	var devices []DeviceInfo
+	if err := json.NewDecoder(rec.Body).Decode(&devices); err != nil {
+		t.Fatal(err)
+	}
+	if len(devices) != 3 {
+		t.Fatalf("got %d devices, want 3 without the sidecar files", len(devices))
+	}
+	want := []*DeviceCapabilities{
+		{MemoryMB: 4096, ComputeUnits: 8, BandwidthGbps: 25},
+		{MemoryMB: 16384, ComputeUnits: 32, BandwidthGbps: 100},
+		nil,
+	}
+	for i, dev := range devices {
+		if got := dev.Capabilities; (got == nil) != (want[i] == nil) || got != nil && *got != *want[i] {
+			t.Errorf("device %s capabilities = %+v, want %+v", dev.Name, got, want[i])
+		}
+	}
+}
+
+func TestPreferredAllocationCapabilities(t *testing.T) {
+	ids := []string{deviceID("micro0"), deviceID("micro1"), deviceID("micro2")}
+	tests := []struct {
+		name string
+		md   metadata.MD // precondition: the constraints of the call
+		must []string
+		want []string
+	}{
+		{name: "no constraints", want: ids},
+		{name: "min memory", md: metadata.Pairs("micro-plugin-min-memory-mb", "8192"), want: ids[1:2]},
+		{name: "min memory met by all", md: metadata.Pairs("micro-plugin-min-memory-mb", "4096"), want: ids[:2]},
+		{name: "min compute units", md: metadata.Pairs("micro-plugin-min-compute-units", "64"), want: nil},
+		{name: "must include kept", md: metadata.Pairs("micro-plugin-min-memory-mb", "8192"), must: ids[:1], want: ids[:2]},
+		{name: "invalid constraint ignored", md: metadata.Pairs("micro-plugin-min-memory-mb", "lots"), want: ids},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newCapabilityServer(t)
+			ctx := context.Background()
+			if tt.md != nil {
+				ctx = metadata.NewOutgoingContext(ctx, tt.md)
+			}
+			req := &deviceapi.PreferredAllocationRequest{
+				ContainerRequests: []*deviceapi.ContainerPreferredAllocationRequest{{
+					AvailableDeviceIDs:   ids,
+					MustIncludeDeviceIDs: tt.must,
+					AllocationSize:       3,
+				}},
+			}
+			resp, err := newPluginClient(t, s).GetPreferredAllocation(ctx, req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := resp.ContainerResponses[0].DeviceIDs
+			slices.Sort(got)
+			want := slices.Sorted(slices.Values(tt.want))
+			if !slices.Equal(got, want) {
+				t.Errorf("preferred devices = %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestCapabilityFilter(t *testing.T) {
+	devices := []*MicroDevice{
+		{Name: "small", Capabilities: &DeviceCapabilities{MemoryMB: 2048}},
+		{Name: "large", Capabilities: &DeviceCapabilities{MemoryMB: 32768}},
+		{Name: "unknown"},
+	}
+	var names []string
+	for _, dev := range (CapabilityFilter{MinMemoryMB: 4096}).Filter(devices) {
+		names = append(names, dev.Name)
+	}
+	if !slices.Equal(names, []string{"large"}) {
+		t.Errorf("filtered devices = %v, want [large]", names)
+	}
+	if got := (CapabilityFilter{}).Filter(devices); len(got) != len(devices) {
+		t.Errorf("zero filter kept %d devices, want %d", len(got), len(devices))
+	}
+}
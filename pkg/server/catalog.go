@@ -0,0 +1,119 @@
+package server
+
+import (
+	"encoding/json"
+	"os"
+	"time"
+
+	"github.com/kelein/micro-device-plugin/pkg/version"
+)
+
+// manifestSuffix is appended to the socket base name to build the
+// manifest file name, e.g. `micro-plugin-manifest.json`
+const manifestSuffix = "-plugin-manifest.json"
+
+// PluginManifest is the plugin metadata published for node tooling
+type PluginManifest struct {
+	Name         string    `json:"name"`
+	Version      string    `json:"version"`
+	ResourceName string    `json:"resource_name"`
+	SocketPath   string    `json:"socket_path"`
+	PID          int       `json:"pid"`
+	StartTime    time.Time `json:"start_time"`
+	Features     []string  `json:"features"`
+}
+
+// PluginCatalog publishes the plugin manifest at a known path
+type PluginCatalog struct {
+	path string
+}
+
+// NewPluginCatalog creates a catalog writing the manifest to path
+func NewPluginCatalog(path string) *PluginCatalog {
+	return &PluginCatalog{path: path}
+}
+
+// Write atomically replaces the manifest file
+func (c *PluginCatalog) Write(m *PluginManifest) error {
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(c.path, data, 0644)
+}
+
+// Remove deletes the manifest file if it belongs to current process
+func (c *PluginCatalog) Remove() error {
+	m, err := ReadPluginManifest(c.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	if m.PID != os.Getpid() {
+		return nil
+	}
+	return os.Remove(c.path)
+}
+
+// ReadPluginManifest reads a plugin manifest file
+func ReadPluginManifest(path string) (*PluginManifest, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	m := &PluginManifest{}
+	if err := json.Unmarshal(data, m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// manifest describes the running plugin
+func (s *MicroDeviceServer) manifest() *PluginManifest {
+	return &PluginManifest{
+		Name:         version.AppName,
+		Version:      version.Version,
+		ResourceName: s.resourceName,
+		SocketPath:   s.socketPath(),
+		PID:          os.Getpid(),
+		StartTime:    s.startTime,
+		Features:     s.features(),
+	}
+}
+
+// features lists the enabled optional plugin features
+func (s *MicroDeviceServer) features() []string {
+	features := []string{}
+	enabled := []struct {
+		name string
+		on   bool
+	}{
+		{"grpc-reflection", s.reflection},
+		{"delta-list-and-watch", s.delta},
+		{"claim-tokens", s.claims != nil},
+		{"udev", s.udevSubsystem != ""},
+		{"preferred-allocation", s.affinity != nil},
+		{"lease-heartbeat", s.heartbeat != nil},
+		{"deallocate-hook", s.podClient != nil},
+		{"node-condition", s.conditions != nil},
+		{"rest-api", s.restAPI},
+		{"self-update", s.updater != nil},
+		{"metrics-file", s.metricsFile != ""},
+		{"sharding", s.shardCount > 1},
+	}
+	for _, f := range enabled {
+		if f.on {
+			features = append(features, f.name)
+		}
+	}
+	return features
+}
+
+// writeManifest publishes the plugin manifest to the catalog
+func (s *MicroDeviceServer) writeManifest() {
+	if err := s.catalog.Write(s.manifest()); err != nil {
+		s.logger.Error("write plugin manifest failed", "path", s.catalog.path, "err", err)
+	}
+}
@@ -0,0 +1,80 @@
+package server
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/kelein/micro-device-plugin/pkg/version"
+)
+
+func TestPluginManifestRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "micro-plugin-manifest.json")
+	want := &PluginManifest{
+		Name:         "micro-device-plugin",
+		Version:      "v1.2.3",
+		ResourceName: "example.com/micro",
+		SocketPath:   "/var/lib/kubelet/device-plugins/micro.sock",
+		PID:          os.Getpid(),
+		StartTime:    time.Date(2024, 5, 1, 12, 30, 0, 0, time.UTC),
+		Features:     []string{"rest-api", "udev"},
+	}
+
+	c := NewPluginCatalog(path)
+	if err := c.Write(want); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+	got, err := ReadPluginManifest(path)
+	if err != nil {
+		t.Fatalf("ReadPluginManifest() error = %v", err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ReadPluginManifest() = %+v, want %+v", got, want)
+	}
+
+	// the manifest of another process is kept
+	other := *want
+	other.PID = os.Getpid() + 1
+	if err := c.Write(&other); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Remove(); err != nil {
+		t.Fatalf("Remove() error = %v", err)
+	}
+	if _, err := os.Stat(path); err != nil {
+		t.Errorf("manifest of another process removed: %v", err)
+	}
+
+	if err := c.Write(want); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Remove(); err != nil {
+		t.Fatalf("Remove() error = %v", err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("manifest still exists after Remove: %v", err)
+	}
+}
+
+func TestServerManifest(t *testing.T) {
+	s, dir := newTestServer(t, WithResourceName("example.com/micro"), WithRESTAPI(true), WithReflection(true))
+	s.writeManifest()
+
+	got, err := ReadPluginManifest(filepath.Join(dir, "micro-plugin-manifest.json"))
+	if err != nil {
+		t.Fatalf("ReadPluginManifest() error = %v", err)
+	}
+	if got.Name != version.AppName || got.Version != version.Version || got.ResourceName != "example.com/micro" {
+		t.Errorf("manifest = %+v, want %s %s of example.com/micro", got, version.AppName, version.Version)
+	}
+	if got.SocketPath != s.socketPath() || got.PID != os.Getpid() || !got.StartTime.Equal(s.startTime) {
+		t.Errorf("manifest socket %s, pid %d, start %v, want %s, %d, %v",
+			got.SocketPath, got.PID, got.StartTime, s.socketPath(), os.Getpid(), s.startTime)
+	}
+	if want := []string{"grpc-reflection", "rest-api"}; !slices.Equal(got.Features, want) {
+		t.Errorf("manifest features = %v, want %v", got.Features, want)
+	}
+}
@@ -0,0 +1,42 @@
+package server
+
+import (
+	"sort"
+	"strings"
+
+	deviceapi "k8s.io/kubelet/pkg/apis/deviceplugin/v1beta1"
+)
+
+// cdiKind returns the CDI vendor/class kind of an extended resource,
+// resources without a class use the device class
+func cdiKind(resource string) string {
+	if strings.Contains(resource, "/") {
+		return resource
+	}
+	return resource + "/device"
+}
+
+// cdiDevices returns the fully qualified CDI device names of the
+// devices, e.g. micro.example.com/device=micro0
+func (s *MicroDeviceServer) cdiDevices(ids []string) []*deviceapi.CDIDevice {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	wanted := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		wanted[id] = true
+	}
+	var names []string
+	for _, dev := range s.devices {
+		if wanted[dev.ID] {
+			names = append(names, cdiKind(s.resourceName)+"="+dev.Name)
+		}
+	}
+	sort.Strings(names)
+
+	devices := make([]*deviceapi.CDIDevice, len(names))
+	for i, name := range names {
+		devices[i] = &deviceapi.CDIDevice{Name: name}
+	}
+	return devices
+}
@@ -0,0 +1,89 @@
+package server
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ClaimStore issues one-time tokens proving a device was allocated
+type ClaimStore struct {
+	mu     sync.Mutex
+	ttl    time.Duration
+	tokens map[string]claimToken
+}
+
+type claimToken struct {
+	value   string
+	expires time.Time
+}
+
+// NewClaimStore creates a claim token store with token expiry ttl
+func NewClaimStore(ttl time.Duration) *ClaimStore {
+	return &ClaimStore{
+		ttl:    ttl,
+		tokens: make(map[string]claimToken),
+	}
+}
+
+// Issue generates a new token shared by the allocated devices
+func (c *ClaimStore) Issue(deviceIDs []string) string {
+	token := uuid.NewString()
+	expires := time.Now().Add(c.ttl)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.expire()
+	for _, id := range deviceIDs {
+		c.tokens[id] = claimToken{value: token, expires: expires}
+	}
+	return token
+}
+
+// Verify reports whether token is a valid claim of the device, a
+// verified claim is consumed and cannot be verified again
+func (c *ClaimStore) Verify(deviceID, token string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	claim, ok := c.tokens[deviceID]
+	if !ok || time.Now().After(claim.expires) {
+		return false
+	}
+	if subtle.ConstantTimeCompare([]byte(claim.value), []byte(token)) != 1 {
+		return false
+	}
+	delete(c.tokens, deviceID)
+	return true
+}
+
+// Revoke drops the tokens of the devices
+func (c *ClaimStore) Revoke(deviceIDs []string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, id := range deviceIDs {
+		delete(c.tokens, id)
+	}
+}
+
+// expire drops expired tokens, must be called with c.mu held
+func (c *ClaimStore) expire() {
+	now := time.Now()
+	for id, claim := range c.tokens {
+		if now.After(claim.expires) {
+			delete(c.tokens, id)
+		}
+	}
+}
+
+func (s *MicroDeviceServer) handleVerifyClaim(w http.ResponseWriter, r *http.Request) {
+	device := r.URL.Query().Get("device")
+	token := r.URL.Query().Get("token")
+	if device == "" || token == "" || !s.claims.Verify(device, token) {
+		http.Error(w, "invalid device claim", http.StatusForbidden)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
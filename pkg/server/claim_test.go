@@ -0,0 +1,84 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/kelein/micro-device-plugin/pkg/testutil"
+)
+
+func TestClaimStore(t *testing.T) {
+	c := NewClaimStore(time.Minute)
+	token := c.Issue([]string{"dev0", "dev1"})
+
+	if c.Verify("dev0", "wrong") {
+		t.Error("Verify() accepted a wrong token")
+	}
+	if c.Verify("dev2", token) {
+		t.Error("Verify() accepted the token for a device it was not issued for")
+	}
+	if !c.Verify("dev0", token) {
+		t.Error("Verify() rejected the issued token")
+	}
+	if c.Verify("dev0", token) {
+		t.Error("Verify() accepted a token twice")
+	}
+	if !c.Verify("dev1", token) {
+		t.Error("Verify() rejected the token of the other allocated device")
+	}
+
+	token = c.Issue([]string{"dev0"})
+	c.Revoke([]string{"dev0"})
+	if c.Verify("dev0", token) {
+		t.Error("Verify() accepted a revoked token")
+	}
+}
+
+func TestClaimStoreExpiry(t *testing.T) {
+	c := NewClaimStore(10 * time.Millisecond)
+	token := c.Issue([]string{"dev0"})
+	time.Sleep(20 * time.Millisecond)
+	if c.Verify("dev0", token) {
+		t.Error("Verify() accepted an expired token")
+	}
+
+	c.Issue([]string{"dev1"})
+	c.mu.Lock()
+	_, ok := c.tokens["dev0"]
+	c.mu.Unlock()
+	if ok {
+		t.Error("expired token not dropped on issue")
+	}
+}
+
+func TestHandleVerifyClaim(t *testing.T) {
+	s, _ := newTestServer(t, WithClaimTokens(time.Minute))
+	id := s.addDevice(&MicroDevice{Name: "micro0"})
+	resp, err := s.Allocate(context.Background(), testutil.NewMockAllocateRequest().WithDeviceIDs(id).Build())
+	if err != nil {
+		t.Fatalf("Allocate() = %v", err)
+	}
+	token := resp.ContainerResponses[0].Envs["MICRO_DEVICE_TOKEN"]
+	if token == "" {
+		t.Fatal("MICRO_DEVICE_TOKEN not injected")
+	}
+
+	for _, tt := range []struct {
+		token string
+		want  int
+	}{
+		{"wrong", http.StatusForbidden},
+		{token, http.StatusOK},
+		{token, http.StatusForbidden},
+	} {
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/verify-claim?device="+id+"&token="+tt.token, nil)
+		s.Handler().ServeHTTP(rec, req)
+		if rec.Code != tt.want {
+			t.Errorf("GET /verify-claim token %s status = %d, want %d", tt.token, rec.Code, tt.want)
+		}
+	}
+}
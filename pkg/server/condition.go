@@ -0,0 +1,94 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// DefaultConditionType is the node condition reporting the plugin readiness
+const DefaultConditionType = "MicroDevicePluginReady"
+
+// NodeConditionReporter reports the plugin readiness as a node condition
+type NodeConditionReporter struct {
+	client        kubernetes.Interface
+	nodeName      string
+	conditionType string
+
+	mu   sync.Mutex
+	last corev1.ConditionStatus
+}
+
+// NewNodeConditionReporter creates a reporter patching the condition
+// type of the node
+func NewNodeConditionReporter(client kubernetes.Interface, nodeName, conditionType string) *NodeConditionReporter {
+	return &NodeConditionReporter{
+		client:        client,
+		nodeName:      nodeName,
+		conditionType: conditionType,
+	}
+}
+
+// Report patches the node condition to ready with the reason and
+// message, unchanged readiness is not patched again
+func (r *NodeConditionReporter) Report(ctx context.Context, ready bool, reason, message string) error {
+	status := corev1.ConditionFalse
+	if ready {
+		status = corev1.ConditionTrue
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if status == r.last {
+		return nil
+	}
+
+	now := metav1.NewTime(time.Now())
+	patch, err := json.Marshal(map[string]any{
+		"status": map[string]any{
+			"conditions": []corev1.NodeCondition{{
+				Type:               corev1.NodeConditionType(r.conditionType),
+				Status:             status,
+				Reason:             reason,
+				Message:            message,
+				LastHeartbeatTime:  now,
+				LastTransitionTime: now,
+			}},
+		},
+	})
+	if err != nil {
+		return err
+	}
+	_, err = r.client.CoreV1().Nodes().PatchStatus(ctx, r.nodeName, patch)
+	if err != nil {
+		return err
+	}
+	r.last = status
+	return nil
+}
+
+// reportCondition reports the node condition of the current plugin phase
+func (s *MicroDeviceServer) reportCondition() {
+	if s.conditions == nil {
+		return
+	}
+	phase := s.Status().Phase
+	switch phase {
+	case PhaseRunning, PhaseDegraded, PhaseFailed:
+	default:
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(s.ctx, 10*time.Second)
+	defer cancel()
+	ready := phase == PhaseRunning
+	message := "micro device plugin is " + phase
+	if err := s.conditions.Report(ctx, ready, "Plugin"+phase, message); err != nil {
+		s.logger.Error("report node condition failed", "phase", phase, "err", err)
+	}
+}
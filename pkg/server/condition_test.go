@@ -0,0 +1,98 @@
+package server
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestNodeConditionTransitions(t *testing.T) {
+	const conditionType = "ExampleMicroReady"
+	client := fake.NewClientset(&corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node1"}})
+	dir := t.TempDir()
+	createDevices(t, dir, "micro0", "micro1")
+	s, _ := newTestServer(t, WithDevicePath(dir),
+		WithNodeConditions(NewNodeConditionReporter(client, "node1", conditionType)))
+	for _, name := range []string{"micro0", "micro1"} {
+		s.addDevice(&MicroDevice{Name: name, Path: filepath.Join(dir, name)})
+	}
+
+	nodeCondition := func() *corev1.NodeCondition {
+		t.Helper()
+		node, err := client.CoreV1().Nodes().Get(context.Background(), "node1", metav1.GetOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i, c := range node.Status.Conditions {
+			if c.Type == conditionType {
+				return &node.Status.Conditions[i]
+			}
+		}
+		return nil
+	}
+
+	// precondition: a starting plugin reports no condition
+	s.reportCondition()
+	if c := nodeCondition(); c != nil {
+		t.Fatalf("starting plugin reported condition %+v", c)
+	}
+
+	steps := []struct {
+		name       string
+		action     func()
+		wantStatus corev1.ConditionStatus
+		wantReason string
+	}{
+		{
+			name:       "registered",
+			action:     func() { setRegistered(s, true); s.reportCondition() },
+			wantStatus: corev1.ConditionTrue,
+			wantReason: "PluginRunning",
+		},
+		{
+			name: "degraded",
+			action: func() {
+				if err := os.Remove(filepath.Join(dir, "micro1")); err != nil {
+					t.Fatal(err)
+				}
+				s.checkHealth()
+			},
+			wantStatus: corev1.ConditionFalse,
+			wantReason: "PluginDegraded",
+		},
+		{
+			name: "recovered",
+			action: func() {
+				createDevices(t, dir, "micro1")
+				s.checkHealth()
+			},
+			wantStatus: corev1.ConditionTrue,
+			wantReason: "PluginRunning",
+		},
+		{
+			name: "failed",
+			action: func() {
+				setRegistered(s, false)
+				s.setRestartCount(maxRestartNum + 1)
+				s.reportCondition()
+			},
+			wantStatus: corev1.ConditionFalse,
+			wantReason: "PluginFailed",
+		},
+	}
+	for _, step := range steps {
+		step.action()
+		c := nodeCondition()
+		if c == nil {
+			t.Fatalf("%s: node has no %s condition", step.name, conditionType)
+		}
+		if c.Status != step.wantStatus || c.Reason != step.wantReason {
+			t.Errorf("%s: condition = %s (%s), want %s (%s)", step.name, c.Status, c.Reason, step.wantStatus, step.wantReason)
+		}
+	}
+}
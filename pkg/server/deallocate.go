@@ -0,0 +1,192 @@
+package server
+
+import (
+	"context"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/tools/cache"
+	podresourcesapi "k8s.io/kubelet/pkg/apis/podresources/v1"
+
+	"github.com/kelein/micro-device-plugin/pkg/state"
+)
+
+// PodResourcesSocket is the kubelet pod resources API socket
+const PodResourcesSocket = "/var/lib/kubelet/pod-resources/kubelet.sock"
+
+// DeallocateFunc is called with the devices freed by a deleted pod
+type DeallocateFunc func(deviceIDs []string, podUID string)
+
+// PodDeviceLookup resolves the device IDs of a resource assigned to a pod
+type PodDeviceLookup interface {
+	PodDevices(ctx context.Context, namespace, name, resource string) ([]string, error)
+}
+
+// PodResourcesLookup resolves pod devices from the kubelet pod resources API
+type PodResourcesLookup struct {
+	Socket  string
+	Timeout time.Duration
+}
+
+// PodDevices lists the pod resources and returns the device IDs of the
+// resource assigned to the containers of namespace/name
+func (l PodResourcesLookup) PodDevices(ctx context.Context, namespace, name, resource string) ([]string, error) {
+	conn, err := dialUnix(l.Socket, l.Timeout)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	ctx, cancel := context.WithTimeout(ctx, l.Timeout)
+	defer cancel()
+	resp, err := podresourcesapi.NewPodResourcesListerClient(conn).List(ctx, &podresourcesapi.ListPodResourcesRequest{})
+	if err != nil {
+		return nil, err
+	}
+
+	var ids []string
+	for _, pod := range resp.GetPodResources() {
+		if pod.GetNamespace() != namespace || pod.GetName() != name {
+			continue
+		}
+		for _, c := range pod.GetContainers() {
+			for _, dev := range c.GetDevices() {
+				if dev.GetResourceName() == resource {
+					ids = append(ids, dev.GetDeviceIds()...)
+				}
+			}
+		}
+	}
+	return ids, nil
+}
+
+// RegisterDeallocateHook registers fn to be called when a pod allocated
+// devices of the plugin is deleted
+func (s *MicroDeviceServer) RegisterDeallocateHook(fn DeallocateFunc) {
+	s.allocMu.Lock()
+	defer s.allocMu.Unlock()
+	s.deallocHooks = append(s.deallocHooks, fn)
+}
+
+// markAllocated records the devices handed out by Allocate
+func (s *MicroDeviceServer) markAllocated(ids []string) {
+	s.allocMu.Lock()
+	for _, id := range ids {
+		s.allocated[id] = true
+	}
+	s.metrics.activeAllocations.Set(float64(len(s.allocated)))
+	s.allocMu.Unlock()
+	s.recordState(state.OpAlloc, ids, "")
+}
+
+// releaseDevices is the built-in deallocate hook dropping the device
+// allocations and claim tokens
+func (s *MicroDeviceServer) releaseDevices(ids []string, podUID string) {
+	s.allocMu.Lock()
+	for _, id := range ids {
+		delete(s.allocated, id)
+	}
+	s.metrics.activeAllocations.Set(float64(len(s.allocated)))
+	s.allocMu.Unlock()
+	s.recordState(state.OpDealloc, ids, "")
+	if s.allocRegistry != nil {
+		s.allocRegistry.Release(s.resourceName, ids)
+	}
+
+	if s.claims != nil {
+		s.claims.Revoke(ids)
+	}
+	s.logger.Info("devices deallocated", "pod", podUID, "devices", s.logIDs(ids))
+}
+
+// watchPods runs a pod informer on the node, resolving the devices of
+// running pods and calling the deallocate hooks on their deletion
+func (s *MicroDeviceServer) watchPods() {
+	factory := informers.NewSharedInformerFactoryWithOptions(s.podClient, 0,
+		informers.WithTweakListOptions(func(opts *metav1.ListOptions) {
+			opts.FieldSelector = fields.OneTermEqualSelector("spec.nodeName", s.nodeName).String()
+		}),
+	)
+	informer := factory.Core().V1().Pods().Informer()
+	_, err := informer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc:    s.trackPod,
+		UpdateFunc: func(_, obj any) { s.trackPod(obj) },
+		DeleteFunc: s.deletePod,
+	})
+	if err != nil {
+		s.logger.Error("add pod event handler failed", "err", err)
+		return
+	}
+
+	s.logger.Info("pod informer started", "node", s.nodeName)
+	factory.Start(s.ctx.Done())
+}
+
+// trackPod records the devices of a pod requesting the plugin resource
+func (s *MicroDeviceServer) trackPod(obj any) {
+	pod, ok := obj.(*corev1.Pod)
+	if !ok || !s.requestsResource(pod) {
+		return
+	}
+
+	s.allocMu.Lock()
+	_, tracked := s.podDevices[string(pod.UID)]
+	s.allocMu.Unlock()
+	if tracked {
+		return
+	}
+
+	ids, err := s.podLookup.PodDevices(s.ctx, pod.Namespace, pod.Name, s.resourceName)
+	if err != nil {
+		s.logger.Error("lookup pod devices failed", "pod", pod.Name, "err", err)
+		return
+	}
+	if len(ids) == 0 {
+		return
+	}
+
+	s.allocMu.Lock()
+	s.podDevices[string(pod.UID)] = ids
+	s.allocMu.Unlock()
+	s.recordOwner(ids, string(pod.UID))
+}
+
+// deletePod calls the deallocate hooks with the devices of the pod
+func (s *MicroDeviceServer) deletePod(obj any) {
+	if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+		obj = tombstone.Obj
+	}
+	pod, ok := obj.(*corev1.Pod)
+	if !ok {
+		return
+	}
+
+	uid := string(pod.UID)
+	s.allocMu.Lock()
+	ids, tracked := s.podDevices[uid]
+	delete(s.podDevices, uid)
+	hooks := append([]DeallocateFunc{}, s.deallocHooks...)
+	s.allocMu.Unlock()
+	if !tracked {
+		return
+	}
+
+	for _, fn := range hooks {
+		fn(ids, uid)
+	}
+}
+
+// requestsResource reports whether a container of pod requests the
+// plugin resource
+func (s *MicroDeviceServer) requestsResource(pod *corev1.Pod) bool {
+	name := corev1.ResourceName(s.resourceName)
+	for _, c := range pod.Spec.Containers {
+		if _, ok := c.Resources.Limits[name]; ok {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,99 @@
+package server
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"github.com/kelein/micro-device-plugin/pkg/testing/assert"
+)
+
+// podLookup resolves the pod devices from a fixed map keyed by pod name
+type podLookup map[string][]string
+
+func (l podLookup) PodDevices(_ context.Context, _, name, _ string) ([]string, error) {
+	return l[name], nil
+}
+
+// devicePod creates a pod of node1 whose container limits the resource
+func devicePod(name, resourceName string) *corev1.Pod {
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default", UID: types.UID(name + "-uid")},
+		Spec: corev1.PodSpec{
+			NodeName: "node1",
+			Containers: []corev1.Container{{
+				Name: "app",
+				Resources: corev1.ResourceRequirements{
+					Limits: corev1.ResourceList{corev1.ResourceName(resourceName): resource.MustParse("1")},
+				},
+			}},
+		},
+	}
+}
+
+type deallocation struct {
+	ids    []string
+	podUID string
+}
+
+func TestDeallocateHook(t *testing.T) {
+	resourceName := "example.com/micro"
+	client := fake.NewClientset(devicePod("app", resourceName), devicePod("other", "example.com/other"))
+	reg := prometheus.NewRegistry()
+	s, _ := newTestServer(t, WithMetrics(reg), WithResourceName(resourceName),
+		WithDeallocateHook(client, "node1", podLookup{"app": {"micro0"}, "other": {"micro1"}}))
+	s.markAllocated([]string{"micro0"})
+	assert.AssertMetricValue(t, reg, "micro_device_plugin_active_allocations", nil, 1)
+
+	calls := make(chan deallocation, 4)
+	s.RegisterDeallocateHook(func(ids []string, podUID string) {
+		calls <- deallocation{ids: ids, podUID: podUID}
+	})
+	s.watchPods()
+
+	// precondition: the informer tracked the devices of the pod
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		s.allocMu.Lock()
+		_, tracked := s.podDevices["app-uid"]
+		s.allocMu.Unlock()
+		if tracked {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("pod informer did not track the pod devices")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// action: delete the pods, only the pod of the resource is deallocated
+	for _, name := range []string{"other", "app"} {
+		if err := client.CoreV1().Pods("default").Delete(context.Background(), name, metav1.DeleteOptions{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	select {
+	case got := <-calls:
+		if !slices.Equal(got.ids, []string{"micro0"}) || got.podUID != "app-uid" {
+			t.Errorf("deallocate hook called with %v of pod %s, want [micro0] of app-uid", got.ids, got.podUID)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("deallocate hook not called on pod deletion")
+	}
+	select {
+	case got := <-calls:
+		t.Errorf("unexpected deallocate hook call with %v of pod %s", got.ids, got.podUID)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	// the built-in hook released the allocation
+	assert.AssertMetricValue(t, reg, "micro_device_plugin_active_allocations", nil, 0)
+}
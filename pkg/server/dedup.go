@@ -0,0 +1,62 @@
+package server
+
+import (
+	"slices"
+	"strings"
+	"time"
+
+	deviceapi "k8s.io/kubelet/pkg/apis/deviceplugin/v1beta1"
+)
+
+type cachedAllocate struct {
+	resp    *deviceapi.AllocateResponse
+	expires time.Time
+}
+
+// allocateKey builds a deterministic key of the request from the sorted
+// device IDs of each container
+func allocateKey(req *deviceapi.AllocateRequest) string {
+	containers := make([]string, 0, len(req.ContainerRequests))
+	for _, c := range req.ContainerRequests {
+		ids := slices.Clone(c.DevicesIDs)
+		slices.Sort(ids)
+		containers = append(containers, strings.Join(ids, ","))
+	}
+	return strings.Join(containers, "|")
+}
+
+// deduplicateAllocate returns the cached response of an identical
+// request retried by kubelet within the idempotency window
+func (s *MicroDeviceServer) deduplicateAllocate(req *deviceapi.AllocateRequest) (cached bool, resp *deviceapi.AllocateResponse) {
+	if s.idempotencyWindow <= 0 {
+		return false, nil
+	}
+	v, ok := s.allocateCache.Load(allocateKey(req))
+	if !ok {
+		return false, nil
+	}
+	entry := v.(cachedAllocate)
+	if time.Now().After(entry.expires) {
+		return false, nil
+	}
+	return true, entry.resp
+}
+
+// cacheAllocate stores the response of req for the idempotency window
+// and drops the expired responses
+func (s *MicroDeviceServer) cacheAllocate(req *deviceapi.AllocateRequest, resp *deviceapi.AllocateResponse) {
+	if s.idempotencyWindow <= 0 {
+		return
+	}
+	now := time.Now()
+	s.allocateCache.Range(func(k, v any) bool {
+		if now.After(v.(cachedAllocate).expires) {
+			s.allocateCache.Delete(k)
+		}
+		return true
+	})
+	s.allocateCache.Store(allocateKey(req), cachedAllocate{
+		resp:    resp,
+		expires: now.Add(s.idempotencyWindow),
+	})
+}
@@ -0,0 +1,67 @@
+package server
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/kelein/micro-device-plugin/pkg/testutil"
+)
+
+func TestAllocateIdempotency(t *testing.T) {
+	tests := []struct {
+		name       string
+		window     time.Duration // precondition: idempotency window
+		wait       time.Duration // precondition: delay of the retry
+		reordered  bool          // precondition: the retry lists the devices in another order
+		wantCached bool
+	}{
+		{name: "retry within window", window: 10 * time.Second, wantCached: true},
+		{name: "reordered retry", window: 10 * time.Second, reordered: true, wantCached: true},
+		{name: "retry after window", window: 20 * time.Millisecond, wait: 50 * time.Millisecond},
+		{name: "cache disabled", window: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, _ := newTestServer(t, WithAllocateIdempotencyWindow(tt.window))
+			id0 := s.addDevice(&MicroDevice{Name: "micro0"})
+			id1 := s.addDevice(&MicroDevice{Name: "micro1"})
+
+			first, err := s.Allocate(context.Background(), testutil.NewMockAllocateRequest().WithDeviceIDs(id0, id1).Build())
+			if err != nil {
+				t.Fatal(err)
+			}
+			time.Sleep(tt.wait)
+			retry := testutil.NewMockAllocateRequest().WithDeviceIDs(id0, id1)
+			if tt.reordered {
+				retry = testutil.NewMockAllocateRequest().WithDeviceIDs(id1, id0)
+			}
+			second, err := s.Allocate(context.Background(), retry.Build())
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if cached := first == second; cached != tt.wantCached {
+				t.Errorf("second Allocate() returned the cached response = %v, want %v", cached, tt.wantCached)
+			}
+		})
+	}
+}
+
+func TestAllocateIdempotencyDistinctRequests(t *testing.T) {
+	s, _ := newTestServer(t)
+	id0 := s.addDevice(&MicroDevice{Name: "micro0"})
+	id1 := s.addDevice(&MicroDevice{Name: "micro1"})
+
+	first, err := s.Allocate(context.Background(), testutil.NewMockAllocateRequest().WithDeviceIDs(id0).Build())
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := s.Allocate(context.Background(), testutil.NewMockAllocateRequest().WithDeviceIDs(id1).Build())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first == second {
+		t.Error("Allocate() of other devices returned the cached response")
+	}
+}
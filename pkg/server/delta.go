@@ -0,0 +1,229 @@
+package server
+
+import (
+	"context"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"syscall"
+	"time"
+
+	"google.golang.org/grpc"
+	deviceapi "k8s.io/kubelet/pkg/apis/deviceplugin/v1beta1"
+)
+
+const (
+	// DeltaRemoved is the health of the removed devices of a delta response
+	DeltaRemoved = "Removed"
+
+	// deltaProxySuffix replaces the .sock suffix of the plugin socket name
+	// to name the DeltaProxy socket registered with kubelet
+	deltaProxySuffix = "-delta-proxy.sock"
+)
+
+// DeviceDelta describes the device changes between two snapshots
+type DeviceDelta struct {
+	Upserted []*deviceapi.Device
+	Removed  []string
+}
+
+// Empty reports whether the delta contains no changes
+func (d DeviceDelta) Empty() bool {
+	return len(d.Upserted) == 0 && len(d.Removed) == 0
+}
+
+// Size returns the number of changed devices
+func (d DeviceDelta) Size() int {
+	return len(d.Upserted) + len(d.Removed)
+}
+
+// DiffDevices computes the added, removed and health changed devices
+func DiffDevices(prev, next []*deviceapi.Device) DeviceDelta {
+	old := make(map[string]*deviceapi.Device, len(prev))
+	for _, dev := range prev {
+		old[dev.ID] = dev
+	}
+
+	var delta DeviceDelta
+	for _, dev := range next {
+		if p, ok := old[dev.ID]; !ok || p.String() != dev.String() {
+			delta.Upserted = append(delta.Upserted, dev)
+		}
+		delete(old, dev.ID)
+	}
+	for id := range old {
+		delta.Removed = append(delta.Removed, id)
+	}
+	sort.Strings(delta.Removed)
+	return delta
+}
+
+// DeltaReconciler merges device deltas into the full device list
+// required by the kubelet device plugin API
+type DeltaReconciler struct {
+	devices map[string]*deviceapi.Device
+}
+
+// NewDeltaReconciler creates a reconciler from a full device snapshot
+func NewDeltaReconciler(snapshot []*deviceapi.Device) *DeltaReconciler {
+	r := &DeltaReconciler{devices: make(map[string]*deviceapi.Device, len(snapshot))}
+	for _, dev := range snapshot {
+		r.devices[dev.ID] = dev
+	}
+	return r
+}
+
+// Apply merges the delta and returns the full device list
+func (r *DeltaReconciler) Apply(delta DeviceDelta) []*deviceapi.Device {
+	for _, dev := range delta.Upserted {
+		r.devices[dev.ID] = dev
+	}
+	for _, id := range delta.Removed {
+		delete(r.devices, id)
+	}
+	return r.List()
+}
+
+// List returns the current full device list
+func (r *DeltaReconciler) List() []*deviceapi.Device {
+	devs := make([]*deviceapi.Device, 0, len(r.devices))
+	for _, dev := range r.devices {
+		devs = append(devs, dev)
+	}
+	return devs
+}
+
+// EncodeDelta encodes the delta as a ListAndWatch response holding the
+// changed devices only, the removed devices have the DeltaRemoved health
+func EncodeDelta(delta DeviceDelta) *deviceapi.ListAndWatchResponse {
+	devs := make([]*deviceapi.Device, 0, delta.Size())
+	devs = append(devs, delta.Upserted...)
+	for _, id := range delta.Removed {
+		devs = append(devs, &deviceapi.Device{ID: id, Health: DeltaRemoved})
+	}
+	return &deviceapi.ListAndWatchResponse{Devices: devs}
+}
+
+// DecodeDelta decodes a delta response encoded by EncodeDelta
+func DecodeDelta(resp *deviceapi.ListAndWatchResponse) DeviceDelta {
+	var delta DeviceDelta
+	for _, dev := range resp.Devices {
+		if dev.Health == DeltaRemoved {
+			delta.Removed = append(delta.Removed, dev.ID)
+			continue
+		}
+		delta.Upserted = append(delta.Upserted, dev)
+	}
+	return delta
+}
+
+// Compile-time check of the device plugin service of the proxy
+var _ deviceapi.DevicePluginServer = (*DeltaProxy)(nil)
+
+// DeltaProxy serves the device plugin API to kubelet in front of a plugin
+// streaming deltas. Kubelet reads every ListAndWatch response as the full
+// device list, so the proxy merges the deltas of the plugin into full
+// lists; the other calls are forwarded unchanged.
+type DeltaProxy struct {
+	upstream deviceapi.DevicePluginClient
+}
+
+// NewDeltaProxy creates a proxy of the plugin served by upstream
+func NewDeltaProxy(upstream deviceapi.DevicePluginClient) *DeltaProxy {
+	return &DeltaProxy{upstream: upstream}
+}
+
+// GetDevicePluginOptions forwards the call to the plugin
+func (p *DeltaProxy) GetDevicePluginOptions(ctx context.Context, e *deviceapi.Empty) (*deviceapi.DevicePluginOptions, error) {
+	return p.upstream.GetDevicePluginOptions(ctx, e)
+}
+
+// ListAndWatch sends the full device list merged from the snapshot and
+// the deltas of the plugin stream
+func (p *DeltaProxy) ListAndWatch(e *deviceapi.Empty, srv deviceapi.DevicePlugin_ListAndWatchServer) error {
+	stream, err := p.upstream.ListAndWatch(srv.Context(), e)
+	if err != nil {
+		return err
+	}
+	snapshot, err := stream.Recv()
+	if err != nil {
+		return err
+	}
+	reconciler := NewDeltaReconciler(snapshot.Devices)
+	if err := srv.Send(&deviceapi.ListAndWatchResponse{Devices: reconciler.List()}); err != nil {
+		return err
+	}
+	for {
+		resp, err := stream.Recv()
+		if err != nil {
+			return err
+		}
+		devs := reconciler.Apply(DecodeDelta(resp))
+		if err := srv.Send(&deviceapi.ListAndWatchResponse{Devices: devs}); err != nil {
+			return err
+		}
+	}
+}
+
+// GetPreferredAllocation forwards the call to the plugin
+func (p *DeltaProxy) GetPreferredAllocation(ctx context.Context, req *deviceapi.PreferredAllocationRequest) (*deviceapi.PreferredAllocationResponse, error) {
+	return p.upstream.GetPreferredAllocation(ctx, req)
+}
+
+// Allocate forwards the call to the plugin
+func (p *DeltaProxy) Allocate(ctx context.Context, req *deviceapi.AllocateRequest) (*deviceapi.AllocateResponse, error) {
+	return p.upstream.Allocate(ctx, req)
+}
+
+// PreStartContainer forwards the call to the plugin
+func (p *DeltaProxy) PreStartContainer(ctx context.Context, req *deviceapi.PreStartContainerRequest) (*deviceapi.PreStartContainerResponse, error) {
+	return p.upstream.PreStartContainer(ctx, req)
+}
+
+// endpointPath returns the socket path registered with kubelet, the
+// DeltaProxy socket if ListAndWatch streams deltas
+func (s *MicroDeviceServer) endpointPath() string {
+	if !s.delta {
+		return s.socketPath()
+	}
+	return filepath.Join(s.pluginPath, strings.TrimSuffix(s.socketName, ".sock")+deltaProxySuffix)
+}
+
+// serveDeltaProxy serves a DeltaProxy of the plugin socket on the
+// endpoint socket, replacing the proxy served before
+func (s *MicroDeviceServer) serveDeltaProxy() error {
+	err := syscall.Unlink(s.endpointPath())
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	conn, err := s.dial(s.socketPath(), time.Second*5)
+	if err != nil {
+		return err
+	}
+	listener, err := net.Listen("unix", s.endpointPath())
+	if err != nil {
+		conn.Close()
+		return err
+	}
+
+	proxy := grpc.NewServer(s.grpcOpts...)
+	deviceapi.RegisterDevicePluginServer(proxy, NewDeltaProxy(deviceapi.NewDevicePluginClient(conn)))
+	s.mu.Lock()
+	old := s.proxy
+	s.proxy = proxy
+	s.mu.Unlock()
+	if old != nil {
+		old.Stop()
+	}
+
+	s.logger.Info("starting delta proxy", "socket", s.endpointPath())
+	s.SafeGo("deltaProxy", func() {
+		defer conn.Close()
+		if err := proxy.Serve(listener); err != nil {
+			s.logger.Error("delta proxy stopped", "err", err)
+		}
+	})
+	return nil
+}
@@ -0,0 +1,77 @@
+//go:build integration
+
+package server
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	deviceapi "k8s.io/kubelet/pkg/apis/deviceplugin/v1beta1"
+
+	"github.com/kelein/micro-device-plugin/pkg/testutil"
+)
+
+func TestDeltaProxyRegistration(t *testing.T) {
+	dir := t.TempDir()
+	kubelet, err := testutil.NewFakeKubelet(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(kubelet.Stop)
+	devices := t.TempDir()
+	createDevices(t, devices, "micro0", "micro1", "micro2")
+	s, _ := newTestServer(t, WithPluginPath(dir), WithDevicePath(devices), WithMetrics(prometheus.NewRegistry()),
+		WithHealthInterval(0), WithDeltaListAndWatch(true))
+	if err := s.Run(); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.RegisterToKubelet(); err != nil {
+		t.Fatal(err)
+	}
+
+	// expected: kubelet is registered with the proxy socket, never with
+	// the plugin socket streaming deltas
+	reqs := kubelet.Requests()
+	if len(reqs) != 1 || reqs[0].Endpoint != "micro-delta-proxy.sock" {
+		t.Fatalf("register requests = %v, want the endpoint micro-delta-proxy.sock", reqs)
+	}
+
+	conn, err := dialUnix(filepath.Join(dir, reqs[0].Endpoint), 5*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	stream, err := deviceapi.NewDevicePluginClient(conn).ListAndWatch(ctx, &deviceapi.Empty{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := stream.Recv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.Devices) != 3 {
+		t.Fatalf("first response has %d devices, want 3", len(resp.Devices))
+	}
+
+	// action: a device is removed, kubelet still receives the full list
+	done := make(chan struct{})
+	defer close(done)
+	go removeDeviceNotified(s, "micro1", done)
+	resp, err = stream.Recv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.Devices) != 2 {
+		t.Fatalf("response after removal has %d devices, want the full list of 2", len(resp.Devices))
+	}
+	for _, dev := range resp.Devices {
+		if dev.ID == deviceID("micro1") || dev.Health != deviceapi.Healthy {
+			t.Errorf("device %s health %s sent to kubelet, want only the healthy remaining devices", dev.ID, dev.Health)
+		}
+	}
+}
@@ -0,0 +1,95 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	deviceapi "k8s.io/kubelet/pkg/apis/deviceplugin/v1beta1"
+)
+
+// removeDeviceNotified removes the device and notifies ListAndWatch until
+// done is closed, the notification is dropped while no stream waits
+func removeDeviceNotified(s *MicroDeviceServer, name string, done <-chan struct{}) {
+	s.deleteDevice(name)
+	for {
+		s.notifyChange()
+		select {
+		case <-done:
+			return
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+}
+
+func TestDeltaListAndWatch(t *testing.T) {
+	const n = 1000
+	s, _ := newTestServer(t, WithDeltaListAndWatch(true))
+	for i := 0; i < n; i++ {
+		s.addDevice(&MicroDevice{Name: fmt.Sprintf("micro%d", i)})
+	}
+
+	// the proxy stands between the plugin and kubelet
+	proxy := grpc.NewServer()
+	deviceapi.RegisterDevicePluginServer(proxy, NewDeltaProxy(newPluginClient(t, s)))
+	t.Cleanup(proxy.Stop)
+	kubelet := dialBufconn(t, proxy)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	plugin, err := newPluginClient(t, s).ListAndWatch(ctx, &deviceapi.Empty{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	proxied, err := kubelet.ListAndWatch(ctx, &deviceapi.Empty{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, stream := range map[string]deviceapi.DevicePlugin_ListAndWatchClient{"plugin": plugin, "proxy": proxied} {
+		resp, err := stream.Recv()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(resp.Devices) != n {
+			t.Fatalf("%s first response has %d devices, want the full list of %d", name, len(resp.Devices), n)
+		}
+	}
+
+	done := make(chan struct{})
+	defer close(done)
+	go removeDeviceNotified(s, "micro7", done)
+
+	resp, err := plugin.Recv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.Devices) != 1 || resp.Devices[0].ID != deviceID("micro7") || resp.Devices[0].Health != DeltaRemoved {
+		t.Errorf("plugin delta = %v, want only the removal of micro7", resp.Devices)
+	}
+
+	resp, err = proxied.Recv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.Devices) != n-1 {
+		t.Fatalf("proxied list has %d devices, want %d", len(resp.Devices), n-1)
+	}
+	for _, dev := range resp.Devices {
+		if dev.ID == deviceID("micro7") {
+			t.Error("proxied list still holds the removed device")
+		}
+	}
+}
+
+func TestEncodeDecodeDelta(t *testing.T) {
+	delta := DeviceDelta{
+		Upserted: []*deviceapi.Device{{ID: "a", Health: deviceapi.Unhealthy}},
+		Removed:  []string{"b"},
+	}
+	got := DecodeDelta(EncodeDelta(delta))
+	if len(got.Upserted) != 1 || got.Upserted[0].ID != "a" || len(got.Removed) != 1 || got.Removed[0] != "b" {
+		t.Errorf("DecodeDelta(EncodeDelta()) = %+v, want %+v", got, delta)
+	}
+}
@@ -0,0 +1,47 @@
+//go:build integration
+
+package server
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+)
+
+func TestCreateDevicePath(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "micro")
+	s, _ := newTestServer(t, WithDevicePath(path), WithHealthInterval(0), WithCreateDevicePath(true))
+	if err := s.Run(); err != nil {
+		t.Fatalf("Run() = %v", err)
+	}
+
+	info, err := os.Stat(path)
+	if err != nil || !info.IsDir() {
+		t.Fatalf("device path not created: %v", err)
+	}
+	if got := len(s.Devices()); got != 0 {
+		t.Errorf("discovered %d devices, want 0", got)
+	}
+}
+
+func TestCreateDevicePathDisabled(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "micro")
+	s, _ := newTestServer(t, WithDevicePath(path), WithHealthInterval(0))
+	if err := s.Run(); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("Run() = %v, want %v", err, os.ErrNotExist)
+	}
+}
+
+func TestCreateDevicePathFailure(t *testing.T) {
+	// a regular file as parent fails the creation
+	parent := filepath.Join(t.TempDir(), "file")
+	if err := os.WriteFile(parent, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	s, _ := newTestServer(t, WithDevicePath(filepath.Join(parent, "micro")), WithHealthInterval(0), WithCreateDevicePath(true))
+	if err := s.Run(); !errors.Is(err, syscall.ENOTDIR) {
+		t.Errorf("Run() = %v, want the discovery error %v", err, syscall.ENOTDIR)
+	}
+}
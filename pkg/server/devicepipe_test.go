@@ -0,0 +1,45 @@
+package server
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/kelein/micro-device-plugin/pkg/discovery"
+)
+
+func TestWatchDevicePipe(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "devices.pipe")
+	if err := syscall.Mkfifo(path, 0600); err != nil {
+		t.Fatal(err)
+	}
+	s, _ := newTestServer(t, WithDiscoverer(discovery.NewPipeDiscoverer(path)))
+	go s.watchDevice()
+
+	f, err := os.OpenFile(path, os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	f.WriteString(`{"op":"add","name":"micro0"}` + "\n")
+	f.WriteString(`{"op":"add","name":"micro1"}` + "\n")
+	f.WriteString(`{"op":"remove","name":"micro0"}` + "\n")
+
+	want := []string{deviceID("micro1")}
+	deadline := time.Now().Add(5 * time.Second)
+	var got []string
+	for time.Now().Before(deadline) {
+		got = got[:0]
+		for _, dev := range s.deviceList() {
+			got = append(got, dev.ID)
+		}
+		if slices.Equal(got, want) {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Errorf("device list = %v, want %v", got, want)
+}
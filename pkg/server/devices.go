@@ -0,0 +1,81 @@
+package server
+
+import (
+	"net/http"
+	"path"
+	"sort"
+	"strings"
+
+	deviceapi "k8s.io/kubelet/pkg/apis/deviceplugin/v1beta1"
+)
+
+// DeviceInfo describes a device known to the plugin
+type DeviceInfo struct {
+	Name          string            `json:"name"`
+	ID            string            `json:"id"`
+	Path          string            `json:"path,omitempty"`
+	Health        string            `json:"health"`
+	Reserved      bool              `json:"reserved"`
+	AffinityGroup string            `json:"affinityGroup,omitempty"`
+	Annotations   map[string]string `json:"annotations,omitempty"`
+
+	Capabilities *DeviceCapabilities `json:"capabilities,omitempty"`
+}
+
+// Devices returns the devices known to the plugin sorted by name
+func (s *MicroDeviceServer) Devices() []DeviceInfo {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	infos := make([]DeviceInfo, 0, len(s.devices))
+	for _, dev := range s.devices {
+		infos = append(infos, DeviceInfo{
+			Name:          dev.Name,
+			ID:            dev.ID,
+			Path:          dev.Path,
+			Health:        dev.Health,
+			Reserved:      isReserved(dev),
+			AffinityGroup: dev.Annotations[affinityGroupAnnotation],
+			Annotations:   dev.Annotations,
+			Capabilities:  dev.Capabilities,
+		})
+	}
+	sort.Slice(infos, func(i, j int) bool {
+		return infos[i].Name < infos[j].Name
+	})
+	return infos
+}
+
+// handleDevices serves the devices filtered by the optional `health`
+// (healthy or unhealthy) and `name` glob query parameters
+func (s *MicroDeviceServer) handleDevices(w http.ResponseWriter, r *http.Request) {
+	health := r.URL.Query().Get("health")
+	switch {
+	case health == "":
+	case strings.EqualFold(health, deviceapi.Healthy):
+		health = deviceapi.Healthy
+	case strings.EqualFold(health, deviceapi.Unhealthy):
+		health = deviceapi.Unhealthy
+	default:
+		http.Error(w, "health must be healthy or unhealthy", http.StatusBadRequest)
+		return
+	}
+	pattern := r.URL.Query().Get("name")
+	if _, err := path.Match(pattern, ""); err != nil {
+		http.Error(w, "invalid name pattern: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	devices := []DeviceInfo{}
+	for _, dev := range s.Devices() {
+		if health != "" && dev.Health != health {
+			continue
+		}
+		if pattern != "" {
+			if ok, _ := path.Match(pattern, dev.Name); !ok {
+				continue
+			}
+		}
+		devices = append(devices, dev)
+	}
+	writeJSON(w, http.StatusOK, devices)
+}
@@ -0,0 +1,76 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+
+	deviceapi "k8s.io/kubelet/pkg/apis/deviceplugin/v1beta1"
+)
+
+func TestHandleDevices(t *testing.T) {
+	s, _ := newTestServer(t, WithRESTAPI(true))
+	for _, name := range []string{"micro0", "micro1", "micro2", "ssd0"} {
+		s.addDevice(&MicroDevice{Name: name})
+	}
+	// precondition: micro1 and ssd0 are unhealthy
+	s.mu.Lock()
+	s.devices["micro1"].Health = deviceapi.Unhealthy
+	s.devices["ssd0"].Health = deviceapi.Unhealthy
+	s.mu.Unlock()
+
+	tests := []struct {
+		name     string
+		query    string
+		want     []string
+		wantCode int
+	}{
+		{name: "all", want: []string{"micro0", "micro1", "micro2", "ssd0"}},
+		{name: "healthy", query: "health=healthy", want: []string{"micro0", "micro2"}},
+		{name: "unhealthy", query: "health=Unhealthy", want: []string{"micro1", "ssd0"}},
+		{name: "name glob", query: "name=micro*", want: []string{"micro0", "micro1", "micro2"}},
+		{name: "health and name", query: "health=unhealthy&name=micro?", want: []string{"micro1"}},
+		{name: "no match", query: "name=gpu*", want: []string{}},
+		{name: "invalid health", query: "health=degraded", wantCode: http.StatusBadRequest},
+		{name: "invalid name", query: "name=micro[", wantCode: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			s.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/devices?"+tt.query, nil))
+			wantCode := tt.wantCode
+			if wantCode == 0 {
+				wantCode = http.StatusOK
+			}
+			if rec.Code != wantCode {
+				t.Fatalf("GET /api/v1/devices?%s status = %d, want %d", tt.query, rec.Code, wantCode)
+			}
+			if wantCode != http.StatusOK {
+				return
+			}
+
+			var devices []DeviceInfo
+			if err := json.Unmarshal(rec.Body.Bytes(), &devices); err != nil {
+				t.Fatalf("decode devices: %v", err)
+			}
+			got := []string{}
+			for _, dev := range devices {
+				got = append(got, dev.Name)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("GET /api/v1/devices?%s = %v, want %v", tt.query, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHandleDevicesDisabled(t *testing.T) {
+	s, _ := newTestServer(t, WithRESTAPI(false))
+	rec := httptest.NewRecorder()
+	s.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/devices", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("GET /api/v1/devices status = %d, want %d", rec.Code, http.StatusNotFound)
+	}
+}
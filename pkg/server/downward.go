@@ -0,0 +1,38 @@
+package server
+
+import (
+	deviceapi "k8s.io/kubelet/pkg/apis/deviceplugin/v1beta1"
+)
+
+// nodeNameEnv is the container env var holding the node name
+const nodeNameEnv = "MICRO_NODE_NAME"
+
+// DownwardAPIInjector adds the downward API fields known to the plugin
+// to the container env vars of the Allocate responses. The device plugin
+// API carries no pod identity, kubelet sends the device IDs only, so the
+// pod name and namespace are left to the env fieldRefs of the pod spec.
+type DownwardAPIInjector struct {
+	// NodeName is injected as MICRO_NODE_NAME
+	NodeName string
+}
+
+// NewDownwardAPIInjector creates an injector of the node name, the
+// NODE_NAME env var or the host name if nodeName is empty
+func NewDownwardAPIInjector(nodeName string) *DownwardAPIInjector {
+	if nodeName == "" {
+		nodeName = NodeName()
+	}
+	return &DownwardAPIInjector{NodeName: nodeName}
+}
+
+// Inject adds the downward API env vars to the container allocate
+// response
+func (d *DownwardAPIInjector) Inject(resp *deviceapi.ContainerAllocateResponse) {
+	if d.NodeName == "" {
+		return
+	}
+	if resp.Envs == nil {
+		resp.Envs = make(map[string]string)
+	}
+	resp.Envs[nodeNameEnv] = d.NodeName
+}
@@ -0,0 +1,34 @@
+package server
+
+import (
+	"context"
+	"testing"
+
+	"github.com/kelein/micro-device-plugin/pkg/testing/assert"
+	"github.com/kelein/micro-device-plugin/pkg/testutil"
+)
+
+func TestDownwardAPIInjector(t *testing.T) {
+	t.Setenv("NODE_NAME", "node-a")
+	s, _ := newTestServer(t, WithDownwardAPIInjector(NewDownwardAPIInjector("")))
+
+	req := testutil.NewMockAllocateRequest().WithDeviceIDs(deviceID("micro0")).Build()
+	resp, err := s.Allocate(context.Background(), req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.AssertAllocateResponse(t, resp, map[string]string{nodeNameEnv: "node-a"}, nil)
+	envs := resp.ContainerResponses[0].Envs
+	for _, name := range []string{"MICRO_POD_NAME", "MICRO_POD_NAMESPACE"} {
+		if v, ok := envs[name]; ok {
+			t.Errorf("%s = %q injected without a pod identity in the request", name, v)
+		}
+	}
+
+	s.downwardAPI = NewDownwardAPIInjector("node-b")
+	resp, err = s.Allocate(context.Background(), testutil.NewMockAllocateRequest().WithDeviceIDs(deviceID("micro1")).Build())
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.AssertAllocateResponse(t, resp, map[string]string{nodeNameEnv: "node-b"}, nil)
+}
@@ -0,0 +1,206 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	resourceapi "k8s.io/api/resource/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
+	deviceapi "k8s.io/kubelet/pkg/apis/deviceplugin/v1beta1"
+
+	"github.com/kelein/micro-device-plugin/pkg/state"
+)
+
+// DRAAdapter fulfills the dynamic resource allocation ResourceClaims
+// requesting the plugin device class from the device map of the server
+type DRAAdapter struct {
+	server   *MicroDeviceServer
+	client   kubernetes.Interface
+	nodeName string
+}
+
+// NewDRAAdapter creates a DRA adapter allocating the devices of s to the
+// ResourceClaims, the node name is used as the device pool
+func NewDRAAdapter(s *MicroDeviceServer, client kubernetes.Interface, nodeName string) *DRAAdapter {
+	return &DRAAdapter{server: s, client: client, nodeName: nodeName}
+}
+
+// DRADriverName returns the DRA driver name of an extended resource,
+// e.g. micro.example.com for micro.example.com/device
+func DRADriverName(resource string) string {
+	domain, _, _ := strings.Cut(resource, "/")
+	return domain
+}
+
+// DRADeviceClass returns the DRA device class name of an extended
+// resource, e.g. device.micro.example.com for micro.example.com/device
+func DRADeviceClass(resource string) string {
+	domain, name, ok := strings.Cut(resource, "/")
+	if !ok {
+		return domain
+	}
+	return name + "." + domain
+}
+
+// Run watches the ResourceClaims until ctx is done
+func (a *DRAAdapter) Run(ctx context.Context) {
+	factory := informers.NewSharedInformerFactory(a.client, 0)
+	informer := factory.Resource().V1().ResourceClaims().Informer()
+	_, err := informer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc:    func(obj any) { a.syncClaim(ctx, obj) },
+		UpdateFunc: func(_, obj any) { a.syncClaim(ctx, obj) },
+		DeleteFunc: a.deleteClaim,
+	})
+	if err != nil {
+		a.server.logger.Error("add resource claim event handler failed", "err", err)
+		return
+	}
+
+	a.server.logger.Info("resource claim informer started", "class", a.deviceClass())
+	factory.Start(ctx.Done())
+}
+
+func (a *DRAAdapter) driver() string {
+	return DRADriverName(a.server.resourceName)
+}
+
+func (a *DRAAdapter) deviceClass() string {
+	return DRADeviceClass(a.server.resourceName)
+}
+
+// syncClaim allocates the devices of an unallocated claim and records the
+// devices of an allocated one
+func (a *DRAAdapter) syncClaim(ctx context.Context, obj any) {
+	claim, ok := obj.(*resourceapi.ResourceClaim)
+	if !ok {
+		return
+	}
+	if claim.Status.Allocation != nil {
+		a.server.markAllocated(a.claimDevices(claim))
+		return
+	}
+
+	results, err := a.allocate(claim)
+	if err != nil {
+		a.server.logger.Warn("allocate resource claim failed", "claim", claim.Namespace+"/"+claim.Name, "err", err)
+		return
+	}
+	if len(results) == 0 {
+		return
+	}
+
+	updated := claim.DeepCopy()
+	updated.Status.Allocation = &resourceapi.AllocationResult{
+		Devices: resourceapi.DeviceAllocationResult{Results: results},
+	}
+	ids := resultDevices(results)
+	_, err = a.client.ResourceV1().ResourceClaims(claim.Namespace).UpdateStatus(ctx, updated, metav1.UpdateOptions{})
+	if err != nil {
+		a.server.releaseDevices(ids, string(claim.UID))
+		a.server.logger.Error("update resource claim status failed", "claim", claim.Namespace+"/"+claim.Name, "err", err)
+		return
+	}
+	a.server.recordState(state.OpAlloc, ids, "")
+	a.server.logger.Info("resource claim allocated", "claim", claim.Namespace+"/"+claim.Name, "devices", a.server.logIDs(ids))
+}
+
+// allocate picks the devices of the claim requests for the plugin
+// device class with the allocation strategy, in device ID order without
+// one, the devices are marked allocated
+func (a *DRAAdapter) allocate(claim *resourceapi.ResourceClaim) ([]resourceapi.DeviceRequestAllocationResult, error) {
+	s := a.server
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	s.allocMu.Lock()
+	defer s.allocMu.Unlock()
+
+	var available []*MicroDevice
+	for _, dev := range s.devices {
+		if dev.Health == deviceapi.Healthy && !isReserved(dev) && !s.allocated[dev.ID] {
+			available = append(available, dev)
+		}
+	}
+	available = sortedByID(available)
+
+	var results []resourceapi.DeviceRequestAllocationResult
+	for _, req := range claim.Spec.Devices.Requests {
+		if req.Exactly == nil || req.Exactly.DeviceClassName != a.deviceClass() {
+			continue
+		}
+		count := int(req.Exactly.Count)
+		if req.Exactly.AllocationMode == resourceapi.DeviceAllocationModeAll {
+			count = len(available)
+		} else if count == 0 {
+			count = 1
+		}
+		if count > len(available) {
+			return nil, fmt.Errorf("request %s: %d devices requested, %d available", req.Name, count, len(available))
+		}
+		selected := available[:count]
+		if s.strategy != nil {
+			var err error
+			if selected, err = s.strategy.Select(available, count); err != nil {
+				return nil, fmt.Errorf("request %s: %w", req.Name, err)
+			}
+		}
+		taken := make(map[string]bool, count)
+		for _, dev := range selected {
+			taken[dev.ID] = true
+			results = append(results, resourceapi.DeviceRequestAllocationResult{
+				Request: req.Name,
+				Driver:  a.driver(),
+				Pool:    a.nodeName,
+				Device:  dev.ID,
+			})
+		}
+		available = slices.DeleteFunc(available, func(dev *MicroDevice) bool { return taken[dev.ID] })
+	}
+
+	for _, id := range resultDevices(results) {
+		s.allocated[id] = true
+	}
+	s.metrics.activeAllocations.Set(float64(len(s.allocated)))
+	return results, nil
+}
+
+// deleteClaim releases the devices allocated to a deleted claim
+func (a *DRAAdapter) deleteClaim(obj any) {
+	if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+		obj = tombstone.Obj
+	}
+	claim, ok := obj.(*resourceapi.ResourceClaim)
+	if !ok {
+		return
+	}
+	if ids := a.claimDevices(claim); len(ids) > 0 {
+		a.server.releaseDevices(ids, string(claim.UID))
+	}
+}
+
+// claimDevices returns the plugin devices allocated to the claim
+func (a *DRAAdapter) claimDevices(claim *resourceapi.ResourceClaim) []string {
+	if claim.Status.Allocation == nil {
+		return nil
+	}
+
+	var ids []string
+	for _, r := range claim.Status.Allocation.Devices.Results {
+		if r.Driver == a.driver() && r.Pool == a.nodeName {
+			ids = append(ids, r.Device)
+		}
+	}
+	return ids
+}
+
+func resultDevices(results []resourceapi.DeviceRequestAllocationResult) []string {
+	ids := make([]string, len(results))
+	for i, r := range results {
+		ids[i] = r.Device
+	}
+	return ids
+}
@@ -0,0 +1,70 @@
+package server
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	resourceapi "k8s.io/api/resource/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"github.com/kelein/micro-device-plugin/pkg/testing/assert"
+)
+
+func TestDRAAdapterFulfillsClaim(t *testing.T) {
+	client := fake.NewClientset()
+	reg := prometheus.NewRegistry()
+	s, _ := newTestServer(t, WithResourceName("micro.example.com/device"),
+		WithDRA(client, "node-1"), WithMetrics(reg))
+	id := s.addDevice(&MicroDevice{Name: "micro0"})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	s.dra.Run(ctx)
+
+	claim := &resourceapi.ResourceClaim{
+		ObjectMeta: metav1.ObjectMeta{Name: "claim", Namespace: "default", UID: "claim-uid"},
+		Spec: resourceapi.ResourceClaimSpec{
+			Devices: resourceapi.DeviceClaim{
+				Requests: []resourceapi.DeviceRequest{{
+					Name:    "micro",
+					Exactly: &resourceapi.ExactDeviceRequest{DeviceClassName: "device.micro.example.com"},
+				}},
+			},
+		},
+	}
+	claims := client.ResourceV1().ResourceClaims("default")
+	if _, err := claims.Create(ctx, claim, metav1.CreateOptions{}); err != nil {
+		t.Fatalf("create resource claim: %v", err)
+	}
+
+	var got *resourceapi.ResourceClaim
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		c, err := claims.Get(ctx, "claim", metav1.GetOptions{})
+		if err == nil && c.Status.Allocation != nil {
+			got = c
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if got == nil {
+		t.Fatal("resource claim was not allocated")
+	}
+
+	want := resourceapi.DeviceRequestAllocationResult{
+		Request: "micro",
+		Driver:  "micro.example.com",
+		Pool:    "node-1",
+		Device:  id,
+	}
+	results := got.Status.Allocation.Devices.Results
+	if !reflect.DeepEqual(results, []resourceapi.DeviceRequestAllocationResult{want}) {
+		t.Errorf("allocation results = %+v, want %+v", results, want)
+	}
+
+	assert.AssertMetricValue(t, reg, "micro_device_plugin_active_allocations", nil, 1)
+}
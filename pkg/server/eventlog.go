@@ -0,0 +1,75 @@
+package server
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/kelein/micro-device-plugin/pkg/state"
+)
+
+// parseEventType returns the event type named name
+func parseEventType(name string) (EventType, bool) {
+	for t := DeviceAdded; t <= AllocationCompleted; t++ {
+		if t.String() == name {
+			return t, true
+		}
+	}
+	return 0, false
+}
+
+// replayEvents restores the event history from the event log, the device
+// map rebuilt from the logged events is reported for comparison with the
+// discovered devices
+func (s *MicroDeviceServer) replayEvents() {
+	if s.eventStore == nil {
+		return
+	}
+	events := s.eventStore.Since(time.Time{})
+	for _, event := range events {
+		t, ok := parseEventType(event.Type)
+		if !ok {
+			continue
+		}
+		var payload state.EventPayload
+		if err := json.Unmarshal(event.Payload, &payload); err != nil {
+			s.logger.Error("decode logged event failed", "seq", event.Seq, "err", err)
+			continue
+		}
+		s.history.record(DeviceEvent{
+			Type:      t,
+			Device:    payload.Device,
+			DeviceIDs: payload.DeviceIDs,
+			RequestID: payload.RequestID,
+			Time:      event.Time,
+		})
+	}
+
+	devices, err := state.ReplayDevices(events)
+	if err != nil {
+		s.logger.Error("replay event log failed", "err", err)
+		return
+	}
+	s.logger.Info("event log replayed", "events", len(events), "devices", len(devices))
+}
+
+// recordEvents appends the events of all types published on the bus to
+// the event log
+func (s *MicroDeviceServer) recordEvents() {
+	if s.eventStore == nil {
+		return
+	}
+	for t := DeviceAdded; t <= AllocationCompleted; t++ {
+		s.events.Subscribe(t, s.logEvent)
+	}
+}
+
+func (s *MicroDeviceServer) logEvent(event DeviceEvent) {
+	payload := state.EventPayload{
+		Device:    event.Device,
+		DeviceIDs: event.DeviceIDs,
+		RequestID: event.RequestID,
+	}
+	if _, err := s.eventStore.Append(event.Type.String(), payload); err != nil {
+		s.logger.Error("append event log failed", "type", event.Type, "err", err)
+	}
+}
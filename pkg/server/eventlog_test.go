@@ -0,0 +1,117 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	deviceapi "k8s.io/kubelet/pkg/apis/deviceplugin/v1beta1"
+
+	"github.com/kelein/micro-device-plugin/pkg/state"
+	"github.com/kelein/micro-device-plugin/pkg/testing/assert"
+)
+
+func openEventStore(t *testing.T, path string) *state.EventStore {
+	t.Helper()
+	store, err := state.OpenEventStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { store.Close() })
+	return store
+}
+
+func TestEventLogReplaysDeviceMap(t *testing.T) {
+	dir := t.TempDir()
+	logPath := filepath.Join(t.TempDir(), "events.jsonl")
+	store := openEventStore(t, logPath)
+	s, _ := newTestServer(t, WithEventStore(store))
+
+	for _, name := range []string{"micro0", "micro1", "micro2"} {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, nil, 0600); err != nil {
+			t.Fatal(err)
+		}
+		s.addDevice(&MicroDevice{Name: name, Path: path, Health: deviceapi.Healthy})
+	}
+	s.deleteDevice("micro1")
+	if err := os.Remove(filepath.Join(dir, "micro2")); err != nil {
+		t.Fatal(err)
+	}
+	s.checkHealth()
+	s.markAllocated([]string{deviceID("micro0")})
+	assert.AssertDeviceUnhealthy(t, s, "micro2")
+
+	s.mu.RLock()
+	want := make(map[string]MicroDevice, len(s.devices))
+	for name, dev := range s.devices {
+		want[name] = *dev
+	}
+	s.mu.RUnlock()
+
+	// replay the file written by the server rather than the loaded events
+	store.Close()
+	replayed := openEventStore(t, logPath)
+	got, err := state.ReplayDevices(replayed.Since(time.Time{}))
+	if err != nil {
+		t.Fatalf("ReplayDevices() error = %v", err)
+	}
+	if len(got) != len(want) {
+		t.Fatalf("replayed %d devices, want %d", len(got), len(want))
+	}
+	for name, dev := range want {
+		r, ok := got[name]
+		if !ok {
+			t.Errorf("replayed device map has no device %s", name)
+			continue
+		}
+		if r.ID != dev.ID || r.Path != dev.Path || r.Health != dev.Health {
+			t.Errorf("replayed device %s = %+v, want %+v", name, *r, dev)
+		}
+	}
+	if got["micro2"].Health != deviceapi.Unhealthy {
+		t.Error("replayed device micro2 is not unhealthy")
+	}
+
+	restarted, _ := newTestServer(t, WithEventStore(replayed))
+	if n := len(restarted.history.recent()); n != len(replayed.Since(time.Time{})) {
+		t.Errorf("restored %d history events, want all logged events", n)
+	}
+}
+
+func TestHandleEvents(t *testing.T) {
+	store := openEventStore(t, filepath.Join(t.TempDir(), "events.jsonl"))
+	s, _ := newTestServer(t, WithEventStore(store))
+	s.addDevice(&MicroDevice{Name: "micro0", Path: "/etc/micro/micro0"})
+
+	get := func(target string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		s.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, target, nil))
+		return rec
+	}
+
+	rec := get("/events?since=0")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("GET /events status = %d, want %d", rec.Code, http.StatusOK)
+	}
+	var events []state.Event
+	if err := json.Unmarshal(rec.Body.Bytes(), &events); err != nil {
+		t.Fatalf("decode events: %v", err)
+	}
+	if len(events) != 1 || events[0].Type != "DeviceAdded" {
+		t.Errorf("GET /events = %+v, want the DeviceAdded event", events)
+	}
+
+	future := strconv.FormatInt(time.Now().Add(time.Hour).Unix(), 10)
+	if rec := get("/events?since=" + future); rec.Body.String() != "[]\n" {
+		t.Errorf("GET /events in the future = %q, want no events", rec.Body.String())
+	}
+	if rec := get("/events?since=yesterday"); rec.Code != http.StatusBadRequest {
+		t.Errorf("GET /events with invalid since status = %d, want %d", rec.Code, http.StatusBadRequest)
+	}
+}
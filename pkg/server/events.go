@@ -0,0 +1,107 @@
+package server
+
+import (
+	"sync"
+	"time"
+)
+
+// EventType is the kind of a device event
+type EventType int
+
+// Device event types
+const (
+	DeviceAdded EventType = iota
+	DeviceRemoved
+	DeviceUnhealthy
+	DeviceHealthy
+	AllocationStarted
+	AllocationCompleted
+)
+
+func (t EventType) String() string {
+	switch t {
+	case DeviceAdded:
+		return "DeviceAdded"
+	case DeviceRemoved:
+		return "DeviceRemoved"
+	case DeviceUnhealthy:
+		return "DeviceUnhealthy"
+	case DeviceHealthy:
+		return "DeviceHealthy"
+	case AllocationStarted:
+		return "AllocationStarted"
+	case AllocationCompleted:
+		return "AllocationCompleted"
+	default:
+		return "Unknown"
+	}
+}
+
+// DeviceEvent is published on device changes and allocations, Device is
+// set for device events, DeviceIDs and RequestID for allocation events
+type DeviceEvent struct {
+	Type      EventType
+	Device    *MicroDevice
+	DeviceIDs []string
+	RequestID string
+	Time      time.Time
+}
+
+// EventHandler handles a published device event
+type EventHandler func(event DeviceEvent)
+
+// UnsubscribeFunc removes a subscribed handler
+type UnsubscribeFunc func()
+
+// EventBus dispatches device events to the subscribed handlers
+type EventBus struct {
+	mu       sync.RWMutex
+	nextID   int
+	handlers map[EventType]map[int]EventHandler
+}
+
+// NewEventBus creates an empty event bus
+func NewEventBus() *EventBus {
+	return &EventBus{handlers: make(map[EventType]map[int]EventHandler)}
+}
+
+// Subscribe registers handler for the events of eventType
+func (b *EventBus) Subscribe(eventType EventType, handler EventHandler) UnsubscribeFunc {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	id := b.nextID
+	b.nextID++
+	if b.handlers[eventType] == nil {
+		b.handlers[eventType] = make(map[int]EventHandler)
+	}
+	b.handlers[eventType][id] = handler
+
+	return func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		delete(b.handlers[eventType], id)
+	}
+}
+
+// Publish calls the handlers subscribed to the event type synchronously,
+// handlers must not block the publishing plugin
+func (b *EventBus) Publish(event DeviceEvent) {
+	if event.Time.IsZero() {
+		event.Time = time.Now()
+	}
+	b.mu.RLock()
+	handlers := make([]EventHandler, 0, len(b.handlers[event.Type]))
+	for _, h := range b.handlers[event.Type] {
+		handlers = append(handlers, h)
+	}
+	b.mu.RUnlock()
+
+	for _, h := range handlers {
+		h(event)
+	}
+}
+
+// Events returns the device event bus of the server
+func (s *MicroDeviceServer) Events() *EventBus {
+	return s.events
+}
@@ -0,0 +1,76 @@
+package server
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestEventBusDeviceAdded(t *testing.T) {
+	dir := t.TempDir()
+	s, _ := newTestServer(t, WithDevicePath(dir))
+	added := make(chan DeviceEvent, 16)
+	unsubscribe := s.Events().Subscribe(DeviceAdded, func(event DeviceEvent) {
+		added <- event
+	})
+	removed := make(chan DeviceEvent, 16)
+	s.Events().Subscribe(DeviceRemoved, func(event DeviceEvent) {
+		removed <- event
+	})
+	go s.watchDevice()
+
+	// action: create device files until the watcher is ready and reports
+	// the first one
+	var event DeviceEvent
+	created := make(map[string]bool)
+	deadline := time.After(5 * time.Second)
+	for i := 0; event.Device == nil; i++ {
+		name := "micro" + strconv.Itoa(i)
+		createDevices(t, dir, name)
+		created[name] = true
+		select {
+		case event = <-added:
+		case <-time.After(50 * time.Millisecond):
+		case <-deadline:
+			t.Fatal("DeviceAdded handler not called on device file creation")
+		}
+	}
+	if !created[event.Device.Name] || event.Device.ID != deviceID(event.Device.Name) {
+		t.Errorf("DeviceAdded event of device %+v, want one of the created files", event.Device)
+	}
+	if event.Time.IsZero() {
+		t.Error("DeviceAdded event has no time")
+	}
+
+	// action: the unsubscribed handler is not called, the removal is
+	// published to the DeviceRemoved handler
+	unsubscribe()
+	for len(added) > 0 {
+		<-added
+	}
+	if err := os.Remove(filepath.Join(dir, event.Device.Name)); err != nil {
+		t.Fatal(err)
+	}
+	createDevices(t, dir, "micro-late")
+	select {
+	case got := <-removed:
+		if got.Device == nil || got.Device.Name != event.Device.Name {
+			t.Errorf("DeviceRemoved event of device %+v, want %s", got.Device, event.Device.Name)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("DeviceRemoved handler not called on device file removal")
+	}
+	timeout := time.After(100 * time.Millisecond)
+	for {
+		select {
+		case got := <-added:
+			if got.Device.Name == "micro-late" {
+				t.Errorf("unsubscribed handler called with %+v", got.Device)
+			}
+		case <-timeout:
+			return
+		}
+	}
+}
@@ -0,0 +1,100 @@
+package server
+
+import (
+	"context"
+	"slices"
+	"strconv"
+	"testing"
+	"time"
+
+	deviceapi "k8s.io/kubelet/pkg/apis/deviceplugin/v1beta1"
+
+	"github.com/kelein/micro-device-plugin/pkg/config"
+	"github.com/kelein/micro-device-plugin/pkg/testutil"
+)
+
+// gatedServer creates a test server configured with the feature gates
+func gatedServer(t *testing.T, gates config.FeatureGates, opts ...Option) *MicroDeviceServer {
+	t.Helper()
+	cfg := config.Default()
+	cfg.PluginPath = t.TempDir()
+	cfg.DevicePath = t.TempDir()
+	cfg.FeatureGates = gates
+	s, _ := newTestServer(t, append([]Option{WithConfig(cfg)}, opts...)...)
+	return s
+}
+
+func TestFeatureGateAllocate(t *testing.T) {
+	tests := []struct {
+		name      string
+		gates     config.FeatureGates
+		wantToken bool
+		wantCDI   bool
+	}{
+		{name: "defaults", wantToken: true},
+		{name: "claim tokens disabled", gates: config.FeatureGates{config.ClaimTokens: false}},
+		{name: "cdi enabled", gates: config.FeatureGates{config.CDIDeviceSpecs: true}, wantToken: true, wantCDI: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := gatedServer(t, tt.gates, WithClaimTokens(time.Minute))
+			// precondition: kubelet supports CDI devices
+			s.mu.Lock()
+			s.kubeletFeatures.CDI = true
+			s.mu.Unlock()
+			id := s.addDevice(&MicroDevice{Name: "micro0"})
+
+			resp, err := s.Allocate(context.Background(), testutil.NewMockAllocateRequest().WithDeviceIDs(id).Build())
+			if err != nil {
+				t.Fatal(err)
+			}
+			c := resp.ContainerResponses[0]
+			if _, ok := c.Envs["MICRO_DEVICE_TOKEN"]; ok != tt.wantToken {
+				t.Errorf("claim token injected = %v, want %v", ok, tt.wantToken)
+			}
+			if got := len(c.CDIDevices) > 0; got != tt.wantCDI {
+				t.Errorf("CDI devices %v, want injected %v", c.CDIDevices, tt.wantCDI)
+			}
+		})
+	}
+}
+
+func TestFeatureGateNUMATopology(t *testing.T) {
+	tests := []struct {
+		name  string
+		gates config.FeatureGates
+		want  []string // expected: preferred devices
+	}{
+		// the NUMA node 1 holds both requested devices
+		{name: "enabled", want: []string{"micro1", "micro2"}},
+		// the scorer is ignored, the available devices are taken in order
+		{name: "disabled", gates: config.FeatureGates{config.NUMATopology: false}, want: []string{"micro0", "micro1"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := gatedServer(t, tt.gates, WithScorer(NUMAScorer{}))
+			var ids []string
+			for i, node := range []string{"0", "1", "1"} {
+				ids = append(ids, s.addDevice(&MicroDevice{
+					Name:        "micro" + strconv.Itoa(i),
+					Annotations: map[string]string{numaAnnotation: node},
+				}))
+			}
+
+			req := &deviceapi.PreferredAllocationRequest{
+				ContainerRequests: []*deviceapi.ContainerPreferredAllocationRequest{{
+					AvailableDeviceIDs: ids,
+					AllocationSize:     2,
+				}},
+			}
+			resp, err := newPluginClient(t, s).GetPreferredAllocation(context.Background(), req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := slices.Sorted(slices.Values(resp.ContainerResponses[0].DeviceIDs))
+			if want := sortedIDs(tt.want...); !slices.Equal(got, want) {
+				t.Errorf("preferred devices = %v, want %v", got, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,44 @@
+package server
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	deviceapi "k8s.io/kubelet/pkg/apis/deviceplugin/v1beta1"
+)
+
+// newTestServer creates a server with its own metrics registry, plugin
+// and device directories and no watchdog, stopped at the end of the test.
+// The options override the defaults, it returns the plugin directory.
+func newTestServer(t *testing.T, opts ...Option) (*MicroDeviceServer, string) {
+	t.Helper()
+	dir := t.TempDir()
+	opts = append([]Option{
+		WithPluginPath(dir),
+		WithDevicePath(t.TempDir()),
+		WithWatchdogTimeout(0),
+		WithMetrics(prometheus.NewRegistry()),
+	}, opts...)
+	s, err := NewMicroDeviceServer(opts...)
+	if err != nil {
+		t.Fatalf("NewMicroDeviceServer() error = %v", err)
+	}
+	t.Cleanup(s.Stop)
+	return s, dir
+}
+
+// DeviceHealth returns the health of the named device advertised to
+// kubelet, reserved and draining devices are unhealthy. It implements
+// assert.HealthReporter.
+func (s *MicroDeviceServer) DeviceHealth(name string) (string, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	dev, ok := s.devices[name]
+	if !ok {
+		return "", false
+	}
+	if s.draining {
+		return deviceapi.Unhealthy, true
+	}
+	return allocatableDevice(dev).Health, true
+}
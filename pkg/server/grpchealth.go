@@ -0,0 +1,26 @@
+package server
+
+import (
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+// devicePluginService is the gRPC service name of the device plugin API
+const devicePluginService = "v1beta1.DevicePlugin"
+
+// updateGRPCHealth reports the plugin serving over the gRPC health
+// service when it is registered with kubelet and has a healthy device
+func (s *MicroDeviceServer) updateGRPCHealth() {
+	if s.grpcHealth == nil {
+		return
+	}
+	s.mu.RLock()
+	registered := s.registered && !s.draining
+	s.mu.RUnlock()
+
+	status := healthpb.HealthCheckResponse_NOT_SERVING
+	if registered && s.healthyCount() > 0 {
+		status = healthpb.HealthCheckResponse_SERVING
+	}
+	s.grpcHealth.SetServingStatus("", status)
+	s.grpcHealth.SetServingStatus(devicePluginService, status)
+}
@@ -0,0 +1,65 @@
+//go:build integration
+
+package server
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+func TestGRPCHealth(t *testing.T) {
+	s, dir := newTestServer(t, WithHealthInterval(0))
+	startFakeKubelet(t, dir)
+	if err := s.Run(); err != nil {
+		t.Fatalf("Run() = %v", err)
+	}
+
+	conn, err := grpc.NewClient("unix://"+s.socketPath(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	client := healthpb.NewHealthClient(conn)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	resp, err := client.Check(ctx, &healthpb.HealthCheckRequest{})
+	if err != nil {
+		t.Fatalf("Check() error = %v", err)
+	}
+	if resp.Status != healthpb.HealthCheckResponse_NOT_SERVING {
+		t.Errorf("Check() before registration = %s, want NOT_SERVING", resp.Status)
+	}
+
+	watch, err := client.Watch(ctx, &healthpb.HealthCheckRequest{Service: devicePluginService})
+	if err != nil {
+		t.Fatalf("Watch() error = %v", err)
+	}
+	expect := func(want healthpb.HealthCheckResponse_ServingStatus) {
+		t.Helper()
+		resp, err := watch.Recv()
+		if err != nil {
+			t.Fatalf("Watch Recv() error = %v", err)
+		}
+		if resp.Status != want {
+			t.Fatalf("Watch status = %s, want %s", resp.Status, want)
+		}
+	}
+	expect(healthpb.HealthCheckResponse_NOT_SERVING)
+
+	// registered with a healthy device
+	s.addDevice(&MicroDevice{Name: "micro0"})
+	if err := s.RegisterToKubelet(); err != nil {
+		t.Fatalf("RegisterToKubelet() = %v", err)
+	}
+	expect(healthpb.HealthCheckResponse_SERVING)
+
+	// the last healthy device is gone
+	s.deleteDevice("micro0")
+	expect(healthpb.HealthCheckResponse_NOT_SERVING)
+}
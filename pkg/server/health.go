@@ -0,0 +1,110 @@
+package server
+
+import (
+	"strconv"
+	"time"
+
+	deviceapi "k8s.io/kubelet/pkg/apis/deviceplugin/v1beta1"
+
+	"github.com/kelein/micro-device-plugin/pkg/state"
+)
+
+// healthCheck periodically checks the health of devices
+func (s *MicroDeviceServer) healthCheck() {
+	s.logger.Info("device health check started", "interval", s.healthInterval)
+	ticker := time.NewTicker(s.healthInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			s.checkHealth()
+		case <-s.ctx.Done():
+			s.logger.Info("device health check exited")
+			return
+		}
+	}
+}
+
+// checkHealth updates the device health with the health policy and
+// notifies kubelet of health changes
+func (s *MicroDeviceServer) checkHealth() {
+	// the health policy may run commands, check copies of the devices
+	// without holding the lock
+	s.mu.RLock()
+	var checked []MicroDevice
+	for _, dev := range s.devices {
+		if dev.Path != "" {
+			checked = append(checked, *dev)
+		}
+	}
+	s.mu.RUnlock()
+	healths := make(map[string]string, len(checked))
+	for i := range checked {
+		healths[checked[i].Name] = s.checkDevice(&checked[i])
+	}
+
+	var events []DeviceEvent
+	changed := make(map[string][]string)
+	s.mu.Lock()
+	for name, health := range healths {
+		dev, ok := s.devices[name]
+		if !ok || !s.isWarm(name) || attestationFailed(dev) {
+			continue
+		}
+		if health == deviceapi.Healthy {
+			s.lastSeen[dev.Name] = time.Now()
+		}
+		if dev.Health != health {
+			s.logger.Info("device health changed", "name", dev.Name, "health", health)
+			dev.Health = health
+			changed[health] = append(changed[health], dev.ID)
+			snapshot := *dev
+			event := DeviceEvent{Type: DeviceHealthy, Device: &snapshot}
+			if health == deviceapi.Unhealthy {
+				event.Type = DeviceUnhealthy
+			}
+			events = append(events, event)
+		}
+	}
+	s.mu.Unlock()
+
+	for health, ids := range changed {
+		s.recordState(state.OpHealthChange, ids, health)
+	}
+	for _, event := range events {
+		s.events.Publish(event)
+	}
+
+	s.writeDeviceCount()
+	s.writeMetricsFile()
+	s.reportCondition()
+	s.updateGRPCHealth()
+	if len(events) > 0 {
+		s.notifyChange()
+	}
+}
+
+// healthyCount returns the number of healthy devices
+func (s *MicroDeviceServer) healthyCount() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	n := 0
+	for _, dev := range s.devices {
+		if dev.Health == deviceapi.Healthy {
+			n++
+		}
+	}
+	return n
+}
+
+// writeDeviceCount writes the healthy device count file if configured
+func (s *MicroDeviceServer) writeDeviceCount() {
+	if s.deviceCountFile == "" {
+		return
+	}
+	count := strconv.Itoa(s.healthyCount())
+	if err := writeFileAtomic(s.deviceCountFile, []byte(count), 0644); err != nil {
+		s.logger.Error("write device count file failed", "path", s.deviceCountFile, "err", err)
+	}
+}
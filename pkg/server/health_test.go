@@ -0,0 +1,45 @@
+package server
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDeviceCountFile(t *testing.T) {
+	devDir := t.TempDir()
+	createDevices(t, devDir, "micro0", "micro1", "micro2")
+	// the directory of the count file does not exist yet
+	countFile := filepath.Join(t.TempDir(), "run", "micro-device-count")
+	s, _ := newTestServer(t, WithDevicePath(devDir), WithDeviceCountFile(countFile))
+
+	readCount := func() string {
+		t.Helper()
+		data, err := os.ReadFile(countFile)
+		if err != nil {
+			t.Fatalf("read device count file: %v", err)
+		}
+		return string(data)
+	}
+
+	// action: discover the devices
+	if err := s.findDevice(); err != nil {
+		t.Fatal(err)
+	}
+	if got := readCount(); got != "3" {
+		t.Errorf("device count after discovery = %q, want 3", got)
+	}
+
+	// action: a device fails the health check tick
+	if err := os.Remove(filepath.Join(devDir, "micro1")); err != nil {
+		t.Fatal(err)
+	}
+	s.checkHealth()
+	if got := readCount(); got != "2" {
+		t.Errorf("device count after health check = %q, want 2", got)
+	}
+
+	if _, err := os.Stat(countFile + ".tmp"); !os.IsNotExist(err) {
+		t.Errorf("temporary count file left behind: %v", err)
+	}
+}
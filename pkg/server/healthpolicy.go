@@ -0,0 +1,126 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"time"
+
+	deviceapi "k8s.io/kubelet/pkg/apis/deviceplugin/v1beta1"
+)
+
+// DeviceHealthPolicy determines the health of a device, the returned
+// health is deviceapi.Healthy or deviceapi.Unhealthy
+type DeviceHealthPolicy interface {
+	Check(device *MicroDevice) (string, error)
+}
+
+// Compile-time checks of the DeviceHealthPolicy implementations
+var (
+	_ DeviceHealthPolicy = FileExistPolicy{}
+	_ DeviceHealthPolicy = FileReadablePolicy{}
+	_ DeviceHealthPolicy = CommandPolicy{}
+	_ DeviceHealthPolicy = AlwaysHealthyPolicy{}
+)
+
+// NewHealthPolicy returns the built-in health policy by name:
+// file-exist, file-readable, command or always-healthy, the command
+// is only used by the command policy
+func NewHealthPolicy(name, command string) (DeviceHealthPolicy, error) {
+	switch name {
+	case "file-exist":
+		return FileExistPolicy{}, nil
+	case "file-readable":
+		return FileReadablePolicy{}, nil
+	case "command":
+		if command == "" {
+			return nil, errors.New("command health policy requires a command")
+		}
+		return CommandPolicy{Command: command, Timeout: 10 * time.Second}, nil
+	case "always-healthy":
+		return AlwaysHealthyPolicy{}, nil
+	default:
+		return nil, fmt.Errorf("unknown health policy %q", name)
+	}
+}
+
+// FileExistPolicy reports devices healthy while their file exists
+type FileExistPolicy struct{}
+
+// Check implements DeviceHealthPolicy
+func (FileExistPolicy) Check(dev *MicroDevice) (string, error) {
+	if _, err := os.Stat(dev.Path); err != nil {
+		return deviceapi.Unhealthy, nil
+	}
+	return deviceapi.Healthy, nil
+}
+
+// FileReadablePolicy reports devices healthy while their file can be
+// opened for reading
+type FileReadablePolicy struct{}
+
+// Check implements DeviceHealthPolicy
+func (FileReadablePolicy) Check(dev *MicroDevice) (string, error) {
+	f, err := os.Open(dev.Path)
+	if err != nil {
+		return deviceapi.Unhealthy, nil
+	}
+	f.Close()
+	return deviceapi.Healthy, nil
+}
+
+// CommandPolicy runs a shell command per device and reports the device
+// healthy if it exits with 0. The device is passed in the environment
+// variables MICRO_DEVICE_NAME, MICRO_DEVICE_ID and MICRO_DEVICE_PATH.
+type CommandPolicy struct {
+	Command string
+	Timeout time.Duration
+}
+
+// Check implements DeviceHealthPolicy
+func (p CommandPolicy) Check(dev *MicroDevice) (string, error) {
+	ctx := context.Background()
+	if p.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, p.Timeout)
+		defer cancel()
+	}
+
+	cmd := exec.CommandContext(ctx, "/bin/sh", "-c", p.Command)
+	cmd.Env = append(os.Environ(),
+		"MICRO_DEVICE_NAME="+dev.Name,
+		"MICRO_DEVICE_ID="+dev.ID,
+		"MICRO_DEVICE_PATH="+dev.Path,
+	)
+	err := cmd.Run()
+	var exitErr *exec.ExitError
+	switch {
+	case err == nil:
+		return deviceapi.Healthy, nil
+	case errors.As(err, &exitErr) && ctx.Err() == nil:
+		return deviceapi.Unhealthy, nil
+	default:
+		return deviceapi.Unhealthy, fmt.Errorf("run health command: %w", err)
+	}
+}
+
+// AlwaysHealthyPolicy reports all devices healthy
+type AlwaysHealthyPolicy struct{}
+
+// Check implements DeviceHealthPolicy
+func (AlwaysHealthyPolicy) Check(*MicroDevice) (string, error) {
+	return deviceapi.Healthy, nil
+}
+
+// checkDevice returns the device health under the health policy, errors
+// are logged and reported unhealthy
+func (s *MicroDeviceServer) checkDevice(dev *MicroDevice) string {
+	health, err := s.healthPolicy.Check(dev)
+	if err != nil {
+		s.logger.Warn("device health check failed", "name", dev.Name, "err", err)
+		return deviceapi.Unhealthy
+	}
+	return health
+}
@@ -0,0 +1,82 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	deviceapi "k8s.io/kubelet/pkg/apis/deviceplugin/v1beta1"
+
+	"github.com/kelein/micro-device-plugin/pkg/testing/assert"
+)
+
+func TestHealthPolicies(t *testing.T) {
+	dir := t.TempDir()
+	present := filepath.Join(dir, "micro0")
+	if err := os.WriteFile(present, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	unreadable := filepath.Join(dir, "micro1")
+	if err := os.WriteFile(unreadable, nil, 0); err != nil {
+		t.Fatal(err)
+	}
+	missing := filepath.Join(dir, "micro2")
+
+	tests := []struct {
+		name   string
+		policy DeviceHealthPolicy
+		path   string
+		want   string
+	}{
+		{"exist", FileExistPolicy{}, present, deviceapi.Healthy},
+		{"exist missing", FileExistPolicy{}, missing, deviceapi.Unhealthy},
+		{"readable", FileReadablePolicy{}, present, deviceapi.Healthy},
+		{"readable missing", FileReadablePolicy{}, missing, deviceapi.Unhealthy},
+		{"command success", CommandPolicy{Command: `test "$MICRO_DEVICE_NAME" = micro0`}, present, deviceapi.Healthy},
+		{"command failure", CommandPolicy{Command: "exit 3"}, present, deviceapi.Unhealthy},
+		{"always healthy", AlwaysHealthyPolicy{}, missing, deviceapi.Healthy},
+	}
+	if os.Getuid() != 0 {
+		tests = append(tests, struct {
+			name   string
+			policy DeviceHealthPolicy
+			path   string
+			want   string
+		}{"readable unreadable", FileReadablePolicy{}, unreadable, deviceapi.Unhealthy})
+	}
+	for _, tt := range tests {
+		got, err := tt.policy.Check(&MicroDevice{Name: "micro0", Path: tt.path})
+		if err != nil {
+			t.Errorf("%s: Check() error = %v", tt.name, err)
+		}
+		if got != tt.want {
+			t.Errorf("%s: Check() = %s, want %s", tt.name, got, tt.want)
+		}
+	}
+}
+
+type unhealthyPolicy struct{}
+
+func (unhealthyPolicy) Check(*MicroDevice) (string, error) {
+	return deviceapi.Unhealthy, errors.New("device offline")
+}
+
+func TestHealthPolicyCheckHealthAndPreStart(t *testing.T) {
+	s, _ := newTestServer(t, WithHealthPolicy(unhealthyPolicy{}))
+	s.devices["micro0"] = &MicroDevice{Name: "micro0", ID: deviceID("micro0"), Path: "/dev/micro0", Health: deviceapi.Healthy}
+
+	go func() { <-s.notify }()
+	s.checkHealth()
+	assert.AssertDeviceUnhealthy(t, s, "micro0")
+
+	_, err := s.PreStartContainer(context.Background(), &deviceapi.PreStartContainerRequest{
+		DevicesIDs: []string{deviceID("micro0")},
+	})
+	if status.Code(err) != codes.FailedPrecondition {
+		t.Errorf("PreStartContainer() error = %v, want FailedPrecondition", err)
+	}
+}
@@ -0,0 +1,125 @@
+package server
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+
+	"github.com/kelein/micro-device-plugin/pkg/state"
+)
+
+// Handler returns the HTTP handler serving metrics and plugin status
+func (s *MicroDeviceServer) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.Handle("GET /metrics", s.metricsHandler())
+	mux.HandleFunc("GET /status", s.handleStatus)
+	mux.HandleFunc("GET /healthz", s.handleHealthz)
+	mux.HandleFunc("GET /info", s.handleInfo)
+	mux.HandleFunc("GET /ui", s.handleUI)
+	mux.HandleFunc("GET /snapshot", s.handleSnapshot)
+	if s.cfg != nil {
+		mux.HandleFunc("GET /config", s.handleConfig)
+	}
+	if s.eventStore != nil {
+		mux.HandleFunc("GET /events", s.handleEvents)
+	}
+	if s.claims != nil {
+		mux.HandleFunc("GET /verify-claim", s.handleVerifyClaim)
+	}
+	if s.restAPI {
+		mux.HandleFunc("GET /api/v1/devices", s.handleDevices)
+	}
+	if s.updater != nil {
+		mux.HandleFunc("POST /update", s.handleUpdate)
+	}
+	if s.standalone {
+		mux.HandleFunc("GET /devices", s.handleStandaloneDevices)
+		mux.HandleFunc("POST /allocate", s.handleStandaloneAllocate)
+		mux.HandleFunc("POST /deallocate", s.handleStandaloneDeallocate)
+	}
+	return mux
+}
+
+// metricsHandler serves the metrics of the server registry
+func (s *MicroDeviceServer) metricsHandler() http.Handler {
+	if s.registry != prometheus.DefaultRegisterer {
+		return promhttp.HandlerFor(s.gatherer(), promhttp.HandlerOpts{})
+	}
+	return promhttp.Handler()
+}
+
+func (s *MicroDeviceServer) handleStatus(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, s.Status())
+}
+
+func (s *MicroDeviceServer) handleHealthz(w http.ResponseWriter, r *http.Request) {
+	if err := s.Live(); err != nil {
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+	w.Write([]byte("ok"))
+}
+
+// handleConfig serves the active configuration with the sensitive values
+// redacted
+func (s *MicroDeviceServer) handleConfig(w http.ResponseWriter, r *http.Request) {
+	data, err := s.cfg.MarshalSafeJSON()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(data)
+}
+
+// handleSnapshot serves the device map serialized to protobuf for the
+// transfer to another plugin process
+func (s *MicroDeviceServer) handleSnapshot(w http.ResponseWriter, r *http.Request) {
+	data, err := s.devicesSnapshot()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/protobuf")
+	w.Write(data)
+}
+
+// handleEvents serves the logged events recorded since the unix timestamp
+// of the since parameter, all events if unset
+func (s *MicroDeviceServer) handleEvents(w http.ResponseWriter, r *http.Request) {
+	var since time.Time
+	if v := r.URL.Query().Get("since"); v != "" {
+		sec, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			http.Error(w, "invalid since timestamp "+strconv.Quote(v), http.StatusBadRequest)
+			return
+		}
+		since = time.Unix(sec, 0)
+	}
+	events := s.eventStore.Since(since)
+	if events == nil {
+		events = []state.Event{}
+	}
+	writeJSON(w, http.StatusOK, events)
+}
+
+// devicesSnapshot serializes the device map to protobuf
+func (s *MicroDeviceServer) devicesSnapshot() ([]byte, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return state.SerializeDevices(s.devices)
+}
+
+// writeJSON writes v as JSON response with the status code
+func writeJSON(w http.ResponseWriter, code int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		slog.Error("write json response failed", "err", err)
+	}
+}
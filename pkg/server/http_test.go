@@ -0,0 +1,142 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"maps"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
+
+	"github.com/kelein/micro-device-plugin/pkg/config"
+	"github.com/kelein/micro-device-plugin/pkg/state"
+	"github.com/kelein/micro-device-plugin/pkg/testing/assert"
+	"github.com/kelein/micro-device-plugin/pkg/testutil"
+)
+
+func TestHandleConfig(t *testing.T) {
+	cfg := config.Default()
+	cfg.ResourceName = "example.com/micro"
+	cfg.MaxDevices = 4
+	cfg.AttestationKeyFile = "/etc/micro/attest.key"
+	cfg.AdmissionTLSCertFile = "/etc/micro/tls.crt"
+	cfg.AdmissionTLSKeyFile = "/etc/micro/tls.key"
+	s, _ := newTestServer(t, WithConfig(cfg))
+
+	rec := httptest.NewRecorder()
+	s.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/config", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("GET /config status = %d, want %d", rec.Code, http.StatusOK)
+	}
+
+	var got config.Config
+	if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+		t.Fatalf("decode config: %v", err)
+	}
+	if got.ResourceName != cfg.ResourceName || got.DevicePath != cfg.DevicePath || got.MaxDevices != cfg.MaxDevices {
+		t.Errorf("GET /config = %+v, want the values of %+v", got, cfg)
+	}
+	if got.AdmissionTLSCertFile != cfg.AdmissionTLSCertFile {
+		t.Errorf("admission TLS cert file = %q, want %q", got.AdmissionTLSCertFile, cfg.AdmissionTLSCertFile)
+	}
+	if got.AttestationKeyFile != config.Redacted || got.AdmissionTLSKeyFile != config.Redacted {
+		t.Errorf("key files %q and %q not redacted", got.AttestationKeyFile, got.AdmissionTLSKeyFile)
+	}
+	if cfg.AttestationKeyFile != "/etc/micro/attest.key" {
+		t.Error("redaction modified the active config")
+	}
+}
+
+func TestHandleConfigWithoutConfig(t *testing.T) {
+	s, _ := newTestServer(t)
+
+	rec := httptest.NewRecorder()
+	s.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/config", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("GET /config status = %d, want %d", rec.Code, http.StatusNotFound)
+	}
+}
+
+func TestHandleSnapshot(t *testing.T) {
+	s, _ := newTestServer(t)
+	s.addDevice(&MicroDevice{Name: "micro0", Path: "/etc/micro/micro0"})
+	s.addDevice(&MicroDevice{Name: "micro1", Path: "/etc/micro/micro1"})
+
+	rec := httptest.NewRecorder()
+	s.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/snapshot", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("GET /snapshot status = %d, want %d", rec.Code, http.StatusOK)
+	}
+	if ct := rec.Header().Get("Content-Type"); ct != "application/protobuf" {
+		t.Errorf("Content-Type = %q, want application/protobuf", ct)
+	}
+
+	devices, err := state.DeserializeDevices(rec.Body.Bytes())
+	if err != nil {
+		t.Fatalf("DeserializeDevices() = %v", err)
+	}
+	for _, name := range []string{"micro0", "micro1"} {
+		dev, ok := devices[name]
+		if !ok {
+			t.Errorf("snapshot has no device %s", name)
+			continue
+		}
+		if dev.ID != deviceID(name) || dev.Path != "/etc/micro/"+name {
+			t.Errorf("snapshot device %s = %+v", name, dev)
+		}
+	}
+}
+
+func TestHandleMetrics(t *testing.T) {
+	s, _ := newTestServer(t, WithMetrics(prometheus.NewRegistry()))
+	id := s.addDevice(&MicroDevice{Name: "micro0"})
+	if _, err := s.Allocate(context.Background(), testutil.NewMockAllocateRequest().WithDeviceIDs(id).Build()); err != nil {
+		t.Fatal(err)
+	}
+
+	rec := httptest.NewRecorder()
+	s.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("GET /metrics status = %d, want %d", rec.Code, http.StatusOK)
+	}
+
+	// the scraped text is parsed back so that it is checked as served
+	var parser expfmt.TextParser
+	families, err := parser.TextToMetricFamilies(rec.Body)
+	if err != nil {
+		t.Fatalf("parse /metrics: %v", err)
+	}
+	scraped := prometheus.GathererFunc(func() ([]*dto.MetricFamily, error) {
+		return slices.Collect(maps.Values(families)), nil
+	})
+	assert.AssertMetricValue(t, scraped, "micro_device_plugin_active_allocations", nil, 1)
+}
+
+func TestHandleHealthz(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	s, _ := newTestServer(t, WithMetrics(reg), WithDevicesMin(2))
+
+	steps := []struct {
+		name     string
+		action   func()
+		wantCode int
+	}{
+		{name: "one device", action: func() { s.addDevice(&MicroDevice{Name: "micro0"}) }, wantCode: http.StatusServiceUnavailable},
+		{name: "two devices", action: func() { s.addDevice(&MicroDevice{Name: "micro1"}) }, wantCode: http.StatusOK},
+		{name: "device removed", action: func() { s.removeDevice("micro0") }, wantCode: http.StatusServiceUnavailable},
+	}
+	for _, step := range steps {
+		step.action()
+		rec := httptest.NewRecorder()
+		s.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+		if rec.Code != step.wantCode {
+			t.Errorf("%s: GET /healthz status = %d, want %d", step.name, rec.Code, step.wantCode)
+		}
+	}
+	assert.AssertMetricValue(t, reg, "micro_device_plugin_liveness_failure_total", nil, 2)
+}
@@ -0,0 +1,65 @@
+package server
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"errors"
+	"fmt"
+)
+
+// maxDeviceIDLength is the longest device ID accepted by kubelet
+const maxDeviceIDLength = 63
+
+// deviceID returns the stable ID of the device file name, hex encoded
+// so that the ID is a valid UTF-8 string for the device plugin API
+func deviceID(name string) string {
+	sum := md5.Sum([]byte(name))
+	return hex.EncodeToString(sum[:])
+}
+
+// ValidateDeviceID checks the device ID is at most 63 characters of
+// `[a-zA-Z0-9-_.]`
+func ValidateDeviceID(id string) error {
+	if id == "" {
+		return errors.New("device id is empty")
+	}
+	if len(id) > maxDeviceIDLength {
+		return fmt.Errorf("device id exceeds %d characters", maxDeviceIDLength)
+	}
+	for i := 0; i < len(id); i++ {
+		c := id[i]
+		switch {
+		case 'a' <= c && c <= 'z', 'A' <= c && c <= 'Z', '0' <= c && c <= '9':
+		case c == '-', c == '_', c == '.':
+		default:
+			return fmt.Errorf("device id contains invalid character %q", c)
+		}
+	}
+	return nil
+}
+
+// redactedID replaces the device IDs in log messages when device IDs
+// must not be logged
+const redactedID = "[REDACTED]"
+
+// redact returns the device ID if logging it is allowed
+func redact(id string, allowed bool) string {
+	if allowed {
+		return id
+	}
+	return redactedID
+}
+
+// logID returns the device ID as it may appear in log messages
+func (s *MicroDeviceServer) logID(id string) string {
+	return redact(id, s.logDeviceIDs)
+}
+
+// logIDs returns the device IDs as they may appear in log messages
+func (s *MicroDeviceServer) logIDs(ids []string) []string {
+	logged := make([]string, len(ids))
+	for i, id := range ids {
+		logged[i] = s.logID(id)
+	}
+	return logged
+}
@@ -0,0 +1,84 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"log/slog"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/kelein/micro-device-plugin/pkg/testing/assert"
+	"github.com/kelein/micro-device-plugin/pkg/testutil"
+)
+
+func FuzzDeviceID(f *testing.F) {
+	seeds := []string{
+		"",
+		"micro0",
+		"../../etc/passwd",
+		"dev\x00ice",
+		"设备-0",
+		strings.Repeat("a", 255),
+		strings.Repeat("é", 4096),
+		"\xff\xfe",
+	}
+	for _, seed := range seeds {
+		f.Add(seed)
+	}
+
+	f.Fuzz(func(t *testing.T, name string) {
+		id := deviceID(name)
+		if len(id) != 32 {
+			t.Fatalf("deviceID(%q) = %q, want 32 hex characters", name, id)
+		}
+		if _, err := hex.DecodeString(id); err != nil {
+			t.Fatalf("deviceID(%q) = %q is not hex encoded: %v", name, id, err)
+		}
+		if !utf8.ValidString(id) {
+			t.Fatalf("deviceID(%q) = %q is not valid UTF-8", name, id)
+		}
+		if err := ValidateDeviceID(id); err != nil {
+			t.Fatalf("deviceID(%q) = %q fails validation: %v", name, id, err)
+		}
+		if again := deviceID(name); again != id {
+			t.Fatalf("deviceID(%q) is not stable: %q != %q", name, id, again)
+		}
+	})
+}
+
+func TestValidateDeviceIDRejects(t *testing.T) {
+	for _, id := range []string{
+		"",
+		"dev\x00ice",
+		"dev/0",
+		"设备",
+		strings.Repeat("a", maxDeviceIDLength+1),
+	} {
+		if err := ValidateDeviceID(id); err == nil {
+			t.Errorf("ValidateDeviceID(%q) = nil, want error", id)
+		}
+	}
+}
+
+func TestRedactDeviceIDs(t *testing.T) {
+	var logs bytes.Buffer
+	s, _ := newTestServer(t, WithLogger(slog.New(slog.NewTextHandler(&logs, nil))),
+		WithLogDeviceIDs(false))
+
+	id := s.addDevice(&MicroDevice{Name: "micro0"})
+	resp, err := s.Allocate(context.Background(), testutil.NewMockAllocateRequest().WithDeviceIDs(id).Build())
+	if err != nil {
+		t.Fatalf("Allocate() error = %v", err)
+	}
+	// only the logs are redacted, the container still gets the device
+	assert.AssertAllocateResponse(t, resp, map[string]string{"MICRO_DEVICES": id}, nil)
+
+	if strings.Contains(logs.String(), id) {
+		t.Errorf("log output contains device id %s:\n%s", id, logs.String())
+	}
+	if !strings.Contains(logs.String(), redactedID) {
+		t.Errorf("log output does not contain %s:\n%s", redactedID, logs.String())
+	}
+}
@@ -0,0 +1,52 @@
+package server
+
+import "time"
+
+// startIdleTimer arms the idle timer if no device was discovered, the
+// timer is stopped by every discovered device and re-armed when the last
+// device is removed
+func (s *MicroDeviceServer) startIdleTimer() {
+	if s.maxIdleTime <= 0 {
+		return
+	}
+	s.events.Subscribe(DeviceAdded, func(DeviceEvent) { s.resetIdleTimer() })
+	s.events.Subscribe(DeviceRemoved, func(DeviceEvent) { s.resetIdleTimer() })
+	s.resetIdleTimer()
+}
+
+// resetIdleTimer stops the idle timer if the server has devices and
+// restarts it with the full idle time otherwise
+func (s *MicroDeviceServer) resetIdleTimer() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.devices) > 0 {
+		if s.idleTimer != nil {
+			s.idleTimer.Stop()
+		}
+		return
+	}
+	if s.idleTimer == nil {
+		s.idleTimer = time.AfterFunc(s.maxIdleTime, s.idleFired)
+		return
+	}
+	s.idleTimer.Reset(s.maxIdleTime)
+}
+
+func (s *MicroDeviceServer) idleFired() {
+	if s.ctx.Err() != nil {
+		return
+	}
+	s.mu.RLock()
+	count := len(s.devices)
+	s.mu.RUnlock()
+	if count > 0 {
+		return
+	}
+	s.logger.Error("no devices discovered within the max idle time", "path", s.devicePath, "timeout", s.maxIdleTime)
+	s.idleOnce.Do(func() { close(s.idle) })
+}
+
+// Idle is closed when no device was discovered within the max idle time
+func (s *MicroDeviceServer) Idle() <-chan struct{} {
+	return s.idle
+}
@@ -0,0 +1,66 @@
+//go:build integration
+
+package server
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestMaxIdleTime(t *testing.T) {
+	s, _ := newTestServer(t, WithHealthInterval(0), WithMaxIdleTime(200*time.Millisecond))
+	start := time.Now()
+	if err := s.Run(); err != nil {
+		t.Fatalf("Run() = %v", err)
+	}
+
+	select {
+	case <-s.Idle():
+		if elapsed := time.Since(start); elapsed < 200*time.Millisecond {
+			t.Errorf("idle after %v, before the max idle time", elapsed)
+		}
+	case <-time.After(400*time.Millisecond - time.Since(start)):
+		t.Fatal("plugin without devices not idle within 400ms")
+	}
+}
+
+func TestMaxIdleTimeResetByDevice(t *testing.T) {
+	dir := t.TempDir()
+	s, _ := newTestServer(t, WithDevicePath(dir), WithHealthInterval(0), WithMaxIdleTime(200*time.Millisecond))
+	if err := s.Run(); err != nil {
+		t.Fatalf("Run() = %v", err)
+	}
+
+	time.Sleep(100 * time.Millisecond)
+	if err := os.WriteFile(filepath.Join(dir, "micro0"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-s.Idle():
+		t.Fatal("plugin idle after a device was discovered")
+	case <-time.After(400 * time.Millisecond):
+	}
+
+	if err := os.Remove(filepath.Join(dir, "micro0")); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-s.Idle():
+	case <-time.After(time.Second):
+		t.Fatal("plugin not idle after its last device was removed")
+	}
+}
+
+func TestMaxIdleTimeDisabled(t *testing.T) {
+	s, _ := newTestServer(t, WithHealthInterval(0))
+	if err := s.Run(); err != nil {
+		t.Fatalf("Run() = %v", err)
+	}
+	select {
+	case <-s.Idle():
+		t.Fatal("plugin idle with the max idle time disabled")
+	case <-time.After(300 * time.Millisecond):
+	}
+}
@@ -0,0 +1,86 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"strings"
+
+	"github.com/kelein/micro-device-plugin/pkg/version"
+)
+
+// PluginInfo describes a running plugin for cluster inventory tools
+type PluginInfo struct {
+	Runtime      map[string]any  `json:"runtime"`
+	Health       PluginStatus    `json:"health"`
+	Config       ConfigSummary   `json:"config"`
+	FeatureGates map[string]bool `json:"featureGates"`
+}
+
+// ConfigSummary is the active configuration of the plugin without the
+// file paths of the secrets
+type ConfigSummary struct {
+	ResourceName    string   `json:"resourceName"`
+	ResourceAliases []string `json:"resourceAliases"`
+	DevicePath      string   `json:"devicePath"`
+	PluginPath      string   `json:"pluginPath"`
+	SocketName      string   `json:"socketName"`
+	MaxDevices      int      `json:"maxDevices"`
+	ReserveDevices  int      `json:"reserveDevices"`
+	Shard           int      `json:"shard"`
+	ShardCount      int      `json:"shardCount"`
+	Standalone      bool     `json:"standalone"`
+}
+
+// MarshalJSON encodes the info omitting the zero values
+func (i PluginInfo) MarshalJSON() ([]byte, error) {
+	return marshalNonZero(i)
+}
+
+// MarshalJSON encodes the configuration omitting the zero values
+func (c ConfigSummary) MarshalJSON() ([]byte, error) {
+	return marshalNonZero(c)
+}
+
+// marshalNonZero encodes the struct v as a JSON object of its fields
+// named by their json tags, the fields with zero values are omitted
+func marshalNonZero(v any) ([]byte, error) {
+	val := reflect.ValueOf(v)
+	fields := make(map[string]any, val.NumField())
+	for i := 0; i < val.NumField(); i++ {
+		name, _, _ := strings.Cut(val.Type().Field(i).Tag.Get("json"), ",")
+		if f := val.Field(i); name != "" && name != "-" && !f.IsZero() {
+			fields[name] = f.Interface()
+		}
+	}
+	return json.Marshal(fields)
+}
+
+// Info returns the runtime, health, configuration and feature gates of
+// the plugin
+func (s *MicroDeviceServer) Info() PluginInfo {
+	info := PluginInfo{
+		Runtime:      version.Runtime(),
+		Health:       s.Status(),
+		FeatureGates: s.featureGates.Effective(),
+	}
+	s.mu.RLock()
+	info.Config = ConfigSummary{
+		ResourceName:    s.resourceName,
+		ResourceAliases: s.resourceAliases,
+		DevicePath:      s.devicePath,
+		PluginPath:      s.pluginPath,
+		SocketName:      s.socketName,
+		MaxDevices:      s.maxDevices,
+		ReserveDevices:  s.reserveDevices,
+		Shard:           s.shard,
+		ShardCount:      s.shardCount,
+		Standalone:      s.standalone,
+	}
+	s.mu.RUnlock()
+	return info
+}
+
+func (s *MicroDeviceServer) handleInfo(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, s.Info())
+}
@@ -0,0 +1,49 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/kelein/micro-device-plugin/pkg/config"
+)
+
+func TestHandleInfo(t *testing.T) {
+	s, _ := newTestServer(t, WithResourceName("example.com/micro"), WithDevicePath(t.TempDir()))
+	s.addDevice(&MicroDevice{Name: "micro0"})
+
+	rec := httptest.NewRecorder()
+	s.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/info", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("GET /info status = %d, want %d", rec.Code, http.StatusOK)
+	}
+
+	var info PluginInfo
+	if err := json.Unmarshal(rec.Body.Bytes(), &info); err != nil {
+		t.Fatalf("decode info: %v", err)
+	}
+	if info.Runtime["app"] == nil || info.Runtime["pid"] == nil || info.Runtime["uptime"] == nil {
+		t.Errorf("runtime = %v, want app, pid and uptime", info.Runtime)
+	}
+	if info.Health.Phase == "" || info.Health.Uptime == "" || info.Health.DeviceCount != 1 {
+		t.Errorf("health = %+v, want a phase, uptime and 1 device", info.Health)
+	}
+	c := info.Config
+	if c.ResourceName != "example.com/micro" || c.DevicePath == "" || c.PluginPath == "" || c.SocketName == "" {
+		t.Errorf("config = %+v, want the resource name and paths", c)
+	}
+	if got := info.FeatureGates[config.ClaimTokens]; !got || len(info.FeatureGates) != len(config.FeatureGates(nil).Effective()) {
+		t.Errorf("feature gates = %v, want every known gate", info.FeatureGates)
+	}
+
+	var raw map[string]map[string]any
+	if err := json.Unmarshal(rec.Body.Bytes(), &raw); err != nil {
+		t.Fatal(err)
+	}
+	for _, field := range []string{"maxDevices", "resourceAliases", "standalone"} {
+		if v, ok := raw["config"][field]; ok {
+			t.Errorf("zero config field %s = %v not omitted", field, v)
+		}
+	}
+}
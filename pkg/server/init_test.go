@@ -0,0 +1,51 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/kelein/micro-device-plugin/pkg/discovery"
+)
+
+// blockingDiscoverer blocks the device discovery until release is closed,
+// like a directory read on a hung network filesystem
+type blockingDiscoverer struct {
+	release chan struct{}
+}
+
+func (d blockingDiscoverer) Discover() ([]*MicroDevice, error) {
+	<-d.release
+	return []*MicroDevice{{Name: "micro0"}}, nil
+}
+
+func (d blockingDiscoverer) Watch(ctx context.Context, _ chan<- discovery.DiscoveryEvent) error {
+	<-ctx.Done()
+	return nil
+}
+
+func TestRunInitTimeout(t *testing.T) {
+	release := make(chan struct{})
+	t.Cleanup(func() { close(release) })
+	s, _ := newTestServer(t, WithDiscoverer(blockingDiscoverer{release: release}),
+		WithInitTimeout(50*time.Millisecond))
+
+	start := time.Now()
+	if err := s.Run(); !errors.Is(err, ErrInitTimeout) {
+		t.Fatalf("Run() error = %v, want %v", err, ErrInitTimeout)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("Run() returned after %v, want the init timeout", elapsed)
+	}
+	if got := len(s.deviceList()); got != 0 {
+		t.Errorf("advertised %d devices after the init timeout, want 0", got)
+	}
+	if _, err := os.Stat(s.socketPath()); err == nil {
+		t.Error("plugin socket created after the init timeout")
+	}
+	if got := s.Status().LastError; got != ErrInitTimeout.Error() {
+		t.Errorf("status last error = %q, want %q", got, ErrInitTimeout)
+	}
+}
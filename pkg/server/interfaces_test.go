@@ -0,0 +1,36 @@
+package server
+
+import (
+	"reflect"
+	"testing"
+
+	deviceapi "k8s.io/kubelet/pkg/apis/deviceplugin/v1beta1"
+
+	"github.com/kelein/micro-device-plugin/pkg/discovery"
+)
+
+func TestInterfaceCompliance(t *testing.T) {
+	tests := []struct {
+		impl  any
+		iface reflect.Type
+	}{
+		{(*MicroDeviceServer)(nil), reflect.TypeOf((*deviceapi.DevicePluginServer)(nil)).Elem()},
+		{(*MicroDeviceServer)(nil), reflect.TypeOf((*DevicePlugin)(nil)).Elem()},
+		{(*DeltaProxy)(nil), reflect.TypeOf((*deviceapi.DevicePluginServer)(nil)).Elem()},
+		{(*PluginManager)(nil), reflect.TypeOf((*DevicePlugin)(nil)).Elem()},
+		{NUMAScorer{}, reflect.TypeOf((*DeviceScorer)(nil)).Elem()},
+		{(*RoundRobinScorer)(nil), reflect.TypeOf((*DeviceScorer)(nil)).Elem()},
+		{ThermalScorer{}, reflect.TypeOf((*DeviceScorer)(nil)).Elem()},
+		{FileExistPolicy{}, reflect.TypeOf((*DeviceHealthPolicy)(nil)).Elem()},
+		{CommandPolicy{}, reflect.TypeOf((*DeviceHealthPolicy)(nil)).Elem()},
+		{(*discovery.FilesystemDiscoverer)(nil), reflect.TypeOf((*discovery.Discoverer)(nil)).Elem()},
+		{(*discovery.StaticDiscoverer)(nil), reflect.TypeOf((*discovery.Discoverer)(nil)).Elem()},
+		{(*discovery.PipeDiscoverer)(nil), reflect.TypeOf((*discovery.Discoverer)(nil)).Elem()},
+	}
+	for _, tt := range tests {
+		typ := reflect.TypeOf(tt.impl)
+		if !typ.Implements(tt.iface) {
+			t.Errorf("%s does not implement %s", typ, tt.iface)
+		}
+	}
+}
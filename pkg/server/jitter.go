@@ -0,0 +1,38 @@
+package server
+
+import (
+	"crypto/rand"
+	"math/big"
+	"time"
+)
+
+// registerJitter returns a random delay below max, read from crypto/rand
+// so that replicas booting together do not draw correlated delays
+func registerJitter(max time.Duration) time.Duration {
+	ms := max.Milliseconds()
+	if ms <= 0 {
+		return 0
+	}
+	n, err := rand.Int(rand.Reader, big.NewInt(ms))
+	if err != nil {
+		return 0
+	}
+	return time.Duration(n.Int64()) * time.Millisecond
+}
+
+// waitRegisterJitter delays the first registration by a random jitter to
+// spread the registrations of the plugins starting on node boot, the
+// registrations after kubelet restarts are not delayed
+func (s *MicroDeviceServer) waitRegisterJitter() {
+	s.jitterOnce.Do(func() {
+		d := registerJitter(s.registerJitter)
+		if d == 0 {
+			return
+		}
+		s.logger.Info("delay first registration", "jitter", d)
+		select {
+		case <-time.After(d):
+		case <-s.ctx.Done():
+		}
+	})
+}
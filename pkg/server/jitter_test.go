@@ -0,0 +1,61 @@
+package server
+
+import (
+	"testing"
+	"time"
+
+	"github.com/kelein/micro-device-plugin/pkg/testutil"
+)
+
+func TestRegisterJitter(t *testing.T) {
+	if d := registerJitter(0); d != 0 {
+		t.Errorf("registerJitter(0) = %v, want 0", d)
+	}
+	for i := 0; i < 100; i++ {
+		if d := registerJitter(100 * time.Millisecond); d < 0 || d >= 100*time.Millisecond {
+			t.Fatalf("registerJitter(100ms) = %v, want in [0, 100ms)", d)
+		}
+	}
+}
+
+func TestRegisterToKubeletJitter(t *testing.T) {
+	tests := []struct {
+		name   string
+		jitter time.Duration
+		max    time.Duration // upper bound of the first registration
+	}{
+		{name: "immediate", jitter: 0, max: 50 * time.Millisecond},
+		{name: "jitter", jitter: 100 * time.Millisecond, max: 150 * time.Millisecond},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			kubelet, err := testutil.NewFakeKubelet(dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			t.Cleanup(kubelet.Stop)
+			s, _ := newTestServer(t, WithPluginPath(dir), WithRegisterJitter(tt.jitter))
+
+			start := time.Now()
+			if err := s.RegisterToKubelet(); err != nil {
+				t.Fatal(err)
+			}
+			if elapsed := time.Since(start); elapsed > tt.max {
+				t.Errorf("first registration took %v, want at most %v", elapsed, tt.max)
+			}
+
+			// re-registration after a kubelet restart is not delayed
+			start = time.Now()
+			if err := s.RegisterToKubelet(); err != nil {
+				t.Fatal(err)
+			}
+			if elapsed := time.Since(start); elapsed > 50*time.Millisecond {
+				t.Errorf("second registration took %v, want no jitter", elapsed)
+			}
+			if n := len(kubelet.Requests()); n != 2 {
+				t.Errorf("kubelet got %d requests, want 2", n)
+			}
+		})
+	}
+}
@@ -0,0 +1,53 @@
+package server
+
+import (
+	"context"
+	"os"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+// NewKubeClient creates a kubernetes client from the kubeconfig file,
+// or from the in-cluster service account when kubeconfig is empty
+func NewKubeClient(kubeconfig string) (kubernetes.Interface, error) {
+	var (
+		cfg *rest.Config
+		err error
+	)
+	if kubeconfig != "" {
+		cfg, err = clientcmd.BuildConfigFromFlags("", kubeconfig)
+	} else {
+		cfg, err = rest.InClusterConfig()
+	}
+	if err != nil {
+		return nil, err
+	}
+	return kubernetes.NewForConfig(cfg)
+}
+
+// NodeName returns the name of the node running the plugin
+func NodeName() string {
+	if name := os.Getenv("NODE_NAME"); name != "" {
+		return name
+	}
+	name, _ := os.Hostname()
+	return name
+}
+
+// NodeMatchesSelector reports whether the labels of the named node
+// match the label selector, e.g. tier=premium,zone=us-east-1a
+func NodeMatchesSelector(ctx context.Context, client kubernetes.Interface, nodeName, selector string) (bool, error) {
+	sel, err := labels.Parse(selector)
+	if err != nil {
+		return false, err
+	}
+	node, err := client.CoreV1().Nodes().Get(ctx, nodeName, metav1.GetOptions{})
+	if err != nil {
+		return false, err
+	}
+	return sel.Matches(labels.Set(node.Labels)), nil
+}
@@ -0,0 +1,204 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"k8s.io/apimachinery/pkg/util/version"
+	deviceapi "k8s.io/kubelet/pkg/apis/deviceplugin/v1beta1"
+)
+
+// KubeletVersionFile is the kubelet version file in the plugin directory
+const KubeletVersionFile = "kubelet-version"
+
+// kubeletVersionHeader carries the kubelet version in the healthz response
+const kubeletVersionHeader = "X-Kubernetes-Version"
+
+// ErrUnsupportedAPIVersion is returned by RegisterToKubelet with a strict
+// version check if kubelet supports none of the plugin API versions
+var ErrUnsupportedAPIVersion = errors.New("kubelet device plugin API version not supported")
+
+// supportedVersionsPattern matches the API versions kubelet lists when it
+// rejects the version of a register request
+var supportedVersionsPattern = regexp.MustCompile(`[Ss]upported versions are \[([^\]]*)\]`)
+
+// Minimum kubelet versions of the device plugin API features
+var (
+	preferredAllocationVersion = version.MajorMinor(1, 17)
+	pluginOptionsV2Version     = version.MajorMinor(1, 20)
+	cdiVersion                 = version.MajorMinor(1, 28)
+)
+
+// KubeletFeatures are the device plugin API features the kubelet supports
+type KubeletFeatures struct {
+	// PreferredAllocation is the GetPreferredAllocation RPC
+	PreferredAllocation bool
+	// PluginOptionsV2 are the device plugin options besides PreStartRequired
+	PluginOptionsV2 bool
+	// CDI are the CDI device names of Allocate responses
+	CDI bool
+}
+
+// FeaturesForVersion returns the features of the kubelet version, all
+// features are enabled for an unknown version
+func FeaturesForVersion(v *version.Version) KubeletFeatures {
+	if v == nil {
+		return KubeletFeatures{PreferredAllocation: true, PluginOptionsV2: true, CDI: true}
+	}
+	return KubeletFeatures{
+		PreferredAllocation: v.AtLeast(preferredAllocationVersion),
+		PluginOptionsV2:     v.AtLeast(pluginOptionsV2Version),
+		CDI:                 v.AtLeast(cdiVersion),
+	}
+}
+
+// KubeletVersionDetector detects the kubelet version from the version
+// file written by kubelet into the plugin directory, falling back to
+// the kubelet healthz endpoint if configured
+type KubeletVersionDetector struct {
+	pluginPath string
+	healthzURL string
+	client     *http.Client
+}
+
+// NewKubeletVersionDetector creates a detector reading the version file
+// of pluginPath, the healthz endpoint is not queried if healthzURL is empty
+func NewKubeletVersionDetector(pluginPath, healthzURL string) *KubeletVersionDetector {
+	return &KubeletVersionDetector{
+		pluginPath: pluginPath,
+		healthzURL: healthzURL,
+		client:     &http.Client{Timeout: 5 * time.Second},
+	}
+}
+
+// Detect returns the kubelet version
+func (d *KubeletVersionDetector) Detect(ctx context.Context) (*version.Version, error) {
+	data, err := os.ReadFile(filepath.Join(d.pluginPath, KubeletVersionFile))
+	if err == nil {
+		return version.ParseGeneric(strings.TrimSpace(string(data)))
+	}
+	if !errors.Is(err, os.ErrNotExist) || d.healthzURL == "" {
+		return nil, err
+	}
+	return d.detectHealthz(ctx)
+}
+
+// detectHealthz reads the kubelet version header of the healthz response
+func (d *KubeletVersionDetector) detectHealthz(ctx context.Context) (*version.Version, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, d.healthzURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("X-Kubernetes-Client-Version", deviceapi.Version)
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	resp.Body.Close()
+
+	v := resp.Header.Get(kubeletVersionHeader)
+	if v == "" {
+		return nil, fmt.Errorf("kubelet healthz response has no %s header", kubeletVersionHeader)
+	}
+	return version.ParseGeneric(v)
+}
+
+// detectKubeletVersion updates the kubelet version and its features,
+// the features are all enabled if the version is unknown
+func (s *MicroDeviceServer) detectKubeletVersion() {
+	if s.versionDetector == nil {
+		return
+	}
+	v, err := s.versionDetector.Detect(s.ctx)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		s.logger.Info("kubelet version unknown, enable all features")
+	case err != nil:
+		s.logger.Warn("detect kubelet version failed, enable all features", "err", err)
+	}
+	if err != nil {
+		v = nil
+	}
+	features := FeaturesForVersion(v)
+
+	s.mu.Lock()
+	s.kubeletVersion = v
+	s.kubeletFeatures = features
+	s.mu.Unlock()
+	if v != nil {
+		s.logger.Info("kubelet version detected", "version", v.String(),
+			"preferredAllocation", features.PreferredAllocation,
+			"pluginOptionsV2", features.PluginOptionsV2,
+			"cdi", features.CDI,
+		)
+	}
+}
+
+// KubeletFeatures returns the device plugin API features of the kubelet
+func (s *MicroDeviceServer) KubeletFeatures() KubeletFeatures {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.kubeletFeatures
+}
+
+// parseAPIVersions returns the API versions kubelet lists in the error
+// rejecting a register request, nil if the error lists none
+func parseAPIVersions(err error) []string {
+	if err == nil {
+		return nil
+	}
+	m := supportedVersionsPattern.FindStringSubmatch(err.Error())
+	if m == nil {
+		return nil
+	}
+	var versions []string
+	for _, v := range strings.Fields(m[1]) {
+		if v = strings.Trim(v, `"`); v != "" {
+			versions = append(versions, v)
+		}
+	}
+	return versions
+}
+
+// checkAPIVersion registers the validate sentinel version to learn the
+// API versions of kubelet from its rejection, kubelet supporting none of
+// the plugin versions fails a strict check and is logged otherwise
+func (s *MicroDeviceServer) checkAPIVersion(client deviceapi.RegistrationClient) error {
+	if !s.versionCheck {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(s.ctx, 5*time.Second)
+	defer cancel()
+	_, err := client.Register(ctx, &deviceapi.RegisterRequest{
+		Version:      validateVersion,
+		Endpoint:     filepath.Base(s.endpointPath()),
+		ResourceName: s.resourceName,
+	})
+	versions := parseAPIVersions(err)
+	if len(versions) == 0 {
+		s.logger.Warn("kubelet device plugin API versions unknown, skip version check", "err", err)
+		return nil
+	}
+	for _, v := range versions {
+		if slices.Contains(s.supportedVersions, v) {
+			s.logger.Info("kubelet device plugin API version supported", "version", v)
+			return nil
+		}
+	}
+
+	err = fmt.Errorf("%w: kubelet supports %s, plugin supports %s", ErrUnsupportedAPIVersion,
+		strings.Join(versions, ","), strings.Join(s.supportedVersions, ","))
+	if s.strictVersionCheck {
+		return err
+	}
+	s.logger.Warn("register plugin despite unsupported kubelet version", "err", err)
+	return nil
+}
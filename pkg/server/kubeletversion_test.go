@@ -0,0 +1,81 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	deviceapi "k8s.io/kubelet/pkg/apis/deviceplugin/v1beta1"
+)
+
+func TestKubeletVersionFeatures(t *testing.T) {
+	tests := []struct {
+		version string
+		want    KubeletFeatures
+	}{
+		{"v1.16.4", KubeletFeatures{}},
+		{"v1.18.0", KubeletFeatures{PreferredAllocation: true}},
+		{"v1.24.1", KubeletFeatures{PreferredAllocation: true, PluginOptionsV2: true}},
+		{"v1.28.0\n", KubeletFeatures{PreferredAllocation: true, PluginOptionsV2: true, CDI: true}},
+		{"invalid", KubeletFeatures{PreferredAllocation: true, PluginOptionsV2: true, CDI: true}},
+	}
+	for _, tt := range tests {
+		dir := t.TempDir()
+		if err := os.WriteFile(filepath.Join(dir, KubeletVersionFile), []byte(tt.version), 0644); err != nil {
+			t.Fatal(err)
+		}
+		s, _ := newTestServer(t, WithPluginPath(dir), WithScorer(RandomScorer{}))
+		s.detectKubeletVersion()
+		if got := s.KubeletFeatures(); got != tt.want {
+			t.Errorf("version %q features = %+v, want %+v", tt.version, got, tt.want)
+		}
+
+		opts, _ := s.GetDevicePluginOptions(context.Background(), &deviceapi.Empty{})
+		want := tt.want.PreferredAllocation && tt.want.PluginOptionsV2
+		if opts.GetPreferredAllocationAvailable != want {
+			t.Errorf("version %q GetPreferredAllocationAvailable = %v, want %v", tt.version, opts.GetPreferredAllocationAvailable, want)
+		}
+		s.Stop()
+	}
+}
+
+func TestKubeletVersionHealthz(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Kubernetes-Client-Version") == "" {
+			t.Error("healthz request without client version header")
+		}
+		w.Header().Set(kubeletVersionHeader, "v1.19.2")
+		w.Write([]byte("ok"))
+	}))
+	t.Cleanup(srv.Close)
+
+	v, err := NewKubeletVersionDetector(t.TempDir(), srv.URL).Detect(context.Background())
+	if err != nil {
+		t.Fatalf("Detect() error = %v", err)
+	}
+	if got := FeaturesForVersion(v); got != (KubeletFeatures{PreferredAllocation: true}) {
+		t.Errorf("features of %s = %+v", v, got)
+	}
+}
+
+func TestParseAPIVersions(t *testing.T) {
+	tests := []struct {
+		err  error
+		want []string
+	}{
+		{nil, nil},
+		{errors.New("connection refused"), nil},
+		{errors.New(`requested API version "validate" is not supported by kubelet. Supported versions are ["v1beta1"]`), []string{"v1beta1"}},
+		{errors.New(`rpc error: code = Unknown desc = Supported versions are ["v1alpha" "v1beta1"]`), []string{"v1alpha", "v1beta1"}},
+	}
+	for _, tt := range tests {
+		if got := parseAPIVersions(tt.err); !slices.Equal(got, tt.want) {
+			t.Errorf("parseAPIVersions(%v) = %q, want %q", tt.err, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,96 @@
+package server
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	coordinationv1 "k8s.io/api/coordination/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// LeaseHeartbeat periodically renews a Lease so that monitoring tools
+// can detect a stale plugin from the kubernetes API
+type LeaseHeartbeat struct {
+	client    kubernetes.Interface
+	namespace string
+	name      string
+	holder    string
+	interval  time.Duration
+
+	// failures counts the failed renewals, set by the server running
+	// the heartbeat
+	failures prometheus.Counter
+}
+
+// NewLeaseHeartbeat creates a heartbeat renewing the namespace/name
+// lease held by holder every interval
+func NewLeaseHeartbeat(client kubernetes.Interface, namespace, name, holder string, interval time.Duration) *LeaseHeartbeat {
+	return &LeaseHeartbeat{
+		client:    client,
+		namespace: namespace,
+		name:      name,
+		holder:    holder,
+		interval:  interval,
+	}
+}
+
+// Run renews the lease on every tick until ctx is done
+func (h *LeaseHeartbeat) Run(ctx context.Context) {
+	slog.Info("lease heartbeat started", "namespace", h.namespace, "name", h.name)
+	ticker := time.NewTicker(h.interval)
+	defer ticker.Stop()
+
+	for {
+		if err := h.Renew(ctx); err != nil {
+			if h.failures != nil {
+				h.failures.Inc()
+			}
+			slog.Error("renew heartbeat lease failed", "name", h.name, "err", err)
+		}
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			slog.Info("lease heartbeat exited")
+			return
+		}
+	}
+}
+
+// Renew creates the lease or updates its renew time
+func (h *LeaseHeartbeat) Renew(ctx context.Context) error {
+	leases := h.client.CoordinationV1().Leases(h.namespace)
+	now := metav1.NewMicroTime(time.Now())
+	seconds := int32(3 * h.interval / time.Second)
+
+	lease, err := leases.Get(ctx, h.name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		lease = &coordinationv1.Lease{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      h.name,
+				Namespace: h.namespace,
+			},
+			Spec: coordinationv1.LeaseSpec{
+				HolderIdentity:       &h.holder,
+				LeaseDurationSeconds: &seconds,
+				AcquireTime:          &now,
+				RenewTime:            &now,
+			},
+		}
+		_, err = leases.Create(ctx, lease, metav1.CreateOptions{})
+		return err
+	}
+	if err != nil {
+		return err
+	}
+
+	lease.Spec.HolderIdentity = &h.holder
+	lease.Spec.LeaseDurationSeconds = &seconds
+	lease.Spec.RenewTime = &now
+	_, err = leases.Update(ctx, lease, metav1.UpdateOptions{})
+	return err
+}
@@ -0,0 +1,43 @@
+package server
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestLeaseHeartbeatRenew(t *testing.T) {
+	client := fake.NewClientset()
+	h := NewLeaseHeartbeat(client, "kube-system", "micro-node1", "node1", 10*time.Second)
+	ctx := context.Background()
+
+	if err := h.Renew(ctx); err != nil {
+		t.Fatalf("Renew() create = %v", err)
+	}
+	lease, err := client.CoordinationV1().Leases("kube-system").Get(ctx, "micro-node1", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("get lease: %v", err)
+	}
+	if *lease.Spec.HolderIdentity != "node1" || *lease.Spec.LeaseDurationSeconds != 30 {
+		t.Errorf("lease holder = %s, duration = %d, want node1 and 30", *lease.Spec.HolderIdentity, *lease.Spec.LeaseDurationSeconds)
+	}
+	created := lease.Spec.RenewTime.Time
+
+	time.Sleep(10 * time.Millisecond)
+	if err := h.Renew(ctx); err != nil {
+		t.Fatalf("Renew() update = %v", err)
+	}
+	lease, err = client.CoordinationV1().Leases("kube-system").Get(ctx, "micro-node1", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("get lease: %v", err)
+	}
+	if !lease.Spec.RenewTime.After(created) {
+		t.Errorf("lease renew time %v not after %v", lease.Spec.RenewTime.Time, created)
+	}
+	if !lease.Spec.AcquireTime.Time.Equal(created) {
+		t.Errorf("lease acquire time changed to %v", lease.Spec.AcquireTime.Time)
+	}
+}
@@ -0,0 +1,172 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	deviceapi "k8s.io/kubelet/pkg/apis/deviceplugin/v1beta1"
+
+	"github.com/kelein/micro-device-plugin/pkg/discovery"
+	"github.com/kelein/micro-device-plugin/pkg/testing/assert"
+	"github.com/kelein/micro-device-plugin/pkg/testutil"
+)
+
+// chanDiscoverer forwards the events of its channel to the server
+type chanDiscoverer chan discovery.DiscoveryEvent
+
+func (d chanDiscoverer) Discover() ([]*MicroDevice, error) { return nil, nil }
+
+func (d chanDiscoverer) Watch(ctx context.Context, events chan<- discovery.DiscoveryEvent) error {
+	for {
+		select {
+		case e := <-d:
+			events <- e
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+func TestListAndWatchSendError(t *testing.T) {
+	s, _ := newTestServer(t)
+	s.devices["micro0"] = &MicroDevice{Name: "micro0", ID: deviceID("micro0"), Health: deviceapi.Healthy}
+
+	sendErr := errors.New("stream closed")
+	srv := testutil.NewMockListAndWatchServer(context.Background())
+	srv.FailOnSend(2, sendErr)
+
+	done := make(chan error, 1)
+	go func() {
+		done <- s.ListAndWatch(&deviceapi.Empty{}, srv)
+	}()
+	if !srv.WaitForSends(1, time.Second) {
+		t.Fatal("ListAndWatch did not send the initial device list")
+	}
+
+	s.notify <- true
+	select {
+	case err := <-done:
+		if !errors.Is(err, sendErr) {
+			t.Errorf("ListAndWatch() = %v, want %v", err, sendErr)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("ListAndWatch did not exit on send error")
+	}
+	if got := len(srv.Responses()); got != 1 {
+		t.Errorf("recorded %d responses, want 1", got)
+	}
+	assert.AssertListAndWatchContains(t, srv.Responses(), deviceID("micro0"), deviceapi.Healthy)
+}
+
+func TestWatchDeviceWithoutListAndWatch(t *testing.T) {
+	d := make(chanDiscoverer)
+	reg := prometheus.NewRegistry()
+	s, _ := newTestServer(t, WithDiscoverer(d), WithNotifyBufferSize(2), WithMetrics(reg))
+	go s.watchDevice()
+
+	const n = 20
+	for i := 0; i < n; i++ {
+		dev := &MicroDevice{Name: fmt.Sprintf("micro%d", i)}
+		select {
+		case d <- discovery.DiscoveryEvent{Type: discovery.DeviceCreated, Device: dev}:
+		case <-time.After(time.Second):
+			t.Fatalf("watchDevice blocked after %d device changes", i)
+		}
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for len(s.deviceList()) != n && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if got := len(s.deviceList()); got != n {
+		t.Fatalf("server has %d devices, want %d", got, n)
+	}
+	if got := len(s.notify); got != 2 {
+		t.Errorf("pending notifications = %d, want 2", got)
+	}
+	// nothing drains the buffer, every change after the first two is dropped
+	assert.AssertMetricValue(t, reg, "micro_device_plugin_notify_dropped_total", nil, n-2)
+}
+
+func TestListAndWatchStreamLimit(t *testing.T) {
+	s, _ := newTestServer(t, WithMaxConcurrentStreams(1))
+	id := s.addDevice(&MicroDevice{Name: "micro0"})
+	client := newPluginClient(t, s)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	first, err := client.ListAndWatch(ctx, &deviceapi.Empty{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := first.Recv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.AssertListAndWatchContains(t, []*deviceapi.ListAndWatchResponse{resp}, id, deviceapi.Healthy)
+
+	second, err := client.ListAndWatch(ctx, &deviceapi.Empty{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := second.Recv(); status.Code(err) != codes.ResourceExhausted {
+		t.Fatalf("second stream error = %v, want %v", err, codes.ResourceExhausted)
+	}
+
+	// a stream closed by kubelet releases its slot for the reconnect
+	cancel()
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		stream, err := client.ListAndWatch(context.Background(), &deviceapi.Empty{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = stream.Recv()
+		if err == nil {
+			break
+		}
+		if status.Code(err) != codes.ResourceExhausted || time.Now().After(deadline) {
+			t.Fatalf("reconnected stream error = %v, want the device list", err)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestListAndWatchMaxSendMsgSize(t *testing.T) {
+	tests := []struct {
+		name     string
+		maxSend  int
+		wantCode codes.Code
+	}{
+		{name: "library default", wantCode: codes.OK},
+		{name: "small max send size", maxSend: 1024, wantCode: codes.ResourceExhausted},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, _ := newTestServer(t, WithGRPCOptions(GRPCServerOptions(0, tt.maxSend, 0, 0)...))
+			for i := range 100 {
+				s.addDevice(&MicroDevice{Name: fmt.Sprintf("micro%d", i)})
+			}
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			stream, err := newPluginClient(t, s).ListAndWatch(ctx, &deviceapi.Empty{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp, err := stream.Recv()
+			if code := status.Code(err); code != tt.wantCode {
+				t.Fatalf("Recv() error = %v, want %v", err, tt.wantCode)
+			}
+			if err == nil && len(resp.Devices) != 100 {
+				t.Errorf("ListAndWatch sent %d devices, want 100", len(resp.Devices))
+			}
+		})
+	}
+}
@@ -0,0 +1,11 @@
+//go:build !unix
+
+package server
+
+import "net"
+
+// ListenWithBacklog listens on the address with the system backlog, the
+// backlog is only configurable on unix
+func ListenWithBacklog(network, addr string, backlog int) (net.Listener, error) {
+	return net.Listen(network, addr)
+}
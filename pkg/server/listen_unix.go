@@ -0,0 +1,81 @@
+//go:build unix
+
+package server
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"syscall"
+)
+
+// ListenWithBacklog listens on the unix or tcp address with the listen
+// backlog, net.Listen always uses the system maximum. The kernel caps the
+// backlog at net.core.somaxconn.
+func ListenWithBacklog(network, addr string, backlog int) (net.Listener, error) {
+	if backlog <= 0 {
+		return nil, fmt.Errorf("listen backlog %d must be positive", backlog)
+	}
+	family, sa, err := listenSockaddr(network, addr)
+	if err != nil {
+		return nil, err
+	}
+
+	syscall.ForkLock.RLock()
+	fd, err := syscall.Socket(family, syscall.SOCK_STREAM, 0)
+	if err == nil {
+		syscall.CloseOnExec(fd)
+	}
+	syscall.ForkLock.RUnlock()
+	if err != nil {
+		return nil, os.NewSyscallError("socket", err)
+	}
+	f := os.NewFile(uintptr(fd), addr)
+	defer f.Close()
+
+	if family != syscall.AF_UNIX {
+		if err := syscall.SetsockoptInt(fd, syscall.SOL_SOCKET, syscall.SO_REUSEADDR, 1); err != nil {
+			return nil, os.NewSyscallError("setsockopt", err)
+		}
+	}
+	if err := syscall.Bind(fd, sa); err != nil {
+		return nil, os.NewSyscallError("bind", err)
+	}
+	if err := syscall.Listen(fd, backlog); err != nil {
+		return nil, os.NewSyscallError("listen", err)
+	}
+
+	// the listener owns a duplicate of the socket
+	l, err := net.FileListener(f)
+	if err != nil {
+		return nil, err
+	}
+	if ul, ok := l.(*net.UnixListener); ok {
+		ul.SetUnlinkOnClose(true)
+	}
+	return l, nil
+}
+
+// listenSockaddr returns the socket family and address of addr
+func listenSockaddr(network, addr string) (int, syscall.Sockaddr, error) {
+	switch network {
+	case "unix":
+		return syscall.AF_UNIX, &syscall.SockaddrUnix{Name: addr}, nil
+	case "tcp", "tcp4", "tcp6":
+		tcpAddr, err := net.ResolveTCPAddr(network, addr)
+		if err != nil {
+			return 0, nil, err
+		}
+		// an unspecified tcp address listens on both IPv4 and IPv6
+		if ip4 := tcpAddr.IP.To4(); network == "tcp4" || ip4 != nil && network != "tcp6" {
+			sa := &syscall.SockaddrInet4{Port: tcpAddr.Port}
+			copy(sa.Addr[:], ip4)
+			return syscall.AF_INET, sa, nil
+		}
+		sa := &syscall.SockaddrInet6{Port: tcpAddr.Port}
+		copy(sa.Addr[:], tcpAddr.IP.To16())
+		return syscall.AF_INET6, sa, nil
+	default:
+		return 0, nil, fmt.Errorf("listen backlog is not supported on network %s", network)
+	}
+}
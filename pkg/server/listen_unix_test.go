@@ -0,0 +1,71 @@
+//go:build integration && unix
+
+package server
+
+import (
+	"net"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestListenWithBacklog(t *testing.T) {
+	tests := []struct {
+		network string
+		addr    func(t *testing.T) string
+	}{
+		{network: "unix", addr: func(t *testing.T) string { return filepath.Join(t.TempDir(), "backlog.sock") }},
+		{network: "tcp", addr: func(*testing.T) string { return "127.0.0.1:0" }},
+	}
+	const backlog = 32
+	for _, tt := range tests {
+		t.Run(tt.network, func(t *testing.T) {
+			l, err := ListenWithBacklog(tt.network, tt.addr(t), backlog)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer l.Close()
+
+			// nothing accepts, the connections wait in the backlog
+			for i := 0; i < backlog; i++ {
+				conn, err := net.DialTimeout(l.Addr().Network(), l.Addr().String(), time.Second)
+				if err != nil {
+					t.Fatalf("connection %d refused: %v", i, err)
+				}
+				defer conn.Close()
+			}
+			conn, err := l.Accept()
+			if err != nil {
+				t.Fatal(err)
+			}
+			conn.Close()
+		})
+	}
+}
+
+func TestListenWithBacklogUnlinksSocket(t *testing.T) {
+	sock := filepath.Join(t.TempDir(), "backlog.sock")
+	l, err := ListenWithBacklog("unix", sock, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	l.Close()
+	if _, err := net.Dial("unix", sock); err == nil {
+		t.Fatal("dial closed socket succeeded")
+	}
+	// the socket file is removed on close so that it can be reused
+	l, err = ListenWithBacklog("unix", sock, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	l.Close()
+}
+
+func TestListenWithBacklogInvalid(t *testing.T) {
+	if _, err := ListenWithBacklog("unix", filepath.Join(t.TempDir(), "backlog.sock"), 0); err == nil {
+		t.Error("ListenWithBacklog() with backlog 0 succeeded")
+	}
+	if _, err := ListenWithBacklog("udp", "127.0.0.1:0", 8); err == nil {
+		t.Error("ListenWithBacklog() on udp succeeded")
+	}
+}
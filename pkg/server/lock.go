@@ -0,0 +1,94 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// ErrAlreadyRunning is returned when another plugin instance holds the lock
+var ErrAlreadyRunning = errors.New("micro device plugin is already running")
+
+// FileLock is an exclusive flock preventing concurrent plugin instances
+type FileLock struct {
+	path string
+	file *os.File
+}
+
+// NewFileLock creates a file lock at path
+func NewFileLock(path string) *FileLock {
+	return &FileLock{path: path}
+}
+
+// Acquire takes the lock, retrying until timeout. The kernel releases the
+// flock of a crashed process, so a held lock always belongs to a live
+// instance, possibly in another PID namespace, and is never taken over.
+func (l *FileLock) Acquire(timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	for {
+		err := l.tryLock()
+		if err == nil {
+			return nil
+		}
+		if !errors.Is(err, syscall.EWOULDBLOCK) {
+			return err
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("%w: lock %s held by pid %d", ErrAlreadyRunning, l.path, l.owner())
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+}
+
+func (l *FileLock) tryLock() error {
+	f, err := os.OpenFile(l.path, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return err
+	}
+	if err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX|syscall.LOCK_NB); err != nil {
+		f.Close()
+		return err
+	}
+
+	content := fmt.Sprintf("%d\n%s\n", os.Getpid(), time.Now().Format(time.RFC3339))
+	if err := f.Truncate(0); err != nil {
+		f.Close()
+		return err
+	}
+	if _, err := f.WriteAt([]byte(content), 0); err != nil {
+		f.Close()
+		return err
+	}
+	l.file = f
+	return nil
+}
+
+// Release unlocks the lock file, the file is kept so that all instances
+// always lock the same inode
+func (l *FileLock) Release() error {
+	if l.file == nil {
+		return nil
+	}
+	syscall.Flock(int(l.file.Fd()), syscall.LOCK_UN)
+	err := l.file.Close()
+	l.file = nil
+	return err
+}
+
+// owner returns the pid recorded in the lock file, 0 if unknown
+func (l *FileLock) owner() int {
+	data, err := os.ReadFile(l.path)
+	if err != nil {
+		return 0
+	}
+	line, _, _ := strings.Cut(string(data), "\n")
+	pid, err := strconv.Atoi(strings.TrimSpace(line))
+	if err != nil {
+		return 0
+	}
+	return pid
+}
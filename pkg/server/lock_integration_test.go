@@ -0,0 +1,21 @@
+//go:build integration
+
+package server
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestRunAlreadyRunning(t *testing.T) {
+	first, dir := newTestServer(t, WithHealthInterval(0))
+	if err := first.Run(); err != nil {
+		t.Fatal(err)
+	}
+
+	second, _ := newTestServer(t, WithPluginPath(dir), WithHealthInterval(0), WithLockTimeout(200*time.Millisecond))
+	if err := second.Run(); !errors.Is(err, ErrAlreadyRunning) {
+		t.Fatalf("second Run() error = %v, want %v", err, ErrAlreadyRunning)
+	}
+}
@@ -0,0 +1,36 @@
+package server
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestFileLock(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "micro.lock")
+	first, second := NewFileLock(path), NewFileLock(path)
+	if err := first.Acquire(time.Second); err != nil {
+		t.Fatal(err)
+	}
+
+	// a held lock is never taken over, whatever pid the file records
+	if err := os.WriteFile(path, []byte("999999\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := second.Acquire(100 * time.Millisecond); !errors.Is(err, ErrAlreadyRunning) {
+		t.Fatalf("second Acquire() error = %v, want %v", err, ErrAlreadyRunning)
+	}
+
+	if err := first.Release(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(path); err != nil {
+		t.Errorf("lock file removed on release: %v", err)
+	}
+	if err := second.Acquire(time.Second); err != nil {
+		t.Fatalf("Acquire() after release error = %v", err)
+	}
+	second.Release()
+}
@@ -0,0 +1,130 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// DefaultLogSampleWindow is the default window of the log sampling
+const DefaultLogSampleWindow = 10 * time.Second
+
+// SamplingHandler passes at most maxPerWindow records of the same message
+// per window to the inner handler, the records are counted in a bucket
+// per message refilled when its window ends. The number of suppressed
+// records is logged as a summary at the end of the window.
+type SamplingHandler struct {
+	inner        slog.Handler
+	window       time.Duration
+	maxPerWindow int
+	state        *samplingState
+}
+
+// samplingState is shared by the handlers derived with WithAttrs and
+// WithGroup so that a message is sampled regardless of its attributes
+type samplingState struct {
+	mu      sync.Mutex
+	buckets map[string]*sampleBucket
+}
+
+// sampleBucket counts the records of a message in the current window
+type sampleBucket struct {
+	start      time.Time
+	passed     int
+	suppressed int
+	level      slog.Level
+	timer      *time.Timer
+}
+
+// NewSamplingHandler wraps inner to pass at most maxPerWindow records of
+// the same message per window
+func NewSamplingHandler(inner slog.Handler, window time.Duration, maxPerWindow int) *SamplingHandler {
+	return &SamplingHandler{
+		inner:        inner,
+		window:       window,
+		maxPerWindow: max(maxPerWindow, 1),
+		state:        &samplingState{buckets: make(map[string]*sampleBucket)},
+	}
+}
+
+// Enabled reports whether the inner handler handles records of level
+func (h *SamplingHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.inner.Enabled(ctx, level)
+}
+
+// Handle passes the record to the inner handler unless its message used
+// up the records of the window
+func (h *SamplingHandler) Handle(ctx context.Context, r slog.Record) error {
+	now := time.Now()
+	h.state.mu.Lock()
+	b, ok := h.state.buckets[r.Message]
+	if !ok || now.Sub(b.start) >= h.window && b.suppressed == 0 {
+		b = &sampleBucket{start: now}
+		h.state.buckets[r.Message] = b
+	}
+	if b.passed < h.maxPerWindow {
+		b.passed++
+		h.state.mu.Unlock()
+		return h.inner.Handle(ctx, r)
+	}
+
+	b.suppressed++
+	b.level = max(b.level, r.Level)
+	if b.timer == nil {
+		msg := r.Message
+		b.timer = time.AfterFunc(b.start.Add(h.window).Sub(now), func() { h.flush(msg) })
+	}
+	h.state.mu.Unlock()
+	return nil
+}
+
+// flush ends the window of the message and logs its suppressed records
+func (h *SamplingHandler) flush(msg string) {
+	h.state.mu.Lock()
+	b, ok := h.state.buckets[msg]
+	if ok {
+		delete(h.state.buckets, msg)
+		b.timer.Stop()
+	}
+	h.state.mu.Unlock()
+	if !ok || b.suppressed == 0 {
+		return
+	}
+
+	r := slog.NewRecord(time.Now(), b.level, fmt.Sprintf("%d similar messages suppressed", b.suppressed), 0)
+	r.AddAttrs(slog.String("message", msg), slog.Duration("window", h.window))
+	// a failing log output leaves nowhere to report the error
+	h.inner.Handle(context.Background(), r)
+}
+
+// Flush logs the suppressed records of the current windows, it is called
+// before the log output is closed
+func (h *SamplingHandler) Flush() {
+	h.state.mu.Lock()
+	var msgs []string
+	for msg, b := range h.state.buckets {
+		if b.suppressed > 0 {
+			msgs = append(msgs, msg)
+		}
+	}
+	h.state.mu.Unlock()
+	for _, msg := range msgs {
+		h.flush(msg)
+	}
+}
+
+// WithAttrs returns a sampling handler of the inner handler with attrs
+func (h *SamplingHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	c := *h
+	c.inner = h.inner.WithAttrs(attrs)
+	return &c
+}
+
+// WithGroup returns a sampling handler of the inner handler with group
+func (h *SamplingHandler) WithGroup(name string) slog.Handler {
+	c := *h
+	c.inner = h.inner.WithGroup(name)
+	return &c
+}
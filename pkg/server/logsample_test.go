@@ -0,0 +1,90 @@
+package server
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"testing"
+	"time"
+)
+
+// recordingHandler records the messages of the handled records
+type recordingHandler struct {
+	mu       sync.Mutex
+	messages []string
+	attrs    []map[string]string
+}
+
+func (h *recordingHandler) Enabled(context.Context, slog.Level) bool { return true }
+
+func (h *recordingHandler) Handle(_ context.Context, r slog.Record) error {
+	attrs := make(map[string]string)
+	r.Attrs(func(a slog.Attr) bool {
+		attrs[a.Key] = a.Value.String()
+		return true
+	})
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.messages = append(h.messages, r.Message)
+	h.attrs = append(h.attrs, attrs)
+	return nil
+}
+
+func (h *recordingHandler) WithAttrs([]slog.Attr) slog.Handler { return h }
+
+func (h *recordingHandler) WithGroup(string) slog.Handler { return h }
+
+func (h *recordingHandler) records() ([]string, []map[string]string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return append([]string(nil), h.messages...), append([]map[string]string(nil), h.attrs...)
+}
+
+func TestSamplingHandler(t *testing.T) {
+	inner := &recordingHandler{}
+	logger := slog.New(NewSamplingHandler(inner, 100*time.Millisecond, 2))
+
+	for i := 0; i < 100; i++ {
+		logger.Info("device event", "i", i)
+	}
+	logger.With("device", "micro0").Info("other event")
+	if messages, _ := inner.records(); len(messages) != 3 {
+		t.Fatalf("inner handler got %d records, want 3: %v", len(messages), messages)
+	}
+
+	// the summary is logged at the end of the window
+	deadline := time.Now().Add(2 * time.Second)
+	messages, attrs := inner.records()
+	for len(messages) < 4 {
+		if time.Now().After(deadline) {
+			t.Fatal("no summary logged at the end of the window")
+		}
+		time.Sleep(10 * time.Millisecond)
+		messages, attrs = inner.records()
+	}
+	if messages[3] != "98 similar messages suppressed" || attrs[3]["message"] != "device event" {
+		t.Errorf("summary = %q %v, want 98 suppressed device event messages", messages[3], attrs[3])
+	}
+
+	// the next window passes the message again
+	logger.Info("device event")
+	if messages, _ := inner.records(); len(messages) != 5 {
+		t.Errorf("inner handler got %d records after the window, want 5", len(messages))
+	}
+}
+
+func TestSamplingHandlerFlush(t *testing.T) {
+	inner := &recordingHandler{}
+	h := NewSamplingHandler(inner, time.Hour, 1)
+	logger := slog.New(h)
+	for i := 0; i < 10; i++ {
+		logger.Warn("slow allocation")
+	}
+	h.Flush()
+
+	messages, _ := inner.records()
+	want := []string{"slow allocation", "9 similar messages suppressed"}
+	if len(messages) != len(want) || messages[0] != want[0] || messages[1] != want[1] {
+		t.Errorf("records = %v, want %v", messages, want)
+	}
+}
@@ -0,0 +1,177 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+// PluginManager runs the micro device plugin as multiple shards, each
+// shard serves a disjoint subset of devices on its own plugin socket
+type PluginManager struct {
+	mu      sync.Mutex
+	servers []*MicroDeviceServer
+}
+
+var _ DevicePlugin = (*PluginManager)(nil)
+
+// NewPluginManager creates count plugin shards configured with opts.
+// With more than one shard, shard i listens on `micro-<i>.sock` and
+// advertises the `<resource>-<i>` resource. Every resource alias adds a
+// server per shard advertising the same devices under the alias. It fails
+// if a server cannot be created, the servers created before are stopped.
+func NewPluginManager(count int, opts ...Option) (*PluginManager, error) {
+	if count < 1 {
+		count = 1
+	}
+	m := &PluginManager{}
+	for i := 0; i < count; i++ {
+		shardOpts := append(opts[:len(opts):len(opts)], withShard(i, count))
+		s, err := NewMicroDeviceServer(shardOpts...)
+		if err != nil {
+			m.Stop()
+			return nil, err
+		}
+		m.servers = append(m.servers, s)
+		if len(s.resourceAliases) == 0 {
+			continue
+		}
+
+		// the alias servers advertise the devices of the shard, the
+		// shared registry keeps kubelet from allocating a device twice
+		s.allocRegistry = NewAllocationRegistry()
+		for j, alias := range s.resourceAliases {
+			aliasOpts := append(opts[:len(opts):len(opts)], withAlias(j, alias, s.allocRegistry), withShard(i, count))
+			alias, err := NewMicroDeviceServer(aliasOpts...)
+			if err != nil {
+				m.Stop()
+				return nil, err
+			}
+			m.servers = append(m.servers, alias)
+		}
+	}
+	return m, nil
+}
+
+// withShard assigns the server to shard i of count
+func withShard(i, count int) Option {
+	return func(s *MicroDeviceServer) {
+		s.shard = i
+		s.shardCount = count
+		if count > 1 {
+			s.socketName = fmt.Sprintf("%s-%d.sock", strings.TrimSuffix(s.socketName, ".sock"), i)
+			s.resourceName = fmt.Sprintf("%s-%d", s.resourceName, i)
+		}
+	}
+}
+
+// ShardOf returns the shard of the device name. Names ending with an
+// index, e.g. micro3, are assigned round robin by index so that
+// consecutively numbered devices are spread evenly over the shards, other
+// names use jump consistent hashing so that few devices move when the
+// shard count changes
+func ShardOf(name string, count int) int {
+	if count <= 1 {
+		return 0
+	}
+	if i := len(strings.TrimRightFunc(name, unicode.IsDigit)); i < len(name) {
+		if index, err := strconv.Atoi(name[i:]); err == nil {
+			return index % count
+		}
+	}
+
+	h := fnv.New64a()
+	h.Write([]byte(name))
+	key := h.Sum64()
+
+	var b, j int64 = -1, 0
+	for j < int64(count) {
+		b = j
+		key = key*2862933555777941757 + 1
+		j = int64(float64(b+1) * (float64(int64(1)<<31) / float64((key>>33)+1)))
+	}
+	return int(b)
+}
+
+// Servers returns the plugin shards
+func (m *PluginManager) Servers() []*MicroDeviceServer {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]*MicroDeviceServer{}, m.servers...)
+}
+
+// Run starts all plugin shards
+func (m *PluginManager) Run() error {
+	for _, s := range m.Servers() {
+		if err := s.Run(); err != nil {
+			return fmt.Errorf("run shard %s: %w", s.resourceName, err)
+		}
+	}
+	return nil
+}
+
+// Idle is closed when every plugin shard is idle
+func (m *PluginManager) Idle() <-chan struct{} {
+	servers := m.Servers()
+	if len(servers) == 1 {
+		return servers[0].Idle()
+	}
+	idle := make(chan struct{})
+	go func() {
+		for _, s := range servers {
+			<-s.Idle()
+		}
+		close(idle)
+	}()
+	return idle
+}
+
+// RegisterToKubelet registers every plugin shard with kubelet, the shards
+// register concurrently so that their registration jitters overlap
+func (m *PluginManager) RegisterToKubelet() error {
+	servers := m.Servers()
+	errs := make([]error, len(servers))
+	var wg sync.WaitGroup
+	for i, s := range servers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := s.RegisterToKubelet(); err != nil {
+				errs[i] = fmt.Errorf("register shard %s: %w", s.resourceName, err)
+			}
+		}()
+	}
+	wg.Wait()
+	return errors.Join(errs...)
+}
+
+// Stop stops all plugin shards
+func (m *PluginManager) Stop() {
+	for _, s := range m.Servers() {
+		s.Stop()
+	}
+}
+
+// Handler serves the first shard at the root path and every shard
+// under `/shards/<i>/`, the REST API adds `POST /api/v1/shrink`
+func (m *PluginManager) Handler() http.Handler {
+	servers := m.Servers()
+	mux := http.NewServeMux()
+	mux.Handle("/", servers[0].Handler())
+	if servers[0].restAPI {
+		mux.HandleFunc("POST /api/v1/shrink", m.handleShrink)
+	}
+	if len(servers) == 1 {
+		return mux
+	}
+	for i, s := range servers {
+		prefix := fmt.Sprintf("/shards/%d", i)
+		mux.Handle(prefix+"/", http.StripPrefix(prefix, s.Handler()))
+	}
+	return mux
+}
@@ -0,0 +1,82 @@
+package server
+
+import (
+	"fmt"
+	"path/filepath"
+	"testing"
+)
+
+func TestShardOf(t *testing.T) {
+	tests := []struct {
+		devices int
+		shards  int
+		want    []int // expected: devices per shard
+	}{
+		{devices: 4, shards: 2, want: []int{2, 2}},
+		{devices: 8, shards: 3, want: []int{3, 3, 2}},
+		{devices: 1000, shards: 4, want: []int{250, 250, 250, 250}},
+		{devices: 3, shards: 1, want: []int{3}},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("%d devices %d shards", tt.devices, tt.shards), func(t *testing.T) {
+			got := make([]int, tt.shards)
+			for i := 0; i < tt.devices; i++ {
+				got[ShardOf(fmt.Sprintf("micro%d", i), tt.shards)]++
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Errorf("devices per shard = %v, want %v", got, tt.want)
+					break
+				}
+			}
+		})
+	}
+
+	// names without index are hashed deterministically
+	for _, name := range []string{"gpu", "micro-a", "accel.x"} {
+		shard := ShardOf(name, 3)
+		if shard < 0 || shard >= 3 || ShardOf(name, 3) != shard {
+			t.Errorf("ShardOf(%s, 3) = %d, want a stable shard in [0, 3)", name, shard)
+		}
+	}
+}
+
+func TestPluginManagerShards(t *testing.T) {
+	m := newTestManager(t)
+	servers := m.Servers()
+	createDevices(t, servers[0].devicePath, "micro0", "micro1", "micro2", "micro3")
+
+	seen := map[string]int{}
+	for i, s := range servers {
+		if err := s.findDevice(); err != nil {
+			t.Fatalf("shard %d findDevice() = %v", i, err)
+		}
+		if got := len(s.deviceList()); got != 2 {
+			t.Errorf("shard %d advertises %d devices, want 2", i, got)
+		}
+		for _, dev := range s.deviceList() {
+			seen[dev.ID]++
+		}
+		if want := fmt.Sprintf("micro-%d.sock", i); s.socketName != want {
+			t.Errorf("shard %d socket = %s, want %s", i, s.socketName, want)
+		}
+		if want := fmt.Sprintf("micro-%d.lock", i); filepath.Base(s.lock.path) != want {
+			t.Errorf("shard %d lock file = %s, want %s", i, filepath.Base(s.lock.path), want)
+		}
+	}
+	if len(seen) != 4 {
+		t.Errorf("shards advertise %d distinct devices, want 4", len(seen))
+	}
+	for id, n := range seen {
+		if n != 1 {
+			t.Errorf("device %s advertised by %d shards", id, n)
+		}
+	}
+}
+
+func TestUnshardedLockFile(t *testing.T) {
+	s, _ := newTestServer(t)
+	if got := filepath.Base(s.lock.path); got != "micro.lock" {
+		t.Errorf("lock file = %s, want micro.lock", got)
+	}
+}
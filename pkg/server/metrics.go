@@ -0,0 +1,191 @@
+package server
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/expfmt"
+)
+
+// metricsNamespace is the prometheus namespace of the plugin metrics
+const metricsNamespace = "micro_device_plugin"
+
+// pluginMetrics are the metrics of a server instance, every server owns
+// its collectors so that the instances sharing a registry under
+// different labels report their own values
+type pluginMetrics struct {
+	leaseRenewalFailures     prometheus.Counter
+	livenessFailures         prometheus.Counter
+	activeAllocations        prometheus.Gauge
+	activeStreams            prometheus.Gauge
+	reconnectReconciliations prometheus.Counter
+	socketRecoveries         prometheus.Counter
+	notifyDropped            prometheus.Counter
+	deviceTemperature        prometheus.Gauge
+	systemReserved           prometheus.Gauge
+	gcCleanedAllocations     prometheus.Counter
+	panicsRecovered          *prometheus.CounterVec
+}
+
+func newPluginMetrics() *pluginMetrics {
+	return &pluginMetrics{
+		leaseRenewalFailures: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "lease_renewal_failures_total",
+			Help:      "Total number of failed heartbeat lease renewals",
+		}),
+		livenessFailures: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "liveness_failure_total",
+			Help:      "Total number of failed liveness checks",
+		}),
+		activeAllocations: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: metricsNamespace,
+			Name:      "active_allocations",
+			Help:      "Number of devices allocated to running pods",
+		}),
+		activeStreams: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: metricsNamespace,
+			Name:      "active_streams",
+			Help:      "Number of active ListAndWatch streams",
+		}),
+		reconnectReconciliations: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "reconnect_reconciliations_total",
+			Help:      "Total number of device reconciliations on ListAndWatch reconnect",
+		}),
+		socketRecoveries: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "socket_recoveries_total",
+			Help:      "Total number of plugin socket recoveries after external removal",
+		}),
+		notifyDropped: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "notify_dropped_total",
+			Help:      "Total number of device change notifications dropped over a full buffer",
+		}),
+		deviceTemperature: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: metricsNamespace,
+			Name:      "device_temperature_celsius",
+			Help:      "Average temperature of the devices scored by the thermal scorer",
+		}),
+		systemReserved: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: metricsNamespace,
+			Name:      "system_reserved_devices",
+			Help:      "Number of devices reserved for the system and hidden from kubelet",
+		}),
+		gcCleanedAllocations: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "gc_cleaned_allocations_total",
+			Help:      "Total number of orphaned device allocations removed from the state",
+		}),
+		panicsRecovered: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "panics_recovered_total",
+			Help:      "Total number of recovered panics by goroutine",
+		}, []string{"goroutine"}),
+	}
+}
+
+// metricLabels returns the labels telling apart the metrics of the
+// servers sharing a registry, the namespace of namespaced servers and the
+// resource of the plugin shards and resource aliases
+func (s *MicroDeviceServer) metricLabels() prometheus.Labels {
+	labels := prometheus.Labels{}
+	if s.namespace != "" {
+		labels["namespace"] = s.namespace
+	}
+	if s.shardCount > 1 || len(s.resourceAliases) > 0 || s.allocRegistry != nil {
+		labels["resource"] = s.resourceName
+	}
+	return labels
+}
+
+// registerMetrics registers the server metrics to its registry, a server
+// sharing the registry and labels of another server instance updates the
+// collectors already registered by the instance
+func (s *MicroDeviceServer) registerMetrics() error {
+	reg := s.registry
+	if labels := s.metricLabels(); len(labels) > 0 {
+		reg = prometheus.WrapRegistererWith(labels, reg)
+	}
+	m := s.metrics
+	errs := []error{
+		registerCollector(reg, &m.leaseRenewalFailures),
+		registerCollector(reg, &m.livenessFailures),
+		registerCollector(reg, &m.activeAllocations),
+		registerCollector(reg, &m.activeStreams),
+		registerCollector(reg, &m.reconnectReconciliations),
+		registerCollector(reg, &m.socketRecoveries),
+		registerCollector(reg, &m.notifyDropped),
+		registerCollector(reg, &m.deviceTemperature),
+		registerCollector(reg, &m.systemReserved),
+		registerCollector(reg, &m.gcCleanedAllocations),
+		registerCollector(reg, &m.panicsRecovered),
+	}
+	if s.priorityAllocator != nil {
+		errs = append(errs, registerCollector(reg, &s.priorityAllocator.depth))
+	}
+	if err := errors.Join(errs...); err != nil {
+		return fmt.Errorf("register metrics: %w", err)
+	}
+
+	if s.heartbeat != nil {
+		s.heartbeat.failures = m.leaseRenewalFailures
+	}
+	if t, ok := s.scorer.(ThermalScorer); ok {
+		t.temperature = m.deviceTemperature
+		s.scorer = t
+	}
+	return nil
+}
+
+// registerCollector registers the collector c points to, c is replaced
+// by the collector of the same metric already registered
+func registerCollector[C prometheus.Collector](reg prometheus.Registerer, c *C) error {
+	err := reg.Register(*c)
+	var are prometheus.AlreadyRegisteredError
+	if !errors.As(err, &are) {
+		return err
+	}
+	existing, ok := are.ExistingCollector.(C)
+	if !ok {
+		return err
+	}
+	*c = existing
+	return nil
+}
+
+// gatherer returns the gatherer of the server registry
+func (s *MicroDeviceServer) gatherer() prometheus.Gatherer {
+	if g, ok := s.registry.(prometheus.Gatherer); ok && s.registry != prometheus.DefaultRegisterer {
+		return g
+	}
+	return prometheus.DefaultGatherer
+}
+
+// writeMetricsFile writes the gathered metrics in Prometheus text format
+// to the metrics file if configured
+func (s *MicroDeviceServer) writeMetricsFile() {
+	if s.metricsFile == "" {
+		return
+	}
+	families, err := s.gatherer().Gather()
+	if err != nil {
+		s.logger.Error("gather metrics failed", "err", err)
+		return
+	}
+
+	var buf bytes.Buffer
+	for _, mf := range families {
+		if _, err := expfmt.MetricFamilyToText(&buf, mf); err != nil {
+			s.logger.Error("encode metrics failed", "metric", mf.GetName(), "err", err)
+			return
+		}
+	}
+	if err := writeFileAtomic(s.metricsFile, buf.Bytes(), 0644); err != nil {
+		s.logger.Error("write metrics file failed", "path", s.metricsFile, "err", err)
+	}
+}
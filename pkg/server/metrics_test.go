@@ -0,0 +1,55 @@
+package server
+
+import (
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
+
+	"github.com/kelein/micro-device-plugin/pkg/testing/assert"
+)
+
+// readMetricsFile parses the Prometheus text format file as a gatherer
+func readMetricsFile(t *testing.T, path string) prometheus.Gatherer {
+	t.Helper()
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("open metrics file: %v", err)
+	}
+	defer f.Close()
+
+	var parser expfmt.TextParser
+	families, err := parser.TextToMetricFamilies(f)
+	if err != nil {
+		t.Fatalf("parse metrics file: %v", err)
+	}
+	return prometheus.GathererFunc(func() ([]*dto.MetricFamily, error) {
+		return slices.Collect(maps.Values(families)), nil
+	})
+}
+
+func TestMetricsFile(t *testing.T) {
+	devDir := t.TempDir()
+	createDevices(t, devDir, "micro0", "micro1")
+	metricsFile := filepath.Join(t.TempDir(), "textfile", "micro.prom")
+	s, _ := newTestServer(t, WithDevicePath(devDir), WithMetricsFile(metricsFile))
+	s.markAllocated([]string{deviceID("micro0")})
+
+	// action: the discovered devices write the metrics file
+	if err := s.findDevice(); err != nil {
+		t.Fatal(err)
+	}
+	scraped := readMetricsFile(t, metricsFile)
+	assert.AssertMetricValue(t, scraped, "micro_device_plugin_active_allocations", nil, 1)
+	assert.AssertMetricValue(t, scraped, "micro_device_plugin_notify_dropped_total", nil, 0)
+
+	// action: the health check tick rewrites the metrics file
+	s.releaseDevices([]string{deviceID("micro0")}, "pod")
+	s.checkHealth()
+	assert.AssertMetricValue(t, readMetricsFile(t, metricsFile), "micro_device_plugin_active_allocations", nil, 0)
+}
@@ -0,0 +1,76 @@
+//go:build integration
+
+package server
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/kelein/micro-device-plugin/pkg/state"
+	"github.com/kelein/micro-device-plugin/pkg/testing/assert"
+	"github.com/kelein/micro-device-plugin/pkg/testutil"
+)
+
+func TestNamespacedInstances(t *testing.T) {
+	pluginDir, stateRoot := t.TempDir(), t.TempDir()
+	reg := prometheus.NewRegistry()
+
+	var servers []*MicroDeviceServer
+	for _, ns := range []string{"team-a", "team-b"} {
+		dir := NamespacedStateDir(stateRoot, ns)
+		if err := os.MkdirAll(dir, 0700); err != nil {
+			t.Fatal(err)
+		}
+		wal, err := state.OpenWAL(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { wal.Close() })
+		store, err := state.NewStateStore("json", dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		s, _ := newTestServer(t, WithPluginPath(pluginDir), WithNamespace(ns), WithMetrics(reg),
+			WithStateWAL(wal, time.Hour), WithStateStore(store))
+		if err := s.Run(); err != nil {
+			t.Fatalf("Run() namespace %s = %v", ns, err)
+		}
+		servers = append(servers, s)
+	}
+
+	a, b := servers[0], servers[1]
+	if a.socketPath() == b.socketPath() {
+		t.Fatalf("namespaces share the socket %s", a.socketPath())
+	}
+	for _, s := range servers {
+		if want := filepath.Join(pluginDir, s.namespace+"-"+microSocket); s.socketPath() != want {
+			t.Errorf("socket = %s, want %s", s.socketPath(), want)
+		}
+		if _, err := os.Stat(s.socketPath()); err != nil {
+			t.Errorf("socket of namespace %s not created: %v", s.namespace, err)
+		}
+	}
+
+	// the instances count their own allocations
+	id := a.addDevice(&MicroDevice{Name: "micro0"})
+	if _, err := a.Allocate(context.Background(), testutil.NewMockAllocateRequest().WithDeviceIDs(id).Build()); err != nil {
+		t.Fatalf("Allocate() = %v", err)
+	}
+	assert.AssertMetricValue(t, reg, "micro_device_plugin_active_allocations", map[string]string{"namespace": "team-a"}, 1)
+	assert.AssertMetricValue(t, reg, "micro_device_plugin_active_allocations", map[string]string{"namespace": "team-b"}, 0)
+
+	for _, s := range servers {
+		s.Stop()
+	}
+	for _, ns := range []string{"team-a", "team-b"} {
+		if _, err := os.Stat(filepath.Join(stateRoot, ns, "state.json")); err != nil {
+			t.Errorf("state file of namespace %s not saved: %v", ns, err)
+		}
+	}
+}
@@ -0,0 +1,109 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"strconv"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes"
+)
+
+// DefaultNodeLabelPrefix prefixes the device inventory node labels
+const DefaultNodeLabelPrefix = "micro.example.com"
+
+// Device inventory node label names following the label prefix
+const (
+	deviceCountLabel   = "device-count"
+	deviceHealthyLabel = "device-healthy"
+)
+
+// NodeLabelManager exposes the device inventory as node labels so that
+// pods can select nodes with devices without extended resources
+type NodeLabelManager struct {
+	client   kubernetes.Interface
+	nodeName string
+	prefix   string
+	interval time.Duration
+}
+
+// NewNodeLabelManager creates a manager labeling the node with prefix,
+// reconciling the labels every interval
+func NewNodeLabelManager(client kubernetes.Interface, nodeName, prefix string, interval time.Duration) *NodeLabelManager {
+	return &NodeLabelManager{
+		client:   client,
+		nodeName: nodeName,
+		prefix:   prefix,
+		interval: interval,
+	}
+}
+
+// Run reconciles the node labels with the inventory on every tick until
+// ctx is done
+func (m *NodeLabelManager) Run(ctx context.Context, inventory func() (count, healthy int)) {
+	slog.Info("node label reconcile started", "node", m.nodeName, "prefix", m.prefix)
+	ticker := time.NewTicker(m.interval)
+	defer ticker.Stop()
+
+	for {
+		count, healthy := inventory()
+		if err := m.Sync(ctx, count, healthy); err != nil {
+			slog.Error("reconcile node labels failed", "node", m.nodeName, "err", err)
+		}
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			slog.Info("node label reconcile exited")
+			return
+		}
+	}
+}
+
+// Sync patches the device count and health labels of the node, the
+// labels are removed when the node has no devices
+func (m *NodeLabelManager) Sync(ctx context.Context, count, healthy int) error {
+	labels := m.Labels(count, healthy)
+
+	// a null value removes the label in a merge patch
+	patched := make(map[string]*string, 2)
+	for _, name := range []string{deviceCountLabel, deviceHealthyLabel} {
+		key := m.prefix + "/" + name
+		if v, ok := labels[key]; ok {
+			patched[key] = &v
+		} else {
+			patched[key] = nil
+		}
+	}
+	patch, err := json.Marshal(map[string]any{
+		"metadata": map[string]any{"labels": patched},
+	})
+	if err != nil {
+		return err
+	}
+	_, err = m.client.CoreV1().Nodes().Patch(ctx, m.nodeName, types.MergePatchType, patch, metav1.PatchOptions{})
+	return err
+}
+
+// Labels returns the node labels of the device inventory, empty if the
+// node has no devices
+func (m *NodeLabelManager) Labels(count, healthy int) map[string]string {
+	labels := make(map[string]string, 2)
+	if count == 0 {
+		return labels
+	}
+	labels[m.prefix+"/"+deviceCountLabel] = strconv.Itoa(count)
+	labels[m.prefix+"/"+deviceHealthyLabel] = strconv.FormatBool(healthy > 0)
+	return labels
+}
+
+// deviceInventory returns the number of devices and healthy devices
+func (s *MicroDeviceServer) deviceInventory() (count, healthy int) {
+	s.mu.RLock()
+	count = len(s.devices)
+	s.mu.RUnlock()
+	return count, s.healthyCount()
+}
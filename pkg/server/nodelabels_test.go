@@ -0,0 +1,56 @@
+package server
+
+import (
+	"context"
+	"maps"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestNodeLabelManagerSync(t *testing.T) {
+	ctx := context.Background()
+	client := fake.NewClientset(&corev1.Node{ObjectMeta: metav1.ObjectMeta{
+		Name:   "node1",
+		Labels: map[string]string{"kubernetes.io/os": "linux"},
+	}})
+	m := NewNodeLabelManager(client, "node1", "micro.example.com", 0)
+
+	nodeLabels := func() map[string]string {
+		t.Helper()
+		node, err := client.CoreV1().Nodes().Get(ctx, "node1", metav1.GetOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return node.Labels
+	}
+
+	steps := []struct {
+		count, healthy int
+		want           map[string]string
+	}{
+		{5, 5, map[string]string{
+			"kubernetes.io/os":                 "linux",
+			"micro.example.com/device-count":   "5",
+			"micro.example.com/device-healthy": "true",
+		}},
+		{2, 0, map[string]string{
+			"kubernetes.io/os":                 "linux",
+			"micro.example.com/device-count":   "2",
+			"micro.example.com/device-healthy": "false",
+		}},
+		{0, 0, map[string]string{
+			"kubernetes.io/os": "linux",
+		}},
+	}
+	for _, step := range steps {
+		if err := m.Sync(ctx, step.count, step.healthy); err != nil {
+			t.Fatalf("Sync(%d, %d) error = %v", step.count, step.healthy, err)
+		}
+		if got := nodeLabels(); !maps.Equal(got, step.want) {
+			t.Errorf("labels after Sync(%d, %d) = %v, want %v", step.count, step.healthy, got, step.want)
+		}
+	}
+}
@@ -0,0 +1,645 @@
+package server
+
+import (
+	"log/slog"
+	"regexp"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/keepalive"
+	"k8s.io/client-go/kubernetes"
+
+	"github.com/kelein/micro-device-plugin/pkg/config"
+	"github.com/kelein/micro-device-plugin/pkg/discovery"
+	"github.com/kelein/micro-device-plugin/pkg/state"
+)
+
+// Option configures the micro device plugin server
+type Option func(*MicroDeviceServer)
+
+// WithDevicePath sets the directory of the micro device files
+func WithDevicePath(path string) Option {
+	return func(s *MicroDeviceServer) {
+		s.devicePath = path
+	}
+}
+
+// WithPluginPath sets the kubelet device plugin directory
+func WithPluginPath(path string) Option {
+	return func(s *MicroDeviceServer) {
+		s.pluginPath = path
+	}
+}
+
+// WithResourceName sets the extended resource name advertised to kubelet
+func WithResourceName(name string) Option {
+	return func(s *MicroDeviceServer) {
+		s.resourceName = name
+	}
+}
+
+// WithSocketName sets the unix socket file name of the plugin
+func WithSocketName(name string) Option {
+	return func(s *MicroDeviceServer) {
+		s.socketName = name
+	}
+}
+
+// WithDevicePathWatchRecursive discovers and watches the device files of
+// the device path subdirectories
+func WithDevicePathWatchRecursive(enable bool) Option {
+	return func(s *MicroDeviceServer) {
+		s.recursive = enable
+	}
+}
+
+// WithPollInterval polls the device directory and the plugin socket every
+// interval instead of watching them with fsnotify, 0 uses fsnotify
+func WithPollInterval(interval time.Duration) Option {
+	return func(s *MicroDeviceServer) {
+		s.pollInterval = interval
+	}
+}
+
+// WithRuntimeAdapter adjusts the Allocate responses to the container
+// runtime of the node with a RuntimeAdapter
+func WithRuntimeAdapter(a *RuntimeAdapter) Option {
+	return func(s *MicroDeviceServer) {
+		s.runtime = a
+	}
+}
+
+// WithDownwardAPIInjector adds the downward API fields of i to the
+// container env vars of the Allocate responses
+func WithDownwardAPIInjector(i *DownwardAPIInjector) Option {
+	return func(s *MicroDeviceServer) {
+		s.downwardAPI = i
+	}
+}
+
+// WithWarmer warms the newly discovered devices with w, the devices are
+// reported unhealthy until warming succeeds
+func WithWarmer(w DeviceWarmer) Option {
+	return func(s *MicroDeviceServer) {
+		s.warmer = w
+	}
+}
+
+// WithNodeLabels exposes the device inventory as node labels with m
+func WithNodeLabels(m *NodeLabelManager) Option {
+	return func(s *MicroDeviceServer) {
+		s.nodeLabels = m
+	}
+}
+
+// WithGRPCHealth serves the grpc.health.v1 health service on the plugin
+// socket, reporting SERVING when registered with a healthy device
+func WithGRPCHealth(enable bool) Option {
+	return func(s *MicroDeviceServer) {
+		s.enableGRPCHealth = enable
+	}
+}
+
+// WithNotifyBufferSize sets the size of the buffered device change
+// notification channel, notifications over a full buffer are dropped
+func WithNotifyBufferSize(size int) Option {
+	return func(s *MicroDeviceServer) {
+		s.notifyBuffer = size
+	}
+}
+
+// WithAttestor verifies the device files with the attestor before they
+// are advertised, failing devices are unhealthy
+func WithAttestor(a Attestor) Option {
+	return func(s *MicroDeviceServer) {
+		s.attestor = a
+	}
+}
+
+// WithAllocationWebhook asks the webhook to approve the Allocate
+// requests, denied requests fail with PermissionDenied
+func WithAllocationWebhook(w *AllocationWebhook) Option {
+	return func(s *MicroDeviceServer) {
+		s.webhook = w
+	}
+}
+
+// WithStandalone runs the device discovery and health checks without
+// the device plugin API for bare-metal use, the devices are allocated
+// with the REST API of the HTTP handler instead
+func WithStandalone(standalone bool) Option {
+	return func(s *MicroDeviceServer) {
+		s.standalone = standalone
+	}
+}
+
+// WithAdmission validates the pods requesting the plugin resource with
+// the admission webhook a, the plugin shards share the webhook server
+func WithAdmission(a *AdmissionController) Option {
+	return func(s *MicroDeviceServer) {
+		s.admission = a
+		a.attach(s)
+	}
+}
+
+// WithStaticDevicesFile falls back to the devices declared in the static
+// devices file path when the device discovery finds no devices
+func WithStaticDevicesFile(path string) Option {
+	return func(s *MicroDeviceServer) {
+		s.fallback = discovery.NewStaticFileDiscoverer(path)
+	}
+}
+
+// WithLogger sets the logger of the server
+func WithLogger(logger *slog.Logger) Option {
+	return func(s *MicroDeviceServer) {
+		s.logger = logger
+	}
+}
+
+// WithMetrics sets the registerer of the plugin metrics
+func WithMetrics(reg prometheus.Registerer) Option {
+	return func(s *MicroDeviceServer) {
+		s.registry = reg
+	}
+}
+
+// WithConfig applies the plugin configuration
+func WithConfig(cfg *config.Config) Option {
+	return func(s *MicroDeviceServer) {
+		s.devicePath = cfg.DevicePath
+		s.pluginPath = cfg.PluginPath
+		s.resourceName = cfg.ResourceName
+		s.maxDevices = cfg.MaxDevices
+		s.resourceCount = cfg.ResourceCount
+		s.archDevicePaths = cfg.ArchDevicePaths
+		s.featureGates = cfg.FeatureGates
+		s.resourceAliases = cfg.ResourceAliases
+		if len(cfg.SupportedVersions) > 0 {
+			s.supportedVersions = cfg.SupportedVersions
+		}
+		s.cfg = cfg
+	}
+}
+
+// WithCreateDevicePath creates the device directory on start if it does
+// not exist
+func WithCreateDevicePath(create bool) Option {
+	return func(s *MicroDeviceServer) {
+		s.createPath = create
+	}
+}
+
+// WithStartupProbe delays the device discovery of Run until probe
+// succeeds
+func WithStartupProbe(probe *StartupProbe) Option {
+	return func(s *MicroDeviceServer) {
+		s.startupProbe = probe
+	}
+}
+
+// WithReflection enables gRPC server reflection for grpcurl debugging
+func WithReflection(enable bool) Option {
+	return func(s *MicroDeviceServer) {
+		s.reflection = enable
+	}
+}
+
+// WithDevicesRegex only includes devices whose filename matches re
+func WithDevicesRegex(re *regexp.Regexp) Option {
+	return func(s *MicroDeviceServer) {
+		s.devicesRe = re
+	}
+}
+
+// WithDeviceSelector filters and transforms the devices matching the
+// devices regex with selector before adding them
+func WithDeviceSelector(selector DeviceSelector) Option {
+	return func(s *MicroDeviceServer) {
+		s.selector = selector
+	}
+}
+
+// WithAffinityMap assigns the devices to the affinity groups of groups,
+// keyed by device name, preferred allocations stay within one group
+func WithAffinityMap(groups map[string]string) Option {
+	return func(s *MicroDeviceServer) {
+		s.affinityMap = groups
+	}
+}
+
+// WithUdev discovers devices from udev events of the subsystem
+func WithUdev(subsystem string) Option {
+	return func(s *MicroDeviceServer) {
+		s.udevSubsystem = subsystem
+	}
+}
+
+// WithGRPCOptions appends options used to create the gRPC server
+func WithGRPCOptions(opts ...grpc.ServerOption) Option {
+	return func(s *MicroDeviceServer) {
+		s.grpcOpts = append(s.grpcOpts, opts...)
+	}
+}
+
+// WithClaimTokens injects a device claim token expiring after ttl
+// into each allocated container
+func WithClaimTokens(ttl time.Duration) Option {
+	return func(s *MicroDeviceServer) {
+		s.claims = NewClaimStore(ttl)
+	}
+}
+
+// WithDeltaListAndWatch sends only the changed devices after the first
+// full list of ListAndWatch, encoded by EncodeDelta. Kubelet requires full
+// lists, so the plugin registers a DeltaProxy socket with kubelet instead
+// of the plugin socket.
+func WithDeltaListAndWatch(enable bool) Option {
+	return func(s *MicroDeviceServer) {
+		s.delta = enable
+	}
+}
+
+// WithDiscoverer replaces the default filesystem device discoverer
+func WithDiscoverer(d discovery.Discoverer) Option {
+	return func(s *MicroDeviceServer) {
+		s.discoverer = d
+	}
+}
+
+// WithMaxDevices limits the number of advertised devices, 0 for no limit
+func WithMaxDevices(n int) Option {
+	return func(s *MicroDeviceServer) {
+		s.maxDevices = n
+	}
+}
+
+// WithResourceCount sets the number of devices the startup quota check
+// expects to discover, 0 to skip the check
+func WithResourceCount(n int) Option {
+	return func(s *MicroDeviceServer) {
+		s.resourceCount = n
+	}
+}
+
+// WithLockTimeout sets how long to wait for the plugin instance lock
+func WithLockTimeout(timeout time.Duration) Option {
+	return func(s *MicroDeviceServer) {
+		s.lockTimeout = timeout
+	}
+}
+
+// WithRegisterJitter delays the first registration with kubelet by a
+// random duration below max, 0 registers immediately
+func WithRegisterJitter(max time.Duration) Option {
+	return func(s *MicroDeviceServer) {
+		s.registerJitter = max
+	}
+}
+
+// WithPriorityAllocator serves the allocation requests through a priority
+// queue of size requests per priority, high priority requests first. The
+// allocations of kubelet are normal priority, see PriorityMetadataKey.
+func WithPriorityAllocator(size int) Option {
+	return func(s *MicroDeviceServer) {
+		s.priorityAllocator = NewPriorityAllocator(size)
+	}
+}
+
+// WithSocketBacklog sets the listen backlog of the plugin socket, 0 uses
+// the system default
+func WithSocketBacklog(backlog int) Option {
+	return func(s *MicroDeviceServer) {
+		s.socketBacklog = backlog
+	}
+}
+
+// WithSeccompProfileManager annotates the allocations with the seccomp
+// profiles of their devices managed by m
+func WithSeccompProfileManager(m *SeccompProfileManager) Option {
+	return func(s *MicroDeviceServer) {
+		s.seccomp = m
+	}
+}
+
+// WithCPUAffinity prefers devices co-located with the CPUs when kubelet
+// asks for a preferred allocation. The device plugin API carries no
+// container CPU set, so the preferred CPUs are configured per plugin.
+func WithCPUAffinity(reader CPUAffinityReader, cpus []int) Option {
+	return func(s *MicroDeviceServer) {
+		s.affinity = reader
+		s.preferredCPUs = make(map[int]bool, len(cpus))
+		for _, cpu := range cpus {
+			s.preferredCPUs[cpu] = true
+		}
+	}
+}
+
+// WithPIDFile writes the plugin process id to path while running
+func WithPIDFile(path string) Option {
+	return func(s *MicroDeviceServer) {
+		s.pidFile = path
+	}
+}
+
+// WithReserveDevices reserves n devices from allocation while still
+// tracking them in the device map
+func WithReserveDevices(n int) Option {
+	return func(s *MicroDeviceServer) {
+		s.reserveDevices = n
+	}
+}
+
+// WithReserveForSystem reserves the first n devices sorted by name for
+// the system daemons of the node, they are tracked in the device map but
+// not advertised to kubelet
+func WithReserveForSystem(n int) Option {
+	return func(s *MicroDeviceServer) {
+		s.systemReserve = n
+	}
+}
+
+// WithLeaseHeartbeat renews the heartbeat lease while running
+func WithLeaseHeartbeat(h *LeaseHeartbeat) Option {
+	return func(s *MicroDeviceServer) {
+		s.heartbeat = h
+	}
+}
+
+// WithDevicesMin fails the liveness check when the number of healthy
+// devices drops below n
+func WithDevicesMin(n int) Option {
+	return func(s *MicroDeviceServer) {
+		s.devicesMin = n
+	}
+}
+
+// WithStrictQuota fails Run when the discovered devices are fewer than
+// the max devices or the devices min, or differ from the resource count,
+// instead of warning
+func WithStrictQuota(strict bool) Option {
+	return func(s *MicroDeviceServer) {
+		s.strictQuota = strict
+	}
+}
+
+// WithWatchdogTimeout re-registers the plugin if kubelet does not call
+// ListAndWatch within timeout after registration, 0 disables it
+func WithWatchdogTimeout(timeout time.Duration) Option {
+	return func(s *MicroDeviceServer) {
+		s.watchdogTimeout = timeout
+	}
+}
+
+// WithHealthInterval sets the device health check interval, 0 disables it
+func WithHealthInterval(interval time.Duration) Option {
+	return func(s *MicroDeviceServer) {
+		s.healthInterval = interval
+	}
+}
+
+// WithDeviceCountFile writes the healthy device count to path on every
+// device change and health check
+func WithDeviceCountFile(path string) Option {
+	return func(s *MicroDeviceServer) {
+		s.deviceCountFile = path
+	}
+}
+
+// WithDeallocateHook watches the pods of the node with client and calls
+// the deallocate hooks when a pod allocated devices is deleted, the pod
+// devices are resolved with lookup
+func WithDeallocateHook(client kubernetes.Interface, nodeName string, lookup PodDeviceLookup) Option {
+	return func(s *MicroDeviceServer) {
+		s.podClient = client
+		s.nodeName = nodeName
+		s.podLookup = lookup
+	}
+}
+
+// WithArchDetector sets the node architecture detector
+func WithArchDetector(d *ArchDetector) Option {
+	return func(s *MicroDeviceServer) {
+		s.arch = d
+	}
+}
+
+// WithAllocateIdempotencyWindow returns the cached response for identical
+// Allocate requests retried within window, 0 disables the cache
+func WithAllocateIdempotencyWindow(window time.Duration) Option {
+	return func(s *MicroDeviceServer) {
+		s.idempotencyWindow = window
+	}
+}
+
+// WithMaxConcurrentStreams limits the number of concurrent ListAndWatch
+// streams and gRPC streams per connection, 0 for no limit
+func WithMaxConcurrentStreams(n int) Option {
+	return func(s *MicroDeviceServer) {
+		s.maxStreams = n
+	}
+}
+
+// WithUpdater serves the `POST /update` endpoint replacing the plugin
+// binary with u, the endpoint only downloads from the allowed hosts of u
+func WithUpdater(u *Updater) Option {
+	return func(s *MicroDeviceServer) {
+		s.updater = u
+	}
+}
+
+// WithMetricsFile writes the metrics in Prometheus text format to path
+// on every device change and health check
+func WithMetricsFile(path string) Option {
+	return func(s *MicroDeviceServer) {
+		s.metricsFile = path
+	}
+}
+
+// WithInitTimeout aborts Run with ErrInitTimeout if the initial device
+// discovery takes longer than timeout, 0 waits forever
+func WithInitTimeout(timeout time.Duration) Option {
+	return func(s *MicroDeviceServer) {
+		s.initTimeout = timeout
+	}
+}
+
+// WithRESTAPI serves the `GET /api/v1/devices` device inventory endpoint
+func WithRESTAPI(enable bool) Option {
+	return func(s *MicroDeviceServer) {
+		s.restAPI = enable
+	}
+}
+
+// WithNodeConditions reports the plugin readiness as a node condition
+// after registration and on every health check
+func WithNodeConditions(r *NodeConditionReporter) Option {
+	return func(s *MicroDeviceServer) {
+		s.conditions = r
+	}
+}
+
+// WithValidateOnReconnect rescans the devices before the initial device
+// list is sent on every ListAndWatch connection
+func WithValidateOnReconnect(enable bool) Option {
+	return func(s *MicroDeviceServer) {
+		s.validateOnReconnect = enable
+	}
+}
+
+// WithNamespace isolates plugin instances sharing a node, the namespace
+// prefixes the socket, lock, manifest and pid file names and labels
+// the plugin metrics
+func WithNamespace(namespace string) Option {
+	return func(s *MicroDeviceServer) {
+		s.namespace = namespace
+	}
+}
+
+// WithScorer ranks the candidate devices of GetPreferredAllocation with
+// scorer, it takes precedence over the CPU affinity scoring
+func WithScorer(scorer DeviceScorer) Option {
+	return func(s *MicroDeviceServer) {
+		s.scorer = scorer
+	}
+}
+
+// WithAllowUnsafeIDs only warns about invalid device IDs instead of
+// skipping the devices
+func WithAllowUnsafeIDs(allow bool) Option {
+	return func(s *MicroDeviceServer) {
+		s.allowUnsafeIDs = allow
+	}
+}
+
+// WithLogDeviceIDs redacts the device IDs in log messages when disabled
+func WithLogDeviceIDs(enable bool) Option {
+	return func(s *MicroDeviceServer) {
+		s.logDeviceIDs = enable
+	}
+}
+
+// WithDRA fulfills the DRA ResourceClaims requesting the plugin device
+// class with the devices of the node
+func WithDRA(client kubernetes.Interface, nodeName string) Option {
+	return func(s *MicroDeviceServer) {
+		s.dra = NewDRAAdapter(s, client, nodeName)
+	}
+}
+
+// WithAllocationStrategy selects the preferred devices of
+// GetPreferredAllocation and the devices of DRA claims with strategy, it
+// takes precedence over the device scorer
+func WithAllocationStrategy(strategy AllocationStrategy) Option {
+	return func(s *MicroDeviceServer) {
+		s.strategy = strategy
+	}
+}
+
+// WithRecoverPanics restarts the plugin goroutines after a recovered
+// panic, starting with a one second back-off
+func WithRecoverPanics(enable bool) Option {
+	return func(s *MicroDeviceServer) {
+		s.recoverPanics = enable
+	}
+}
+
+// WithKubeletVersionDetector detects the kubelet version with d to
+// enable the device plugin API features the kubelet supports
+func WithKubeletVersionDetector(d *KubeletVersionDetector) Option {
+	return func(s *MicroDeviceServer) {
+		s.versionDetector = d
+	}
+}
+
+// WithHealthPolicy determines the device health in the health check and
+// PreStartContainer with p, the device file existence by default
+func WithHealthPolicy(p DeviceHealthPolicy) Option {
+	return func(s *MicroDeviceServer) {
+		s.healthPolicy = p
+	}
+}
+
+// WithStateWAL records the device allocations and health changes in w
+// and restores the allocations on start, the WAL is compacted every
+// compactInterval and on stop
+func WithStateWAL(w *state.WAL, compactInterval time.Duration) Option {
+	return func(s *MicroDeviceServer) {
+		s.wal = w
+		s.compactInterval = compactInterval
+	}
+}
+
+// WithStateStore saves the known devices and allocations of the state WAL
+// to store on every compaction, the allocations of store are restored on
+// start if the WAL recorded nothing
+func WithStateStore(store state.StateStore) Option {
+	return func(s *MicroDeviceServer) {
+		s.stateStore = store
+	}
+}
+
+// WithEventStore appends the published device and allocation events to
+// store, the event history is restored from the store on start
+func WithEventStore(store *state.EventStore) Option {
+	return func(s *MicroDeviceServer) {
+		s.eventStore = store
+	}
+}
+
+// WithVersionCheck checks kubelet supports one of the plugin API versions
+// before registering, an unsupported kubelet fails the registration if
+// strict and is logged otherwise
+func WithVersionCheck(strict bool) Option {
+	return func(s *MicroDeviceServer) {
+		s.versionCheck = true
+		s.strictVersionCheck = strict
+	}
+}
+
+// WithResourceAliases advertises the devices under the alias resource
+// names too, the PluginManager starts one server per alias. A device
+// allocated through one name is refused under the others until it is
+// released by WithDeallocateHook or WithStateGC.
+func WithResourceAliases(aliases ...string) Option {
+	return func(s *MicroDeviceServer) {
+		s.resourceAliases = aliases
+	}
+}
+
+// WithStateGC removes the allocations of pods missing from the cluster
+// from the state WAL every interval, the pods are listed with client
+func WithStateGC(client kubernetes.Interface, interval time.Duration) Option {
+	return func(s *MicroDeviceServer) {
+		s.gcClient = client
+		s.gcInterval = interval
+	}
+}
+
+// WithMaxIdleTime closes Idle if no device is discovered within d, the
+// idle time restarts when the last device is removed, 0 disables it
+func WithMaxIdleTime(d time.Duration) Option {
+	return func(s *MicroDeviceServer) {
+		s.maxIdleTime = d
+	}
+}
+
+// GRPCServerOptions builds gRPC server options, zero values keep the
+// gRPC library defaults
+func GRPCServerOptions(maxRecvMsgSize, maxSendMsgSize int, keepaliveTime, keepaliveTimeout time.Duration) []grpc.ServerOption {
+	var opts []grpc.ServerOption
+	if maxRecvMsgSize > 0 {
+		opts = append(opts, grpc.MaxRecvMsgSize(maxRecvMsgSize))
+	}
+	if maxSendMsgSize > 0 {
+		opts = append(opts, grpc.MaxSendMsgSize(maxSendMsgSize))
+	}
+	if keepaliveTime > 0 || keepaliveTimeout > 0 {
+		opts = append(opts, grpc.KeepaliveParams(keepalive.ServerParameters{
+			Time:    keepaliveTime,
+			Timeout: keepaliveTimeout,
+		}))
+	}
+	return opts
+}
@@ -0,0 +1,119 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/kelein/micro-device-plugin/pkg/config"
+	"github.com/kelein/micro-device-plugin/pkg/testing/assert"
+	"github.com/kelein/micro-device-plugin/pkg/testutil"
+)
+
+func TestWithPaths(t *testing.T) {
+	devicePath, pluginPath := t.TempDir(), t.TempDir()
+	s, _ := newTestServer(t, WithDevicePath(devicePath), WithPluginPath(pluginPath),
+		WithResourceName("example.com/micro"))
+
+	if s.devicePath != devicePath {
+		t.Errorf("device path = %s, want %s", s.devicePath, devicePath)
+	}
+	if want := filepath.Join(pluginPath, microSocket); s.socketPath() != want {
+		t.Errorf("socket path = %s, want %s", s.socketPath(), want)
+	}
+	if want := filepath.Join(pluginPath, "micro.lock"); s.lock.path != want {
+		t.Errorf("lock file = %s, want %s", s.lock.path, want)
+	}
+	if s.resourceName != "example.com/micro" {
+		t.Errorf("resource name = %s, want example.com/micro", s.resourceName)
+	}
+}
+
+func TestWithLogger(t *testing.T) {
+	var logs bytes.Buffer
+	s, _ := newTestServer(t, WithLogger(slog.New(slog.NewTextHandler(&logs, nil))))
+	id := s.addDevice(&MicroDevice{Name: "micro0"})
+
+	if _, err := s.Allocate(context.Background(), testutil.NewMockAllocateRequest().WithDeviceIDs(id).Build()); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(logs.String(), "received request") {
+		t.Errorf("logger output has no allocation request:\n%s", logs.String())
+	}
+}
+
+func TestWithMetrics(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	s, _ := newTestServer(t, WithMetrics(reg))
+	id := s.addDevice(&MicroDevice{Name: "micro0"})
+	s.markAllocated([]string{id})
+
+	assert.AssertMetricValue(t, reg, "micro_device_plugin_active_allocations", nil, 1)
+
+	// a second server on the registry updates the registered metrics
+	other, err := NewMicroDeviceServer(WithMetrics(reg), WithPluginPath(t.TempDir()), WithWatchdogTimeout(0))
+	if err != nil {
+		t.Fatalf("NewMicroDeviceServer() on a shared registry = %v", err)
+	}
+	defer other.Stop()
+	if other.metrics.activeAllocations != s.metrics.activeAllocations {
+		t.Error("second server does not use the registered collector")
+	}
+	other.markAllocated([]string{deviceID("micro1"), deviceID("micro2")})
+	assert.AssertMetricValue(t, reg, "micro_device_plugin_active_allocations", nil, 2)
+}
+
+func TestWithMetricsConflict(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "micro_device_plugin_active_allocations",
+		Help: "Conflicting metric of another component",
+	}))
+
+	s, err := NewMicroDeviceServer(WithMetrics(reg), WithPluginPath(t.TempDir()), WithWatchdogTimeout(0))
+	if err == nil {
+		s.Stop()
+		t.Fatal("NewMicroDeviceServer() with a conflicting metric succeeded, want error")
+	}
+	if !strings.Contains(err.Error(), "register metrics") {
+		t.Errorf("NewMicroDeviceServer() = %v, want a register metrics error", err)
+	}
+}
+
+func TestWithConfig(t *testing.T) {
+	cfg := config.Default()
+	cfg.DevicePath = t.TempDir()
+	cfg.PluginPath = t.TempDir()
+	cfg.ResourceName = "example.com/micro"
+	cfg.MaxDevices = 4
+	cfg.FeatureGates = config.FeatureGates{config.CDIDeviceSpecs: true}
+	cfg.ResourceAliases = []string{"example.com/legacy"}
+	cfg.SupportedVersions = []string{"v1beta1", "v1"}
+
+	s, _ := newTestServer(t, WithConfig(cfg))
+	if s.devicePath != cfg.DevicePath || s.pluginPath != cfg.PluginPath || s.resourceName != cfg.ResourceName {
+		t.Errorf("paths = %s, %s, %s, want the configured %s, %s, %s",
+			s.devicePath, s.pluginPath, s.resourceName, cfg.DevicePath, cfg.PluginPath, cfg.ResourceName)
+	}
+	if s.maxDevices != 4 {
+		t.Errorf("max devices = %d, want 4", s.maxDevices)
+	}
+	if !s.featureGates.IsEnabled(config.CDIDeviceSpecs) {
+		t.Errorf("feature gate %s is disabled, want enabled", config.CDIDeviceSpecs)
+	}
+	if !slices.Equal(s.resourceAliases, cfg.ResourceAliases) {
+		t.Errorf("resource aliases = %v, want %v", s.resourceAliases, cfg.ResourceAliases)
+	}
+	if !slices.Equal(s.supportedVersions, cfg.SupportedVersions) {
+		t.Errorf("supported versions = %v, want %v", s.supportedVersions, cfg.SupportedVersions)
+	}
+	if s.cfg != cfg {
+		t.Error("server does not keep the applied configuration")
+	}
+}
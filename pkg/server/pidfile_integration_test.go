@@ -0,0 +1,64 @@
+//go:build integration
+
+package server
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+)
+
+func TestRunPIDFile(t *testing.T) {
+	pidFile := filepath.Join(t.TempDir(), "micro.pid")
+	s, _ := newTestServer(t, WithHealthInterval(0), WithPIDFile(pidFile))
+	if err := s.Run(); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(pidFile)
+	if err != nil {
+		t.Fatalf("read pid file: %v", err)
+	}
+	if got, want := string(data), strconv.Itoa(os.Getpid()); got != want {
+		t.Errorf("pid file = %q, want %q", got, want)
+	}
+
+	// action: a clean shutdown removes the pid file
+	s.Stop()
+	if _, err := os.Stat(pidFile); !os.IsNotExist(err) {
+		t.Errorf("pid file still exists after Stop: %v", err)
+	}
+}
+
+func TestRunPIDFileRunning(t *testing.T) {
+	tests := []struct {
+		name    string
+		pid     int // precondition: pid written to the existing pid file
+		wantErr error
+	}{
+		{name: "running process", pid: os.Getppid(), wantErr: ErrAlreadyRunning},
+		{name: "stale pid", pid: 1<<31 - 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pidFile := filepath.Join(t.TempDir(), "micro.pid")
+			if err := os.WriteFile(pidFile, []byte(strconv.Itoa(tt.pid)), 0644); err != nil {
+				t.Fatal(err)
+			}
+			s, _ := newTestServer(t, WithHealthInterval(0), WithPIDFile(pidFile))
+
+			if err := s.Run(); !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Run() error = %v, want %v", err, tt.wantErr)
+			}
+			want := strconv.Itoa(os.Getpid())
+			if tt.wantErr != nil {
+				want = strconv.Itoa(tt.pid)
+			}
+			if data, _ := os.ReadFile(pidFile); string(data) != want {
+				t.Errorf("pid file = %q, want %q", data, want)
+			}
+		})
+	}
+}
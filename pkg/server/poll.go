@@ -0,0 +1,69 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"os"
+	"time"
+)
+
+// PollSocket checks the socket at path every interval until ctx is done
+// and signals on the returned channel when the socket is created or
+// recreated, detected by a changed modification time. It replaces the
+// fsnotify watch in environments without inotify support.
+func PollSocket(ctx context.Context, path string, interval time.Duration) <-chan struct{} {
+	created := make(chan struct{})
+	go func() {
+		var last time.Time
+		if info, err := os.Stat(path); err == nil {
+			last = info.ModTime()
+		}
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+			case <-ctx.Done():
+				return
+			}
+
+			info, err := os.Stat(path)
+			if err != nil {
+				last = time.Time{}
+				continue
+			}
+			if info.ModTime().Equal(last) {
+				continue
+			}
+			last = info.ModTime()
+			select {
+			case created <- struct{}{}:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return created
+}
+
+// pollSocket recovers the plugin socket when a periodic check finds it
+// removed by an external actor
+func (s *MicroDeviceServer) pollSocket() {
+	ticker := time.NewTicker(s.pollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if _, err := os.Stat(s.socketPath()); !errors.Is(err, os.ErrNotExist) {
+				continue
+			}
+			if s.ctx.Err() != nil {
+				return
+			}
+			s.recoverSocket()
+		case <-s.ctx.Done():
+			return
+		}
+	}
+}
@@ -0,0 +1,51 @@
+package server
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestPollSocket(t *testing.T) {
+	sock := filepath.Join(t.TempDir(), KubeSocket)
+	if err := os.WriteFile(sock, nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	created := PollSocket(ctx, sock, 10*time.Millisecond)
+
+	select {
+	case <-created:
+		t.Fatal("existing socket reported as created")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	// kubelet restarts remove and recreate its socket
+	if err := os.Remove(sock); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(30 * time.Millisecond)
+	if err := os.WriteFile(sock, nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-created:
+	case <-time.After(time.Second):
+		t.Fatal("recreated socket not detected")
+	}
+
+	// a new modification time without removal is a recreation as well
+	later := time.Now().Add(time.Minute)
+	if err := os.Chtimes(sock, later, later); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-created:
+	case <-time.After(time.Second):
+		t.Fatal("replaced socket not detected")
+	}
+}
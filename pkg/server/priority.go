@@ -0,0 +1,200 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"google.golang.org/grpc/metadata"
+)
+
+// Priority is the priority of an allocation request
+type Priority int
+
+// Priorities of the allocation requests, served from high to low
+const (
+	PriorityLow Priority = iota
+	PriorityNormal
+	PriorityHigh
+)
+
+// PriorityAnnotation is the annotation naming the priority of a request,
+// the device plugin API carries no request annotations so the priority is
+// read from the gRPC metadata key PriorityMetadataKey of the Allocate call
+const PriorityAnnotation = "micro.plugin/priority"
+
+// PriorityMetadataKey is the gRPC metadata key carrying the priority
+// annotation, gRPC metadata keys cannot contain a slash.
+//
+// Kubelet sets no metadata on Allocate and the request does not name the
+// pod, so the priority of a pod annotation cannot be resolved: kubelet
+// allocations are always normal priority. Only other callers of the plugin
+// socket, such as a proxy in front of the plugin, can set the priority.
+const PriorityMetadataKey = "micro-plugin-priority"
+
+// errAllocatorStopped is returned for the requests queued when the
+// priority allocator stops
+var errAllocatorStopped = errors.New("priority allocator stopped")
+
+func (p Priority) String() string {
+	switch p {
+	case PriorityLow:
+		return "low"
+	case PriorityNormal:
+		return "normal"
+	case PriorityHigh:
+		return "high"
+	default:
+		return fmt.Sprintf("Priority(%d)", int(p))
+	}
+}
+
+// ParsePriority parses a priority annotation value, empty is normal
+func ParsePriority(value string) (Priority, error) {
+	switch value {
+	case "high":
+		return PriorityHigh, nil
+	case "normal", "":
+		return PriorityNormal, nil
+	case "low":
+		return PriorityLow, nil
+	default:
+		return PriorityNormal, fmt.Errorf("invalid priority %q, must be high, normal or low", value)
+	}
+}
+
+// AllocationPriority returns the priority annotated on the RPC call of
+// ctx, calls without or with an invalid annotation are normal
+func AllocationPriority(ctx context.Context) Priority {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return PriorityNormal
+	}
+	values := md.Get(PriorityMetadataKey)
+	if len(values) == 0 {
+		return PriorityNormal
+	}
+	p, err := ParsePriority(values[0])
+	if err != nil {
+		return PriorityNormal
+	}
+	return p
+}
+
+// allocationJob is an allocation request waiting in the priority queue,
+// claimed either by the scheduler to run it or by its caller to cancel it
+type allocationJob struct {
+	run     func()
+	claimed atomic.Bool
+	done    chan struct{}
+}
+
+// execute runs the job, its caller is released even if the job panics
+func (j *allocationJob) execute() {
+	defer close(j.done)
+	j.run()
+}
+
+// PriorityAllocator serializes the allocation requests and serves the
+// queued high priority requests before the normal and the low ones
+type PriorityAllocator struct {
+	queues [PriorityHigh + 1]chan *allocationJob
+	wake   chan struct{}
+	done   chan struct{}
+	stop   sync.Once
+
+	// depth is the number of queued requests by priority
+	depth *prometheus.GaugeVec
+}
+
+// NewPriorityAllocator creates a priority allocator queueing up to size
+// requests per priority, the allocator serves requests once Run starts
+func NewPriorityAllocator(size int) *PriorityAllocator {
+	a := &PriorityAllocator{
+		wake: make(chan struct{}, 1),
+		done: make(chan struct{}),
+		depth: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: metricsNamespace,
+			Name:      "allocation_queue_depth",
+			Help:      "Number of allocation requests waiting in the priority queue by priority",
+		}, []string{"priority"}),
+	}
+	for p := range a.queues {
+		a.queues[p] = make(chan *allocationJob, max(size, 1))
+		a.depth.WithLabelValues(Priority(p).String()).Set(0)
+	}
+	return a
+}
+
+// Do queues f with priority p and waits until the scheduler ran it, f is
+// not run if ctx is done before it is scheduled
+func (a *PriorityAllocator) Do(ctx context.Context, p Priority, f func()) error {
+	if p < PriorityLow || p > PriorityHigh {
+		p = PriorityNormal
+	}
+	job := &allocationJob{run: f, done: make(chan struct{})}
+	select {
+	case a.queues[p] <- job:
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-a.done:
+		return errAllocatorStopped
+	}
+	a.depth.WithLabelValues(p.String()).Inc()
+	select {
+	case a.wake <- struct{}{}:
+	default:
+	}
+
+	select {
+	case <-job.done:
+		return nil
+	case <-ctx.Done():
+		if job.claimed.CompareAndSwap(false, true) {
+			return ctx.Err()
+		}
+	case <-a.done:
+		if job.claimed.CompareAndSwap(false, true) {
+			return errAllocatorStopped
+		}
+	}
+	// the scheduler is running the job
+	<-job.done
+	return nil
+}
+
+// Run schedules the queued requests until ctx is done, Run may be
+// restarted after a panic of a request
+func (a *PriorityAllocator) Run(ctx context.Context) {
+	for {
+		job := a.next()
+		if job == nil {
+			select {
+			case <-a.wake:
+				continue
+			case <-ctx.Done():
+				a.stop.Do(func() { close(a.done) })
+				return
+			}
+		}
+		if job.claimed.CompareAndSwap(false, true) {
+			job.execute()
+		}
+	}
+}
+
+// next dequeues the request of the highest priority, nil if none waits
+func (a *PriorityAllocator) next() *allocationJob {
+	for p := PriorityHigh; p >= PriorityLow; p-- {
+		select {
+		case job := <-a.queues[p]:
+			a.depth.WithLabelValues(p.String()).Dec()
+			return job
+		default:
+		}
+	}
+	return nil
+}
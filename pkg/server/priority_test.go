@@ -0,0 +1,175 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	promtestutil "github.com/prometheus/client_golang/prometheus/testutil"
+	"google.golang.org/grpc/metadata"
+
+	"github.com/kelein/micro-device-plugin/pkg/testutil"
+)
+
+func TestAllocationPriority(t *testing.T) {
+	tests := []struct {
+		name string
+		md   metadata.MD
+		want Priority
+	}{
+		{name: "no metadata", want: PriorityNormal},
+		{name: "high", md: metadata.Pairs(PriorityMetadataKey, "high"), want: PriorityHigh},
+		{name: "low", md: metadata.Pairs(PriorityMetadataKey, "low"), want: PriorityLow},
+		{name: "invalid", md: metadata.Pairs(PriorityMetadataKey, "urgent"), want: PriorityNormal},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			if tt.md != nil {
+				ctx = metadata.NewIncomingContext(ctx, tt.md)
+			}
+			if got := AllocationPriority(ctx); got != tt.want {
+				t.Errorf("AllocationPriority() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// waitQueueDepth waits until n requests of priority p are queued in a
+func waitQueueDepth(t *testing.T, a *PriorityAllocator, p Priority, n float64) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for promtestutil.ToFloat64(a.depth.WithLabelValues(p.String())) != n {
+		if time.Now().After(deadline) {
+			t.Fatalf("%s queue depth did not reach %v", p, n)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestPriorityAllocatorOrder(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	a := NewPriorityAllocator(4)
+	go a.Run(ctx)
+
+	// hold the scheduler until the requests are queued
+	running, release := make(chan struct{}), make(chan struct{})
+	go a.Do(ctx, PriorityNormal, func() {
+		close(running)
+		<-release
+	})
+	<-running
+
+	var mu sync.Mutex
+	var order []Priority
+	var wg sync.WaitGroup
+	for _, p := range []Priority{PriorityLow, PriorityNormal, PriorityHigh, PriorityLow} {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := a.Do(ctx, p, func() {
+				mu.Lock()
+				order = append(order, p)
+				mu.Unlock()
+			})
+			if err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	waitQueueDepth(t, a, PriorityLow, 2)
+	waitQueueDepth(t, a, PriorityNormal, 1)
+	waitQueueDepth(t, a, PriorityHigh, 1)
+	close(release)
+	wg.Wait()
+
+	want := []Priority{PriorityHigh, PriorityNormal, PriorityLow, PriorityLow}
+	if !slices.Equal(order, want) {
+		t.Errorf("order = %v, want %v", order, want)
+	}
+	waitQueueDepth(t, a, PriorityLow, 0)
+}
+
+func TestPriorityAllocatorCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	a := NewPriorityAllocator(1)
+	go a.Run(ctx)
+
+	running, release := make(chan struct{}), make(chan struct{})
+	go a.Do(ctx, PriorityNormal, func() {
+		close(running)
+		<-release
+	})
+	<-running
+
+	// a request canceled while queued is never run
+	reqCtx, reqCancel := context.WithCancel(ctx)
+	errc := make(chan error, 1)
+	ran := false
+	go func() { errc <- a.Do(reqCtx, PriorityHigh, func() { ran = true }) }()
+	waitQueueDepth(t, a, PriorityHigh, 1)
+	reqCancel()
+	if err := <-errc; !errors.Is(err, context.Canceled) {
+		t.Errorf("Do() error = %v, want %v", err, context.Canceled)
+	}
+	close(release)
+	waitQueueDepth(t, a, PriorityHigh, 0)
+	if err := a.Do(ctx, PriorityLow, func() {}); err != nil {
+		t.Fatal(err)
+	}
+	if ran {
+		t.Error("canceled request was run")
+	}
+}
+
+func TestKubeletAllocateDefaultPriority(t *testing.T) {
+	s, _ := newTestServer(t, WithPriorityAllocator(4))
+	id := s.addDevice(&MicroDevice{Name: "micro0"})
+	a := s.priorityAllocator
+
+	// precondition: the scheduler is held until the allocation is queued
+	running, release := make(chan struct{}), make(chan struct{})
+	go a.Do(context.Background(), PriorityHigh, func() {
+		close(running)
+		<-release
+	})
+	<-running
+
+	// action: Allocate through the plugin socket like kubelet, without
+	// gRPC metadata
+	client := newPluginClient(t, s)
+	done := make(chan error, 1)
+	go func() {
+		_, err := client.Allocate(context.Background(), testutil.NewMockAllocateRequest().WithDeviceIDs(id).Build())
+		done <- err
+	}()
+
+	// expected: the allocation waits in the normal priority queue
+	waitQueueDepth(t, a, PriorityNormal, 1)
+	waitQueueDepth(t, a, PriorityHigh, 0)
+	close(release)
+	if err := <-done; err != nil {
+		t.Fatalf("Allocate() = %v", err)
+	}
+	waitQueueDepth(t, a, PriorityNormal, 0)
+}
+
+func TestAllocateWithPriorityAllocator(t *testing.T) {
+	s, _ := newTestServer(t, WithPriorityAllocator(4))
+	s.addDevice(&MicroDevice{Name: "micro0"})
+
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs(PriorityMetadataKey, "high"))
+	req := testutil.NewMockAllocateRequest().WithDeviceIDs(deviceID("micro0")).Build()
+	resp, err := s.Allocate(ctx, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.ContainerResponses) != 1 {
+		t.Errorf("got %d container responses, want 1", len(resp.ContainerResponses))
+	}
+}
@@ -0,0 +1,42 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrQuota is returned by Run with a strict quota when the discovered
+// devices do not satisfy the configured device counts
+var ErrQuota = errors.New("device quota not satisfied")
+
+// checkQuota compares the initially discovered devices with the max
+// devices, devices min and resource count settings, a mismatch is logged
+// as a warning or fails with ErrQuota if the quota is strict
+func (s *MicroDeviceServer) checkQuota() error {
+	s.mu.RLock()
+	count := len(s.devices)
+	s.mu.RUnlock()
+
+	var errs []error
+	if s.maxDevices > 0 && s.maxDevices > count {
+		errs = append(errs, fmt.Errorf("%w: max devices %d exceeds the %d discovered devices", ErrQuota, s.maxDevices, count))
+	}
+	if s.devicesMin > 0 && count < s.devicesMin {
+		errs = append(errs, fmt.Errorf("%w: %d discovered devices below the minimum %d", ErrQuota, count, s.devicesMin))
+	}
+	if s.resourceCount > 0 && count != s.resourceCount {
+		errs = append(errs, fmt.Errorf("%w: %d discovered devices differ from the resource count %d", ErrQuota, count, s.resourceCount))
+	}
+	if len(errs) == 0 {
+		return nil
+	}
+
+	err := errors.Join(errs...)
+	if s.strictQuota {
+		return err
+	}
+	for _, e := range errs {
+		s.logger.Warn("device quota mismatch", "err", e)
+	}
+	return nil
+}
@@ -0,0 +1,73 @@
+//go:build integration
+
+package server
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// deviceDir creates a device directory with n device files
+func deviceDir(t *testing.T, n int) string {
+	t.Helper()
+	dir := t.TempDir()
+	for i := range n {
+		if err := os.WriteFile(filepath.Join(dir, fmt.Sprintf("micro%d", i)), nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestQuota(t *testing.T) {
+	tests := []struct {
+		name     string
+		opts     []Option
+		wantErr  bool
+		wantWarn string
+	}{
+		{name: "satisfied", opts: []Option{WithMaxDevices(2), WithDevicesMin(2), WithStrictQuota(true)}},
+		{name: "max over discovered", opts: []Option{WithMaxDevices(10)}, wantWarn: "max devices 10 exceeds the 2 discovered devices"},
+		{name: "min over discovered", opts: []Option{WithDevicesMin(3)}, wantWarn: "2 discovered devices below the minimum 3"},
+		{name: "strict max over discovered", opts: []Option{WithMaxDevices(10), WithStrictQuota(true)}, wantErr: true},
+		{name: "strict min over discovered", opts: []Option{WithDevicesMin(3), WithStrictQuota(true)}, wantErr: true},
+		{name: "resource count satisfied", opts: []Option{WithResourceCount(2), WithStrictQuota(true)}},
+		{name: "resource count over discovered", opts: []Option{WithResourceCount(3)}, wantWarn: "2 discovered devices differ from the resource count 3"},
+		{name: "strict resource count under discovered", opts: []Option{WithResourceCount(1), WithStrictQuota(true)}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var logs bytes.Buffer
+			opts := append([]Option{
+				WithDevicePath(deviceDir(t, 2)),
+				WithHealthInterval(0),
+				WithLogger(slog.New(slog.NewTextHandler(&logs, nil))),
+			}, tt.opts...)
+			s, _ := newTestServer(t, opts...)
+
+			err := s.Run()
+			if tt.wantErr {
+				if !errors.Is(err, ErrQuota) {
+					t.Fatalf("Run() = %v, want %v", err, ErrQuota)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Run() = %v", err)
+			}
+			warned := strings.Contains(logs.String(), "device quota mismatch")
+			if tt.wantWarn == "" && warned {
+				t.Errorf("unexpected quota warning:\n%s", logs.String())
+			}
+			if tt.wantWarn != "" && !strings.Contains(logs.String(), tt.wantWarn) {
+				t.Errorf("log output has no quota warning %q:\n%s", tt.wantWarn, logs.String())
+			}
+		})
+	}
+}
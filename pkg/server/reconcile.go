@@ -0,0 +1,44 @@
+package server
+
+// reconcileDevices rescans the devices, dropping the devices whose file
+// disappeared and adding the devices created since the last scan
+func (s *MicroDeviceServer) reconcileDevices() {
+	devices, err := s.discoverer.Discover()
+	if err != nil {
+		s.logger.Error("reconcile devices failed", "err", err)
+		return
+	}
+
+	found := make(map[string]*MicroDevice, len(devices))
+	for _, dev := range devices {
+		if s.matchDevice(dev.Name) {
+			found[dev.Name] = dev
+		}
+	}
+
+	s.mu.RLock()
+	var stale []string
+	for name := range s.devices {
+		if _, ok := found[name]; !ok {
+			stale = append(stale, name)
+		}
+	}
+	for name := range found {
+		if _, ok := s.devices[name]; ok {
+			delete(found, name)
+		}
+	}
+	s.mu.RUnlock()
+
+	if len(stale) == 0 && len(found) == 0 {
+		return
+	}
+	for _, name := range stale {
+		s.deleteDevice(name)
+	}
+	for _, dev := range found {
+		s.addDevice(dev)
+	}
+	s.metrics.reconnectReconciliations.Inc()
+	s.logger.Info("devices reconciled on reconnect", "removed", len(stale), "added", len(found))
+}
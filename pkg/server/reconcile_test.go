@@ -0,0 +1,82 @@
+package server
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	deviceapi "k8s.io/kubelet/pkg/apis/deviceplugin/v1beta1"
+
+	"github.com/kelein/micro-device-plugin/pkg/testing/assert"
+	"github.com/kelein/micro-device-plugin/pkg/testutil"
+)
+
+// listAndWatchOnce opens a ListAndWatch stream, returns the initial
+// device IDs and closes the stream
+func listAndWatchOnce(t *testing.T, s *MicroDeviceServer) []string {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	srv := testutil.NewMockListAndWatchServer(ctx)
+	done := make(chan error, 1)
+	go func() {
+		done <- s.ListAndWatch(&deviceapi.Empty{}, srv)
+	}()
+	if !srv.WaitForSends(1, time.Second) {
+		t.Fatal("ListAndWatch did not send the initial device list")
+	}
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("ListAndWatch did not exit on stream close")
+	}
+
+	var ids []string
+	for _, dev := range srv.Responses()[0].Devices {
+		ids = append(ids, dev.ID)
+	}
+	slices.Sort(ids)
+	return ids
+}
+
+func TestValidateOnReconnect(t *testing.T) {
+	tests := []struct {
+		name     string
+		validate bool
+		want     []string // expected: device names of the second stream
+		wantRuns float64
+	}{
+		{name: "validate on reconnect", validate: true, want: []string{"micro0", "micro2"}, wantRuns: 1},
+		{name: "no validation", want: []string{"micro0", "micro1"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			createDevices(t, dir, "micro0", "micro1")
+			reg := prometheus.NewRegistry()
+			s, _ := newTestServer(t, WithDevicePath(dir), WithMetrics(reg), WithValidateOnReconnect(tt.validate))
+			if err := s.findDevice(); err != nil {
+				t.Fatal(err)
+			}
+			if got, want := listAndWatchOnce(t, s), sortedIDs("micro0", "micro1"); !slices.Equal(got, want) {
+				t.Fatalf("first ListAndWatch devices = %v, want %v", got, want)
+			}
+
+			// action: the device files change while kubelet is away and
+			// no file event is handled
+			if err := os.Remove(filepath.Join(dir, "micro1")); err != nil {
+				t.Fatal(err)
+			}
+			createDevices(t, dir, "micro2")
+
+			if got, want := listAndWatchOnce(t, s), sortedIDs(tt.want...); !slices.Equal(got, want) {
+				t.Errorf("second ListAndWatch devices = %v, want %v", got, want)
+			}
+			assert.AssertMetricValue(t, reg, "micro_device_plugin_reconnect_reconciliations_total", nil, tt.wantRuns)
+		})
+	}
+}
@@ -0,0 +1,58 @@
+package server
+
+import (
+	"context"
+	"slices"
+	"testing"
+
+	"google.golang.org/grpc/codes"
+	reflectionpb "google.golang.org/grpc/reflection/grpc_reflection_v1alpha"
+	"google.golang.org/grpc/status"
+)
+
+// listServices lists the gRPC services of the server with reflection
+func listServices(t *testing.T, s *MicroDeviceServer) ([]string, error) {
+	t.Helper()
+	client := reflectionpb.NewServerReflectionClient(bufconnConn(t, s.serv))
+	stream, err := client.ServerReflectionInfo(context.Background())
+	if err != nil {
+		return nil, err
+	}
+	defer stream.CloseSend()
+
+	err = stream.Send(&reflectionpb.ServerReflectionRequest{
+		MessageRequest: &reflectionpb.ServerReflectionRequest_ListServices{},
+	})
+	if err != nil {
+		return nil, err
+	}
+	resp, err := stream.Recv()
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, svc := range resp.GetListServicesResponse().GetService() {
+		names = append(names, svc.GetName())
+	}
+	return names, nil
+}
+
+func TestReflection(t *testing.T) {
+	s, _ := newTestServer(t, WithReflection(true))
+
+	services, err := listServices(t, s)
+	if err != nil {
+		t.Fatalf("list services: %v", err)
+	}
+	if !slices.Contains(services, "v1beta1.DevicePlugin") {
+		t.Errorf("services = %v, want v1beta1.DevicePlugin listed", services)
+	}
+}
+
+func TestReflectionDisabled(t *testing.T) {
+	s, _ := newTestServer(t, WithReflection(false))
+
+	if _, err := listServices(t, s); status.Code(err) != codes.Unimplemented {
+		t.Errorf("list services error = %v, want %v", err, codes.Unimplemented)
+	}
+}
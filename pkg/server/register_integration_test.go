@@ -0,0 +1,141 @@
+//go:build integration
+
+// Integration tests registering the plugin with a FakeKubelet over a
+// real unix socket, run them with `go test -tags integration`.
+
+package server
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	deviceapi "k8s.io/kubelet/pkg/apis/deviceplugin/v1beta1"
+
+	"github.com/kelein/micro-device-plugin/pkg/testutil"
+)
+
+func startFakeKubelet(t *testing.T, dir string) *testutil.FakeKubelet {
+	t.Helper()
+	kubelet, err := testutil.NewFakeKubelet(dir)
+	if err != nil {
+		t.Fatalf("start fake kubelet: %v", err)
+	}
+	t.Cleanup(kubelet.Stop)
+	return kubelet
+}
+
+func TestRegisterToKubelet(t *testing.T) {
+	s, dir := newTestServer(t, WithResourceName("example.com/micro"))
+	kubelet := startFakeKubelet(t, dir)
+
+	if err := s.RegisterToKubelet(); err != nil {
+		t.Fatalf("RegisterToKubelet() = %v", err)
+	}
+
+	reqs := kubelet.Requests()
+	if len(reqs) != 1 {
+		t.Fatalf("kubelet received %d register requests, want 1", len(reqs))
+	}
+	want := &deviceapi.RegisterRequest{
+		Version:      deviceapi.Version,
+		Endpoint:     microSocket,
+		ResourceName: "example.com/micro",
+	}
+	if reqs[0].String() != want.String() {
+		t.Errorf("register request = %v, want %v", reqs[0], want)
+	}
+	if !s.Status().RegisteredWithKubelet {
+		t.Error("status is not registered with kubelet after registration")
+	}
+}
+
+func TestRegisterCustomSocketName(t *testing.T) {
+	s, dir := newTestServer(t, WithSocketName("gpu.sock"))
+	kubelet := startFakeKubelet(t, dir)
+
+	if err := s.Run(); err != nil {
+		t.Fatalf("Run() = %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "gpu.sock")); err != nil {
+		t.Errorf("plugin socket not created: %v", err)
+	}
+	if err := s.RegisterToKubelet(); err != nil {
+		t.Fatalf("RegisterToKubelet() = %v", err)
+	}
+
+	reqs := kubelet.Requests()
+	if len(reqs) != 1 || reqs[0].Endpoint != "gpu.sock" {
+		t.Errorf("register requests = %v, want endpoint gpu.sock", reqs)
+	}
+}
+
+func TestValidateSocketName(t *testing.T) {
+	if err := ValidateSocketName("gpu.sock"); err != nil {
+		t.Errorf("ValidateSocketName(gpu.sock) = %v", err)
+	}
+	for _, name := range []string{"gpu", ".sock", "a/gpu.sock", `a\gpu.sock`, strings.Repeat("a", 100) + ".sock"} {
+		if err := ValidateSocketName(name); err == nil {
+			t.Errorf("ValidateSocketName(%q) = nil, want error", name)
+		}
+	}
+}
+
+func TestRegisterToKubeletRejected(t *testing.T) {
+	s, dir := newTestServer(t)
+	kubelet := startFakeKubelet(t, dir)
+	kubelet.SetError(errors.New("unsupported resource"))
+
+	if err := s.RegisterToKubelet(); err == nil {
+		t.Fatal("RegisterToKubelet() succeeded, want rejection error")
+	}
+
+	status := s.Status()
+	if status.RegisteredWithKubelet {
+		t.Error("status is registered with kubelet after rejection")
+	}
+	if status.LastError == "" {
+		t.Error("status has no last error after rejection")
+	}
+}
+
+func TestRegisterToKubeletUnavailable(t *testing.T) {
+	s, _ := newTestServer(t)
+
+	if err := s.RegisterToKubelet(); err == nil {
+		t.Fatal("RegisterToKubelet() succeeded without kubelet, want error")
+	}
+	if s.Status().RegisteredWithKubelet {
+		t.Error("status is registered with kubelet without kubelet")
+	}
+}
+
+func TestPluginManagerRegisterShards(t *testing.T) {
+	dir := t.TempDir()
+	kubelet := startFakeKubelet(t, dir)
+	m, err := NewPluginManager(2, WithPluginPath(dir), WithDevicePath(t.TempDir()), WithWatchdogTimeout(0))
+	if err != nil {
+		t.Fatalf("NewPluginManager() error = %v", err)
+	}
+	t.Cleanup(m.Stop)
+
+	if err := m.RegisterToKubelet(); err != nil {
+		t.Fatalf("RegisterToKubelet() = %v", err)
+	}
+
+	got := make(map[string]string)
+	for _, req := range kubelet.Requests() {
+		got[req.Endpoint] = req.ResourceName
+	}
+	want := map[string]string{
+		"micro-0.sock": "micro.plugin-0",
+		"micro-1.sock": "micro.plugin-1",
+	}
+	for endpoint, resource := range want {
+		if got[endpoint] != resource {
+			t.Errorf("endpoint %s registered resource %q, want %q", endpoint, got[endpoint], resource)
+		}
+	}
+}
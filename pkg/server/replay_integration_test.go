@@ -0,0 +1,45 @@
+//go:build integration
+
+package server
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/kelein/micro-device-plugin/pkg/testutil"
+)
+
+// TestReplayScenarios replays the recorded kubelet scenarios of
+// cmd/replay against an in-process plugin
+func TestReplayScenarios(t *testing.T) {
+	paths, err := filepath.Glob("../../cmd/replay/scenarios/*.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(paths) == 0 {
+		t.Fatal("no replay scenarios found")
+	}
+
+	for _, path := range paths {
+		t.Run(filepath.Base(path), func(t *testing.T) {
+			sc, err := testutil.LoadScenario(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			devicePath := t.TempDir()
+			s, pluginPath := newTestServer(t, WithDevicePath(devicePath))
+			start := func() error {
+				if err := s.Run(); err != nil {
+					return err
+				}
+				return s.RegisterToKubelet()
+			}
+
+			r := testutil.NewReplayer(pluginPath, devicePath)
+			if err := r.Replay(context.Background(), sc, start); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
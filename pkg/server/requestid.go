@@ -0,0 +1,52 @@
+package server
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/google/uuid"
+	"google.golang.org/grpc"
+)
+
+// requestIDKey is the context key of the RPC request ID
+type requestIDKey struct{}
+
+// RequestID returns the request ID of an RPC call, or empty when the
+// context does not carry one
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// withRequestID returns a context carrying a new request ID
+func withRequestID(ctx context.Context) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, uuid.NewString())
+}
+
+// requestLogger returns the server logger with the request ID of ctx
+func (s *MicroDeviceServer) requestLogger(ctx context.Context) *slog.Logger {
+	if id := RequestID(ctx); id != "" {
+		return s.logger.With("request_id", id)
+	}
+	return s.logger
+}
+
+// unaryRequestID generates a request ID for every unary RPC call
+func unaryRequestID(ctx context.Context, req any, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	return handler(withRequestID(ctx), req)
+}
+
+// streamRequestID generates a request ID for every streaming RPC call
+func streamRequestID(srv any, ss grpc.ServerStream, _ *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	return handler(srv, &requestIDStream{ServerStream: ss, ctx: withRequestID(ss.Context())})
+}
+
+// requestIDStream is a server stream carrying the request ID context
+type requestIDStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *requestIDStream) Context() context.Context {
+	return s.ctx
+}
@@ -0,0 +1,56 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"google.golang.org/grpc"
+	deviceapi "k8s.io/kubelet/pkg/apis/deviceplugin/v1beta1"
+)
+
+func TestAllocateRequestID(t *testing.T) {
+	var logs bytes.Buffer
+	s, _ := newTestServer(t, WithLogger(slog.New(slog.NewJSONHandler(&logs, nil))))
+	s.devices["micro0"] = &MicroDevice{Name: "micro0", ID: deviceID("micro0"), Health: deviceapi.Healthy}
+	s.devices["micro1"] = &MicroDevice{Name: "micro1", ID: deviceID("micro1"), Health: deviceapi.Healthy}
+
+	req := &deviceapi.AllocateRequest{
+		ContainerRequests: []*deviceapi.ContainerAllocateRequest{
+			{DevicesIDs: []string{deviceID("micro0")}},
+			{DevicesIDs: []string{deviceID("micro1")}},
+		},
+	}
+	handler := func(ctx context.Context, req any) (any, error) {
+		return s.Allocate(ctx, req.(*deviceapi.AllocateRequest))
+	}
+	if _, err := unaryRequestID(context.Background(), req, &grpc.UnaryServerInfo{}, handler); err != nil {
+		t.Fatalf("Allocate() error = %v", err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(logs.String()), "\n")
+	if len(lines) < 2 {
+		t.Fatalf("got %d log lines, want at least 2:\n%s", len(lines), logs.String())
+	}
+	var requestID string
+	for _, line := range lines {
+		var entry struct {
+			RequestID string `json:"request_id"`
+		}
+		if err := json.Unmarshal([]byte(line), &entry); err != nil {
+			t.Fatalf("parse log line %q: %v", line, err)
+		}
+		if entry.RequestID == "" {
+			t.Fatalf("log line without request_id: %s", line)
+		}
+		if requestID == "" {
+			requestID = entry.RequestID
+		}
+		if entry.RequestID != requestID {
+			t.Errorf("request_id = %s, want %s", entry.RequestID, requestID)
+		}
+	}
+}
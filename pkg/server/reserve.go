@@ -0,0 +1,65 @@
+package server
+
+import (
+	"sort"
+
+	deviceapi "k8s.io/kubelet/pkg/apis/deviceplugin/v1beta1"
+)
+
+// reservedAnnotation marks a device reserved from allocation
+const reservedAnnotation = "micro.plugin/reserved"
+
+// systemReservedAnnotation marks a device reserved for the system
+// daemons of the node, hidden from kubelet
+const systemReservedAnnotation = "micro.plugin/system-reserved"
+
+// applyReservations marks the first system reserved devices sorted by
+// name and the reserved devices following them, must be called with s.mu
+// held
+func (s *MicroDeviceServer) applyReservations() {
+	if s.reserveDevices <= 0 && s.systemReserve <= 0 {
+		return
+	}
+
+	names := make([]string, 0, len(s.devices))
+	for name := range s.devices {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var count int
+	for i, name := range names {
+		dev := s.devices[name]
+		delete(dev.Annotations, systemReservedAnnotation)
+		delete(dev.Annotations, reservedAnnotation)
+		switch {
+		case i < s.systemReserve:
+			dev.Annotations[systemReservedAnnotation] = "true"
+			dev.Annotations[reservedAnnotation] = "true"
+			count++
+		case i < s.systemReserve+s.reserveDevices:
+			dev.Annotations[reservedAnnotation] = "true"
+		}
+	}
+	s.metrics.systemReserved.Set(float64(count))
+}
+
+// isReserved reports whether the device is reserved from allocation
+func isReserved(dev *MicroDevice) bool {
+	return dev.Annotations[reservedAnnotation] == "true"
+}
+
+// isSystemReserved reports whether the device is reserved for the system
+// and must not be advertised to kubelet
+func isSystemReserved(dev *MicroDevice) bool {
+	return dev.Annotations[systemReservedAnnotation] == "true"
+}
+
+// allocatableDevice converts the device for kubelet, reserved devices
+// are reported unhealthy so that they are never allocated
+func allocatableDevice(dev *MicroDevice) *deviceapi.Device {
+	d := dev.APIDevice()
+	if isReserved(dev) {
+		d.Health = deviceapi.Unhealthy
+	}
+	return d
+}
@@ -0,0 +1,92 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	deviceapi "k8s.io/kubelet/pkg/apis/deviceplugin/v1beta1"
+
+	"github.com/kelein/micro-device-plugin/pkg/discovery"
+	"github.com/kelein/micro-device-plugin/pkg/testing/assert"
+	"github.com/kelein/micro-device-plugin/pkg/testutil"
+)
+
+func TestReserveForSystem(t *testing.T) {
+	var devices []*MicroDevice
+	for _, i := range []int{3, 0, 4, 1, 2} {
+		devices = append(devices, &MicroDevice{Name: fmt.Sprintf("micro%d", i)})
+	}
+	reg := prometheus.NewRegistry()
+	s, _ := newTestServer(t, WithMetrics(reg),
+		WithDiscoverer(discovery.NewStaticDiscoverer(devices)), WithReserveForSystem(2))
+	if err := s.findDevice(); err != nil {
+		t.Fatalf("findDevice() = %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	srv := testutil.NewMockListAndWatchServer(ctx)
+	go s.ListAndWatch(&deviceapi.Empty{}, srv)
+	if !srv.WaitForSends(1, time.Second) {
+		t.Fatal("ListAndWatch did not send the initial device list")
+	}
+
+	responses := srv.Responses()
+	if got := len(responses[0].Devices); got != 3 {
+		t.Errorf("ListAndWatch sent %d devices, want 3", got)
+	}
+	for _, name := range []string{"micro0", "micro1"} {
+		for _, d := range responses[0].Devices {
+			if d.ID == deviceID(name) {
+				t.Errorf("system reserved device %s sent to kubelet", name)
+			}
+		}
+		if _, ok := s.DeviceHealth(name); !ok {
+			t.Errorf("system reserved device %s not tracked", name)
+		}
+	}
+	for _, name := range []string{"micro2", "micro3", "micro4"} {
+		assert.AssertListAndWatchContains(t, responses, deviceID(name), deviceapi.Healthy)
+	}
+
+	status := s.Status()
+	if status.ReservedCount != 2 || status.AllocatableCapacity != 3 {
+		t.Errorf("status reservedCount = %d, allocatableCapacity = %d, want 2 and 3", status.ReservedCount, status.AllocatableCapacity)
+	}
+	assert.AssertMetricValue(t, reg, "micro_device_plugin_system_reserved_devices", nil, 2)
+}
+
+func TestReserveDevices(t *testing.T) {
+	s, _ := newTestServer(t, WithReserveDevices(2))
+	for i := 0; i < 4; i++ {
+		s.addDevice(&MicroDevice{Name: fmt.Sprintf("micro%d", i)})
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	srv := testutil.NewMockListAndWatchServer(ctx)
+	go s.ListAndWatch(&deviceapi.Empty{}, srv)
+	if !srv.WaitForSends(1, time.Second) {
+		t.Fatal("ListAndWatch did not send the initial device list")
+	}
+
+	responses := srv.Responses()
+	if got := len(responses[0].Devices); got != 4 {
+		t.Errorf("ListAndWatch sent %d devices, want 4", got)
+	}
+	for _, name := range []string{"micro0", "micro1"} {
+		assert.AssertListAndWatchContains(t, responses, deviceID(name), deviceapi.Unhealthy)
+	}
+	for _, name := range []string{"micro2", "micro3"} {
+		assert.AssertListAndWatchContains(t, responses, deviceID(name), deviceapi.Healthy)
+	}
+
+	status := s.Status()
+	if status.TotalCapacity != 4 || status.AllocatableCapacity != 2 || status.ReservedCapacity != 2 {
+		t.Errorf("status capacity total = %d, allocatable = %d, reserved = %d, want 4, 2 and 2",
+			status.TotalCapacity, status.AllocatableCapacity, status.ReservedCapacity)
+	}
+}
@@ -0,0 +1,104 @@
+package server
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strconv"
+
+	deviceapi "k8s.io/kubelet/pkg/apis/deviceplugin/v1beta1"
+)
+
+// Container runtimes adapted by the RuntimeAdapter
+const (
+	RuntimeDocker     = "docker"
+	RuntimeContainerd = "containerd"
+	RuntimeCRIO       = "cri-o"
+)
+
+// defaultMaxEnvValueSize is the longest env var value passed to CRI-O
+// unsplit
+const defaultMaxEnvValueSize = 4096
+
+// RuntimeAdapter adjusts the Allocate responses to the container runtime
+// of the node:
+//   - cri-o: env var values longer than MaxEnvValueSize are split into
+//     KEY_0, KEY_1, ... with the number of parts in KEY_PARTS
+//   - containerd: relative mount and device host paths are resolved
+//     against the device directory
+//   - docker: responses are unchanged
+type RuntimeAdapter struct {
+	Runtime string
+
+	// MaxEnvValueSize is the longest env var value of cri-o containers
+	MaxEnvValueSize int
+
+	// DevicePath resolves the relative host paths of containerd
+	DevicePath string
+}
+
+// NewRuntimeAdapter creates an adapter of the container runtime
+func NewRuntimeAdapter(runtime, devicePath string) (*RuntimeAdapter, error) {
+	switch runtime {
+	case RuntimeDocker, RuntimeContainerd, RuntimeCRIO:
+	default:
+		return nil, fmt.Errorf("unknown container runtime %q, must be %s, %s or %s", runtime, RuntimeDocker, RuntimeContainerd, RuntimeCRIO)
+	}
+	return &RuntimeAdapter{
+		Runtime:         runtime,
+		MaxEnvValueSize: defaultMaxEnvValueSize,
+		DevicePath:      devicePath,
+	}, nil
+}
+
+// Adapt adjusts the container allocate response to the runtime
+func (a *RuntimeAdapter) Adapt(resp *deviceapi.ContainerAllocateResponse) {
+	switch a.Runtime {
+	case RuntimeCRIO:
+		resp.Envs = splitEnvs(resp.Envs, a.MaxEnvValueSize)
+	case RuntimeContainerd:
+		for _, mount := range resp.Mounts {
+			mount.HostPath = a.hostPath(mount.HostPath)
+		}
+		for _, dev := range resp.Devices {
+			dev.HostPath = a.hostPath(dev.HostPath)
+		}
+	}
+}
+
+// hostPath returns the absolute host path of path
+func (a *RuntimeAdapter) hostPath(path string) string {
+	if path == "" || filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(a.DevicePath, path)
+}
+
+// splitEnvs splits the env var values longer than max into numbered parts
+func splitEnvs(envs map[string]string, max int) map[string]string {
+	if max <= 0 {
+		return envs
+	}
+	keys := make([]string, 0, len(envs))
+	for k := range envs {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	split := make(map[string]string, len(envs))
+	for _, k := range keys {
+		v := envs[k]
+		if len(v) <= max {
+			split[k] = v
+			continue
+		}
+		parts := 0
+		for ; len(v) > 0; parts++ {
+			n := min(max, len(v))
+			split[k+"_"+strconv.Itoa(parts)] = v[:n]
+			v = v[n:]
+		}
+		split[k+"_PARTS"] = strconv.Itoa(parts)
+	}
+	return split
+}
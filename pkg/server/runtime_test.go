@@ -0,0 +1,102 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"testing"
+
+	deviceapi "k8s.io/kubelet/pkg/apis/deviceplugin/v1beta1"
+
+	"github.com/kelein/micro-device-plugin/pkg/testing/assert"
+	"github.com/kelein/micro-device-plugin/pkg/testutil"
+)
+
+func TestRuntimeAdapterCRIO(t *testing.T) {
+	adapter, err := NewRuntimeAdapter(RuntimeCRIO, "/dev/micro")
+	if err != nil {
+		t.Fatal(err)
+	}
+	adapter.MaxEnvValueSize = 256
+	s, _ := newTestServer(t, WithRuntimeAdapter(adapter))
+
+	// a large device request exceeds the env var value size
+	var ids []string
+	for i := range 64 {
+		ids = append(ids, deviceID(fmt.Sprintf("micro%d", i)))
+	}
+	req := testutil.NewMockAllocateRequest().WithDeviceIDs(ids...).Build()
+	resp, err := s.Allocate(context.Background(), req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	envs := resp.ContainerResponses[0].Envs
+
+	if _, ok := envs["MICRO_DEVICES"]; ok {
+		t.Error("oversized MICRO_DEVICES is not split")
+	}
+	parts, err := strconv.Atoi(envs["MICRO_DEVICES_PARTS"])
+	if err != nil || parts < 2 {
+		t.Fatalf("MICRO_DEVICES_PARTS = %q, want at least 2 parts", envs["MICRO_DEVICES_PARTS"])
+	}
+	var joined strings.Builder
+	for i := range parts {
+		part := envs["MICRO_DEVICES_"+strconv.Itoa(i)]
+		if len(part) > adapter.MaxEnvValueSize {
+			t.Errorf("part %d has %d bytes, want at most %d", i, len(part), adapter.MaxEnvValueSize)
+		}
+		joined.WriteString(part)
+	}
+	if got, want := joined.String(), strings.Join(ids, ","); got != want {
+		t.Errorf("joined MICRO_DEVICES = %q, want %q", got, want)
+	}
+	if envs["MICRO_NODE_ARCH"] == "" {
+		t.Error("short env var MICRO_NODE_ARCH is missing")
+	}
+}
+
+func TestRuntimeAdapterContainerd(t *testing.T) {
+	adapter, err := NewRuntimeAdapter(RuntimeContainerd, "/dev/micro")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp := &deviceapi.ContainerAllocateResponse{
+		Mounts: []*deviceapi.Mount{
+			{ContainerPath: "/etc/micro", HostPath: "config"},
+			{ContainerPath: "/var/lib/micro", HostPath: "/var/lib/micro"},
+		},
+		Devices: []*deviceapi.DeviceSpec{
+			{ContainerPath: "/dev/micro0", HostPath: "micro0", Permissions: "rw"},
+		},
+	}
+	adapter.Adapt(resp)
+
+	assert.AssertAllocateResponse(t, &deviceapi.AllocateResponse{ContainerResponses: []*deviceapi.ContainerAllocateResponse{resp}},
+		nil, []*deviceapi.Mount{
+			{ContainerPath: "/etc/micro", HostPath: "/dev/micro/config"},
+			{ContainerPath: "/var/lib/micro", HostPath: "/var/lib/micro"},
+		})
+	if got := resp.Devices[0].HostPath; got != "/dev/micro/micro0" {
+		t.Errorf("device host path = %s, want /dev/micro/micro0", got)
+	}
+}
+
+func TestRuntimeAdapterDocker(t *testing.T) {
+	adapter, err := NewRuntimeAdapter(RuntimeDocker, "/dev/micro")
+	if err != nil {
+		t.Fatal(err)
+	}
+	value := strings.Repeat("a", 2*defaultMaxEnvValueSize)
+	resp := &deviceapi.ContainerAllocateResponse{
+		Envs:   map[string]string{"MICRO_DEVICES": value},
+		Mounts: []*deviceapi.Mount{{ContainerPath: "/etc/micro", HostPath: "config"}},
+	}
+	adapter.Adapt(resp)
+
+	assert.AssertAllocateResponse(t, &deviceapi.AllocateResponse{ContainerResponses: []*deviceapi.ContainerAllocateResponse{resp}},
+		map[string]string{"MICRO_DEVICES": value}, []*deviceapi.Mount{{ContainerPath: "/etc/micro", HostPath: "config"}})
+	if _, err := NewRuntimeAdapter("rkt", ""); err == nil {
+		t.Error("NewRuntimeAdapter(rkt) error = nil, want error")
+	}
+}
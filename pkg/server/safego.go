@@ -0,0 +1,75 @@
+package server
+
+import (
+	"context"
+	"runtime/debug"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// maxPanicBackoff caps the restart delay of a repeatedly panicking goroutine
+const maxPanicBackoff = time.Minute
+
+// SafeGo runs f in a goroutine recovering its panics, the goroutine is
+// restarted with an exponential back-off if panic recovery is enabled
+func (s *MicroDeviceServer) SafeGo(name string, f func()) {
+	go func() {
+		backoff := s.panicBackoff
+		for s.callSafe(name, f) {
+			if !s.recoverPanics || s.ctx.Err() != nil {
+				return
+			}
+			s.logger.Warn("restarting goroutine after panic", "goroutine", name, "backoff", backoff)
+			select {
+			case <-time.After(backoff):
+			case <-s.ctx.Done():
+				return
+			}
+			backoff = min(backoff*2, maxPanicBackoff)
+		}
+	}()
+}
+
+// callSafe calls f and reports whether it panicked
+func (s *MicroDeviceServer) callSafe(name string, f func()) (panicked bool) {
+	defer func() {
+		if r := recover(); r != nil {
+			panicked = true
+			s.recordPanic(name, r)
+		}
+	}()
+	f()
+	return false
+}
+
+// recordPanic logs a recovered panic with the stack trace
+func (s *MicroDeviceServer) recordPanic(name string, r any) {
+	s.metrics.panicsRecovered.WithLabelValues(name).Inc()
+	s.logger.Error("recovered panic", "goroutine", name, "panic", r, "stack", string(debug.Stack()))
+}
+
+// unaryRecover turns the panics of unary RPC handlers into internal errors
+func (s *MicroDeviceServer) unaryRecover(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp any, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			s.recordPanic(info.FullMethod, r)
+			err = status.Errorf(codes.Internal, "panic in %s: %v", info.FullMethod, r)
+		}
+	}()
+	return handler(ctx, req)
+}
+
+// streamRecover turns the panics of streaming RPC handlers into internal
+// errors
+func (s *MicroDeviceServer) streamRecover(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			s.recordPanic(info.FullMethod, r)
+			err = status.Errorf(codes.Internal, "panic in %s: %v", info.FullMethod, r)
+		}
+	}()
+	return handler(srv, ss)
+}
@@ -0,0 +1,32 @@
+package server
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/kelein/micro-device-plugin/pkg/testing/assert"
+)
+
+func TestSafeGoRestartsAfterPanic(t *testing.T) {
+	s, _ := newTestServer(t, WithRecoverPanics(true))
+	s.panicBackoff = time.Millisecond
+	t.Cleanup(s.Stop)
+
+	var calls atomic.Int32
+	restarted := make(chan struct{})
+	s.SafeGo("test-panic", func() {
+		if calls.Add(1) == 1 {
+			panic("injected panic")
+		}
+		close(restarted)
+	})
+
+	select {
+	case <-restarted:
+	case <-time.After(time.Second):
+		t.Fatal("goroutine was not restarted after panic")
+	}
+	assert.AssertMetricValue(t, s.gatherer(), "micro_device_plugin_panics_recovered_total",
+		map[string]string{"goroutine": "test-panic"}, 1)
+}
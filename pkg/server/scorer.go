@@ -0,0 +1,191 @@
+package server
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"strings"
+	"sync"
+
+	deviceapi "k8s.io/kubelet/pkg/apis/deviceplugin/v1beta1"
+
+	"github.com/kelein/micro-device-plugin/pkg/discovery"
+)
+
+// Device topology annotations populated from the device file extended
+// attributes `user.numa` and `user.pci`, the NUMA node is also declared
+// by the static devices file
+const (
+	numaAnnotation = discovery.NUMAAnnotation
+	pciAnnotation  = xattrAnnotationPrefix + "pci"
+)
+
+// DeviceScorer scores the candidate devices of a preferred allocation,
+// devices with higher scores are preferred
+type DeviceScorer interface {
+	Score(candidates []*MicroDevice, req *deviceapi.PreferredAllocationRequest) ([]float64, error)
+}
+
+// Compile-time checks of the DeviceScorer implementations
+var (
+	_ DeviceScorer = NUMAScorer{}
+	_ DeviceScorer = PCIeScorer{}
+	_ DeviceScorer = RandomScorer{}
+	_ DeviceScorer = (*RoundRobinScorer)(nil)
+	_ DeviceScorer = ThermalScorer{}
+	_ DeviceScorer = cpuAffinityScorer{}
+)
+
+// NewScorer returns the built-in scorer by name: numa, pcie, random or
+// round-robin
+func NewScorer(name string) (DeviceScorer, error) {
+	switch name {
+	case "numa":
+		return NUMAScorer{}, nil
+	case "pcie":
+		return PCIeScorer{}, nil
+	case "random":
+		return RandomScorer{}, nil
+	case "round-robin":
+		return &RoundRobinScorer{}, nil
+	default:
+		return nil, fmt.Errorf("unknown device scorer %q", name)
+	}
+}
+
+// mustInclude returns the must-include device IDs of the request
+func mustInclude(req *deviceapi.PreferredAllocationRequest) map[string]bool {
+	ids := make(map[string]bool)
+	for _, c := range req.GetContainerRequests() {
+		for _, id := range c.MustIncludeDeviceIDs {
+			ids[id] = true
+		}
+	}
+	return ids
+}
+
+// groupScores scores every candidate by the size of its group, the
+// group of the must-include devices is preferred over all others
+func groupScores(candidates []*MicroDevice, req *deviceapi.PreferredAllocationRequest, group func(*MicroDevice) string) []float64 {
+	must := mustInclude(req)
+	sizes := make(map[string]int)
+	preferred := make(map[string]bool)
+	for _, dev := range candidates {
+		g := group(dev)
+		if g == "" {
+			continue
+		}
+		sizes[g]++
+		if must[dev.ID] {
+			preferred[g] = true
+		}
+	}
+
+	// break ties between equally sized groups so that the devices of a
+	// single group are preferred
+	best := ""
+	for g, n := range sizes {
+		if best == "" || n > sizes[best] || n == sizes[best] && g < best {
+			best = g
+		}
+	}
+
+	scores := make([]float64, len(candidates))
+	for i, dev := range candidates {
+		g := group(dev)
+		if g == "" {
+			continue
+		}
+		scores[i] = float64(sizes[g])
+		if g == best {
+			scores[i] += 0.5
+		}
+		if preferred[g] {
+			scores[i] += float64(len(candidates))
+		}
+	}
+	return scores
+}
+
+// NUMAScorer prefers devices on the NUMA node of the must-include
+// devices, then devices on the NUMA node with the most candidates
+type NUMAScorer struct{}
+
+// Score implements DeviceScorer
+func (NUMAScorer) Score(candidates []*MicroDevice, req *deviceapi.PreferredAllocationRequest) ([]float64, error) {
+	return groupScores(candidates, req, func(dev *MicroDevice) string {
+		return dev.Annotations[numaAnnotation]
+	}), nil
+}
+
+// PCIeScorer prefers devices behind the PCIe bus of the must-include
+// devices, then devices on the bus with the most candidates
+type PCIeScorer struct{}
+
+// Score implements DeviceScorer
+func (PCIeScorer) Score(candidates []*MicroDevice, req *deviceapi.PreferredAllocationRequest) ([]float64, error) {
+	return groupScores(candidates, req, func(dev *MicroDevice) string {
+		return pciBus(dev.Annotations[pciAnnotation])
+	}), nil
+}
+
+// pciBus returns the `domain:bus` of a PCI address such as 0000:3b:00.0
+func pciBus(addr string) string {
+	parts := strings.Split(addr, ":")
+	if len(parts) < 3 {
+		return ""
+	}
+	return parts[0] + ":" + parts[1]
+}
+
+// RandomScorer scores the candidates randomly to spread allocations
+type RandomScorer struct{}
+
+// Score implements DeviceScorer
+func (RandomScorer) Score(candidates []*MicroDevice, _ *deviceapi.PreferredAllocationRequest) ([]float64, error) {
+	scores := make([]float64, len(candidates))
+	for i := range scores {
+		scores[i] = rand.Float64()
+	}
+	return scores, nil
+}
+
+// RoundRobinScorer rotates the preferred candidate on every call so
+// that consecutive allocations start from different devices
+type RoundRobinScorer struct {
+	mu   sync.Mutex
+	next int
+}
+
+// Score implements DeviceScorer
+func (r *RoundRobinScorer) Score(candidates []*MicroDevice, _ *deviceapi.PreferredAllocationRequest) ([]float64, error) {
+	n := len(candidates)
+	scores := make([]float64, n)
+	if n == 0 {
+		return scores, nil
+	}
+
+	r.mu.Lock()
+	start := r.next % n
+	r.next++
+	r.mu.Unlock()
+
+	for i := range candidates {
+		scores[i] = float64(n - (i-start+n)%n)
+	}
+	return scores, nil
+}
+
+// cpuAffinityScorer scores devices by their CPU co-location with the
+// preferred CPUs
+type cpuAffinityScorer struct {
+	s *MicroDeviceServer
+}
+
+// Score implements DeviceScorer
+func (a cpuAffinityScorer) Score(candidates []*MicroDevice, _ *deviceapi.PreferredAllocationRequest) ([]float64, error) {
+	scores := make([]float64, len(candidates))
+	for i, dev := range candidates {
+		scores[i] = float64(a.s.affinityScore(dev))
+	}
+	return scores, nil
+}
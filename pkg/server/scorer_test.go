@@ -0,0 +1,163 @@
+package server
+
+import (
+	"context"
+	"slices"
+	"sort"
+	"strconv"
+	"testing"
+
+	deviceapi "k8s.io/kubelet/pkg/apis/deviceplugin/v1beta1"
+)
+
+// annotatedDevices creates the named candidates with the annotation key
+// set to the value of the same index
+func annotatedDevices(key string, names []string, values []string) []*MicroDevice {
+	devices := make([]*MicroDevice, len(names))
+	for i, name := range names {
+		devices[i] = &MicroDevice{
+			Name:        name,
+			ID:          deviceID(name),
+			Annotations: map[string]string{key: values[i]},
+		}
+	}
+	return devices
+}
+
+// ranking orders the candidate names by descending score, equal scores
+// keep the candidate order
+func ranking(t *testing.T, scorer DeviceScorer, candidates []*MicroDevice, must ...string) []string {
+	t.Helper()
+	req := &deviceapi.PreferredAllocationRequest{
+		ContainerRequests: []*deviceapi.ContainerPreferredAllocationRequest{{MustIncludeDeviceIDs: must}},
+	}
+	scores, err := scorer.Score(candidates, req)
+	if err != nil {
+		t.Fatalf("Score() error = %v", err)
+	}
+	if len(scores) != len(candidates) {
+		t.Fatalf("Score() returned %d scores for %d candidates", len(scores), len(candidates))
+	}
+	order := make([]int, len(candidates))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		return scores[order[i]] > scores[order[j]]
+	})
+	names := make([]string, len(order))
+	for i, j := range order {
+		names[i] = candidates[j].Name
+	}
+	return names
+}
+
+func TestNUMAScorer(t *testing.T) {
+	names := []string{"micro0", "micro1", "micro2", "micro3", "micro4"}
+	candidates := annotatedDevices(numaAnnotation, names, []string{"0", "1", "1", "0", "1"})
+	tests := []struct {
+		name string
+		must []string
+		want []string
+	}{
+		{name: "largest node first", want: []string{"micro1", "micro2", "micro4", "micro0", "micro3"}},
+		{name: "node of must include first", must: []string{deviceID("micro3")}, want: []string{"micro0", "micro3", "micro1", "micro2", "micro4"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ranking(t, NUMAScorer{}, candidates, tt.must...); !slices.Equal(got, tt.want) {
+				t.Errorf("ranking = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPCIeScorer(t *testing.T) {
+	names := []string{"micro0", "micro1", "micro2", "micro3"}
+	candidates := annotatedDevices(pciAnnotation, names, []string{"0000:af:00.0", "0000:3b:00.0", "invalid", "0000:3b:01.0"})
+	tests := []struct {
+		name string
+		must []string
+		want []string
+	}{
+		{name: "largest bus first", want: []string{"micro1", "micro3", "micro0", "micro2"}},
+		{name: "bus of must include first", must: []string{deviceID("micro0")}, want: []string{"micro0", "micro1", "micro3", "micro2"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ranking(t, PCIeScorer{}, candidates, tt.must...); !slices.Equal(got, tt.want) {
+				t.Errorf("ranking = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRandomScorer(t *testing.T) {
+	candidates := annotatedDevices(numaAnnotation, []string{"micro0", "micro1", "micro2"}, []string{"0", "0", "0"})
+	scores, err := RandomScorer{}.Score(candidates, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(scores) != len(candidates) {
+		t.Fatalf("Score() returned %d scores for %d candidates", len(scores), len(candidates))
+	}
+	for i, score := range scores {
+		if score < 0 || score >= 1 {
+			t.Errorf("score of %s = %v, want in [0, 1)", candidates[i].Name, score)
+		}
+	}
+}
+
+func TestRoundRobinScorer(t *testing.T) {
+	names := []string{"micro0", "micro1", "micro2"}
+	candidates := annotatedDevices(numaAnnotation, names, []string{"0", "0", "0"})
+	scorer := &RoundRobinScorer{}
+	wants := [][]string{
+		{"micro0", "micro1", "micro2"},
+		{"micro1", "micro2", "micro0"},
+		{"micro2", "micro0", "micro1"},
+		{"micro0", "micro1", "micro2"},
+	}
+	for call, want := range wants {
+		if got := ranking(t, scorer, candidates); !slices.Equal(got, want) {
+			t.Errorf("call %d ranking = %v, want %v", call, got, want)
+		}
+	}
+}
+
+func TestNewScorer(t *testing.T) {
+	for _, name := range []string{"numa", "pcie", "random", "round-robin"} {
+		if _, err := NewScorer(name); err != nil {
+			t.Errorf("NewScorer(%q) error = %v", name, err)
+		}
+	}
+	if _, err := NewScorer("fastest"); err == nil {
+		t.Error("NewScorer() of an unknown scorer succeeded, want error")
+	}
+}
+
+func TestScorerPreferredAllocation(t *testing.T) {
+	s, _ := newTestServer(t, WithScorer(PCIeScorer{}))
+	var ids []string
+	for i, addr := range []string{"0000:af:00.0", "0000:3b:00.0", "0000:af:01.0", "0000:3b:01.0", "0000:3b:02.0"} {
+		ids = append(ids, s.addDevice(&MicroDevice{
+			Name:        "micro" + strconv.Itoa(i),
+			Annotations: map[string]string{pciAnnotation: addr},
+		}))
+	}
+
+	req := &deviceapi.PreferredAllocationRequest{
+		ContainerRequests: []*deviceapi.ContainerPreferredAllocationRequest{{
+			AvailableDeviceIDs: ids,
+			AllocationSize:     3,
+		}},
+	}
+	resp, err := newPluginClient(t, s).GetPreferredAllocation(context.Background(), req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := slices.Sorted(slices.Values(resp.ContainerResponses[0].DeviceIDs))
+	if want := sortedIDs("micro1", "micro3", "micro4"); !slices.Equal(got, want) {
+		t.Errorf("preferred devices = %v, want the devices of bus 0000:3b %v", got, want)
+	}
+}
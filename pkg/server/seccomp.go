@@ -0,0 +1,106 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	deviceapi "k8s.io/kubelet/pkg/apis/deviceplugin/v1beta1"
+)
+
+// SeccompAnnotationPrefix prefixes the seccomp profile annotations of the
+// Allocate responses. The device plugin API carries no container name,
+// so the annotations are keyed by device name, the response annotations
+// only reach the container allocating the devices.
+const SeccompAnnotationPrefix = "container.seccomp.security.alpha.kubernetes.io/"
+
+// seccompLocalhostPrefix prefixes the profiles of the kubelet seccomp
+// root directory
+const seccompLocalhostPrefix = "localhost/"
+
+// SeccompProfileManager annotates the allocations with the seccomp
+// profiles of their devices, the profile of a device is the
+// `<device-name>.json` file of Dir. Dir must be the kubelet seccomp root,
+// e.g. /var/lib/kubelet/seccomp, for the runtime to find the profiles.
+type SeccompProfileManager struct {
+	Dir string
+}
+
+// NewSeccompProfileManager creates a manager of the profiles of dir
+func NewSeccompProfileManager(dir string) *SeccompProfileManager {
+	return &SeccompProfileManager{Dir: dir}
+}
+
+// ValidateSeccompProfile checks the profile is a JSON seccomp spec with a
+// default action
+func ValidateSeccompProfile(data []byte) error {
+	var spec struct {
+		DefaultAction string `json:"defaultAction"`
+	}
+	if err := json.Unmarshal(data, &spec); err != nil {
+		return fmt.Errorf("decode seccomp profile: %w", err)
+	}
+	if spec.DefaultAction == "" {
+		return errors.New("seccomp profile has no defaultAction")
+	}
+	return nil
+}
+
+// Profile returns the localhost profile reference of the named device,
+// false if the device has no profile
+func (m *SeccompProfileManager) Profile(name string) (string, bool, error) {
+	file := name + ".json"
+	data, err := os.ReadFile(filepath.Join(m.Dir, file))
+	if errors.Is(err, os.ErrNotExist) {
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, fmt.Errorf("read seccomp profile of device %s: %w", name, err)
+	}
+	if err := ValidateSeccompProfile(data); err != nil {
+		return "", false, fmt.Errorf("device %s: %w", name, err)
+	}
+	return seccompLocalhostPrefix + file, true, nil
+}
+
+// Inject adds the seccomp profile annotations of the named devices to the
+// container allocate response, the devices with an invalid profile are
+// skipped and reported
+func (m *SeccompProfileManager) Inject(resp *deviceapi.ContainerAllocateResponse, names []string) error {
+	var errs []error
+	for _, name := range names {
+		profile, ok, err := m.Profile(name)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if !ok {
+			continue
+		}
+		if resp.Annotations == nil {
+			resp.Annotations = make(map[string]string)
+		}
+		resp.Annotations[SeccompAnnotationPrefix+name] = profile
+	}
+	return errors.Join(errs...)
+}
+
+// deviceNames returns the names of the devices of ids
+func (s *MicroDeviceServer) deviceNames(ids []string) []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	wanted := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		wanted[id] = true
+	}
+	var names []string
+	for _, dev := range s.devices {
+		if wanted[dev.ID] {
+			names = append(names, dev.Name)
+		}
+	}
+	return names
+}
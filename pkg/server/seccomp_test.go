@@ -0,0 +1,77 @@
+package server
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/kelein/micro-device-plugin/pkg/testutil"
+)
+
+func TestSeccompProfileManager(t *testing.T) {
+	dir := t.TempDir()
+	profiles := map[string]string{
+		"micro0": `{"defaultAction": "SCMP_ACT_ERRNO", "syscalls": [{"names": ["ioctl"], "action": "SCMP_ACT_ALLOW"}]}`,
+		"micro1": `{"syscalls": []}`,
+	}
+	for name, data := range profiles {
+		if err := os.WriteFile(filepath.Join(dir, name+".json"), []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	s, _ := newTestServer(t, WithSeccompProfileManager(NewSeccompProfileManager(dir)))
+	for _, name := range []string{"micro0", "micro1", "micro2"} {
+		s.addDevice(&MicroDevice{Name: name})
+	}
+
+	tests := []struct {
+		name    string
+		devices []string
+		want    map[string]string // expected: annotations of the response
+	}{
+		{name: "valid profile", devices: []string{"micro0"},
+			want: map[string]string{SeccompAnnotationPrefix + "micro0": "localhost/micro0.json"}},
+		{name: "invalid profile skipped", devices: []string{"micro0", "micro1"},
+			want: map[string]string{SeccompAnnotationPrefix + "micro0": "localhost/micro0.json"}},
+		{name: "no profile", devices: []string{"micro2"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var ids []string
+			for _, name := range tt.devices {
+				ids = append(ids, deviceID(name))
+			}
+			resp, err := s.Allocate(context.Background(), testutil.NewMockAllocateRequest().WithDeviceIDs(ids...).Build())
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := resp.ContainerResponses[0].Annotations
+			if len(got) != len(tt.want) {
+				t.Fatalf("annotations = %v, want %v", got, tt.want)
+			}
+			for k, v := range tt.want {
+				if got[k] != v {
+					t.Errorf("annotation %s = %q, want %q", k, got[k], v)
+				}
+			}
+		})
+	}
+}
+
+func TestValidateSeccompProfile(t *testing.T) {
+	tests := []struct {
+		profile string
+		wantErr bool
+	}{
+		{profile: `{"defaultAction": "SCMP_ACT_ALLOW"}`},
+		{profile: `{"syscalls": []}`, wantErr: true},
+		{profile: `{"defaultAction": ""}`, wantErr: true},
+		{profile: `not json`, wantErr: true},
+	}
+	for _, tt := range tests {
+		if err := ValidateSeccompProfile([]byte(tt.profile)); (err != nil) != tt.wantErr {
+			t.Errorf("ValidateSeccompProfile(%s) error = %v, want error %v", tt.profile, err, tt.wantErr)
+		}
+	}
+}
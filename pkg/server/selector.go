@@ -0,0 +1,143 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"regexp"
+	"time"
+
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// DeviceSelector filters and transforms the discovered devices before
+// they are added to the device map
+type DeviceSelector interface {
+	Select(ctx context.Context, candidates []*MicroDevice) ([]*MicroDevice, error)
+}
+
+// Compile-time checks of the DeviceSelector implementations
+var (
+	_ DeviceSelector = RegexSelector{}
+	_ DeviceSelector = LabelSelector{}
+	_ DeviceSelector = ChainSelector{}
+	_ DeviceSelector = ScriptSelector{}
+)
+
+// RegexSelector selects the devices whose name matches Re
+type RegexSelector struct {
+	Re *regexp.Regexp
+}
+
+// Select implements DeviceSelector
+func (r RegexSelector) Select(_ context.Context, candidates []*MicroDevice) ([]*MicroDevice, error) {
+	var selected []*MicroDevice
+	for _, dev := range candidates {
+		if r.Re.MatchString(dev.Name) {
+			selected = append(selected, dev)
+		}
+	}
+	return selected, nil
+}
+
+// LabelSelector selects the devices whose annotations match the label
+// selector
+type LabelSelector struct {
+	Selector labels.Selector
+}
+
+// NewLabelSelector parses a label selector such as
+// `tier=fast,vendor!=acme`
+func NewLabelSelector(expr string) (LabelSelector, error) {
+	sel, err := labels.Parse(expr)
+	if err != nil {
+		return LabelSelector{}, fmt.Errorf("invalid device label selector %q: %w", expr, err)
+	}
+	return LabelSelector{Selector: sel}, nil
+}
+
+// Select implements DeviceSelector
+func (l LabelSelector) Select(_ context.Context, candidates []*MicroDevice) ([]*MicroDevice, error) {
+	var selected []*MicroDevice
+	for _, dev := range candidates {
+		if l.Selector.Matches(labels.Set(dev.Annotations)) {
+			selected = append(selected, dev)
+		}
+	}
+	return selected, nil
+}
+
+// ChainSelector selects the devices selected by all of its selectors,
+// each selector gets the devices selected by the previous one
+type ChainSelector []DeviceSelector
+
+// Select implements DeviceSelector
+func (c ChainSelector) Select(ctx context.Context, candidates []*MicroDevice) ([]*MicroDevice, error) {
+	selected := candidates
+	for _, sel := range c {
+		var err error
+		if selected, err = sel.Select(ctx, selected); err != nil {
+			return nil, err
+		}
+	}
+	return selected, nil
+}
+
+// ScriptSelector runs a shell command per device and selects the device
+// if it exits with 0. The device is passed in the environment variables
+// MICRO_DEVICE_NAME, MICRO_DEVICE_ID and MICRO_DEVICE_PATH.
+type ScriptSelector struct {
+	Command string
+	Timeout time.Duration
+}
+
+// Select implements DeviceSelector
+func (p ScriptSelector) Select(ctx context.Context, candidates []*MicroDevice) ([]*MicroDevice, error) {
+	var selected []*MicroDevice
+	for _, dev := range candidates {
+		ok, err := p.run(ctx, dev)
+		if err != nil {
+			return nil, fmt.Errorf("select device %s: %w", dev.Name, err)
+		}
+		if ok {
+			selected = append(selected, dev)
+		}
+	}
+	return selected, nil
+}
+
+// run reports whether the command exits with 0 for the device
+func (p ScriptSelector) run(ctx context.Context, dev *MicroDevice) (bool, error) {
+	if p.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, p.Timeout)
+		defer cancel()
+	}
+
+	cmd := exec.CommandContext(ctx, "/bin/sh", "-c", p.Command)
+	cmd.Env = append(os.Environ(),
+		"MICRO_DEVICE_NAME="+dev.Name,
+		"MICRO_DEVICE_ID="+dev.ID,
+		"MICRO_DEVICE_PATH="+dev.Path,
+	)
+	err := cmd.Run()
+	var exitErr *exec.ExitError
+	switch {
+	case err == nil:
+		return true, nil
+	case errors.As(err, &exitErr) && ctx.Err() == nil:
+		return false, nil
+	default:
+		return false, fmt.Errorf("run selector command: %w", err)
+	}
+}
+
+// applySelector applies the device selector to the matched devices
+func (s *MicroDeviceServer) applySelector(devices []*MicroDevice) ([]*MicroDevice, error) {
+	if s.selector == nil {
+		return devices, nil
+	}
+	return s.selector.Select(s.ctx, devices)
+}
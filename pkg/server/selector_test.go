@@ -0,0 +1,95 @@
+package server
+
+import (
+	"context"
+	"reflect"
+	"regexp"
+	"testing"
+
+	"github.com/kelein/micro-device-plugin/pkg/discovery"
+)
+
+func selectorDevices() []*MicroDevice {
+	return []*MicroDevice{
+		{Name: "micro0", Annotations: map[string]string{"tier": "fast"}},
+		{Name: "micro1", Annotations: map[string]string{"tier": "slow"}},
+		{Name: "micro2", Annotations: map[string]string{"tier": "fast"}},
+		{Name: "other0", Annotations: map[string]string{"tier": "fast"}},
+		{Name: "micro3"},
+	}
+}
+
+func selectedNames(devices []*MicroDevice) []string {
+	var names []string
+	for _, dev := range devices {
+		names = append(names, dev.Name)
+	}
+	return names
+}
+
+func TestChainSelector(t *testing.T) {
+	labels, err := NewLabelSelector("tier=fast")
+	if err != nil {
+		t.Fatal(err)
+	}
+	regex := RegexSelector{Re: regexp.MustCompile(`^micro\d+$`)}
+
+	tests := []struct {
+		name  string
+		chain ChainSelector
+		want  []string
+	}{
+		{name: "regex", chain: ChainSelector{regex}, want: []string{"micro0", "micro1", "micro2", "micro3"}},
+		{name: "labels", chain: ChainSelector{labels}, want: []string{"micro0", "micro2", "other0"}},
+		{name: "regex and labels", chain: ChainSelector{regex, labels}, want: []string{"micro0", "micro2"}},
+		{name: "labels and regex", chain: ChainSelector{labels, regex}, want: []string{"micro0", "micro2"}},
+		{name: "empty", chain: ChainSelector{}, want: selectedNames(selectorDevices())},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.chain.Select(context.Background(), selectorDevices())
+			if err != nil {
+				t.Fatalf("Select() = %v", err)
+			}
+			if names := selectedNames(got); !reflect.DeepEqual(names, tt.want) {
+				t.Errorf("Select() = %v, want %v", names, tt.want)
+			}
+		})
+	}
+}
+
+func TestScriptSelector(t *testing.T) {
+	sel := ScriptSelector{Command: `test "$MICRO_DEVICE_NAME" != micro1`}
+	got, err := sel.Select(context.Background(), selectorDevices())
+	if err != nil {
+		t.Fatalf("Select() = %v", err)
+	}
+	if want := []string{"micro0", "micro2", "other0", "micro3"}; !reflect.DeepEqual(selectedNames(got), want) {
+		t.Errorf("Select() = %v, want %v", selectedNames(got), want)
+	}
+
+	if _, err := (ScriptSelector{Command: "exit 1"}).Select(context.Background(), nil); err != nil {
+		t.Errorf("Select() without candidates = %v", err)
+	}
+}
+
+func TestFindDeviceAppliesSelector(t *testing.T) {
+	labels, err := NewLabelSelector("tier=fast")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, _ := newTestServer(t, WithDiscoverer(discovery.NewStaticDiscoverer(selectorDevices())),
+		WithDevicesRegex(regexp.MustCompile(`^micro`)),
+		WithDeviceSelector(labels))
+	if err := s.findDevice(); err != nil {
+		t.Fatalf("findDevice() = %v", err)
+	}
+
+	var names []string
+	for _, info := range s.Devices() {
+		names = append(names, info.Name)
+	}
+	if want := []string{"micro0", "micro2"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("devices = %v, want %v", names, want)
+	}
+}
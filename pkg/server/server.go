@@ -2,33 +2,46 @@ package server
 
 import (
 	"context"
-	"crypto/md5"
+	"errors"
 	"fmt"
 	"log/slog"
 	"net"
+	"net/http"
 	"os"
 	"path"
 	"path/filepath"
+	"regexp"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"syscall"
 	"time"
 
-	"github.com/fsnotify/fsnotify"
+	"github.com/prometheus/client_golang/prometheus"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/reflection"
+	"google.golang.org/grpc/status"
+	"k8s.io/apimachinery/pkg/util/version"
+	"k8s.io/client-go/kubernetes"
 	deviceapi "k8s.io/kubelet/pkg/apis/deviceplugin/v1beta1"
+
+	"github.com/kelein/micro-device-plugin/pkg/config"
+	"github.com/kelein/micro-device-plugin/pkg/discovery"
+	"github.com/kelein/micro-device-plugin/pkg/state"
 )
 
 const (
-	resourceName = "micro.plugin"
-	microPath    = "/etc/micro"
-	microSocket  = "micro.sock"
+	microSocket = "micro.sock"
 
 	// KubeSocket kubelet unix socket
 	KubeSocket = "kubelet.sock"
 
 	// PluginPath device defautl path
-	PluginPath = "/var/lib/kubelet/device-plugins/"
+	PluginPath = config.DefaultPluginPath
 )
 
 const (
@@ -36,68 +49,441 @@ const (
 	maxCrashPeriod = 3600
 )
 
+// unaryStreamHeadroom is the number of concurrent unary RPCs allowed
+// per connection on top of the ListAndWatch stream limit
+const unaryStreamHeadroom = 16
+
+// ErrInitTimeout is returned by Run when the initial device discovery
+// does not finish within the init timeout
+var ErrInitTimeout = errors.New("device discovery init timeout")
+
+// validateVersion is a sentinel API version always rejected by kubelet
+const validateVersion = "validate"
+
+// MicroDevice is a micro device tracked by the server
+type MicroDevice = discovery.MicroDevice
+
+// DevicePlugin is the lifecycle of a device plugin, implemented by a
+// single server and by the sharded PluginManager
+type DevicePlugin interface {
+	Run() error
+	RegisterToKubelet() error
+	Stop()
+	Handler() http.Handler
+	Idle() <-chan struct{}
+}
+
+// Compile-time checks of the interfaces implemented by the server
+var (
+	_ deviceapi.DevicePluginServer = (*MicroDeviceServer)(nil)
+	_ DevicePlugin                 = (*MicroDeviceServer)(nil)
+)
+
 // MicroDeviceServer is a device plugin server
 type MicroDeviceServer struct {
-	devices   map[string]*deviceapi.Device
+	mu        sync.RWMutex
+	devices   map[string]*MicroDevice
 	serv      *grpc.Server
+	proxy     *grpc.Server
 	ctx       context.Context
 	cancel    context.CancelFunc
 	notify    chan bool
 	restarted bool
+
+	startTime    time.Time
+	registered   bool
+	restartCount int
+	lastError    string
+
+	devicePath   string
+	pluginPath   string
+	resourceName string
+	socketName   string
+	logger       *slog.Logger
+	registry     prometheus.Registerer
+
+	reflection     bool
+	devicesRe      *regexp.Regexp
+	udevSubsystem  string
+	grpcOpts       []grpc.ServerOption
+	claims         *ClaimStore
+	delta          bool
+	discoverer     discovery.Discoverer
+	maxDevices     int
+	resourceCount  int
+	lockTimeout    time.Duration
+	lock           *FileLock
+	affinity       CPUAffinityReader
+	preferredCPUs  map[int]bool
+	pidFile        string
+	reserveDevices int
+	systemReserve  int
+	createPath     bool
+	selector       DeviceSelector
+	affinityMap    map[string]string
+	startupProbe   *StartupProbe
+	heartbeat      *LeaseHeartbeat
+	devicesMin     int
+
+	healthInterval  time.Duration
+	deviceCountFile string
+
+	shard      int
+	shardCount int
+
+	watchdogTimeout  time.Duration
+	watchdog         *time.Timer
+	lastListAndWatch time.Time
+
+	allocMu      sync.Mutex
+	allocated    map[string]bool
+	podDevices   map[string][]string
+	deallocHooks []DeallocateFunc
+	podClient    kubernetes.Interface
+	podLookup    PodDeviceLookup
+	nodeName     string
+	dra          *DRAAdapter
+
+	arch            *ArchDetector
+	archDevicePaths map[string]string
+
+	idempotencyWindow time.Duration
+	allocateCache     sync.Map
+
+	maxStreams    int
+	activeStreams atomic.Int32
+
+	updater *Updater
+
+	metricsFile string
+	initTimeout time.Duration
+	restAPI     bool
+	conditions  *NodeConditionReporter
+	catalog     *PluginCatalog
+
+	validateOnReconnect bool
+	namespace           string
+	scorer              DeviceScorer
+	strategy            AllocationStrategy
+	events              *EventBus
+	history             eventHistory
+	lastSeen            map[string]time.Time
+	allowUnsafeIDs      bool
+	recoverPanics       bool
+	versionDetector     *KubeletVersionDetector
+	healthPolicy        DeviceHealthPolicy
+	wal                 *state.WAL
+	recursive           bool
+	fallback            discovery.Discoverer
+	pollInterval        time.Duration
+	runtime             *RuntimeAdapter
+	downwardAPI         *DownwardAPIInjector
+	warmer              DeviceWarmer
+	warmStates          map[string]warmState
+	nodeLabels          *NodeLabelManager
+	enableGRPCHealth    bool
+	notifyBuffer        int
+	attestor            Attestor
+	draining            bool
+	strictQuota         bool
+	webhook             *AllocationWebhook
+	standalone          bool
+	admission           *AdmissionController
+	gcClient            kubernetes.Interface
+	gcInterval          time.Duration
+	stateStore          state.StateStore
+	eventStore          *state.EventStore
+	resourceAliases     []string
+	allocRegistry       *AllocationRegistry
+	versionCheck        bool
+	strictVersionCheck  bool
+	supportedVersions   []string
+	registerJitter      time.Duration
+	jitterOnce          sync.Once
+	priorityAllocator   *PriorityAllocator
+	socketBacklog       int
+	seccomp             *SeccompProfileManager
+	maxIdleTime         time.Duration
+	idleTimer           *time.Timer
+	idle                chan struct{}
+	idleOnce            sync.Once
+	grpcHealth          *health.Server
+	compactInterval     time.Duration
+	kubeletVersion      *version.Version
+	kubeletFeatures     KubeletFeatures
+	panicBackoff        time.Duration
+	logDeviceIDs        bool
+	featureGates        config.FeatureGates
+	cfg                 *config.Config
+	metrics             *pluginMetrics
 }
 
-// NewMicroDeviceServer creates a new device plugin server
-func NewMicroDeviceServer() *MicroDeviceServer {
+// NewMicroDeviceServer creates a new device plugin server, it fails if
+// the server metrics cannot be registered
+func NewMicroDeviceServer(opts ...Option) (*MicroDeviceServer, error) {
 	ctx, cancel := context.WithCancel(context.Background())
-	return &MicroDeviceServer{
-		devices:   make(map[string]*deviceapi.Device),
-		serv:      grpc.NewServer(grpc.EmptyServerOption{}),
+	s := &MicroDeviceServer{
+		devices:   make(map[string]*MicroDevice),
 		ctx:       ctx,
 		cancel:    cancel,
-		notify:    make(chan bool),
 		restarted: false,
+		startTime: time.Now(),
+
+		devicePath:   config.DefaultDevicePath,
+		pluginPath:   config.DefaultPluginPath,
+		resourceName: config.DefaultResourceName,
+		socketName:   microSocket,
+		logger:       slog.Default(),
+		registry:     prometheus.DefaultRegisterer,
+		metrics:      newPluginMetrics(),
+		lockTimeout:  10 * time.Second,
+
+		healthInterval: 10 * time.Second,
+		healthPolicy:   FileExistPolicy{},
+		warmer:         NoOpWarmer{},
+		arch:           NewArchDetector(CPUInfoPath),
+
+		idempotencyWindow: 10 * time.Second,
+		initTimeout:       30 * time.Second,
+		panicBackoff:      time.Second,
+		kubeletFeatures:   FeaturesForVersion(nil),
+		supportedVersions: []string{deviceapi.Version},
+
+		allocated:  make(map[string]bool),
+		podDevices: make(map[string][]string),
+		events:     NewEventBus(),
+		lastSeen:   make(map[string]time.Time),
+		warmStates: make(map[string]warmState),
+		idle:       make(chan struct{}),
+
+		logDeviceIDs:     true,
+		enableGRPCHealth: true,
+		notifyBuffer:     10,
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	s.notify = make(chan bool, max(s.notifyBuffer, 0))
+	s.RegisterDeallocateHook(s.releaseDevices)
+	s.replayEvents()
+	s.history.subscribe(s.events)
+	s.recordEvents()
+	if !s.featureGates.IsEnabled(config.ClaimTokens) {
+		s.claims = nil
+	}
+
+	if path, ok := s.archDevicePaths[s.Architecture()]; ok {
+		s.devicePath = path
+	}
+	if s.discoverer == nil {
+		s.discoverer = &discovery.FilesystemDiscoverer{
+			Path:      s.devicePath,
+			Recursive: s.recursive,
+			Poll:      s.pollInterval,
+		}
+	}
+	if s.versionDetector == nil {
+		s.versionDetector = NewKubeletVersionDetector(s.pluginPath, "")
+	}
+	if s.namespace != "" {
+		s.socketName = namespaced(s.namespace, s.socketName)
+		if s.pidFile != "" {
+			s.pidFile = filepath.Join(filepath.Dir(s.pidFile), namespaced(s.namespace, filepath.Base(s.pidFile)))
+		}
+	}
+	// the lock file is named after the socket, micro.lock for the default
+	// socket, so that the shards and namespaces lock independently
+	base := filepath.Join(s.pluginPath, strings.TrimSuffix(s.socketName, ".sock"))
+	s.lock = NewFileLock(base + ".lock")
+	s.catalog = NewPluginCatalog(base + manifestSuffix)
+	if s.maxStreams > 0 {
+		// kubelet issues the unary RPCs on the ListAndWatch connection,
+		// leave them room next to the long running streams
+		streams := uint32(s.maxStreams) + unaryStreamHeadroom
+		s.grpcOpts = append(s.grpcOpts, grpc.MaxConcurrentStreams(streams))
+	}
+	if s.enableGRPCHealth {
+		s.grpcHealth = health.NewServer()
+		s.updateGRPCHealth()
+	}
+	s.serv = s.newGRPCServer()
+	if err := s.registerMetrics(); err != nil {
+		s.cancel()
+		return nil, err
+	}
+	if s.priorityAllocator != nil {
+		s.SafeGo("priority-allocator", func() { s.priorityAllocator.Run(s.ctx) })
+	}
+	return s, nil
+}
+
+// namespaced prefixes the file name with the plugin namespace
+func namespaced(namespace, name string) string {
+	return namespace + "-" + name
+}
+
+// NamespacedStateDir returns the state directory of the plugin namespace
+// in dir so that the state files of the namespaces never collide
+func NamespacedStateDir(dir, namespace string) string {
+	if namespace == "" {
+		return dir
 	}
+	return filepath.Join(dir, namespace)
 }
 
 // Run starts the micro device plugin server
 func (s *MicroDeviceServer) Run() error {
-	if err := s.findDevice(); err != nil {
-		slog.Error("find device failed", "err", err)
+	if err := s.lock.Acquire(s.lockTimeout); err != nil {
+		s.setError(err)
 		return err
 	}
+	if s.pidFile != "" {
+		if err := WritePIDFile(s.pidFile); err != nil {
+			s.setError(err)
+			return err
+		}
+	}
 
-	go func() {
+	if err := s.waitStartupProbe(); err != nil {
+		s.logger.Error("startup probe failed", "err", err)
+		s.setError(err)
+		return err
+	}
+	s.createDevicePath()
+	if err := s.initDevices(); err != nil {
+		s.logger.Error("find device failed", "err", err)
+		s.setError(err)
+		return err
+	}
+	if err := s.checkQuota(); err != nil {
+		s.logger.Error("check device quota failed", "err", err)
+		s.setError(err)
+		return err
+	}
+	s.restoreState()
+	s.startIdleTimer()
+
+	s.SafeGo("watchDevice", func() {
 		err := s.watchDevice()
 		if err != nil {
-			slog.Error("watch device failed", "err", err)
+			s.logger.Error("watch device failed", "err", err)
 		}
-	}()
+	})
+
+	s.writeDeviceCount()
+	s.writeMetricsFile()
+	s.writeManifest()
+	if s.healthInterval > 0 {
+		s.SafeGo("healthCheck", s.healthCheck)
+	}
+
+	if s.wal != nil && s.compactInterval > 0 {
+		s.SafeGo("compactState", s.compactState)
+	}
+
+	if s.wal != nil && s.gcClient != nil && s.gcInterval > 0 {
+		s.SafeGo("collectState", s.collectState)
+	}
+
+	if s.nodeLabels != nil {
+		s.SafeGo("nodeLabels", func() { s.nodeLabels.Run(s.ctx, s.deviceInventory) })
+	}
+
+	if s.heartbeat != nil {
+		s.SafeGo("heartbeat", func() { s.heartbeat.Run(s.ctx) })
+	}
+
+	if s.podClient != nil {
+		s.SafeGo("watchPods", s.watchPods)
+	}
+
+	if s.dra != nil {
+		s.SafeGo("dra", func() { s.dra.Run(s.ctx) })
+	}
+
+	if s.admission != nil {
+		s.SafeGo("admission", func() { s.admission.Serve(s.ctx, s.logger) })
+	}
+
+	if s.udevSubsystem != "" {
+		s.SafeGo("watchUdev", func() {
+			err := s.watchUdev()
+			if err != nil {
+				s.logger.Error("watch udev failed", "err", err)
+			}
+		})
+	}
+
+	if s.standalone {
+		s.logger.Info("standalone mode, the device plugin API is not served")
+		return nil
+	}
+	if s.reflection {
+		s.logger.Info("gRPC server reflection enabled")
+	}
+	s.mu.RLock()
+	serv := s.serv
+	s.mu.RUnlock()
+	if err := s.serve(serv); err != nil {
+		return err
+	}
+	if s.delta {
+		if err := s.serveDeltaProxy(); err != nil {
+			return err
+		}
+	}
+
+	if err := s.watchSocket(); err != nil {
+		s.logger.Error("watch plugin socket failed", "err", err)
+	}
+	return nil
+}
+
+// newGRPCServer creates the gRPC server of the device plugin API
+func (s *MicroDeviceServer) newGRPCServer() *grpc.Server {
+	opts := []grpc.ServerOption{
+		grpc.ChainUnaryInterceptor(unaryRequestID, unaryTrace, s.unaryRecover),
+		grpc.ChainStreamInterceptor(streamRequestID, streamTrace, s.streamRecover),
+	}
+	serv := grpc.NewServer(append(opts, s.grpcOpts...)...)
+	deviceapi.RegisterDevicePluginServer(serv, s)
+	if s.grpcHealth != nil {
+		healthpb.RegisterHealthServer(serv, s.grpcHealth)
+	}
+	if s.reflection {
+		reflection.Register(serv)
+	}
+	return serv
+}
 
-	deviceapi.RegisterDevicePluginServer(s.serv, s)
-	err := syscall.Unlink(PluginPath + microSocket)
+// serve listens on the plugin socket and serves serv until it is
+// stopped, restarting it when it crashes
+func (s *MicroDeviceServer) serve(serv *grpc.Server) error {
+	err := syscall.Unlink(s.socketPath())
 	if err != nil && !os.IsNotExist(err) {
 		return err
 	}
 
-	listener, err := net.Listen("unix", PluginPath+microSocket)
+	listener, err := s.listen()
 	if err != nil {
 		return err
 	}
 
-	go func() {
+	s.SafeGo("serve", func() {
 		startTime := time.Now()
 		restartNum := 0
 		for {
-			slog.Info("starting RPC server", "resource", resourceName)
-			err = s.serv.Serve(listener)
+			s.logger.Info("starting RPC server", "resource", s.resourceName)
+			err := serv.Serve(listener)
 			if err == nil {
 				break
 			}
 
-			slog.Info("RPC server crashed", "resource", resourceName, "err", err)
+			s.logger.Info("RPC server crashed", "resource", s.resourceName, "err", err)
+			s.setError(err)
 
 			if restartNum > maxRestartNum {
-				slog.Error("micro device plugin has repeatedly crashed recently. Quitting")
+				s.logger.Error("micro device plugin has repeatedly crashed recently. Quitting")
 			}
 
 			crashSeconds := time.Since(startTime).Seconds()
@@ -106,10 +492,11 @@ func (s *MicroDeviceServer) Run() error {
 			} else {
 				restartNum++
 			}
+			s.setRestartCount(restartNum)
 		}
-	}()
+	})
 
-	conn, err := s.dial(microSocket, time.Second*5)
+	conn, err := s.dial(s.socketPath(), time.Second*5)
 	if err != nil {
 		return err
 	}
@@ -117,9 +504,45 @@ func (s *MicroDeviceServer) Run() error {
 	return nil
 }
 
+// Stop stops the micro device plugin server and releases its resources
+func (s *MicroDeviceServer) Stop() {
+	s.logger.Info("stopping micro device plugin ...")
+	s.cancel()
+	if s.grpcHealth != nil {
+		s.grpcHealth.Shutdown()
+	}
+	s.mu.Lock()
+	serv := s.serv
+	proxy := s.proxy
+	if s.watchdog != nil {
+		s.watchdog.Stop()
+	}
+	s.mu.Unlock()
+	if proxy != nil {
+		proxy.Stop()
+	}
+	serv.Stop()
+	if s.wal != nil {
+		s.compact()
+	}
+	if err := s.lock.Release(); err != nil {
+		s.logger.Error("release plugin lock failed", "err", err)
+	}
+	if s.pidFile != "" {
+		if err := RemovePIDFile(s.pidFile); err != nil {
+			s.logger.Error("remove pid file failed", "path", s.pidFile, "err", err)
+		}
+	}
+	if err := s.catalog.Remove(); err != nil {
+		s.logger.Error("remove plugin manifest failed", "err", err)
+	}
+}
+
 // RegisterToKubelet registers the micro device plugin with kubelet
 func (s *MicroDeviceServer) RegisterToKubelet() error {
-	sockFile := filepath.Join(PluginPath + KubeSocket)
+	s.waitRegisterJitter()
+	s.detectKubeletVersion()
+	sockFile := filepath.Join(s.pluginPath, KubeSocket)
 	conn, err := s.dial(sockFile, time.Second*5)
 	if err != nil {
 		return err
@@ -127,63 +550,194 @@ func (s *MicroDeviceServer) RegisterToKubelet() error {
 	defer conn.Close()
 
 	client := deviceapi.NewRegistrationClient(conn)
+	if err := s.checkAPIVersion(client); err != nil {
+		s.logger.Error("kubelet version check failed", "err", err)
+		s.setError(err)
+		return err
+	}
 	req := &deviceapi.RegisterRequest{
 		Version:      deviceapi.Version,
-		Endpoint:     path.Base(PluginPath + microSocket),
-		ResourceName: resourceName,
+		Endpoint:     path.Base(s.endpointPath()),
+		ResourceName: s.resourceName,
 	}
-	slog.Info("Register plugin to kubelet", "endpoint", req.Endpoint)
+	s.logger.Info("Register plugin to kubelet", "endpoint", req.Endpoint)
 	_, err = client.Register(context.Background(), req)
 	if err != nil {
+		s.setError(err)
 		return err
 	}
+
+	s.mu.Lock()
+	s.registered = true
+	s.mu.Unlock()
+	s.startWatchdog()
+	s.reportCondition()
+	s.updateGRPCHealth()
+	return nil
+}
+
+// ValidateKubelet checks the kubelet registration service is reachable
+// without actually registering the micro device plugin
+func (s *MicroDeviceServer) ValidateKubelet() error {
+	sockFile := filepath.Join(s.pluginPath, KubeSocket)
+	conn, err := s.dial(sockFile, time.Second*5)
+	if err != nil {
+		return fmt.Errorf("dial kubelet %s: %w", sockFile, err)
+	}
+	defer conn.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+
+	client := deviceapi.NewRegistrationClient(conn)
+	req := &deviceapi.RegisterRequest{
+		Version:      validateVersion,
+		Endpoint:     path.Base(s.endpointPath()),
+		ResourceName: s.resourceName,
+	}
+	_, err = client.Register(ctx, req)
+	switch status.Code(err) {
+	case codes.OK:
+		s.logger.Warn("kubelet accepted validate sentinel version", "version", validateVersion)
+	case codes.Unavailable, codes.DeadlineExceeded, codes.Canceled:
+		return fmt.Errorf("kubelet %s unreachable: %w", sockFile, err)
+	default:
+		s.logger.Info("kubelet rejected validate sentinel as expected", "err", err)
+	}
 	return nil
 }
 
 // Allocate make the device avilable in container
 func (s *MicroDeviceServer) Allocate(ctx context.Context, reqs *deviceapi.AllocateRequest) (*deviceapi.AllocateResponse, error) {
+	if s.priorityAllocator == nil {
+		return s.allocate(ctx, reqs)
+	}
+	var resp *deviceapi.AllocateResponse
+	err := status.Error(codes.Aborted, "allocation aborted")
+	p := AllocationPriority(ctx)
+	if qerr := s.priorityAllocator.Do(ctx, p, func() { resp, err = s.allocate(ctx, reqs) }); qerr != nil {
+		s.requestLogger(ctx).Warn("allocation dropped from the priority queue", "priority", p, "err", qerr)
+		return nil, status.Errorf(codes.Unavailable, "allocation dropped from the priority queue: %v", qerr)
+	}
+	return resp, err
+}
+
+// allocate allocates the devices of the request
+func (s *MicroDeviceServer) allocate(ctx context.Context, reqs *deviceapi.AllocateRequest) (*deviceapi.AllocateResponse, error) {
+	logger := s.requestLogger(ctx)
+	if cached, resp := s.deduplicateAllocate(reqs); cached {
+		logger.Info("return cached allocate response", "containers", len(reqs.ContainerRequests))
+		return resp, nil
+	}
+	if s.webhook != nil {
+		approved, reason, err := s.webhook.Review(ctx, reqs)
+		if err != nil {
+			logger.Error("allocation webhook review failed", "err", err)
+			return nil, status.Errorf(codes.Unavailable, "allocation webhook review failed: %v", err)
+		}
+		if !approved {
+			logger.Warn("allocation denied by webhook", "reason", reason)
+			return nil, status.Errorf(codes.PermissionDenied, "allocation denied: %s", reason)
+		}
+	}
+
+	if err := s.acquireDevices(reqs.ContainerRequests); err != nil {
+		logger.Warn("reject allocation held by a resource alias", "err", err)
+		return nil, status.Errorf(codes.FailedPrecondition, "%v", err)
+	}
+
 	result := &deviceapi.AllocateResponse{}
 	for _, req := range reqs.ContainerRequests {
-		slog.Info("received request", "data", req)
+		logger.Info("received request", "devices", s.logIDs(req.DevicesIDs))
+		s.events.Publish(DeviceEvent{Type: AllocationStarted, DeviceIDs: req.DevicesIDs, RequestID: RequestID(ctx)})
 		resp := deviceapi.ContainerAllocateResponse{
 			Envs: map[string]string{
-				"MICRO_DEVICES": strings.Join(req.DevicesIDs, ","),
+				"MICRO_DEVICES":   strings.Join(req.DevicesIDs, ","),
+				"MICRO_NODE_ARCH": s.Architecture(),
 			},
 		}
+		for k, v := range xattrEnvs(s.deviceAnnotations(req.DevicesIDs)) {
+			resp.Envs[k] = v
+		}
+		if s.claims != nil {
+			resp.Envs["MICRO_DEVICE_TOKEN"] = s.claims.Issue(req.DevicesIDs)
+		}
+		if s.featureGates.IsEnabled(config.CDIDeviceSpecs) && s.KubeletFeatures().CDI {
+			resp.CDIDevices = s.cdiDevices(req.DevicesIDs)
+		}
+		if s.downwardAPI != nil {
+			s.downwardAPI.Inject(&resp)
+		}
+		if s.seccomp != nil {
+			if err := s.seccomp.Inject(&resp, s.deviceNames(req.DevicesIDs)); err != nil {
+				logger.Error("inject seccomp profiles failed", "err", err)
+			}
+		}
+		if s.runtime != nil {
+			s.runtime.Adapt(&resp)
+		}
+		s.markAllocated(req.DevicesIDs)
+		s.events.Publish(DeviceEvent{Type: AllocationCompleted, DeviceIDs: req.DevicesIDs, RequestID: RequestID(ctx)})
 		result.ContainerResponses = append(result.ContainerResponses, &resp)
 	}
+	s.cacheAllocate(reqs, result)
 	return result, nil
 }
 
 // ListAndWatch return a stream of list devices and update that stream whenever changes
 func (s *MicroDeviceServer) ListAndWatch(e *deviceapi.Empty, srv deviceapi.DevicePlugin_ListAndWatchServer) error {
-	slog.Info("ListAndWatch started")
-	devs := make([]*deviceapi.Device, len(s.devices))
-	i := 0
-	for _, dev := range s.devices {
-		devs[i] = dev
-		i++
+	logger := s.requestLogger(srv.Context())
+	n := s.activeStreams.Add(1)
+	defer func() {
+		s.metrics.activeStreams.Set(float64(s.activeStreams.Add(-1)))
+	}()
+	if s.maxStreams > 0 && int(n) > s.maxStreams {
+		logger.Warn("reject ListAndWatch over the stream limit", "limit", s.maxStreams)
+		return status.Errorf(codes.ResourceExhausted, "ListAndWatch streams limited to %d", s.maxStreams)
+	}
+	s.metrics.activeStreams.Set(float64(n))
+
+	logger.Info("ListAndWatch started")
+	s.pauseWatchdog()
+	defer s.startWatchdog()
+
+	if s.validateOnReconnect {
+		s.reconcileDevices()
 	}
-	err := srv.Send(&deviceapi.ListAndWatchResponse{Devices: devs})
+
+	last := s.deviceList()
+	err := srv.Send(&deviceapi.ListAndWatchResponse{Devices: last})
 	if err != nil {
-		slog.Error("ListAndWatch send device failed", "error", err)
+		logger.Error("ListAndWatch send device failed", "error", err)
 		return err
 	}
 
 	for {
-		slog.Info("waiting for device change ...")
+		logger.Info("waiting for device change ...")
 		select {
 		case <-s.notify:
-			slog.Info("device change detected", "num", len(s.devices))
-			devs := make([]*deviceapi.Device, len(s.devices))
-			i := 0
-			for _, dev := range s.devices {
-				devs[i] = dev
-				i++
+			devs := s.deviceList()
+			resp := &deviceapi.ListAndWatchResponse{Devices: devs}
+			if s.delta {
+				delta := DiffDevices(last, devs)
+				last = devs
+				if delta.Empty() {
+					logger.Info("no device delta detected")
+					continue
+				}
+				logger.Info("device delta detected", "changed", delta.Size())
+				resp = EncodeDelta(delta)
+			}
+			logger.Info("device change detected", "num", len(devs))
+			if err := srv.Send(resp); err != nil {
+				logger.Error("ListAndWatch send device failed", "error", err)
+				return err
 			}
-			srv.Send(&deviceapi.ListAndWatchResponse{Devices: devs})
+		case <-srv.Context().Done():
+			logger.Info("ListAndWatch stream closed by kubelet")
+			return srv.Context().Err()
 		case <-s.ctx.Done():
-			slog.Info("ListAndWatch exited")
+			logger.Info("ListAndWatch exited")
 			return nil
 		}
 	}
@@ -191,104 +745,344 @@ func (s *MicroDeviceServer) ListAndWatch(e *deviceapi.Empty, srv deviceapi.Devic
 
 // GetDevicePluginOptions return options for the device plugin
 func (s *MicroDeviceServer) GetDevicePluginOptions(context.Context, *deviceapi.Empty) (*deviceapi.DevicePluginOptions, error) {
-	return &deviceapi.DevicePluginOptions{PreStartRequired: true}, nil
+	features := s.KubeletFeatures()
+	opts := &deviceapi.DevicePluginOptions{PreStartRequired: true}
+	if features.PluginOptionsV2 {
+		opts.GetPreferredAllocationAvailable = features.PreferredAllocation && (s.deviceScorer() != nil || s.strategy != nil || s.affinityMap != nil)
+	}
+	return opts, nil
 }
 
 // GetPreferredAllocation return the devices chosen for allocation based on the given options
-func (s *MicroDeviceServer) GetPreferredAllocation(context.Context, *deviceapi.PreferredAllocationRequest) (*deviceapi.PreferredAllocationResponse, error) {
-	slog.Info("GetPreferredAllocation executed")
-	return nil, nil
+func (s *MicroDeviceServer) GetPreferredAllocation(ctx context.Context, reqs *deviceapi.PreferredAllocationRequest) (*deviceapi.PreferredAllocationResponse, error) {
+	s.requestLogger(ctx).Info("GetPreferredAllocation executed")
+	result := &deviceapi.PreferredAllocationResponse{}
+	for _, req := range reqs.ContainerRequests {
+		result.ContainerResponses = append(result.ContainerResponses,
+			&deviceapi.ContainerPreferredAllocationResponse{DeviceIDs: s.preferredDevices(ctx, req)},
+		)
+	}
+	return result, nil
 }
 
-// PreStartContainer is called during the device plugin pod starting
-func (s *MicroDeviceServer) PreStartContainer(context.Context, *deviceapi.PreStartContainerRequest) (*deviceapi.PreStartContainerResponse, error) {
-	slog.Info("PreStartContainer executed")
+// PreStartContainer is called during the device plugin pod starting, it
+// fails if an allocated device is unhealthy under the health policy
+func (s *MicroDeviceServer) PreStartContainer(ctx context.Context, req *deviceapi.PreStartContainerRequest) (*deviceapi.PreStartContainerResponse, error) {
+	logger := s.requestLogger(ctx)
+	logger.Info("PreStartContainer executed", "devices", s.logIDs(req.DevicesIDs))
+
+	wanted := make(map[string]bool, len(req.DevicesIDs))
+	for _, id := range req.DevicesIDs {
+		wanted[id] = true
+	}
+	s.mu.RLock()
+	var checked []MicroDevice
+	var cold []string
+	for _, dev := range s.devices {
+		if wanted[dev.ID] && !s.isWarm(dev.Name) {
+			cold = append(cold, dev.Name)
+		}
+		if wanted[dev.ID] && dev.Path != "" {
+			checked = append(checked, *dev)
+		}
+	}
+	s.mu.RUnlock()
+
+	if len(cold) > 0 {
+		logger.Warn("reject container start with cold devices", "names", cold)
+		return nil, status.Errorf(codes.FailedPrecondition, "devices %s are not warm", strings.Join(cold, ","))
+	}
+
+	for i := range checked {
+		if s.checkDevice(&checked[i]) != deviceapi.Healthy {
+			logger.Warn("reject container start with unhealthy device", "name", checked[i].Name)
+			return nil, status.Errorf(codes.FailedPrecondition, "device %s is unhealthy", checked[i].Name)
+		}
+	}
 	return &deviceapi.PreStartContainerResponse{}, nil
 }
 
 // findDevice discovers the micro devices on machine
 func (s *MicroDeviceServer) findDevice() error {
-	dir, err := os.ReadDir(microPath)
+	devices, err := s.discoverer.Discover()
+	if (err != nil || len(devices) == 0) && s.fallback != nil {
+		s.logger.Warn("no micro devices discovered, use fallback discoverer", "err", err)
+		s.discoverer = s.fallback
+		devices, err = s.discoverer.Discover()
+	}
 	if err != nil {
-		slog.Error("failed to read micro path", "err", err)
+		s.logger.Error("failed to discover micro devices", "err", err)
 		return err
 	}
-	for _, f := range dir {
-		if f.IsDir() {
+	var matched []*MicroDevice
+	for _, dev := range devices {
+		if !s.matchDevice(dev.Name) {
+			s.logger.Info("skip unmatched device", "name", dev.Name)
 			continue
 		}
-		byteID := md5.Sum([]byte(f.Name()))
-		id := string(byteID[:])
-		s.devices[f.Name()] = &deviceapi.Device{
-			ID:     id,
-			Health: deviceapi.Healthy,
-		}
-		slog.Info("find device", "name", f.Name(), "ID", id)
+		matched = append(matched, dev)
+	}
+	selected, err := s.applySelector(matched)
+	if err != nil {
+		s.logger.Error("select micro devices failed", "err", err)
+		return err
+	}
+	for _, dev := range selected {
+		id := s.addDevice(dev)
+		s.logger.Info("find device", "name", dev.Name, "ID", s.logID(id))
 	}
 	return nil
 }
 
-func (s *MicroDeviceServer) watchDevice() error {
-	slog.Info("watching micro devices ...")
-	w, err := fsnotify.NewWatcher()
-	if err != nil {
-		return fmt.Errorf("fsnotify NewWatcher error: %w", err)
+// Architecture returns the node architecture
+func (s *MicroDeviceServer) Architecture() string {
+	return s.arch.Architecture()
+}
+
+// initDevices runs the initial device discovery, giving up with
+// ErrInitTimeout if it does not finish within the init timeout
+func (s *MicroDeviceServer) initDevices() error {
+	if s.initTimeout <= 0 {
+		return s.findDevice()
 	}
-	defer w.Close()
 
-	done := make(chan bool)
-	go func() {
-		defer func() {
-			done <- true
-			slog.Info("watch device exit")
-		}()
+	ctx, cancel := context.WithTimeout(s.ctx, s.initTimeout)
+	defer cancel()
+	done := make(chan error, 1)
+	s.SafeGo("findDevice", func() {
+		done <- s.findDevice()
+	})
 
-		for {
-			select {
-			case event, ok := <-w.Events:
-				if !ok {
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		s.logger.Error("device discovery timed out", "path", s.devicePath, "timeout", s.initTimeout)
+		return ErrInitTimeout
+	}
+}
+
+// createDevicePath creates the missing device directory if enabled, a
+// failure is only logged so that the discovery reports the original error
+func (s *MicroDeviceServer) createDevicePath() {
+	if !s.createPath {
+		return
+	}
+	if _, err := os.Stat(s.devicePath); !errors.Is(err, os.ErrNotExist) {
+		return
+	}
+	if err := os.MkdirAll(s.devicePath, 0755); err != nil {
+		s.logger.Error("create device path failed", "path", s.devicePath, "err", err)
+		return
+	}
+	s.logger.Warn("device path missing, created it", "path", s.devicePath)
+}
+
+// matchDevice reports whether the device file name passes the filters
+func (s *MicroDeviceServer) matchDevice(name string) bool {
+	if s.devicesRe != nil && !s.devicesRe.MatchString(name) {
+		return false
+	}
+	if s.attestor != nil && strings.HasSuffix(name, SignatureSuffix) {
+		return false
+	}
+	if strings.HasSuffix(name, discovery.CapabilitiesSuffix) {
+		return false
+	}
+	if s.shardCount > 1 && ShardOf(name, s.shardCount) != s.shard {
+		return false
+	}
+	return true
+}
+
+func (s *MicroDeviceServer) watchDevice() error {
+	s.logger.Info("watching micro devices ...")
+	events := make(chan discovery.DiscoveryEvent)
+	errCh := make(chan error, 1)
+	s.SafeGo("discoverer", func() {
+		errCh <- s.discoverer.Watch(s.ctx, events)
+	})
+
+	for {
+		select {
+		case event := <-events:
+			dev := event.Device
+			switch event.Type {
+			case discovery.DeviceCreated:
+				if !s.matchDevice(dev.Name) {
+					s.logger.Info("skip unmatched device", "name", dev.Name)
 					continue
 				}
-				slog.Info("device event", "kind", event.Op.String())
-
-				if event.Op&fsnotify.Create == fsnotify.Create {
-					byteID := md5.Sum([]byte(event.Name))
-					id := string(byteID[:])
-					s.devices[event.Name] = &deviceapi.Device{
-						ID:     id,
-						Health: deviceapi.Healthy,
-					}
-					slog.Info("found new micro device ", "name", event.Name, "id", id)
+				selected, err := s.applySelector([]*MicroDevice{dev})
+				if err != nil {
+					s.logger.Error("select micro device failed", "name", dev.Name, "err", err)
+					continue
 				}
-
-				if event.Op&fsnotify.Remove == fsnotify.Remove {
-					delete(s.devices, event.Name)
-					s.notify <- true
-					slog.Info("device deleted", "name", event.Name)
+				if len(selected) == 0 {
+					s.logger.Info("skip unselected device", "name", dev.Name)
+					continue
 				}
+				s.addDevice(selected[0])
+				s.notifyChange()
+			case discovery.DeviceRemoved:
+				s.removeDevice(dev.Name)
+			}
 
-			case err, ok := <-w.Errors:
-				if !ok {
-					return
-				}
-				slog.Error("watcher", "err", err)
+		case err := <-errCh:
+			s.logger.Info("watch device exit")
+			return err
+		}
+	}
+}
 
-			case <-s.ctx.Done():
-				break
-			}
+// addDevice adds a new discovered device to the device map
+func (s *MicroDeviceServer) addDevice(dev *MicroDevice) string {
+	if dev.ID == "" {
+		dev.ID = deviceID(dev.Name)
+	}
+	if err := ValidateDeviceID(dev.ID); err != nil {
+		if !s.allowUnsafeIDs {
+			s.logger.Error("invalid device id, skip device", "name", dev.Name, "id", s.logID(dev.ID), "err", err)
+			return dev.ID
 		}
-	}()
+		s.logger.Warn("unsafe device id allowed", "name", dev.Name, "id", s.logID(dev.ID), "err", err)
+	}
+	if dev.Health == "" {
+		dev.Health = deviceapi.Healthy
+	}
+	if dev.Annotations == nil {
+		dev.Annotations = make(map[string]string)
+	}
 
-	if err := w.Add(microPath); err != nil {
-		return fmt.Errorf("watch device error: %w", err)
+	if dev.Path != "" && s.featureGates.IsEnabled(config.XattrMetadata) {
+		attrs, err := XattrReader{}.Read(dev.Path)
+		if err != nil {
+			s.logger.Warn("read device xattr failed", "name", dev.Name, "err", err)
+		}
+		for k, v := range xattrAnnotations(attrs) {
+			dev.Annotations[k] = v
+		}
 	}
-	<-done
+	s.loadCapabilities(dev)
+	s.attest(dev)
+	s.applyAffinityGroup(dev)
 
-	return nil
+	s.mu.Lock()
+	_, exists := s.devices[dev.Name]
+	if !exists && s.maxDevices > 0 && len(s.devices) >= s.maxDevices {
+		s.mu.Unlock()
+		s.logger.Warn("max devices reached, skip device", "name", dev.Name, "max", s.maxDevices)
+		return dev.ID
+	}
+	if !exists {
+		s.startWarm(dev)
+	} else if !s.isWarm(dev.Name) {
+		dev.Health = deviceapi.Unhealthy
+	}
+	s.devices[dev.Name] = dev
+	s.lastSeen[dev.Name] = time.Now()
+	s.applyReservations()
+	s.mu.Unlock()
+	s.writeDeviceCount()
+	s.writeMetricsFile()
+	s.updateGRPCHealth()
+	s.events.Publish(DeviceEvent{Type: DeviceAdded, Device: dev})
+	s.logger.Info("found new micro device ", "name", dev.Name, "id", s.logID(dev.ID))
+	return dev.ID
+}
+
+// removeDevice deletes a device from the device map and notifies kubelet
+func (s *MicroDeviceServer) removeDevice(name string) {
+	s.deleteDevice(name)
+	s.notifyChange()
+}
+
+// notifyChange notifies ListAndWatch of a device change without blocking,
+// the notification is dropped if the buffer is full since a pending one
+// already sends the current device list
+func (s *MicroDeviceServer) notifyChange() {
+	select {
+	case s.notify <- true:
+	default:
+		s.metrics.notifyDropped.Inc()
+	}
+}
+
+// deleteDevice deletes a device from the device map
+func (s *MicroDeviceServer) deleteDevice(name string) {
+	s.mu.Lock()
+	dev, ok := s.devices[name]
+	delete(s.devices, name)
+	delete(s.lastSeen, name)
+	delete(s.warmStates, name)
+	s.applyReservations()
+	s.mu.Unlock()
+	s.writeDeviceCount()
+	s.writeMetricsFile()
+	s.updateGRPCHealth()
+	if ok {
+		s.events.Publish(DeviceEvent{Type: DeviceRemoved, Device: dev})
+	}
+	s.logger.Info("device deleted", "name", name)
+}
+
+// deviceAnnotations returns annotations of the devices with given IDs
+func (s *MicroDeviceServer) deviceAnnotations(ids []string) []map[string]string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	wanted := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		wanted[id] = true
+	}
+	var annos []map[string]string
+	for _, dev := range s.devices {
+		if wanted[dev.ID] {
+			annos = append(annos, dev.Annotations)
+		}
+	}
+	return annos
+}
+
+// deviceList returns a snapshot of the devices for kubelet, system
+// reserved devices are left out
+func (s *MicroDeviceServer) deviceList() []*deviceapi.Device {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	devs := make([]*deviceapi.Device, 0, len(s.devices))
+	for _, dev := range s.devices {
+		if isSystemReserved(dev) {
+			continue
+		}
+		d := allocatableDevice(dev)
+		if s.draining {
+			d.Health = deviceapi.Unhealthy
+		}
+		devs = append(devs, d)
+	}
+	return devs
+}
+
+// listen listens on the plugin socket with the configured backlog
+func (s *MicroDeviceServer) listen() (net.Listener, error) {
+	if s.socketBacklog > 0 {
+		return ListenWithBacklog("unix", s.socketPath(), s.socketBacklog)
+	}
+	return net.Listen("unix", s.socketPath())
+}
+
+// socketPath returns the unix socket path of the plugin
+func (s *MicroDeviceServer) socketPath() string {
+	return filepath.Join(s.pluginPath, s.socketName)
 }
 
 func (s *MicroDeviceServer) dial(unixSocketPath string, timeout time.Duration) (*grpc.ClientConn, error) {
-	return grpc.NewClient(unixSocketPath,
+	return dialUnix(unixSocketPath, timeout)
+}
+
+// dialUnix creates a gRPC client connection to the unix socket
+func dialUnix(unixSocketPath string, timeout time.Duration) (*grpc.ClientConn, error) {
+	return grpc.NewClient("passthrough:///"+unixSocketPath,
 		grpc.WithTransportCredentials(insecure.NewCredentials()),
 		grpc.WithContextDialer(func(ctx context.Context, addr string) (net.Conn, error) {
 			return net.DialTimeout("unix", addr, timeout)
@@ -0,0 +1,127 @@
+//go:build integration
+
+package server
+
+import (
+	"os"
+	"testing"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	deviceapi "k8s.io/kubelet/pkg/apis/deviceplugin/v1beta1"
+
+	"github.com/kelein/micro-device-plugin/pkg/testutil"
+)
+
+func TestRegisterToKubeletFake(t *testing.T) {
+	kubeletErr := status.Error(codes.InvalidArgument, "resource already registered")
+	tests := []struct {
+		name    string
+		err     error // precondition: the error returned by kubelet
+		wantErr bool
+	}{
+		{name: "accepted"},
+		{name: "rejected", err: kubeletErr, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			kubelet, err := testutil.NewFakeKubelet(dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			t.Cleanup(kubelet.Stop)
+			kubelet.SetError(tt.err)
+			s, _ := newTestServer(t, WithPluginPath(dir), WithResourceName("example.com/micro"))
+
+			// action: register the plugin with the fake kubelet
+			err = s.RegisterToKubelet()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("RegisterToKubelet() error = %v, want error %v", err, tt.wantErr)
+			}
+			if tt.wantErr && status.Code(err) != status.Code(tt.err) {
+				t.Errorf("RegisterToKubelet() error = %v, want %v", err, tt.err)
+			}
+
+			reqs := kubelet.Requests()
+			if len(reqs) != 1 || reqs[0].ResourceName != "example.com/micro" || reqs[0].Version != deviceapi.Version {
+				t.Errorf("kubelet requests = %v, want one example.com/micro request", reqs)
+			}
+			s.mu.RLock()
+			registered := s.registered
+			s.mu.RUnlock()
+			if registered == tt.wantErr {
+				t.Errorf("registered = %v after error %v", registered, err)
+			}
+			if last := s.Status().LastError; tt.wantErr && last != err.Error() {
+				t.Errorf("status last error = %q, want %q", last, err)
+			}
+		})
+	}
+}
+
+func TestRun(t *testing.T) {
+	tests := []struct {
+		name    string
+		files   []string // precondition: device files of the directory
+		opts    []Option
+		want    int // expected: number of advertised devices
+		wantErr bool
+	}{
+		{name: "devices discovered", files: []string{"micro0", "micro1"}, want: 2},
+		{name: "max devices", files: []string{"micro0", "micro1"}, opts: []Option{WithMaxDevices(1)}, want: 1},
+		{name: "strict quota unmet", opts: []Option{WithDevicesMin(1), WithStrictQuota(true)}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			devDir := t.TempDir()
+			createDevices(t, devDir, tt.files...)
+			opts := append([]Option{WithDevicePath(devDir), WithHealthInterval(0)}, tt.opts...)
+			s, _ := newTestServer(t, opts...)
+
+			// action: start the plugin and its socket
+			err := s.Run()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Run() error = %v, want error %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if got := len(s.deviceList()); got != tt.want {
+				t.Errorf("advertised %d devices, want %d", got, tt.want)
+			}
+			if _, err := os.Stat(s.socketPath()); err != nil {
+				t.Errorf("plugin socket not created: %v", err)
+			}
+		})
+	}
+}
+
+func TestValidateKubelet(t *testing.T) {
+	tests := []struct {
+		name    string
+		kubelet bool // precondition: a kubelet listens on the plugin path
+		wantErr bool
+	}{
+		{name: "kubelet rejects the sentinel", kubelet: true},
+		{name: "kubelet unreachable", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			if tt.kubelet {
+				kubelet, err := testutil.NewFakeKubelet(dir)
+				if err != nil {
+					t.Fatal(err)
+				}
+				t.Cleanup(kubelet.Stop)
+				kubelet.SetSupportedVersions(deviceapi.Version)
+			}
+			s, _ := newTestServer(t, WithPluginPath(dir))
+
+			if err := s.ValidateKubelet(); (err != nil) != tt.wantErr {
+				t.Errorf("ValidateKubelet() error = %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
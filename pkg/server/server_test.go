@@ -0,0 +1,340 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	deviceapi "k8s.io/kubelet/pkg/apis/deviceplugin/v1beta1"
+
+	"github.com/kelein/micro-device-plugin/pkg/discovery"
+	"github.com/kelein/micro-device-plugin/pkg/testing/assert"
+	"github.com/kelein/micro-device-plugin/pkg/testutil"
+)
+
+// newPluginClient serves the device plugin service of s on an in-memory
+// connection and returns its client
+func newPluginClient(t *testing.T, s *MicroDeviceServer) deviceapi.DevicePluginClient {
+	t.Helper()
+	return dialBufconn(t, s.serv)
+}
+
+// dialBufconn serves serv on an in-memory connection and returns the
+// device plugin client of the connection
+func dialBufconn(t *testing.T, serv *grpc.Server) deviceapi.DevicePluginClient {
+	t.Helper()
+	return deviceapi.NewDevicePluginClient(bufconnConn(t, serv))
+}
+
+// bufconnConn serves serv on an in-memory connection and returns the
+// client connection
+func bufconnConn(t *testing.T, serv *grpc.Server) *grpc.ClientConn {
+	t.Helper()
+	lis := bufconn.Listen(1 << 20)
+	go serv.Serve(lis)
+	conn, err := grpc.NewClient("passthrough:///bufconn",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return lis.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+// createDevices creates the device files of names under dir
+func createDevices(t *testing.T, dir string, names ...string) {
+	t.Helper()
+	for _, name := range names {
+		if err := os.WriteFile(filepath.Join(dir, name), nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestFindDevice(t *testing.T) {
+	tests := []struct {
+		name    string
+		files   []string // precondition: device files of the directory
+		missing bool     // precondition: the directory does not exist
+		want    []string // expected: names of the discovered devices
+		wantErr bool
+	}{
+		{name: "no files"},
+		{name: "one file", files: []string{"micro0"}, want: []string{"micro0"}},
+		{name: "many files", files: []string{"micro0", "micro1", "micro2"}, want: []string{"micro0", "micro1", "micro2"}},
+		{name: "missing directory", missing: true, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			createDevices(t, dir, tt.files...)
+			if tt.missing {
+				dir = filepath.Join(dir, "missing")
+			}
+			s, _ := newTestServer(t, WithDevicePath(dir))
+
+			// action: discover the devices of the directory
+			err := s.findDevice()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("findDevice() error = %v, want error %v", err, tt.wantErr)
+			}
+
+			var got []string
+			for name, dev := range s.devices {
+				got = append(got, name)
+				if dev.ID != deviceID(name) {
+					t.Errorf("device %s ID = %s, want %s", name, dev.ID, deviceID(name))
+				}
+				assert.AssertDeviceHealthy(t, s, name)
+			}
+			slices.Sort(got)
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("devices = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDevicesRegex(t *testing.T) {
+	dir := t.TempDir()
+	createDevices(t, dir, "device-01-ab", "device-1-ab", "device-02-xyz", "micro0")
+	s, _ := newTestServer(t, WithDevicePath(dir),
+		WithDevicesRegex(regexp.MustCompile(`^device-[0-9]{2}-[a-f]+$`)))
+
+	// action: discover the device directory
+	if err := s.findDevice(); err != nil {
+		t.Fatalf("findDevice() = %v", err)
+	}
+	if got, want := deviceNames(s), []string{"device-01-ab"}; !slices.Equal(got, want) {
+		t.Fatalf("discovered devices = %v, want %v", got, want)
+	}
+
+	// action: watch created devices, the events are handled in order
+	d := make(chanDiscoverer)
+	s.discoverer = d
+	go s.watchDevice()
+	for _, name := range []string{"device-3-ab", "device-04-xyz", "device-05-cafe"} {
+		select {
+		case d <- discovery.DiscoveryEvent{Type: discovery.DeviceCreated, Device: &MicroDevice{Name: name}}:
+		case <-time.After(time.Second):
+			t.Fatalf("watchDevice did not receive device %s", name)
+		}
+	}
+	deadline := time.Now().Add(time.Second)
+	for len(deviceNames(s)) < 2 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if got, want := deviceNames(s), []string{"device-01-ab", "device-05-cafe"}; !slices.Equal(got, want) {
+		t.Errorf("devices after watch = %v, want %v", got, want)
+	}
+}
+
+// deviceNames returns the sorted names of the server devices
+func deviceNames(s *MicroDeviceServer) []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	names := make([]string, 0, len(s.devices))
+	for name := range s.devices {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+func TestAllocate(t *testing.T) {
+	tests := []struct {
+		name     string
+		held     []string // precondition: devices allocated through an alias
+		ids      []string // action: device IDs of the request
+		wantCode codes.Code
+	}{
+		{name: "one device", ids: []string{deviceID("micro0")}},
+		{name: "many devices", ids: []string{deviceID("micro0"), deviceID("micro1")}},
+		{name: "no devices", ids: nil},
+		{name: "device held by an alias", held: []string{deviceID("micro1")}, ids: []string{deviceID("micro0"), deviceID("micro1")}, wantCode: codes.FailedPrecondition},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, _ := newTestServer(t)
+			s.allocRegistry = NewAllocationRegistry()
+			if err := s.allocRegistry.Acquire("example.com/alias", tt.held); err != nil {
+				t.Fatal(err)
+			}
+
+			req := testutil.NewMockAllocateRequest().WithDeviceIDs(tt.ids...).Build()
+			resp, err := newPluginClient(t, s).Allocate(context.Background(), req)
+			if code := status.Code(err); code != tt.wantCode {
+				t.Fatalf("Allocate() error = %v, want code %v", err, tt.wantCode)
+			}
+			if err != nil {
+				for _, id := range tt.ids {
+					if s.allocated[id] {
+						t.Errorf("rejected allocation marked %s allocated", id)
+					}
+				}
+				return
+			}
+
+			envs := resp.ContainerResponses[0].Envs
+			if got, want := envs["MICRO_DEVICES"], strings.Join(tt.ids, ","); got != want {
+				t.Errorf("MICRO_DEVICES = %q, want %q", got, want)
+			}
+			for _, id := range tt.ids {
+				if !s.allocated[id] {
+					t.Errorf("device %s not marked allocated", id)
+				}
+			}
+		})
+	}
+}
+
+func TestListAndWatch(t *testing.T) {
+	tests := []struct {
+		name    string
+		initial []string // precondition: devices before the stream starts
+		added   []string // action: devices added while streaming
+	}{
+		{name: "initial send only", initial: []string{"micro0", "micro1"}},
+		{name: "update on notify", initial: []string{"micro0"}, added: []string{"micro1", "micro2"}},
+		{name: "empty then update", added: []string{"micro0"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, _ := newTestServer(t)
+			for _, name := range tt.initial {
+				s.addDevice(&MicroDevice{Name: name})
+			}
+
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			stream, err := newPluginClient(t, s).ListAndWatch(ctx, &deviceapi.Empty{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp, err := stream.Recv()
+			if err != nil {
+				t.Fatalf("initial Recv() = %v", err)
+			}
+			if got := len(resp.Devices); got != len(tt.initial) {
+				t.Errorf("initial send has %d devices, want %d", got, len(tt.initial))
+			}
+			if len(tt.added) == 0 {
+				return
+			}
+
+			for _, name := range tt.added {
+				s.addDevice(&MicroDevice{Name: name})
+			}
+			s.notifyChange()
+			want := len(tt.initial) + len(tt.added)
+			for {
+				resp, err := stream.Recv()
+				if err != nil {
+					t.Fatalf("Recv() after notify = %v", err)
+				}
+				if len(resp.Devices) == want {
+					break
+				}
+			}
+		})
+	}
+}
+
+func TestPreStartContainer(t *testing.T) {
+	tests := []struct {
+		name     string
+		remove   bool // precondition: the device file is removed after discovery
+		wantCode codes.Code
+	}{
+		{name: "device file present", wantCode: codes.OK},
+		{name: "device file absent", remove: true, wantCode: codes.FailedPrecondition},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			createDevices(t, dir, "micro0")
+			s, _ := newTestServer(t, WithDevicePath(dir))
+			if err := s.findDevice(); err != nil {
+				t.Fatal(err)
+			}
+			if tt.remove {
+				if err := os.Remove(filepath.Join(dir, "micro0")); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			req := &deviceapi.PreStartContainerRequest{DevicesIDs: []string{deviceID("micro0")}}
+			_, err := newPluginClient(t, s).PreStartContainer(context.Background(), req)
+			if code := status.Code(err); code != tt.wantCode {
+				t.Errorf("PreStartContainer() error = %v, want code %v", err, tt.wantCode)
+			}
+		})
+	}
+}
+
+func TestGetPreferredAllocation(t *testing.T) {
+	ids := make([]string, 4)
+	for i := range ids {
+		ids[i] = deviceID(fmt.Sprintf("micro%d", i))
+	}
+	tests := []struct {
+		name  string
+		must  []string
+		size  int32
+		avail []string
+		want  int
+	}{
+		{name: "no devices requested", avail: ids, size: 0, want: 0},
+		{name: "subset of available", avail: ids, size: 2, want: 2},
+		{name: "must include kept", avail: ids, must: ids[3:], size: 2, want: 2},
+		{name: "size over available", avail: ids[:1], size: 3, want: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// precondition: no scorer or strategy is configured
+			s, _ := newTestServer(t)
+
+			req := &deviceapi.PreferredAllocationRequest{
+				ContainerRequests: []*deviceapi.ContainerPreferredAllocationRequest{{
+					AvailableDeviceIDs:   tt.avail,
+					MustIncludeDeviceIDs: tt.must,
+					AllocationSize:       tt.size,
+				}},
+			}
+			resp, err := newPluginClient(t, s).GetPreferredAllocation(context.Background(), req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := resp.ContainerResponses[0].DeviceIDs
+			if len(got) != tt.want {
+				t.Fatalf("preferred devices = %v, want %d devices", got, tt.want)
+			}
+			for _, id := range tt.must {
+				if !slices.Contains(got, id) {
+					t.Errorf("preferred devices %v miss must include device %s", got, id)
+				}
+			}
+			for _, id := range got {
+				if !slices.Contains(tt.avail, id) {
+					t.Errorf("preferred device %s is not available", id)
+				}
+			}
+		})
+	}
+}
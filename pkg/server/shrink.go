@@ -0,0 +1,118 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+)
+
+// DefaultDrainTimeout is the drain timeout of a shrink request without
+// a timeout parameter
+const DefaultDrainTimeout = time.Minute
+
+var (
+	// ErrUnknownResource is returned when no plugin shard advertises the
+	// resource
+	ErrUnknownResource = errors.New("unknown resource")
+
+	// ErrDrainTimeout is returned when the allocations of a shrunk shard
+	// are not released within the drain timeout, the shard is stopped
+	// anyway
+	ErrDrainTimeout = errors.New("drain timeout with active allocations")
+)
+
+// drainPollInterval is the interval of checking the drained allocations
+const drainPollInterval = 100 * time.Millisecond
+
+// Shrink gracefully removes the plugin shard advertising the resource:
+// its devices are reported unhealthy so kubelet stops scheduling them,
+// the allocations are awaited up to drainTimeout, then the shard is
+// stopped and its socket removed. The allocations are only released
+// before the timeout with the deallocate hook watching pod deletions.
+func (m *PluginManager) Shrink(resourceName string, drainTimeout time.Duration) error {
+	m.mu.Lock()
+	var s *MicroDeviceServer
+	for i, srv := range m.servers {
+		if srv.resourceName == resourceName {
+			s = srv
+			m.servers = append(m.servers[:i:i], m.servers[i+1:]...)
+			break
+		}
+	}
+	m.mu.Unlock()
+	if s == nil {
+		return fmt.Errorf("%w %s", ErrUnknownResource, resourceName)
+	}
+
+	s.logger.Info("shrinking plugin shard", "resource", resourceName, "drain-timeout", drainTimeout)
+	drained := s.drain(drainTimeout)
+	s.Stop()
+	for _, socket := range []string{s.socketPath(), s.endpointPath()} {
+		if err := os.Remove(socket); err != nil && !os.IsNotExist(err) {
+			s.logger.Error("remove plugin socket failed", "socket", socket, "err", err)
+		}
+	}
+	if !drained {
+		s.logger.Warn("plugin shard stopped with active allocations", "resource", resourceName)
+		return fmt.Errorf("shrink %s: %w", resourceName, ErrDrainTimeout)
+	}
+	s.logger.Info("plugin shard shrunk", "resource", resourceName)
+	return nil
+}
+
+// drain reports all devices unhealthy and waits for the allocations to
+// be released, it returns false if they are not released within timeout
+func (s *MicroDeviceServer) drain(timeout time.Duration) bool {
+	s.mu.Lock()
+	s.draining = true
+	s.mu.Unlock()
+	s.updateGRPCHealth()
+	s.notifyChange()
+
+	deadline := time.Now().Add(timeout)
+	for {
+		s.allocMu.Lock()
+		active := len(s.allocated)
+		s.allocMu.Unlock()
+		if active == 0 {
+			return true
+		}
+		if !time.Now().Before(deadline) {
+			return false
+		}
+		s.logger.Info("waiting for allocations to drain", "active", active)
+		time.Sleep(min(drainPollInterval, time.Until(deadline)))
+	}
+}
+
+// handleShrink shrinks the shard of the resource parameter, the timeout
+// parameter is the drain timeout in seconds
+func (m *PluginManager) handleShrink(w http.ResponseWriter, r *http.Request) {
+	resource := r.URL.Query().Get("resource")
+	if resource == "" {
+		http.Error(w, "missing resource", http.StatusBadRequest)
+		return
+	}
+	timeout := DefaultDrainTimeout
+	if v := r.URL.Query().Get("timeout"); v != "" {
+		seconds, err := strconv.Atoi(v)
+		if err != nil || seconds < 0 {
+			http.Error(w, "timeout must be a non-negative number of seconds", http.StatusBadRequest)
+			return
+		}
+		timeout = time.Duration(seconds) * time.Second
+	}
+
+	err := m.Shrink(resource, timeout)
+	switch {
+	case errors.Is(err, ErrUnknownResource):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case err != nil && !errors.Is(err, ErrDrainTimeout):
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	default:
+		writeJSON(w, http.StatusOK, map[string]any{"resource": resource, "drained": err == nil})
+	}
+}
@@ -0,0 +1,102 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	deviceapi "k8s.io/kubelet/pkg/apis/deviceplugin/v1beta1"
+
+	"github.com/kelein/micro-device-plugin/pkg/testing/assert"
+	"github.com/kelein/micro-device-plugin/pkg/testutil"
+)
+
+func newTestManager(t *testing.T) *PluginManager {
+	t.Helper()
+	m, err := NewPluginManager(2, WithWatchdogTimeout(0), WithMetrics(prometheus.NewRegistry()),
+		WithPluginPath(t.TempDir()), WithDevicePath(t.TempDir()), WithRESTAPI(true))
+	if err != nil {
+		t.Fatalf("NewPluginManager() error = %v", err)
+	}
+	t.Cleanup(m.Stop)
+	return m
+}
+
+func TestShrinkWaitsForAllocations(t *testing.T) {
+	m := newTestManager(t)
+	s := m.Servers()[0]
+	id := s.addDevice(&MicroDevice{Name: "micro0"})
+	s.markAllocated([]string{id})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	stream := testutil.NewMockListAndWatchServer(ctx)
+	go s.ListAndWatch(&deviceapi.Empty{}, stream)
+	if !stream.WaitForSends(1, time.Second) {
+		t.Fatal("ListAndWatch did not send the initial device list")
+	}
+
+	const release = 300 * time.Millisecond
+	time.AfterFunc(release, func() { s.releaseDevices([]string{id}, "pod") })
+	start := time.Now()
+	if err := m.Shrink(s.resourceName, 5*time.Second); err != nil {
+		t.Fatalf("Shrink() = %v", err)
+	}
+	if elapsed := time.Since(start); elapsed < release || elapsed > 2*time.Second {
+		t.Errorf("Shrink() took %v, want about %v", elapsed, release)
+	}
+
+	assert.AssertListAndWatchContains(t, stream.Responses(), id, deviceapi.Unhealthy)
+	if got := len(m.Servers()); got != 1 {
+		t.Errorf("manager has %d shards after shrink, want 1", got)
+	}
+	if s.ctx.Err() == nil {
+		t.Error("shrunk shard was not stopped")
+	}
+}
+
+func TestShrinkDrainTimeout(t *testing.T) {
+	m := newTestManager(t)
+	s := m.Servers()[1]
+	s.markAllocated([]string{s.addDevice(&MicroDevice{Name: "micro0"})})
+
+	const timeout = 300 * time.Millisecond
+	start := time.Now()
+	err := m.Shrink(s.resourceName, timeout)
+	if !errors.Is(err, ErrDrainTimeout) {
+		t.Fatalf("Shrink() = %v, want %v", err, ErrDrainTimeout)
+	}
+	if elapsed := time.Since(start); elapsed < timeout {
+		t.Errorf("Shrink() returned after %v, before the drain timeout %v", elapsed, timeout)
+	}
+	if s.ctx.Err() == nil {
+		t.Error("shard was not stopped after the drain timeout")
+	}
+}
+
+func TestShrinkHandler(t *testing.T) {
+	m := newTestManager(t)
+	tests := []struct {
+		query string
+		code  int
+	}{
+		{query: "", code: http.StatusBadRequest},
+		{query: "?resource=micro.plugin-1&timeout=x", code: http.StatusBadRequest},
+		{query: "?resource=unknown", code: http.StatusNotFound},
+		{query: "?resource=micro.plugin-1&timeout=0", code: http.StatusOK},
+	}
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		m.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/v1/shrink"+tt.query, nil))
+		if rec.Code != tt.code {
+			t.Errorf("POST /api/v1/shrink%s = %d, want %d", tt.query, rec.Code, tt.code)
+		}
+	}
+	if got := len(m.Servers()); got != 1 {
+		t.Errorf("manager has %d shards after shrink, want 1", got)
+	}
+}
@@ -0,0 +1,99 @@
+package server
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// maxSocketNameLength is the longest accepted plugin socket name
+const maxSocketNameLength = 100
+
+// ValidateSocketName checks the plugin socket name is a plain file name
+// ending with .sock
+func ValidateSocketName(name string) error {
+	switch {
+	case !strings.HasSuffix(name, ".sock") || name == ".sock":
+		return fmt.Errorf("socket name %q must end with .sock", name)
+	case strings.ContainsAny(name, `/\`):
+		return fmt.Errorf("socket name %q must not contain path separators", name)
+	case len(name) > maxSocketNameLength:
+		return fmt.Errorf("socket name exceeds %d characters", maxSocketNameLength)
+	}
+	return nil
+}
+
+// watchSocket starts watching the plugin path to recover the plugin
+// socket when it is removed by an external actor, the socket is polled
+// instead if a poll interval is set
+func (s *MicroDeviceServer) watchSocket() error {
+	if s.pollInterval > 0 {
+		s.SafeGo("pollSocket", s.pollSocket)
+		return nil
+	}
+	w, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	if err := w.Add(s.pluginPath); err != nil {
+		w.Close()
+		return err
+	}
+	go s.handleSocketEvents(w)
+	return nil
+}
+
+func (s *MicroDeviceServer) handleSocketEvents(w *fsnotify.Watcher) {
+	defer w.Close()
+	for {
+		select {
+		case event := <-w.Events:
+			if event.Name != s.socketPath() || !event.Has(fsnotify.Remove) && !event.Has(fsnotify.Rename) {
+				continue
+			}
+			if s.ctx.Err() != nil {
+				return
+			}
+			s.recoverSocket()
+		case err := <-w.Errors:
+			s.logger.Error("socket watcher failed", "err", err)
+		case <-s.ctx.Done():
+			return
+		}
+	}
+}
+
+// recoverSocket replaces the gRPC server with one listening on a new
+// plugin socket and registers the plugin with kubelet again
+func (s *MicroDeviceServer) recoverSocket() {
+	s.logger.Warn("plugin socket removed, recovering", "socket", s.socketPath())
+	s.mu.Lock()
+	old := s.serv
+	s.serv = s.newGRPCServer()
+	serv := s.serv
+	registered := s.registered
+	s.mu.Unlock()
+
+	old.Stop()
+	if err := s.serve(serv); err != nil {
+		s.logger.Error("recover plugin socket failed", "err", err)
+		s.setError(err)
+		return
+	}
+	if s.delta {
+		if err := s.serveDeltaProxy(); err != nil {
+			s.logger.Error("recover delta proxy socket failed", "err", err)
+			s.setError(err)
+			return
+		}
+	}
+	s.metrics.socketRecoveries.Inc()
+
+	if !registered {
+		return
+	}
+	if err := s.RegisterToKubelet(); err != nil {
+		s.logger.Error("register after socket recovery failed", "err", err)
+	}
+}
@@ -0,0 +1,66 @@
+//go:build integration
+
+package server
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	deviceapi "k8s.io/kubelet/pkg/apis/deviceplugin/v1beta1"
+
+	"github.com/kelein/micro-device-plugin/pkg/testing/assert"
+	"github.com/kelein/micro-device-plugin/pkg/testutil"
+)
+
+func TestSocketRecovery(t *testing.T) {
+	tests := []struct {
+		name string
+		opts []Option
+	}{
+		{name: "fsnotify"},
+		{name: "polling", opts: []Option{WithPollInterval(50 * time.Millisecond)}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			kubelet, err := testutil.NewFakeKubelet(dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			t.Cleanup(kubelet.Stop)
+			reg := prometheus.NewRegistry()
+			opts := append([]Option{WithPluginPath(dir), WithMetrics(reg), WithHealthInterval(0)}, tt.opts...)
+			s, _ := newTestServer(t, opts...)
+			if err := s.Run(); err != nil {
+				t.Fatal(err)
+			}
+			if err := s.RegisterToKubelet(); err != nil {
+				t.Fatal(err)
+			}
+
+			// action: an external actor deletes the plugin socket
+			if err := os.Remove(s.socketPath()); err != nil {
+				t.Fatal(err)
+			}
+			if !waitRequests(kubelet, 2, 5*time.Second) {
+				t.Fatalf("plugin did not register again, kubelet requests = %d", len(kubelet.Requests()))
+			}
+			assert.AssertMetricValue(t, reg, "micro_device_plugin_socket_recoveries_total", nil, 1)
+
+			// the recreated socket accepts new connections
+			conn, err := dialUnix(s.socketPath(), 5*time.Second)
+			if err != nil {
+				t.Fatalf("dial recovered socket: %v", err)
+			}
+			defer conn.Close()
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			if _, err := deviceapi.NewDevicePluginClient(conn).GetDevicePluginOptions(ctx, &deviceapi.Empty{}); err != nil {
+				t.Errorf("GetDevicePluginOptions() on the recovered socket = %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,157 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	deviceapi "k8s.io/kubelet/pkg/apis/deviceplugin/v1beta1"
+)
+
+// standaloneOwner is the owner logged for the standalone deallocations
+const standaloneOwner = "standalone"
+
+// errDeviceConflict is returned for standalone requests of devices that
+// are unavailable or not allocated
+var errDeviceConflict = errors.New("device conflict")
+
+// StandaloneAllocateRequest requests the devices of the IDs, or Count
+// available devices picked by the allocation strategy without IDs
+type StandaloneAllocateRequest struct {
+	DeviceIDs []string `json:"deviceIDs,omitempty"`
+	Count     int      `json:"count,omitempty"`
+}
+
+// StandaloneDeallocateRequest releases the allocated devices of the IDs
+type StandaloneDeallocateRequest struct {
+	DeviceIDs []string `json:"deviceIDs"`
+}
+
+// StandaloneAllocation describes the devices allocated by the standalone
+// REST API, with the env vars, mounts and device specs of Allocate
+type StandaloneAllocation struct {
+	DeviceIDs []string                `json:"deviceIDs"`
+	Envs      map[string]string       `json:"envs,omitempty"`
+	Mounts    []*deviceapi.Mount      `json:"mounts,omitempty"`
+	Devices   []*deviceapi.DeviceSpec `json:"devices,omitempty"`
+}
+
+// handleStandaloneDevices lists the devices of the plugin
+func (s *MicroDeviceServer) handleStandaloneDevices(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, s.Devices())
+}
+
+// handleStandaloneAllocate allocates the requested devices and returns
+// the response of Allocate for them
+func (s *MicroDeviceServer) handleStandaloneAllocate(w http.ResponseWriter, r *http.Request) {
+	var req StandaloneAllocateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid allocate request: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if len(req.DeviceIDs) == 0 && req.Count <= 0 {
+		http.Error(w, "deviceIDs or a positive count is required", http.StatusBadRequest)
+		return
+	}
+
+	ids, err := s.reserveStandalone(req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	resp, err := s.Allocate(r.Context(), &deviceapi.AllocateRequest{
+		ContainerRequests: []*deviceapi.ContainerAllocateRequest{{DevicesIDs: ids}},
+	})
+	if err != nil {
+		s.releaseDevices(ids, standaloneOwner)
+		code := http.StatusInternalServerError
+		if status.Code(err) == codes.PermissionDenied {
+			code = http.StatusForbidden
+		}
+		http.Error(w, err.Error(), code)
+		return
+	}
+	c := resp.ContainerResponses[0]
+	writeJSON(w, http.StatusOK, StandaloneAllocation{DeviceIDs: ids, Envs: c.Envs, Mounts: c.Mounts, Devices: c.Devices})
+}
+
+// reserveStandalone marks the requested devices allocated if they are
+// healthy and not allocated yet
+func (s *MicroDeviceServer) reserveStandalone(req StandaloneAllocateRequest) ([]string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	s.allocMu.Lock()
+	defer s.allocMu.Unlock()
+
+	byID := make(map[string]*MicroDevice, len(s.devices))
+	var available []*MicroDevice
+	for _, dev := range s.devices {
+		byID[dev.ID] = dev
+		if dev.Health == deviceapi.Healthy && !isReserved(dev) && !s.allocated[dev.ID] && !s.draining {
+			available = append(available, dev)
+		}
+	}
+
+	ids := req.DeviceIDs
+	if len(ids) == 0 {
+		if req.Count > len(available) {
+			return nil, fmt.Errorf("%w: %d devices requested, %d available", errDeviceConflict, req.Count, len(available))
+		}
+		selected := sortedByID(available)[:req.Count]
+		if s.strategy != nil {
+			var err error
+			if selected, err = s.strategy.Select(available, req.Count); err != nil {
+				return nil, fmt.Errorf("%w: %v", errDeviceConflict, err)
+			}
+		}
+		for _, dev := range selected {
+			ids = append(ids, dev.ID)
+		}
+	}
+	for _, id := range ids {
+		dev, ok := byID[id]
+		switch {
+		case !ok:
+			return nil, fmt.Errorf("%w: unknown device %s", errDeviceConflict, id)
+		case s.allocated[id]:
+			return nil, fmt.Errorf("%w: device %s is already allocated", errDeviceConflict, id)
+		case dev.Health != deviceapi.Healthy || isReserved(dev) || s.draining:
+			return nil, fmt.Errorf("%w: device %s is unavailable", errDeviceConflict, id)
+		}
+	}
+
+	for _, id := range ids {
+		s.allocated[id] = true
+	}
+	s.metrics.activeAllocations.Set(float64(len(s.allocated)))
+	return ids, nil
+}
+
+// handleStandaloneDeallocate releases the allocated devices
+func (s *MicroDeviceServer) handleStandaloneDeallocate(w http.ResponseWriter, r *http.Request) {
+	var req StandaloneDeallocateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid deallocate request: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if len(req.DeviceIDs) == 0 {
+		http.Error(w, "deviceIDs is required", http.StatusBadRequest)
+		return
+	}
+
+	s.allocMu.Lock()
+	for _, id := range req.DeviceIDs {
+		if !s.allocated[id] {
+			s.allocMu.Unlock()
+			http.Error(w, fmt.Sprintf("%v: device %s is not allocated", errDeviceConflict, id), http.StatusConflict)
+			return
+		}
+	}
+	s.allocMu.Unlock()
+
+	s.releaseDevices(req.DeviceIDs, standaloneOwner)
+	writeJSON(w, http.StatusOK, StandaloneDeallocateRequest{DeviceIDs: req.DeviceIDs})
+}
@@ -0,0 +1,88 @@
+//go:build integration
+
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	deviceapi "k8s.io/kubelet/pkg/apis/deviceplugin/v1beta1"
+
+	"github.com/kelein/micro-device-plugin/pkg/testing/assert"
+)
+
+func TestStandalone(t *testing.T) {
+	dir := deviceDir(t, 3)
+	s, pluginDir := newTestServer(t, WithDevicePath(dir), WithStandalone(true), WithHealthInterval(0),
+		WithHealthPolicy(CommandPolicy{Command: `test "$MICRO_DEVICE_NAME" != micro0`}))
+	if err := s.Run(); err != nil {
+		t.Fatalf("Run() = %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(pluginDir, microSocket)); !os.IsNotExist(err) {
+		t.Errorf("standalone plugin socket stat error = %v, want not exist", err)
+	}
+	srv := httptest.NewServer(s.Handler())
+	t.Cleanup(srv.Close)
+
+	post := func(path, body string, want int, out any) {
+		t.Helper()
+		resp, err := http.Post(srv.URL+path, "application/json", strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != want {
+			t.Fatalf("POST %s %s = %s, want %d", path, body, resp.Status, want)
+		}
+		if out != nil {
+			if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	devices := func() []DeviceInfo {
+		t.Helper()
+		resp, err := http.Get(srv.URL + "/devices")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		var devices []DeviceInfo
+		if err := json.NewDecoder(resp.Body).Decode(&devices); err != nil {
+			t.Fatal(err)
+		}
+		return devices
+	}
+
+	if got := len(devices()); got != 3 {
+		t.Fatalf("GET /devices returned %d devices, want 3", got)
+	}
+
+	var alloc StandaloneAllocation
+	post("/allocate", `{"count": 2}`, http.StatusOK, &alloc)
+	if len(alloc.DeviceIDs) != 2 {
+		t.Fatalf("allocation = %+v, want 2 devices", alloc)
+	}
+	assert.AssertAllocateResponse(t, &deviceapi.AllocateResponse{
+		ContainerResponses: []*deviceapi.ContainerAllocateResponse{{Envs: alloc.Envs, Mounts: alloc.Mounts}},
+	}, map[string]string{"MICRO_DEVICES": strings.Join(alloc.DeviceIDs, ",")}, nil)
+	post("/allocate", `{"count": 2}`, http.StatusConflict, nil)
+	post("/allocate", `{"deviceIDs": ["`+alloc.DeviceIDs[0]+`"]}`, http.StatusConflict, nil)
+	post("/allocate", `{"deviceIDs": ["unknown"]}`, http.StatusConflict, nil)
+	post("/allocate", `{}`, http.StatusBadRequest, nil)
+
+	body, _ := json.Marshal(StandaloneDeallocateRequest{DeviceIDs: alloc.DeviceIDs})
+	post("/deallocate", string(body), http.StatusOK, nil)
+	post("/deallocate", string(body), http.StatusConflict, nil)
+	post("/allocate", `{"count": 3}`, http.StatusOK, &alloc)
+
+	// the health policy checks the devices in standalone mode too
+	s.checkHealth()
+	assert.AssertDeviceUnhealthy(t, s, "micro0")
+	assert.AssertDeviceHealthy(t, s, "micro1")
+}
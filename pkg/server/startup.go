@@ -0,0 +1,95 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+)
+
+// ErrStartupProbeTimeout is returned by Run if the startup probe does not
+// succeed within its timeout
+var ErrStartupProbeTimeout = errors.New("startup probe timeout")
+
+var probeClient = &http.Client{Timeout: 5 * time.Second}
+
+// StartupProbe delays the device discovery until the node signals its
+// readiness by creating File or by URL answering 200 OK
+type StartupProbe struct {
+	File string
+	URL  string
+
+	// Interval is the polling interval, a second if 0
+	Interval time.Duration
+
+	// Timeout bounds the wait, 0 waits forever
+	Timeout time.Duration
+}
+
+// Wait polls the probe until it succeeds, ctx is done or the timeout
+// expires
+func (p *StartupProbe) Wait(ctx context.Context) error {
+	if p.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, p.Timeout)
+		defer cancel()
+	}
+	interval := p.Interval
+	if interval <= 0 {
+		interval = time.Second
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if p.ready(ctx) {
+			return nil
+		}
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+				return fmt.Errorf("%w after %s", ErrStartupProbeTimeout, p.Timeout)
+			}
+			return ctx.Err()
+		}
+	}
+}
+
+// ready reports whether the file exists or the URL answers 200 OK
+func (p *StartupProbe) ready(ctx context.Context) bool {
+	if p.File != "" {
+		if _, err := os.Stat(p.File); err == nil {
+			return true
+		}
+	}
+	if p.URL != "" {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.URL, nil)
+		if err != nil {
+			return false
+		}
+		resp, err := probeClient.Do(req)
+		if err != nil {
+			return false
+		}
+		resp.Body.Close()
+		return resp.StatusCode == http.StatusOK
+	}
+	return false
+}
+
+// waitStartupProbe blocks until the startup probe succeeds if configured
+func (s *MicroDeviceServer) waitStartupProbe() error {
+	if s.startupProbe == nil {
+		return nil
+	}
+	s.logger.Info("waiting for the startup probe", "file", s.startupProbe.File, "url", s.startupProbe.URL, "timeout", s.startupProbe.Timeout)
+	start := time.Now()
+	if err := s.startupProbe.Wait(s.ctx); err != nil {
+		return err
+	}
+	s.logger.Info("startup probe succeeded", "waited", time.Since(start).Round(time.Millisecond))
+	return nil
+}
@@ -0,0 +1,94 @@
+//go:build integration
+
+package server
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/kelein/micro-device-plugin/pkg/discovery"
+)
+
+// timedDiscoverer records the time of the first discovery
+type timedDiscoverer struct {
+	discovery.Discoverer
+	at atomic.Pointer[time.Time]
+}
+
+func (d *timedDiscoverer) Discover() ([]*MicroDevice, error) {
+	now := time.Now()
+	d.at.CompareAndSwap(nil, &now)
+	return d.Discoverer.Discover()
+}
+
+func TestStartupProbeFile(t *testing.T) {
+	ready := filepath.Join(t.TempDir(), "ready")
+	d := &timedDiscoverer{Discoverer: discovery.NewFilesystemDiscoverer(deviceDir(t, 2))}
+	s, _ := newTestServer(t, WithDiscoverer(d), WithHealthInterval(0),
+		WithStartupProbe(&StartupProbe{File: ready, Interval: 10 * time.Millisecond, Timeout: 5 * time.Second}))
+
+	created := make(chan time.Time, 1)
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		now := time.Now()
+		if err := os.WriteFile(ready, nil, 0644); err != nil {
+			t.Error(err)
+		}
+		created <- now
+	}()
+
+	if err := s.Run(); err != nil {
+		t.Fatalf("Run() = %v", err)
+	}
+	createdAt := <-created
+	discoveredAt := d.at.Load()
+	if discoveredAt == nil {
+		t.Fatal("devices not discovered")
+	}
+	if discoveredAt.Before(createdAt) {
+		t.Errorf("discovery started %v before the startup file was created", createdAt.Sub(*discoveredAt))
+	}
+	if got := len(s.Devices()); got != 2 {
+		t.Errorf("discovered %d devices, want 2", got)
+	}
+}
+
+func TestStartupProbeURL(t *testing.T) {
+	var ready atomic.Bool
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !ready.Load() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	t.Cleanup(srv.Close)
+	time.AfterFunc(100*time.Millisecond, func() { ready.Store(true) })
+
+	s, _ := newTestServer(t, WithHealthInterval(0),
+		WithStartupProbe(&StartupProbe{URL: srv.URL, Interval: 10 * time.Millisecond, Timeout: 5 * time.Second}))
+	start := time.Now()
+	if err := s.Run(); err != nil {
+		t.Fatalf("Run() = %v", err)
+	}
+	if elapsed := time.Since(start); elapsed < 100*time.Millisecond {
+		t.Errorf("Run() returned after %v, before the URL was ready", elapsed)
+	}
+}
+
+func TestStartupProbeTimeout(t *testing.T) {
+	s, _ := newTestServer(t, WithHealthInterval(0), WithStartupProbe(&StartupProbe{
+		File:     filepath.Join(t.TempDir(), "never"),
+		Interval: 10 * time.Millisecond,
+		Timeout:  50 * time.Millisecond,
+	}))
+	if err := s.Run(); !errors.Is(err, ErrStartupProbeTimeout) {
+		t.Fatalf("Run() = %v, want %v", err, ErrStartupProbeTimeout)
+	}
+}
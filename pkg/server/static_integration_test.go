@@ -0,0 +1,75 @@
+//go:build integration
+
+package server
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	deviceapi "k8s.io/kubelet/pkg/apis/deviceplugin/v1beta1"
+
+	"github.com/kelein/micro-device-plugin/pkg/testing/assert"
+	"github.com/kelein/micro-device-plugin/pkg/testutil"
+)
+
+func TestStaticDevicesFallback(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "devices.yaml")
+	writeStatic := func(content string) {
+		t.Helper()
+		if err := os.WriteFile(file, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writeStatic(`devices:
+- name: vmicro0
+  id: static-0
+  numaNode: 1
+- name: vmicro1
+  id: static-1
+  health: Unhealthy
+  annotations:
+    example.com/model: emulated
+`)
+
+	s, _ := newTestServer(t, WithStaticDevicesFile(file), WithHealthInterval(0))
+	if err := s.Run(); err != nil {
+		t.Fatalf("Run() = %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	stream := testutil.NewMockListAndWatchServer(ctx)
+	go s.ListAndWatch(&deviceapi.Empty{}, stream)
+	if !stream.WaitForSends(1, 5*time.Second) {
+		t.Fatal("ListAndWatch sent no device list")
+	}
+
+	devices := stream.Responses()[0].Devices
+	slices.SortFunc(devices, func(a, b *deviceapi.Device) int { return strings.Compare(a.ID, b.ID) })
+	if len(devices) != 2 || devices[0].ID != "static-0" || devices[1].ID != "static-1" {
+		t.Fatalf("ListAndWatch devices = %v, want static-0 and static-1", devices)
+	}
+	assert.AssertListAndWatchContains(t, stream.Responses()[:1], "static-1", deviceapi.Unhealthy)
+	for _, dev := range s.Devices() {
+		if dev.Name == "vmicro0" && dev.Annotations[numaAnnotation] != "1" {
+			t.Errorf("vmicro0 annotations = %v, want NUMA node 1", dev.Annotations)
+		}
+	}
+
+	writeStatic(`devices:
+- name: vmicro0
+  id: static-0
+`)
+	if !stream.WaitForSends(2, 5*time.Second) {
+		t.Fatal("ListAndWatch sent no update after the static devices file changed")
+	}
+	responses := stream.Responses()
+	if got := responses[len(responses)-1].Devices; len(got) != 1 || got[0].ID != "static-0" {
+		t.Errorf("ListAndWatch devices after update = %v, want static-0", got)
+	}
+}
@@ -0,0 +1,101 @@
+package server
+
+import (
+	"fmt"
+	"time"
+
+	deviceapi "k8s.io/kubelet/pkg/apis/deviceplugin/v1beta1"
+)
+
+// Plugin lifecycle phases
+const (
+	PhaseStarting = "Starting"
+	PhaseRunning  = "Running"
+	PhaseDegraded = "Degraded"
+	PhaseFailed   = "Failed"
+)
+
+// PluginStatus is a machine-readable status of the plugin
+type PluginStatus struct {
+	Phase                 string `json:"phase"`
+	RegisteredWithKubelet bool   `json:"registeredWithKubelet"`
+	DeviceCount           int    `json:"deviceCount"`
+	HealthyCount          int    `json:"healthyCount"`
+	LastError             string `json:"lastError,omitempty"`
+	Uptime                string `json:"uptime"`
+	RestartCount          int    `json:"restartCount"`
+	TotalCapacity         int    `json:"totalCapacity"`
+	AllocatableCapacity   int    `json:"allocatableCapacity"`
+	ReservedCapacity      int    `json:"reservedCapacity"`
+	ReservedCount         int    `json:"reservedCount"`
+	LastListAndWatch      string `json:"lastListAndWatch,omitempty"`
+}
+
+// Status returns the current status of the plugin
+func (s *MicroDeviceServer) Status() PluginStatus {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	status := PluginStatus{
+		RegisteredWithKubelet: s.registered,
+		DeviceCount:           len(s.devices),
+		LastError:             s.lastError,
+		Uptime:                time.Since(s.startTime).Round(time.Second).String(),
+		RestartCount:          s.restartCount,
+	}
+	if !s.lastListAndWatch.IsZero() {
+		status.LastListAndWatch = s.lastListAndWatch.Format(time.RFC3339)
+	}
+	status.TotalCapacity = len(s.devices)
+	for _, dev := range s.devices {
+		if dev.Health == deviceapi.Healthy {
+			status.HealthyCount++
+		}
+		if isSystemReserved(dev) {
+			status.ReservedCount++
+		}
+		switch {
+		case isReserved(dev):
+			status.ReservedCapacity++
+		case dev.Health == deviceapi.Healthy:
+			status.AllocatableCapacity++
+		}
+	}
+
+	switch {
+	case s.restartCount > maxRestartNum && !s.registered:
+		status.Phase = PhaseFailed
+	case !s.registered:
+		status.Phase = PhaseStarting
+	case status.HealthyCount < status.DeviceCount:
+		status.Phase = PhaseDegraded
+	default:
+		status.Phase = PhaseRunning
+	}
+	return status
+}
+
+// Live checks the number of healthy devices is not below the minimum
+func (s *MicroDeviceServer) Live() error {
+	healthy := s.healthyCount()
+	if healthy < s.devicesMin {
+		s.metrics.livenessFailures.Inc()
+		s.logger.Warn("healthy devices below minimum", "healthy", healthy, "min", s.devicesMin)
+		return fmt.Errorf("healthy devices %d below minimum %d", healthy, s.devicesMin)
+	}
+	return nil
+}
+
+// setError records the last error of the plugin
+func (s *MicroDeviceServer) setError(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.lastError = err.Error()
+}
+
+// setRestartCount records the RPC server restart count
+func (s *MicroDeviceServer) setRestartCount(n int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.restartCount = n
+}
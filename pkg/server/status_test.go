@@ -0,0 +1,92 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/kelein/micro-device-plugin/pkg/testing/assert"
+)
+
+// setRegistered sets the kubelet registration state of the server
+func setRegistered(s *MicroDeviceServer, registered bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.registered = registered
+}
+
+func TestStatusPhases(t *testing.T) {
+	dir := t.TempDir()
+	createDevices(t, dir, "micro0", "micro1")
+	s, _ := newTestServer(t, WithDevicePath(dir))
+	for _, name := range []string{"micro0", "micro1"} {
+		s.addDevice(&MicroDevice{Name: name, Path: filepath.Join(dir, name)})
+	}
+
+	steps := []struct {
+		name        string
+		action      func()
+		wantPhase   string
+		wantHealthy int
+	}{
+		{name: "not registered", action: func() {}, wantPhase: PhaseStarting, wantHealthy: 2},
+		{name: "registered", action: func() { setRegistered(s, true) }, wantPhase: PhaseRunning, wantHealthy: 2},
+		{
+			name: "device file removed",
+			action: func() {
+				if err := os.Remove(filepath.Join(dir, "micro1")); err != nil {
+					t.Fatal(err)
+				}
+				s.checkHealth()
+				assert.AssertDeviceUnhealthy(t, s, "micro1")
+			},
+			wantPhase:   PhaseDegraded,
+			wantHealthy: 1,
+		},
+		{
+			name: "registration lost",
+			action: func() {
+				setRegistered(s, false)
+				s.setRestartCount(maxRestartNum)
+			},
+			wantPhase:   PhaseStarting,
+			wantHealthy: 1,
+		},
+		{
+			name: "restarts exhausted",
+			action: func() {
+				s.setError(errors.New("kubelet unavailable"))
+				s.setRestartCount(maxRestartNum + 1)
+			},
+			wantPhase:   PhaseFailed,
+			wantHealthy: 1,
+		},
+	}
+	for _, step := range steps {
+		step.action()
+
+		// the status is checked as served by GET /status
+		rec := httptest.NewRecorder()
+		s.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/status", nil))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("%s: GET /status status = %d, want %d", step.name, rec.Code, http.StatusOK)
+		}
+		var got PluginStatus
+		if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+			t.Fatalf("%s: decode status: %v", step.name, err)
+		}
+		if got.Phase != step.wantPhase || got.DeviceCount != 2 || got.HealthyCount != step.wantHealthy {
+			t.Errorf("%s: status = %+v, want phase %s with %d of 2 devices healthy",
+				step.name, got, step.wantPhase, step.wantHealthy)
+		}
+	}
+
+	if got := s.Status(); got.LastError != "kubelet unavailable" || got.RestartCount != maxRestartNum+1 {
+		t.Errorf("status last error %q and restart count %d, want kubelet unavailable and %d",
+			got.LastError, got.RestartCount, maxRestartNum+1)
+	}
+}
@@ -0,0 +1,132 @@
+package server
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"sort"
+	"sync"
+	"time"
+)
+
+// AllocationStrategy selects the devices assigned to an allocation from
+// the available devices
+type AllocationStrategy interface {
+	Select(available []*MicroDevice, count int) ([]*MicroDevice, error)
+}
+
+// NewAllocationStrategy returns the built-in allocation strategy by name:
+// random, round-robin or lru
+func NewAllocationStrategy(name string) (AllocationStrategy, error) {
+	switch name {
+	case "random":
+		return RandomStrategy{}, nil
+	case "round-robin":
+		return &RoundRobinStrategy{}, nil
+	case "lru":
+		return NewLRUStrategy(), nil
+	default:
+		return nil, fmt.Errorf("unknown allocation strategy %q", name)
+	}
+}
+
+// checkCount reports an error if count devices cannot be selected
+func checkCount(available []*MicroDevice, count int) error {
+	if count < 0 || count > len(available) {
+		return fmt.Errorf("%d devices requested, %d available", count, len(available))
+	}
+	return nil
+}
+
+// sortedByID returns a copy of the devices sorted by ID
+func sortedByID(devices []*MicroDevice) []*MicroDevice {
+	sorted := append([]*MicroDevice{}, devices...)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].ID < sorted[j].ID
+	})
+	return sorted
+}
+
+// RandomStrategy selects random devices
+type RandomStrategy struct{}
+
+// Select implements AllocationStrategy
+func (RandomStrategy) Select(available []*MicroDevice, count int) ([]*MicroDevice, error) {
+	if err := checkCount(available, count); err != nil {
+		return nil, err
+	}
+	shuffled := append([]*MicroDevice{}, available...)
+	rand.Shuffle(len(shuffled), func(i, j int) {
+		shuffled[i], shuffled[j] = shuffled[j], shuffled[i]
+	})
+	return shuffled[:count], nil
+}
+
+// RoundRobinStrategy selects the devices in ID order, continuing after
+// the devices of the previous selection
+type RoundRobinStrategy struct {
+	mu   sync.Mutex
+	next int
+}
+
+// Select implements AllocationStrategy
+func (r *RoundRobinStrategy) Select(available []*MicroDevice, count int) ([]*MicroDevice, error) {
+	if err := checkCount(available, count); err != nil {
+		return nil, err
+	}
+	if count == 0 {
+		return nil, nil
+	}
+
+	sorted := sortedByID(available)
+	r.mu.Lock()
+	start := r.next % len(sorted)
+	r.next = start + count
+	r.mu.Unlock()
+
+	selected := make([]*MicroDevice, count)
+	for i := range selected {
+		selected[i] = sorted[(start+i)%len(sorted)]
+	}
+	return selected, nil
+}
+
+// LRUStrategy selects the devices idle for the longest time, devices
+// never selected first
+type LRUStrategy struct {
+	mu              sync.Mutex
+	lastAllocatedAt map[string]time.Time
+}
+
+// NewLRUStrategy creates a least recently used allocation strategy
+func NewLRUStrategy() *LRUStrategy {
+	return &LRUStrategy{lastAllocatedAt: make(map[string]time.Time)}
+}
+
+// Select implements AllocationStrategy
+func (l *LRUStrategy) Select(available []*MicroDevice, count int) ([]*MicroDevice, error) {
+	if err := checkCount(available, count); err != nil {
+		return nil, err
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	sorted := sortedByID(available)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return l.lastAllocatedAt[sorted[i].ID].Before(l.lastAllocatedAt[sorted[j].ID])
+	})
+
+	now := time.Now()
+	selected := sorted[:count]
+	for _, dev := range selected {
+		l.lastAllocatedAt[dev.ID] = now
+	}
+	return selected, nil
+}
+
+// LastAllocatedAt returns the last time the device was selected, zero
+// if it was never selected
+func (l *LRUStrategy) LastAllocatedAt(id string) time.Time {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.lastAllocatedAt[id]
+}
@@ -0,0 +1,108 @@
+package server
+
+import (
+	"context"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+
+	deviceapi "k8s.io/kubelet/pkg/apis/deviceplugin/v1beta1"
+
+	"github.com/kelein/micro-device-plugin/pkg/testing/assert"
+	"github.com/kelein/micro-device-plugin/pkg/testutil"
+)
+
+func testDevices(ids ...string) []*MicroDevice {
+	devices := make([]*MicroDevice, len(ids))
+	for i, id := range ids {
+		devices[i] = &MicroDevice{Name: id, ID: id}
+	}
+	return devices
+}
+
+func selectIDs(t *testing.T, strategy AllocationStrategy, available []*MicroDevice, count int) []string {
+	t.Helper()
+	selected, err := strategy.Select(available, count)
+	if err != nil {
+		t.Fatalf("Select() error = %v", err)
+	}
+	ids := make([]string, len(selected))
+	for i, dev := range selected {
+		ids[i] = dev.ID
+	}
+	return ids
+}
+
+func TestRoundRobinStrategy(t *testing.T) {
+	strategy := &RoundRobinStrategy{}
+	available := testDevices("c", "a", "b")
+
+	for _, want := range [][]string{{"a", "b"}, {"c", "a"}, {"b", "c"}} {
+		if got := selectIDs(t, strategy, available, 2); !reflect.DeepEqual(got, want) {
+			t.Errorf("Select() = %v, want %v", got, want)
+		}
+	}
+}
+
+func TestLRUStrategy(t *testing.T) {
+	strategy := NewLRUStrategy()
+	available := testDevices("c", "a", "b")
+
+	for _, want := range [][]string{{"a", "b"}, {"c"}, {"a"}, {"b", "c"}} {
+		if got := selectIDs(t, strategy, available, len(want)); !reflect.DeepEqual(got, want) {
+			t.Errorf("Select() = %v, want %v", got, want)
+		}
+	}
+	if strategy.LastAllocatedAt("a").IsZero() {
+		t.Error("LastAllocatedAt(a) is zero after selection")
+	}
+	if !strategy.LastAllocatedAt("d").IsZero() {
+		t.Error("LastAllocatedAt(d) is not zero for an unselected device")
+	}
+}
+
+func TestRandomStrategy(t *testing.T) {
+	available := testDevices("a", "b", "c")
+
+	got := selectIDs(t, RandomStrategy{}, available, 3)
+	seen := make(map[string]bool)
+	for _, id := range got {
+		seen[id] = true
+	}
+	if len(got) != 3 || len(seen) != 3 {
+		t.Errorf("Select() = %v, want a permutation of a, b, c", got)
+	}
+	if _, err := (RandomStrategy{}).Select(available, 4); err == nil {
+		t.Error("Select() of more devices than available error = nil")
+	}
+}
+
+func TestAllocationStrategyPreferredAllocation(t *testing.T) {
+	s, _ := newTestServer(t, WithAllocationStrategy(&RoundRobinStrategy{}))
+	var ids []string
+	for _, name := range []string{"micro0", "micro1", "micro2"} {
+		ids = append(ids, s.addDevice(&MicroDevice{Name: name}))
+	}
+	slices.Sort(ids)
+
+	pref, err := s.GetPreferredAllocation(context.Background(), &deviceapi.PreferredAllocationRequest{
+		ContainerRequests: []*deviceapi.ContainerPreferredAllocationRequest{{
+			AvailableDeviceIDs: ids,
+			AllocationSize:     2,
+		}},
+	})
+	if err != nil {
+		t.Fatalf("GetPreferredAllocation() error = %v", err)
+	}
+	preferred := pref.ContainerResponses[0].DeviceIDs
+	if !reflect.DeepEqual(preferred, ids[:2]) {
+		t.Fatalf("preferred devices = %v, want %v", preferred, ids[:2])
+	}
+
+	resp, err := s.Allocate(context.Background(), testutil.NewMockAllocateRequest().WithDeviceIDs(preferred...).Build())
+	if err != nil {
+		t.Fatalf("Allocate() error = %v", err)
+	}
+	assert.AssertAllocateResponse(t, resp, map[string]string{"MICRO_DEVICES": strings.Join(preferred, ",")}, nil)
+}
@@ -0,0 +1,253 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/http/pprof"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"gopkg.in/natefinch/lumberjack.v2"
+
+	"github.com/kelein/micro-device-plugin/pkg/version"
+)
+
+// TelemetryConfig configures the observability backends of the plugin,
+// the zero value logs text at info level to stdout and starts no listener
+type TelemetryConfig struct {
+	// MetricsAddr is the HTTP address serving the metrics, disabled if
+	// empty
+	MetricsAddr string
+
+	// TracingEndpoint is the OTLP/HTTP collector URL receiving the spans
+	// of the plugin RPCs, disabled if empty
+	TracingEndpoint string
+
+	// LogFormat is text or json, text if empty
+	LogFormat string
+
+	// LogLevel is debug, info, warn or error, info if empty
+	LogLevel string
+
+	// LogFile is the rotated log file written instead of stdout if set
+	LogFile string
+
+	// LogMaxSize is the log file size in megabytes triggering the rotation
+	LogMaxSize int
+
+	// LogMaxBackups is the number of rotated log files kept
+	LogMaxBackups int
+
+	// LogSampleRate is the number of records of the same message logged
+	// per sample window, sampling is disabled if 0
+	LogSampleRate int
+
+	// LogSampleWindow is the window of the log sampling, 10s if 0
+	LogSampleWindow time.Duration
+
+	// PprofAddr is the HTTP address serving the pprof profiles, disabled
+	// if empty
+	PprofAddr string
+
+	// Registerer registers the build info collector, the default
+	// Prometheus registerer if nil
+	Registerer prometheus.Registerer
+}
+
+// Telemetry owns the logger, metrics, tracing and pprof wiring of the
+// plugin
+type Telemetry struct {
+	mu       sync.Mutex
+	handler  atomic.Pointer[http.Handler]
+	servers  []*http.Server
+	logFile  io.Closer
+	sampler  *SamplingHandler
+	exporter *OTLPExporter
+}
+
+// NewTelemetry creates the telemetry, the metrics address serves the
+// default Prometheus registry until SetHandler is called
+func NewTelemetry() *Telemetry {
+	t := &Telemetry{}
+	t.SetHandler(promhttp.Handler())
+	return t
+}
+
+// SetHandler replaces the handler served at the metrics address
+func (t *Telemetry) SetHandler(h http.Handler) {
+	t.handler.Store(&h)
+}
+
+// ServeHTTP serves the current metrics address handler
+func (t *Telemetry) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	(*t.handler.Load()).ServeHTTP(w, r)
+}
+
+// Setup configures the default logger, registers the build info
+// collector, starts the span exporter and the metrics and pprof listeners
+func (t *Telemetry) Setup(cfg TelemetryConfig) error {
+	var level slog.Level
+	if cfg.LogLevel != "" {
+		if err := level.UnmarshalText([]byte(cfg.LogLevel)); err != nil {
+			return fmt.Errorf("invalid log level %q", cfg.LogLevel)
+		}
+	}
+	switch cfg.LogFormat {
+	case "", "text", "json":
+	default:
+		return fmt.Errorf("invalid log format %q, must be text or json", cfg.LogFormat)
+	}
+	if cfg.LogSampleRate < 0 || cfg.LogSampleWindow < 0 {
+		return fmt.Errorf("log sample rate and window must not be negative")
+	}
+	var exporter *OTLPExporter
+	if cfg.TracingEndpoint != "" {
+		var err error
+		if exporter, err = NewOTLPExporter(cfg.TracingEndpoint); err != nil {
+			return err
+		}
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	var w io.Writer = os.Stdout
+	if cfg.LogFile != "" {
+		f := NewLogFile(cfg.LogFile, cfg.LogMaxSize, cfg.LogMaxBackups)
+		w, t.logFile = f, f
+	}
+	logger := NewLogger(w, cfg.LogFormat, level)
+	if cfg.LogSampleRate > 0 {
+		window := cfg.LogSampleWindow
+		if window == 0 {
+			window = DefaultLogSampleWindow
+		}
+		t.sampler = NewSamplingHandler(logger.Handler(), window, cfg.LogSampleRate)
+		logger = slog.New(t.sampler)
+	}
+	slog.SetDefault(logger)
+
+	reg := cfg.Registerer
+	if reg == nil {
+		reg = prometheus.DefaultRegisterer
+	}
+	if err := reg.Register(version.NewCollector()); err != nil {
+		var are prometheus.AlreadyRegisteredError
+		if !errors.As(err, &are) {
+			return fmt.Errorf("register build info collector: %w", err)
+		}
+	}
+
+	if exporter != nil {
+		t.exporter = exporter
+		spanExporter.Store(exporter)
+		slog.Info("exporting spans", "endpoint", exporter.url)
+	}
+
+	if cfg.MetricsAddr != "" {
+		if err := t.listen("metrics", cfg.MetricsAddr, t); err != nil {
+			return err
+		}
+	}
+	if cfg.PprofAddr != "" {
+		mux := http.NewServeMux()
+		mux.HandleFunc("/debug/pprof/", pprof.Index)
+		mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+		mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+		mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+		mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+		if err := t.listen("pprof", cfg.PprofAddr, mux); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// listen binds addr and serves h in the background
+func (t *Telemetry) listen(name, addr string, h http.Handler) error {
+	lis, err := net.Listen("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("listen %s address %s: %w", name, addr, err)
+	}
+	srv := &http.Server{Handler: h}
+	t.servers = append(t.servers, srv)
+
+	slog.Info("HTTP server listening", "name", name, "addr", lis.Addr().String())
+	go func() {
+		if err := srv.Serve(lis); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			slog.Error("HTTP server failed", "name", name, "err", err)
+		}
+	}()
+	return nil
+}
+
+// Shutdown stops the listeners, exports the buffered spans and closes
+// the log file, the default logger writes to stdout afterwards
+func (t *Telemetry) Shutdown(ctx context.Context) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	var errs []error
+	for _, srv := range t.servers {
+		errs = append(errs, srv.Shutdown(ctx))
+	}
+	t.servers = nil
+	if t.exporter != nil {
+		spanExporter.CompareAndSwap(t.exporter, nil)
+		errs = append(errs, t.exporter.Shutdown(ctx))
+		t.exporter = nil
+	}
+	if t.sampler != nil {
+		t.sampler.Flush()
+		t.sampler = nil
+	}
+	if t.logFile != nil {
+		slog.SetDefault(NewLogger(os.Stdout, "", slog.LevelInfo))
+		errs = append(errs, t.logFile.Close())
+		t.logFile = nil
+	}
+	return errors.Join(errs...)
+}
+
+// NewLogger creates a logger of the text or json format with short
+// source filenames
+func NewLogger(w io.Writer, format string, level slog.Level) *slog.Logger {
+	replace := func(groups []string, a slog.Attr) slog.Attr {
+		// Use short source filename
+		if a.Key == slog.SourceKey {
+			source := a.Value.Any().(*slog.Source)
+			source.File = filepath.Base(source.File)
+		}
+		return a
+	}
+
+	opts := slog.HandlerOptions{
+		AddSource:   true,
+		Level:       level,
+		ReplaceAttr: replace,
+	}
+	if format == "json" {
+		return slog.New(slog.NewJSONHandler(w, &opts))
+	}
+	return slog.New(slog.NewTextHandler(w, &opts))
+}
+
+// NewLogFile returns a log file rotated when it exceeds maxSize
+// megabytes, keeping maxBackups rotated files
+func NewLogFile(path string, maxSize, maxBackups int) *lumberjack.Logger {
+	return &lumberjack.Logger{
+		Filename:   path,
+		MaxSize:    maxSize,
+		MaxBackups: maxBackups,
+	}
+}
@@ -0,0 +1,192 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	deviceapi "k8s.io/kubelet/pkg/apis/deviceplugin/v1beta1"
+
+	"github.com/kelein/micro-device-plugin/pkg/testutil"
+)
+
+func TestLogFileRotation(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "micro.log")
+	w := NewLogFile(path, 1, 3)
+	t.Cleanup(func() { w.Close() })
+
+	logger := slog.New(slog.NewTextHandler(w, nil))
+	line := strings.Repeat("x", 1024)
+	for i := 0; i < 1200; i++ {
+		logger.Info("filler", "data", line)
+	}
+
+	backups, err := filepath.Glob(filepath.Join(dir, "micro-*.log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(backups) == 0 {
+		t.Error("no rotated log file created after exceeding the size limit")
+	}
+}
+
+// restoreLogger restores the default logger replaced by Setup
+func restoreLogger(t *testing.T) {
+	prev := slog.Default()
+	t.Cleanup(func() { slog.SetDefault(prev) })
+}
+
+func TestTelemetryZeroConfig(t *testing.T) {
+	restoreLogger(t)
+	tel := NewTelemetry()
+	if err := tel.Setup(TelemetryConfig{}); err != nil {
+		t.Fatalf("Setup with zero config: %v", err)
+	}
+	t.Cleanup(func() { tel.Shutdown(context.Background()) })
+
+	if len(tel.servers) != 0 {
+		t.Errorf("zero config started %d listeners, want none", len(tel.servers))
+	}
+	if tel.logFile != nil {
+		t.Error("zero config opened a log file, want stdout")
+	}
+	ctx := context.Background()
+	if !slog.Default().Enabled(ctx, slog.LevelInfo) || slog.Default().Enabled(ctx, slog.LevelDebug) {
+		t.Error("zero config log level is not info")
+	}
+	if _, ok := slog.Default().Handler().(*slog.TextHandler); !ok {
+		t.Errorf("zero config log handler is %T, want text", slog.Default().Handler())
+	}
+	if err := tel.Shutdown(ctx); err != nil {
+		t.Errorf("Shutdown: %v", err)
+	}
+}
+
+func TestTelemetryInvalidConfig(t *testing.T) {
+	restoreLogger(t)
+	for _, cfg := range []TelemetryConfig{
+		{LogLevel: "loud"},
+		{LogFormat: "xml"},
+		{TracingEndpoint: "collector:4318"},
+		{LogSampleRate: -1},
+	} {
+		if err := NewTelemetry().Setup(cfg); err == nil {
+			t.Errorf("Setup(%+v) succeeded, want error", cfg)
+		}
+	}
+}
+
+func TestTelemetryListeners(t *testing.T) {
+	restoreLogger(t)
+	reg := prometheus.NewRegistry()
+	tel := NewTelemetry()
+	err := tel.Setup(TelemetryConfig{
+		MetricsAddr: "127.0.0.1:0",
+		PprofAddr:   "127.0.0.1:0",
+		LogFormat:   "json",
+		LogFile:     filepath.Join(t.TempDir(), "micro.log"),
+		Registerer:  reg,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tel.servers) != 2 {
+		t.Fatalf("started %d listeners, want metrics and pprof", len(tel.servers))
+	}
+	if _, ok := slog.Default().Handler().(*slog.JSONHandler); !ok {
+		t.Errorf("log handler is %T, want json", slog.Default().Handler())
+	}
+	families, err := reg.Gather()
+	if err != nil || len(families) == 0 {
+		t.Errorf("build info collector not registered: %v", err)
+	}
+
+	if err := tel.Shutdown(context.Background()); err != nil {
+		t.Fatalf("Shutdown: %v", err)
+	}
+	if tel.logFile != nil || len(tel.servers) != 0 {
+		t.Error("Shutdown left listeners or the log file open")
+	}
+}
+
+func TestTelemetryTracing(t *testing.T) {
+	restoreLogger(t)
+	requests := make(chan otlpTraceRequest, 4)
+	collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/v1/traces" || r.Header.Get("Content-Type") != "application/json" {
+			t.Errorf("export request %s %s of %s, want a JSON POST to /v1/traces", r.Method, r.URL.Path, r.Header.Get("Content-Type"))
+		}
+		var req otlpTraceRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("decode export request: %v", err)
+		}
+		requests <- req
+	}))
+	defer collector.Close()
+
+	tel := NewTelemetry()
+	if err := tel.Setup(TelemetryConfig{TracingEndpoint: collector.URL, Registerer: prometheus.NewRegistry()}); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { tel.Shutdown(context.Background()) })
+
+	// action: a successful Allocate call and a PreStartContainer call
+	// failing on a device without device file
+	s, _ := newTestServer(t)
+	id := s.addDevice(&MicroDevice{Name: "micro0"})
+	missing := s.addDevice(&MicroDevice{Name: "micro1", Path: filepath.Join(t.TempDir(), "micro1")})
+	client := newPluginClient(t, s)
+	if _, err := client.Allocate(context.Background(), testutil.NewMockAllocateRequest().WithDeviceIDs(id).Build()); err != nil {
+		t.Fatal(err)
+	}
+	_, err := client.PreStartContainer(context.Background(), &deviceapi.PreStartContainerRequest{DevicesIDs: []string{missing}})
+	if status.Code(err) == codes.OK {
+		t.Fatal("PreStartContainer() of a device without device file succeeded")
+	}
+
+	// expected: Shutdown exports the buffered spans
+	if err := tel.Shutdown(context.Background()); err != nil {
+		t.Fatalf("Shutdown: %v", err)
+	}
+	var req otlpTraceRequest
+	select {
+	case req = <-requests:
+	default:
+		t.Fatal("no spans exported on Shutdown")
+	}
+	if len(req.ResourceSpans) != 1 || len(req.ResourceSpans[0].ScopeSpans) != 1 {
+		t.Fatalf("export request %+v, want the spans of one resource and scope", req)
+	}
+	spans := req.ResourceSpans[0].ScopeSpans[0].Spans
+	if len(spans) != 2 {
+		t.Fatalf("exported %d spans, want 2", len(spans))
+	}
+	wants := []struct {
+		name string
+		code int
+	}{
+		{"/v1beta1.DevicePlugin/Allocate", otlpStatusCodeOK},
+		{"/v1beta1.DevicePlugin/PreStartContainer", otlpStatusCodeError},
+	}
+	for i, want := range wants {
+		span := spans[i]
+		if span.Name != want.name || span.Status.Code != want.code {
+			t.Errorf("span %d = %s with status %d, want %s with status %d", i, span.Name, span.Status.Code, want.name, want.code)
+		}
+		if len(span.TraceID) != 32 || len(span.SpanID) != 16 || span.StartTimeUnixNano > span.EndTimeUnixNano {
+			t.Errorf("span %d has trace %q, span %q, times %s..%s", i, span.TraceID, span.SpanID, span.StartTimeUnixNano, span.EndTimeUnixNano)
+		}
+	}
+	if spanExporter.Load() != nil {
+		t.Error("Shutdown left the span exporter installed")
+	}
+}
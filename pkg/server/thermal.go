@@ -0,0 +1,100 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	deviceapi "k8s.io/kubelet/pkg/apis/deviceplugin/v1beta1"
+)
+
+// ThermalRoot is the sysfs directory of the thermal zones
+const ThermalRoot = "/sys/class/thermal"
+
+// LoadThermalZoneMap reads the JSON file mapping the device names to
+// their thermal zones, e.g. {"micro0": "thermal_zone0"}
+func LoadThermalZoneMap(path string) (map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var zones map[string]string
+	if err := json.Unmarshal(data, &zones); err != nil {
+		return nil, fmt.Errorf("decode thermal zone map %s: %w", path, err)
+	}
+	for name, zone := range zones {
+		if zone == "" || strings.ContainsRune(zone, filepath.Separator) {
+			return nil, fmt.Errorf("device %s has invalid thermal zone %q", name, zone)
+		}
+	}
+	return zones, nil
+}
+
+// ThermalScorer prefers the coolest devices so that the chosen set has
+// the lowest average temperature, devices without a readable thermal
+// zone are ranked last
+type ThermalScorer struct {
+	// Root is the thermal zones directory, ThermalRoot if empty
+	Root string
+
+	// Zones maps the device names to their thermal zone directories
+	Zones map[string]string
+
+	// temperature is the average temperature gauge of the server
+	// scoring with the scorer
+	temperature prometheus.Gauge
+}
+
+// NewThermalScorer creates a thermal scorer of the sysfs thermal zones
+func NewThermalScorer(zones map[string]string) ThermalScorer {
+	return ThermalScorer{Root: ThermalRoot, Zones: zones}
+}
+
+// Score implements DeviceScorer
+func (t ThermalScorer) Score(candidates []*MicroDevice, _ *deviceapi.PreferredAllocationRequest) ([]float64, error) {
+	scores := make([]float64, len(candidates))
+	var sum float64
+	var n int
+	for i, dev := range candidates {
+		temp, err := t.Temperature(dev.Name)
+		if err != nil {
+			scores[i] = math.Inf(-1)
+			continue
+		}
+		scores[i] = -temp
+		sum += temp
+		n++
+	}
+	if n > 0 && t.temperature != nil {
+		t.temperature.Set(sum / float64(n))
+	}
+	return scores, nil
+}
+
+// Temperature reads the temperature in degrees Celsius of the thermal
+// zone of the device name
+func (t ThermalScorer) Temperature(name string) (float64, error) {
+	zone, ok := t.Zones[name]
+	if !ok {
+		return 0, fmt.Errorf("device %s has no thermal zone", name)
+	}
+	root := t.Root
+	if root == "" {
+		root = ThermalRoot
+	}
+	data, err := os.ReadFile(filepath.Join(root, zone, "temp"))
+	if err != nil {
+		return 0, err
+	}
+	// sysfs reports millidegrees Celsius
+	milli, err := strconv.ParseInt(strings.TrimSpace(string(data)), 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("parse temperature of %s: %w", zone, err)
+	}
+	return float64(milli) / 1000, nil
+}
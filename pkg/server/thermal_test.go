@@ -0,0 +1,100 @@
+package server
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	deviceapi "k8s.io/kubelet/pkg/apis/deviceplugin/v1beta1"
+
+	"github.com/kelein/micro-device-plugin/pkg/testing/assert"
+)
+
+// fakeThermalZones writes a sysfs thermal tree with a zone of the given
+// millidegrees temperature per device
+func fakeThermalZones(t *testing.T, temps map[string]string) (string, map[string]string) {
+	t.Helper()
+	root := t.TempDir()
+	zones := make(map[string]string, len(temps))
+	i := 0
+	for name, temp := range temps {
+		zone := "thermal_zone" + string(rune('0'+i))
+		i++
+		if err := os.MkdirAll(filepath.Join(root, zone), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(root, zone, "temp"), []byte(temp+"\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		zones[name] = zone
+	}
+	return root, zones
+}
+
+func TestThermalScorerPrefersCoolerDevices(t *testing.T) {
+	root, zones := fakeThermalZones(t, map[string]string{
+		"micro0": "71000",
+		"micro1": "42500",
+		"micro2": "55000",
+		"micro3": "38000",
+	})
+	reg := prometheus.NewRegistry()
+	s, _ := newTestServer(t, WithScorer(ThermalScorer{Root: root, Zones: zones}), WithMetrics(reg))
+	for _, name := range []string{"micro0", "micro1", "micro2", "micro3", "micro4"} {
+		s.addDevice(&MicroDevice{Name: name})
+	}
+
+	resp, err := s.GetPreferredAllocation(context.Background(), &deviceapi.PreferredAllocationRequest{
+		ContainerRequests: []*deviceapi.ContainerPreferredAllocationRequest{{
+			AvailableDeviceIDs: []string{
+				deviceID("micro0"), deviceID("micro1"), deviceID("micro2"), deviceID("micro3"), deviceID("micro4"),
+			},
+			AllocationSize: 2,
+		}},
+	})
+	if err != nil {
+		t.Fatalf("GetPreferredAllocation() = %v", err)
+	}
+	want := []string{deviceID("micro3"), deviceID("micro1")}
+	if got := resp.ContainerResponses[0].DeviceIDs; !reflect.DeepEqual(got, want) {
+		t.Errorf("preferred devices = %v, want the coolest %v", got, want)
+	}
+
+	// the device without thermal zone is not averaged
+	assert.AssertMetricValue(t, reg, "micro_device_plugin_device_temperature_celsius", nil, (71+42.5+55+38)/4.0)
+}
+
+func TestThermalScorerUnknownDevicesLast(t *testing.T) {
+	root, zones := fakeThermalZones(t, map[string]string{"micro1": "90000"})
+	scores, err := ThermalScorer{Root: root, Zones: zones}.Score(testDevices("micro0", "micro1"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if scores[0] >= scores[1] {
+		t.Errorf("scores = %v, want the device without thermal zone last", scores)
+	}
+}
+
+func TestLoadThermalZoneMap(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "zones.json")
+	if err := os.WriteFile(path, []byte(`{"micro0": "thermal_zone3"}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	zones, err := LoadThermalZoneMap(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := map[string]string{"micro0": "thermal_zone3"}; !reflect.DeepEqual(zones, want) {
+		t.Errorf("zones = %v, want %v", zones, want)
+	}
+
+	if err := os.WriteFile(path, []byte(`{"micro0": "../etc"}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadThermalZoneMap(path); err == nil {
+		t.Error("zone outside the thermal directory accepted")
+	}
+}
@@ -0,0 +1,324 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/kelein/micro-device-plugin/pkg/version"
+)
+
+const (
+	// tracingServiceName is the service.name resource attribute of the
+	// exported spans
+	tracingServiceName = "micro-device-plugin"
+
+	// otlpTracesPath is the OTLP/HTTP traces path appended to a collector
+	// URL without path
+	otlpTracesPath = "/v1/traces"
+
+	// spanBatchSize is the number of buffered spans triggering an export
+	spanBatchSize = 512
+
+	// maxQueuedSpans caps the buffered spans, the newer spans are dropped
+	// while the collector is unreachable
+	maxQueuedSpans = 4 * spanBatchSize
+
+	// spanExportInterval is the interval of exporting the buffered spans
+	spanExportInterval = 5 * time.Second
+)
+
+// OTLP span kind and status codes of the JSON encoding
+const (
+	otlpSpanKindServer  = 2
+	otlpStatusCodeOK    = 1
+	otlpStatusCodeError = 2
+)
+
+// spanExporter is the exporter of the RPC spans, tracing is disabled if
+// nil
+var spanExporter atomic.Pointer[OTLPExporter]
+
+// Span is a finished RPC span
+type Span struct {
+	Name       string
+	TraceID    [16]byte
+	SpanID     [8]byte
+	Start      time.Time
+	End        time.Time
+	Attributes map[string]string
+	Err        error
+}
+
+// OTLPExporter batches the finished spans and sends them to an OTLP/HTTP
+// collector as JSON encoded trace export requests
+type OTLPExporter struct {
+	url    string
+	client *http.Client
+
+	mu      sync.Mutex
+	spans   []Span
+	dropped int
+
+	flush chan struct{}
+	stop  chan struct{}
+	done  chan struct{}
+}
+
+// NewOTLPExporter creates an exporter of the collector at endpoint, the
+// /v1/traces path is appended if the URL has no path
+func NewOTLPExporter(endpoint string) (*OTLPExporter, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil || u.Host == "" || (u.Scheme != "http" && u.Scheme != "https") {
+		return nil, fmt.Errorf("invalid tracing endpoint %q, must be an http or https URL", endpoint)
+	}
+	if u.Path == "" || u.Path == "/" {
+		u.Path = otlpTracesPath
+	}
+	e := &OTLPExporter{
+		url:    u.String(),
+		client: &http.Client{Timeout: 10 * time.Second},
+		flush:  make(chan struct{}, 1),
+		stop:   make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+	go e.run()
+	return e, nil
+}
+
+// Record buffers the span until the next export
+func (e *OTLPExporter) Record(span Span) {
+	e.mu.Lock()
+	if len(e.spans) >= maxQueuedSpans {
+		e.dropped++
+		e.mu.Unlock()
+		return
+	}
+	e.spans = append(e.spans, span)
+	full := len(e.spans) >= spanBatchSize
+	e.mu.Unlock()
+	if full {
+		select {
+		case e.flush <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// run exports the buffered spans every export interval and when a batch
+// is full until the exporter is shut down
+func (e *OTLPExporter) run() {
+	defer close(e.done)
+	ticker := time.NewTicker(spanExportInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+		case <-e.flush:
+		case <-e.stop:
+			return
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), spanExportInterval)
+		if err := e.Export(ctx); err != nil {
+			slog.Warn("export spans failed", "endpoint", e.url, "err", err)
+		}
+		cancel()
+	}
+}
+
+// Export sends the buffered spans to the collector, the spans are
+// dropped if the collector rejects them
+func (e *OTLPExporter) Export(ctx context.Context) error {
+	e.mu.Lock()
+	spans, dropped := e.spans, e.dropped
+	e.spans, e.dropped = nil, 0
+	e.mu.Unlock()
+	if dropped > 0 {
+		slog.Warn("span queue full, spans dropped", "dropped", dropped)
+	}
+	if len(spans) == 0 {
+		return nil
+	}
+
+	body, err := json.Marshal(encodeSpans(spans))
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("collector %s returned %s for %d spans", e.url, resp.Status, len(spans))
+	}
+	return nil
+}
+
+// Shutdown stops the periodic export and exports the buffered spans
+func (e *OTLPExporter) Shutdown(ctx context.Context) error {
+	select {
+	case <-e.stop:
+	default:
+		close(e.stop)
+	}
+	select {
+	case <-e.done:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	return e.Export(ctx)
+}
+
+// otlpValue is the any value of an OTLP attribute
+type otlpValue struct {
+	StringValue string `json:"stringValue"`
+}
+
+// otlpAttribute is an OTLP key value attribute
+type otlpAttribute struct {
+	Key   string    `json:"key"`
+	Value otlpValue `json:"value"`
+}
+
+// otlpStatus is the status of an OTLP span
+type otlpStatus struct {
+	Code    int    `json:"code"`
+	Message string `json:"message,omitempty"`
+}
+
+// otlpSpan is the JSON encoding of an OTLP span, the IDs are hex encoded
+// and the 64 bit timestamps are decimal strings
+type otlpSpan struct {
+	TraceID           string          `json:"traceId"`
+	SpanID            string          `json:"spanId"`
+	Name              string          `json:"name"`
+	Kind              int             `json:"kind"`
+	StartTimeUnixNano string          `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string          `json:"endTimeUnixNano"`
+	Attributes        []otlpAttribute `json:"attributes,omitempty"`
+	Status            otlpStatus      `json:"status"`
+}
+
+// otlpScopeSpans are the spans of one instrumentation scope
+type otlpScopeSpans struct {
+	Scope struct {
+		Name    string `json:"name"`
+		Version string `json:"version,omitempty"`
+	} `json:"scope"`
+	Spans []otlpSpan `json:"spans"`
+}
+
+// otlpResourceSpans are the spans of one resource
+type otlpResourceSpans struct {
+	Resource struct {
+		Attributes []otlpAttribute `json:"attributes"`
+	} `json:"resource"`
+	ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+}
+
+// otlpTraceRequest is the OTLP trace export request
+type otlpTraceRequest struct {
+	ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+}
+
+// encodeSpans encodes the spans as an OTLP trace export request
+func encodeSpans(spans []Span) otlpTraceRequest {
+	scope := otlpScopeSpans{Spans: make([]otlpSpan, 0, len(spans))}
+	scope.Scope.Name = tracingServiceName
+	scope.Scope.Version = version.Version
+	for _, span := range spans {
+		out := otlpSpan{
+			TraceID:           hex.EncodeToString(span.TraceID[:]),
+			SpanID:            hex.EncodeToString(span.SpanID[:]),
+			Name:              span.Name,
+			Kind:              otlpSpanKindServer,
+			StartTimeUnixNano: strconv.FormatInt(span.Start.UnixNano(), 10),
+			EndTimeUnixNano:   strconv.FormatInt(span.End.UnixNano(), 10),
+			Status:            otlpStatus{Code: otlpStatusCodeOK},
+		}
+		for k, v := range span.Attributes {
+			out.Attributes = append(out.Attributes, otlpAttribute{Key: k, Value: otlpValue{StringValue: v}})
+		}
+		if span.Err != nil {
+			out.Status = otlpStatus{Code: otlpStatusCodeError, Message: span.Err.Error()}
+		}
+		scope.Spans = append(scope.Spans, out)
+	}
+
+	rs := otlpResourceSpans{ScopeSpans: []otlpScopeSpans{scope}}
+	rs.Resource.Attributes = []otlpAttribute{
+		{Key: "service.name", Value: otlpValue{StringValue: tracingServiceName}},
+		{Key: "service.version", Value: otlpValue{StringValue: version.Version}},
+	}
+	return otlpTraceRequest{ResourceSpans: []otlpResourceSpans{rs}}
+}
+
+// startSpan starts the span of the RPC method, nil if tracing is disabled
+func startSpan(ctx context.Context, method string) *Span {
+	if spanExporter.Load() == nil {
+		return nil
+	}
+	span := &Span{
+		Name:       method,
+		Start:      time.Now(),
+		Attributes: map[string]string{"rpc.system": "grpc", "rpc.method": method},
+	}
+	rand.Read(span.TraceID[:])
+	rand.Read(span.SpanID[:])
+	if id := RequestID(ctx); id != "" {
+		span.Attributes["request_id"] = id
+	}
+	return span
+}
+
+// endSpan records the span with the RPC error to the exporter
+func endSpan(span *Span, err error) {
+	e := spanExporter.Load()
+	if span == nil || e == nil {
+		return
+	}
+	span.End = time.Now()
+	if err != nil && !errors.Is(err, context.Canceled) && status.Code(err) != codes.Canceled {
+		span.Err = err
+		span.Attributes["rpc.grpc.status_code"] = status.Code(err).String()
+	}
+	e.Record(*span)
+}
+
+// unaryTrace records a span of every unary RPC call
+func unaryTrace(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	span := startSpan(ctx, info.FullMethod)
+	resp, err := handler(ctx, req)
+	endSpan(span, err)
+	return resp, err
+}
+
+// streamTrace records a span of every streaming RPC call lasting until
+// the stream is closed
+func streamTrace(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	span := startSpan(ss.Context(), info.FullMethod)
+	err := handler(srv, ss)
+	endSpan(span, err)
+	return err
+}
@@ -0,0 +1,128 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"path/filepath"
+	"strings"
+)
+
+// Udev kernel event actions
+const (
+	UdevAdd    = "add"
+	UdevRemove = "remove"
+)
+
+// UdevEvent is a kernel uevent received from netlink
+type UdevEvent struct {
+	Action    string
+	DevPath   string
+	Subsystem string
+	DevName   string
+	Env       map[string]string
+}
+
+// UdevWatcher watches kernel uevents of a device subsystem
+type UdevWatcher struct {
+	subsystem string
+	reader    io.ReadCloser
+}
+
+// NewUdevWatcher creates a udev watcher reading from the netlink socket
+func NewUdevWatcher(subsystem string) (*UdevWatcher, error) {
+	r, err := openNetlink()
+	if err != nil {
+		return nil, err
+	}
+	return newUdevWatcher(subsystem, r), nil
+}
+
+func newUdevWatcher(subsystem string, r io.ReadCloser) *UdevWatcher {
+	return &UdevWatcher{subsystem: subsystem, reader: r}
+}
+
+// Watch reads uevents until ctx is done and calls fn for each event
+// matching the watched subsystem
+func (w *UdevWatcher) Watch(ctx context.Context, fn func(UdevEvent)) error {
+	go func() {
+		<-ctx.Done()
+		w.reader.Close()
+	}()
+
+	buf := make([]byte, 64*1024)
+	for {
+		n, err := w.reader.Read(buf)
+		if err != nil {
+			if ctx.Err() != nil || errors.Is(err, io.EOF) {
+				return nil
+			}
+			return err
+		}
+
+		event, ok := parseUevent(buf[:n])
+		if !ok || event.Subsystem != w.subsystem {
+			continue
+		}
+		fn(event)
+	}
+}
+
+// parseUevent parses a NUL separated kernel uevent message
+// formatted as `ACTION@DEVPATH\0KEY=VALUE\0...`
+func parseUevent(msg []byte) (UdevEvent, bool) {
+	fields := bytes.Split(msg, []byte{0})
+	if len(fields) == 0 || !bytes.Contains(fields[0], []byte("@")) {
+		return UdevEvent{}, false
+	}
+
+	event := UdevEvent{Env: make(map[string]string)}
+	for _, field := range fields[1:] {
+		key, value, ok := strings.Cut(string(field), "=")
+		if !ok {
+			continue
+		}
+		event.Env[key] = value
+	}
+	event.Action = event.Env["ACTION"]
+	event.DevPath = event.Env["DEVPATH"]
+	event.Subsystem = event.Env["SUBSYSTEM"]
+	event.DevName = event.Env["DEVNAME"]
+	if event.Action == "" {
+		return UdevEvent{}, false
+	}
+	return event, true
+}
+
+// watchUdev translates udev events into device map updates
+func (s *MicroDeviceServer) watchUdev() error {
+	s.logger.Info("watching udev events ...", "subsystem", s.udevSubsystem)
+	w, err := NewUdevWatcher(s.udevSubsystem)
+	if err != nil {
+		return err
+	}
+	return s.handleUdev(w)
+}
+
+func (s *MicroDeviceServer) handleUdev(w *UdevWatcher) error {
+	return w.Watch(s.ctx, func(event UdevEvent) {
+		name := event.DevName
+		if name == "" {
+			name = filepath.Base(event.DevPath)
+		}
+		name = filepath.Base(name)
+		s.logger.Info("udev event", "action", event.Action, "name", name)
+
+		switch event.Action {
+		case UdevAdd:
+			if !s.matchDevice(name) {
+				s.logger.Info("skip unmatched device", "name", name)
+				return
+			}
+			s.addDevice(&MicroDevice{Name: name, Path: filepath.Join(s.devicePath, name)})
+		case UdevRemove:
+			s.removeDevice(name)
+		}
+	})
+}
@@ -0,0 +1,34 @@
+package server
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"syscall"
+)
+
+// netlinkKernelGroup is the multicast group of kernel uevents
+const netlinkKernelGroup = 1
+
+// openNetlink opens a netlink socket subscribed to kernel uevents
+func openNetlink() (io.ReadCloser, error) {
+	fd, err := syscall.Socket(
+		syscall.AF_NETLINK,
+		syscall.SOCK_RAW|syscall.SOCK_CLOEXEC|syscall.SOCK_NONBLOCK,
+		syscall.NETLINK_KOBJECT_UEVENT,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("netlink socket error: %w", err)
+	}
+
+	addr := &syscall.SockaddrNetlink{
+		Family: syscall.AF_NETLINK,
+		Groups: netlinkKernelGroup,
+		Pid:    0,
+	}
+	if err := syscall.Bind(fd, addr); err != nil {
+		syscall.Close(fd)
+		return nil, fmt.Errorf("netlink bind error: %w", err)
+	}
+	return os.NewFile(uintptr(fd), "netlink-uevent"), nil
+}
@@ -0,0 +1,13 @@
+//go:build !linux
+
+package server
+
+import (
+	"errors"
+	"io"
+)
+
+// openNetlink is only supported on linux
+func openNetlink() (io.ReadCloser, error) {
+	return nil, errors.New("udev netlink is only supported on linux")
+}
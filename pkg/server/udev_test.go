@@ -0,0 +1,116 @@
+package server
+
+import (
+	"io"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// mockNetlink returns one uevent message per read and io.EOF once all
+// messages are read
+type mockNetlink struct {
+	messages [][]byte
+}
+
+func (m *mockNetlink) Read(p []byte) (int, error) {
+	if len(m.messages) == 0 {
+		return 0, io.EOF
+	}
+	n := copy(p, m.messages[0])
+	m.messages = m.messages[1:]
+	return n, nil
+}
+
+func (m *mockNetlink) Close() error { return nil }
+
+// uevent formats a kernel uevent message of the device
+func uevent(action, subsystem, name string) []byte {
+	devPath := "/devices/virtual/" + subsystem + "/" + name
+	fields := []string{
+		action + "@" + devPath,
+		"ACTION=" + action,
+		"DEVPATH=" + devPath,
+		"SUBSYSTEM=" + subsystem,
+		"DEVNAME=" + subsystem + "/" + name,
+	}
+	return []byte(strings.Join(fields, "\x00") + "\x00")
+}
+
+func TestParseUevent(t *testing.T) {
+	tests := []struct {
+		name   string
+		msg    []byte
+		want   UdevEvent
+		wantOK bool
+	}{
+		{
+			name: "add",
+			msg:  uevent(UdevAdd, "micro", "micro0"),
+			want: UdevEvent{
+				Action:    UdevAdd,
+				DevPath:   "/devices/virtual/micro/micro0",
+				Subsystem: "micro",
+				DevName:   "micro/micro0",
+				Env: map[string]string{
+					"ACTION":    UdevAdd,
+					"DEVPATH":   "/devices/virtual/micro/micro0",
+					"SUBSYSTEM": "micro",
+					"DEVNAME":   "micro/micro0",
+				},
+			},
+			wantOK: true,
+		},
+		{name: "udev daemon message", msg: []byte("libudev\x00\xfe\xed\xca\xfe")},
+		{name: "no action", msg: []byte("add@/devices/micro0\x00SUBSYSTEM=micro\x00")},
+		{name: "empty", msg: nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := parseUevent(tt.msg)
+			if ok != tt.wantOK {
+				t.Fatalf("parseUevent() ok = %v, want %v", ok, tt.wantOK)
+			}
+			if ok && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseUevent() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHandleUdev(t *testing.T) {
+	s, _ := newTestServer(t, WithUdev("micro"), WithDevicesRegex(regexp.MustCompile(`^micro\d+$`)))
+	netlink := &mockNetlink{messages: [][]byte{
+		uevent(UdevAdd, "micro", "micro0"),
+		uevent(UdevAdd, "micro", "micro1"),
+		uevent(UdevAdd, "block", "sda"),
+		uevent(UdevAdd, "micro", "control"),
+		uevent(UdevRemove, "micro", "micro0"),
+		uevent(UdevAdd, "micro", "micro2"),
+	}}
+
+	// action: the watcher returns once the mock netlink socket is drained
+	if err := s.handleUdev(newUdevWatcher("micro", netlink)); err != nil {
+		t.Fatalf("handleUdev() = %v", err)
+	}
+
+	var got []string
+	s.mu.RLock()
+	for name, dev := range s.devices {
+		got = append(got, name)
+		if want := filepath.Join(s.devicePath, name); dev.Path != want {
+			t.Errorf("device %s path = %s, want %s", name, dev.Path, want)
+		}
+	}
+	s.mu.RUnlock()
+	slices.Sort(got)
+	if want := []string{"micro1", "micro2"}; !slices.Equal(got, want) {
+		t.Errorf("devices = %v, want %v", got, want)
+	}
+	if n := len(s.notify); n == 0 {
+		t.Error("udev events did not notify ListAndWatch")
+	}
+}
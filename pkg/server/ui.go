@@ -0,0 +1,176 @@
+package server
+
+import (
+	"html/template"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxRecentEvents is the number of device events shown on the status page
+const maxRecentEvents = 20
+
+// eventHistory keeps the most recent device events
+type eventHistory struct {
+	mu     sync.Mutex
+	events []DeviceEvent
+}
+
+// subscribe records the events of all types published on bus
+func (h *eventHistory) subscribe(bus *EventBus) {
+	for t := DeviceAdded; t <= AllocationCompleted; t++ {
+		bus.Subscribe(t, h.record)
+	}
+}
+
+func (h *eventHistory) record(event DeviceEvent) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.events = append(h.events, event)
+	if len(h.events) > maxRecentEvents {
+		h.events = h.events[len(h.events)-maxRecentEvents:]
+	}
+}
+
+// recent returns the recorded events, newest first
+func (h *eventHistory) recent() []DeviceEvent {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	events := make([]DeviceEvent, len(h.events))
+	for i, event := range h.events {
+		events[len(events)-1-i] = event
+	}
+	return events
+}
+
+// uiDevice is a device row of the status page
+type uiDevice struct {
+	DeviceInfo
+	NUMANode string
+	LastSeen string
+}
+
+// uiEvent is an event row of the status page
+type uiEvent struct {
+	Time    string
+	Type    string
+	Subject string
+}
+
+// uiPage is the data of the status page template
+type uiPage struct {
+	Resource          string
+	Status            PluginStatus
+	Devices           []uiDevice
+	Events            []uiEvent
+	ActiveAllocations int
+	ActiveStreams     int32
+}
+
+var uiTemplate = template.Must(template.New("ui").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<meta http-equiv="refresh" content="5">
+<title>{{.Resource}} device plugin</title>
+<style>
+body { font-family: sans-serif; margin: 2em; color: #222; }
+table { border-collapse: collapse; margin-bottom: 2em; }
+th, td { border: 1px solid #ccc; padding: 4px 10px; text-align: left; }
+th { background: #f0f0f0; }
+.Healthy { color: #1a7f37; }
+.Unhealthy { color: #cf222e; }
+code { font-size: 0.9em; }
+</style>
+</head>
+<body>
+<h1>{{.Resource}} device plugin</h1>
+
+<h2>Status</h2>
+<table>
+<tr><th>Phase</th><td>{{.Status.Phase}}</td></tr>
+<tr><th>Registered with kubelet</th><td>{{.Status.RegisteredWithKubelet}}</td></tr>
+<tr><th>Uptime</th><td>{{.Status.Uptime}}</td></tr>
+<tr><th>Restarts</th><td>{{.Status.RestartCount}}</td></tr>
+<tr><th>Last ListAndWatch</th><td>{{.Status.LastListAndWatch}}</td></tr>
+{{- if .Status.LastError}}
+<tr><th>Last error</th><td>{{.Status.LastError}}</td></tr>
+{{- end}}
+</table>
+
+<h2>Metrics</h2>
+<table>
+<tr><th>Devices</th><td>{{.Status.DeviceCount}}</td></tr>
+<tr><th>Healthy devices</th><td>{{.Status.HealthyCount}}</td></tr>
+<tr><th>Allocatable capacity</th><td>{{.Status.AllocatableCapacity}}</td></tr>
+<tr><th>Reserved capacity</th><td>{{.Status.ReservedCapacity}}</td></tr>
+<tr><th>Active allocations</th><td>{{.ActiveAllocations}}</td></tr>
+<tr><th>Active ListAndWatch streams</th><td>{{.ActiveStreams}}</td></tr>
+</table>
+
+<h2>Devices</h2>
+<table>
+<tr><th>Name</th><th>ID</th><th>Health</th><th>NUMA node</th><th>Last seen</th></tr>
+{{- range .Devices}}
+<tr><td>{{.Name}}</td><td><code>{{.ID}}</code></td><td class="{{.Health}}">{{.Health}}{{if .Reserved}} (reserved){{end}}</td><td>{{.NUMANode}}</td><td>{{.LastSeen}}</td></tr>
+{{- else}}
+<tr><td colspan="5">no devices</td></tr>
+{{- end}}
+</table>
+
+<h2>Recent events</h2>
+<table>
+<tr><th>Time</th><th>Event</th><th>Subject</th></tr>
+{{- range .Events}}
+<tr><td>{{.Time}}</td><td>{{.Type}}</td><td>{{.Subject}}</td></tr>
+{{- else}}
+<tr><td colspan="3">no events</td></tr>
+{{- end}}
+</table>
+</body>
+</html>
+`))
+
+// handleUI serves the status page for browser based debugging
+func (s *MicroDeviceServer) handleUI(w http.ResponseWriter, r *http.Request) {
+	page := uiPage{
+		Resource:      s.resourceName,
+		Status:        s.Status(),
+		ActiveStreams: s.activeStreams.Load(),
+	}
+
+	s.mu.RLock()
+	lastSeen := make(map[string]time.Time, len(s.lastSeen))
+	for name, t := range s.lastSeen {
+		lastSeen[name] = t
+	}
+	s.mu.RUnlock()
+	for _, dev := range s.Devices() {
+		row := uiDevice{DeviceInfo: dev, NUMANode: dev.Annotations[numaAnnotation]}
+		if t, ok := lastSeen[dev.Name]; ok {
+			row.LastSeen = t.Format(time.RFC3339)
+		}
+		page.Devices = append(page.Devices, row)
+	}
+
+	s.allocMu.Lock()
+	page.ActiveAllocations = len(s.allocated)
+	s.allocMu.Unlock()
+
+	for _, event := range s.history.recent() {
+		row := uiEvent{Time: event.Time.Format(time.RFC3339), Type: event.Type.String()}
+		switch {
+		case event.Device != nil:
+			row.Subject = event.Device.Name
+		case len(event.DeviceIDs) > 0:
+			row.Subject = strings.Join(event.DeviceIDs, ", ")
+		}
+		page.Events = append(page.Events, row)
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := uiTemplate.Execute(w, page); err != nil {
+		s.logger.Error("render status page failed", "err", err)
+	}
+}
@@ -0,0 +1,33 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestHandleUI(t *testing.T) {
+	s, _ := newTestServer(t)
+	s.addDevice(&MicroDevice{Name: "micro0"})
+	s.addDevice(&MicroDevice{Name: "micro1"})
+
+	rec := httptest.NewRecorder()
+	s.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/ui", nil))
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("GET /ui status = %d, want %d", rec.Code, http.StatusOK)
+	}
+	if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/html") {
+		t.Errorf("Content-Type = %q, want text/html", ct)
+	}
+	body := rec.Body.String()
+	for _, name := range []string{"micro0", "micro1"} {
+		if !strings.Contains(body, name) {
+			t.Errorf("status page does not contain device %s", name)
+		}
+	}
+	if !strings.Contains(body, `http-equiv="refresh"`) {
+		t.Error("status page does not auto-refresh")
+	}
+}
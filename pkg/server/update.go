@@ -0,0 +1,214 @@
+package server
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// maxUpdateSize is the default size limit of a downloaded binary
+const maxUpdateSize = 256 << 20
+
+// Updater replaces the plugin binary with a checksum verified download
+// and re-executes it
+type Updater struct {
+	checksum   string
+	client     *http.Client
+	executable string // replaced binary, the running one if empty
+
+	// BeforeExec is called before the process is replaced by the new
+	// binary, e.g. to stop the plugin servers
+	BeforeExec func()
+
+	// AllowedHosts are the hosts, with an optional port, the `POST
+	// /update` endpoint downloads from, it rejects every URL if empty
+	AllowedHosts []string
+
+	// MaxSize limits the size of a downloaded binary in bytes
+	MaxSize int64
+}
+
+// NewUpdater creates an updater only accepting binaries with the
+// hex encoded SHA-256 checksum
+func NewUpdater(checksum string) *Updater {
+	return &Updater{
+		checksum: strings.ToLower(checksum),
+		client:   &http.Client{Timeout: 5 * time.Minute},
+		MaxSize:  maxUpdateSize,
+	}
+}
+
+// path returns the path of the replaced binary
+func (u *Updater) path() (string, error) {
+	if u.executable != "" {
+		return u.executable, nil
+	}
+	return os.Executable()
+}
+
+// checkRemoteURL checks rawURL is an http or https URL of an allowed
+// host, the endpoint never reads local files
+func (u *Updater) checkRemoteURL(rawURL string) error {
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		return err
+	}
+	if parsed.Scheme != "http" && parsed.Scheme != "https" {
+		return fmt.Errorf("update URL scheme %q is not allowed", parsed.Scheme)
+	}
+	for _, host := range u.AllowedHosts {
+		if host != "" && (host == parsed.Host || host == parsed.Hostname()) {
+			return nil
+		}
+	}
+	return fmt.Errorf("update host %q is not allowed", parsed.Host)
+}
+
+// CheckAndApply downloads the binary from rawURL, replaces the running
+// binary if it differs and re-executes it. It only returns on error or
+// when the running binary is already up to date.
+func (u *Updater) CheckAndApply(ctx context.Context, rawURL string) (updated bool, err error) {
+	updated, err = u.Apply(ctx, rawURL)
+	if err != nil || !updated {
+		return updated, err
+	}
+	return true, u.Exec()
+}
+
+// Apply downloads the binary from rawURL and atomically replaces the
+// running binary with it, it reports false if the binary is up to date
+func (u *Updater) Apply(ctx context.Context, rawURL string) (bool, error) {
+	path, err := u.path()
+	if err != nil {
+		return false, err
+	}
+	if current, err := os.ReadFile(path); err == nil && u.verify(current) == nil {
+		slog.Info("plugin binary is up to date", "path", path)
+		return false, nil
+	}
+
+	data, err := u.download(ctx, rawURL)
+	if err != nil {
+		return false, err
+	}
+	if err := u.verify(data); err != nil {
+		return false, err
+	}
+	if err := writeFileAtomic(path, data, 0755); err != nil {
+		return false, err
+	}
+	slog.Info("plugin binary updated", "path", path, "url", rawURL)
+	return true, nil
+}
+
+// Exec replaces the running process with the updated binary
+func (u *Updater) Exec() error {
+	path, err := u.path()
+	if err != nil {
+		return err
+	}
+	if u.BeforeExec != nil {
+		u.BeforeExec()
+	}
+	slog.Info("re-executing plugin binary", "path", path)
+	return syscall.Exec(path, os.Args, os.Environ())
+}
+
+// verify checks the SHA-256 checksum of data
+func (u *Updater) verify(data []byte) error {
+	sum := sha256.Sum256(data)
+	if got := hex.EncodeToString(sum[:]); got != u.checksum {
+		return fmt.Errorf("checksum mismatch: expected %s, got %s", u.checksum, got)
+	}
+	return nil
+}
+
+// download reads the binary from a http, https or file URL, binaries
+// larger than MaxSize are rejected
+func (u *Updater) download(ctx context.Context, rawURL string) ([]byte, error) {
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+
+	switch parsed.Scheme {
+	case "file":
+		f, err := os.Open(parsed.Path)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		return u.readLimited(f, rawURL)
+	case "http", "https":
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+		if err != nil {
+			return nil, err
+		}
+		resp, err := u.client.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("download %s: unexpected status %s", rawURL, resp.Status)
+		}
+		return u.readLimited(resp.Body, rawURL)
+	default:
+		return nil, fmt.Errorf("unsupported update URL scheme %q", parsed.Scheme)
+	}
+}
+
+// readLimited reads r up to MaxSize bytes
+func (u *Updater) readLimited(r io.Reader, rawURL string) ([]byte, error) {
+	data, err := io.ReadAll(io.LimitReader(r, u.MaxSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > u.MaxSize {
+		return nil, fmt.Errorf("download %s: binary exceeds %d bytes", rawURL, u.MaxSize)
+	}
+	return data, nil
+}
+
+func (s *MicroDeviceServer) handleUpdate(w http.ResponseWriter, r *http.Request) {
+	rawURL := r.URL.Query().Get("url")
+	if rawURL == "" {
+		http.Error(w, "missing update url", http.StatusBadRequest)
+		return
+	}
+	if err := s.updater.checkRemoteURL(rawURL); err != nil {
+		s.logger.Warn("reject plugin update", "url", rawURL, "err", err)
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+
+	updated, err := s.updater.Apply(r.Context(), rawURL)
+	if err != nil {
+		s.logger.Error("apply plugin update failed", "url", rawURL, "err", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]bool{"updated": updated})
+	if !updated {
+		return
+	}
+
+	// re-execute once the response is flushed to the client
+	if f, ok := w.(http.Flusher); ok {
+		f.Flush()
+	}
+	go func() {
+		if err := s.updater.Exec(); err != nil {
+			s.logger.Error("re-execute plugin binary failed", "err", err)
+		}
+	}()
+}
@@ -0,0 +1,138 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// binaryServer serves data as the plugin binary
+func binaryServer(t *testing.T, data []byte) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(data)
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// testUpdater creates an updater accepting data, replacing a binary of
+// the temporary directory
+func testUpdater(t *testing.T, data []byte) *Updater {
+	t.Helper()
+	sum := sha256.Sum256(data)
+	u := NewUpdater(hex.EncodeToString(sum[:]))
+	u.executable = filepath.Join(t.TempDir(), "micro")
+	if err := os.WriteFile(u.executable, []byte("old binary"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	return u
+}
+
+func TestUpdaterApply(t *testing.T) {
+	binary := []byte("new binary")
+	srv := binaryServer(t, binary)
+	u := testUpdater(t, binary)
+
+	updated, err := u.Apply(context.Background(), srv.URL+"/micro")
+	if err != nil || !updated {
+		t.Fatalf("Apply() = %v, %v, want updated", updated, err)
+	}
+	got, err := os.ReadFile(u.executable)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, binary) {
+		t.Errorf("binary = %q, want %q", got, binary)
+	}
+	info, err := os.Stat(u.executable)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0755 {
+		t.Errorf("binary mode = %v, want 0755", info.Mode().Perm())
+	}
+
+	// the binary is up to date now
+	if updated, err := u.Apply(context.Background(), srv.URL+"/micro"); err != nil || updated {
+		t.Errorf("second Apply() = %v, %v, want up to date", updated, err)
+	}
+}
+
+func TestUpdaterApplyRejects(t *testing.T) {
+	tests := []struct {
+		name    string
+		served  []byte // precondition: binary served by the download server
+		maxSize int64  // precondition: size limit of the updater, 0 for the default
+		wantErr string
+	}{
+		{name: "checksum mismatch", served: []byte("tampered binary"), wantErr: "checksum mismatch"},
+		{name: "too large", served: []byte("new binary"), maxSize: 4, wantErr: "exceeds 4 bytes"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := binaryServer(t, tt.served)
+			u := testUpdater(t, []byte("new binary"))
+			if tt.maxSize > 0 {
+				u.MaxSize = tt.maxSize
+			}
+
+			updated, err := u.Apply(context.Background(), srv.URL+"/micro")
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) || updated {
+				t.Errorf("Apply() = %v, %v, want error containing %q", updated, err, tt.wantErr)
+			}
+			if got, _ := os.ReadFile(u.executable); string(got) != "old binary" {
+				t.Errorf("binary = %q, want the old binary kept", got)
+			}
+		})
+	}
+}
+
+func TestHandleUpdate(t *testing.T) {
+	srv := binaryServer(t, []byte("tampered binary"))
+	u := testUpdater(t, []byte("new binary"))
+	host := strings.TrimPrefix(srv.URL, "http://")
+	u.AllowedHosts = []string{"updates.example.com", host}
+	s, _ := newTestServer(t, WithUpdater(u))
+
+	local := filepath.Join(t.TempDir(), "micro")
+	if err := os.WriteFile(local, []byte("new binary"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name     string
+		url      string
+		wantCode int
+	}{
+		{name: "missing url", wantCode: http.StatusBadRequest},
+		{name: "file url", url: "file://" + local, wantCode: http.StatusForbidden},
+		{name: "unknown host", url: "https://evil.example.com/micro", wantCode: http.StatusForbidden},
+		{name: "allowed host prefix", url: "http://updates.example.com.evil.io/micro", wantCode: http.StatusForbidden},
+		// the allowed host is downloaded from, its binary fails the checksum
+		{name: "allowed host", url: srv.URL + "/micro", wantCode: http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			target := "/update"
+			if tt.url != "" {
+				target += "?url=" + url.QueryEscape(tt.url)
+			}
+			rec := httptest.NewRecorder()
+			s.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodPost, target, nil))
+			if rec.Code != tt.wantCode {
+				t.Errorf("POST %s status = %d, want %d: %s", target, rec.Code, tt.wantCode, rec.Body)
+			}
+		})
+	}
+	if got, _ := os.ReadFile(u.executable); string(got) != "old binary" {
+		t.Errorf("binary = %q, want the old binary kept", got)
+	}
+}
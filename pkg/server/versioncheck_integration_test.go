@@ -0,0 +1,53 @@
+//go:build integration
+
+package server
+
+import (
+	"errors"
+	"testing"
+
+	deviceapi "k8s.io/kubelet/pkg/apis/deviceplugin/v1beta1"
+)
+
+func TestVersionCheck(t *testing.T) {
+	tests := []struct {
+		name     string
+		kubelet  []string
+		strict   bool
+		wantErr  bool
+		requests int
+	}{
+		{name: "supported", kubelet: []string{"v1alpha", deviceapi.Version}, strict: true, requests: 2},
+		{name: "unsupported strict", kubelet: []string{"v2"}, strict: true, wantErr: true, requests: 1},
+		{name: "unsupported lenient", kubelet: []string{"v2"}, requests: 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, dir := newTestServer(t, WithVersionCheck(tt.strict))
+			kubelet := startFakeKubelet(t, dir)
+			kubelet.SetSupportedVersions(tt.kubelet...)
+
+			err := s.RegisterToKubelet()
+			if tt.wantErr != errors.Is(err, ErrUnsupportedAPIVersion) {
+				t.Errorf("RegisterToKubelet() = %v, want unsupported version error %v", err, tt.wantErr)
+			}
+			reqs := kubelet.Requests()
+			if len(reqs) != tt.requests {
+				t.Fatalf("kubelet received %d register requests, want %d", len(reqs), tt.requests)
+			}
+			if reqs[0].Version != validateVersion {
+				t.Errorf("probe request version = %q, want the validate sentinel", reqs[0].Version)
+			}
+		})
+	}
+}
+
+func TestVersionCheckUnknownVersions(t *testing.T) {
+	s, dir := newTestServer(t, WithVersionCheck(true))
+	startFakeKubelet(t, dir)
+
+	// the fake kubelet accepts the sentinel, listing no versions
+	if err := s.RegisterToKubelet(); err != nil {
+		t.Errorf("RegisterToKubelet() with unknown kubelet versions = %v", err)
+	}
+}
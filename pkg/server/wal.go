@@ -0,0 +1,163 @@
+package server
+
+import (
+	"errors"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/kelein/micro-device-plugin/pkg/state"
+)
+
+// restoreState restores the device allocations recorded in the state WAL,
+// the state file is loaded instead if the WAL recorded nothing
+func (s *MicroDeviceServer) restoreState() {
+	if s.wal == nil {
+		return
+	}
+	snapshot := s.wal.State()
+	if snapshot.Seq == 0 {
+		s.restoreStateFile()
+		return
+	}
+	s.allocMu.Lock()
+	for id := range snapshot.AllocatedAt {
+		s.allocated[id] = true
+	}
+	s.metrics.activeAllocations.Set(float64(len(s.allocated)))
+	s.allocMu.Unlock()
+	s.logger.Info("plugin state restored", "seq", snapshot.Seq, "allocated", len(snapshot.AllocatedAt))
+}
+
+// restoreStateFile restores the device allocations of the state file
+// saved on compaction and records them in the empty state WAL
+func (s *MicroDeviceServer) restoreStateFile() {
+	if s.stateStore == nil {
+		return
+	}
+	ps, err := s.stateStore.Load()
+	if errors.Is(err, os.ErrNotExist) {
+		return
+	}
+	if err != nil {
+		s.logger.Error("load plugin state failed", "err", err)
+		return
+	}
+
+	ids := make([]string, 0, len(ps.AllocatedAt))
+	for id := range ps.AllocatedAt {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	s.allocMu.Lock()
+	for _, id := range ids {
+		s.allocated[id] = true
+	}
+	s.metrics.activeAllocations.Set(float64(len(s.allocated)))
+	s.allocMu.Unlock()
+	for _, id := range ids {
+		entry := state.WALEntry{Op: state.OpAlloc, DeviceIDs: []string{id}, Time: ps.AllocatedAt[id]}
+		if err := s.wal.Append(entry); err != nil {
+			s.logger.Error("append state WAL failed", "op", state.OpAlloc, "err", err)
+		}
+	}
+	s.logger.Info("plugin state restored from the state file", "allocated", len(ids))
+}
+
+// recordState appends a device state change to the state WAL
+func (s *MicroDeviceServer) recordState(op state.Op, ids []string, health string) {
+	if s.wal == nil || len(ids) == 0 {
+		return
+	}
+	entry := state.WALEntry{Op: op, DeviceIDs: ids, Health: health, Time: time.Now()}
+	if err := s.wal.Append(entry); err != nil {
+		s.logger.Error("append state WAL failed", "op", op, "err", err)
+	}
+}
+
+// recordOwner records the pod owning the allocated devices in the state
+// WAL for the garbage collection
+func (s *MicroDeviceServer) recordOwner(ids []string, podUID string) {
+	if s.wal == nil || len(ids) == 0 {
+		return
+	}
+	entry := state.WALEntry{Op: state.OpAssign, DeviceIDs: ids, PodUID: podUID, Time: time.Now()}
+	if err := s.wal.Append(entry); err != nil {
+		s.logger.Error("append state WAL failed", "op", state.OpAssign, "err", err)
+	}
+}
+
+// collectState periodically removes the allocations of deleted pods from
+// the state WAL and releases their devices
+func (s *MicroDeviceServer) collectState() {
+	gc := state.NewGarbageCollector(s.wal, s.gcClient)
+	gc.Release = s.dropAllocations
+	gc.Cleaned = s.metrics.gcCleanedAllocations
+	if err := gc.Collect(s.ctx, s.gcInterval); err != nil {
+		s.logger.Error("state garbage collection failed", "err", err)
+	}
+}
+
+// dropAllocations releases the devices without recording the release,
+// the garbage collector already recorded it
+func (s *MicroDeviceServer) dropAllocations(ids []string) {
+	s.allocMu.Lock()
+	for _, id := range ids {
+		delete(s.allocated, id)
+	}
+	s.metrics.activeAllocations.Set(float64(len(s.allocated)))
+	s.allocMu.Unlock()
+	if s.allocRegistry != nil {
+		s.allocRegistry.Release(s.resourceName, ids)
+	}
+	if s.claims != nil {
+		s.claims.Revoke(ids)
+	}
+	s.logger.Info("orphaned devices released", "devices", s.logIDs(ids))
+}
+
+// compactState periodically compacts the state WAL to its snapshot
+func (s *MicroDeviceServer) compactState() {
+	ticker := time.NewTicker(s.compactInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.ctx.Done():
+			return
+		case <-ticker.C:
+			s.compact()
+		}
+	}
+}
+
+// compact compacts the state WAL and saves the state file
+func (s *MicroDeviceServer) compact() {
+	if err := s.wal.Compact(); err != nil {
+		s.logger.Error("compact state WAL failed", "err", err)
+	}
+	s.saveState()
+}
+
+// saveState saves the known devices and the allocations of the state WAL
+// to the state store
+func (s *MicroDeviceServer) saveState() {
+	if s.stateStore == nil {
+		return
+	}
+	s.mu.RLock()
+	ids := make([]string, 0, len(s.devices))
+	for _, dev := range s.devices {
+		ids = append(ids, dev.ID)
+	}
+	s.mu.RUnlock()
+	sort.Strings(ids)
+
+	ps := &state.PluginState{
+		SchemaVersion: state.CurrentSchemaVersion,
+		DeviceIDs:     ids,
+		AllocatedAt:   s.wal.State().AllocatedAt,
+	}
+	if err := s.stateStore.Save(ps); err != nil {
+		s.logger.Error("save plugin state failed", "err", err)
+	}
+}
@@ -0,0 +1,74 @@
+package server
+
+import (
+	"testing"
+	"time"
+
+	"github.com/kelein/micro-device-plugin/pkg/state"
+)
+
+func TestStateWALRestore(t *testing.T) {
+	dir := t.TempDir()
+	wal, err := state.OpenWAL(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, _ := newTestServer(t, WithStateWAL(wal, 0))
+	s.markAllocated([]string{"a1", "b2"})
+	s.releaseDevices([]string{"a1"}, "pod-1")
+	s.Stop()
+	wal.Close()
+
+	wal, err = state.OpenWAL(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { wal.Close() })
+	s, _ = newTestServer(t, WithStateWAL(wal, 0))
+	s.restoreState()
+
+	s.allocMu.Lock()
+	defer s.allocMu.Unlock()
+	if len(s.allocated) != 1 || !s.allocated["b2"] {
+		t.Errorf("restored allocations = %v, want b2", s.allocated)
+	}
+}
+
+func TestStateFileRestore(t *testing.T) {
+	allocatedAt := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	for _, format := range []string{state.FormatJSON, state.FormatGob} {
+		t.Run(format, func(t *testing.T) {
+			store, err := state.NewStateStore(format, t.TempDir())
+			if err != nil {
+				t.Fatal(err)
+			}
+			// precondition: a state file and an empty WAL
+			err = store.Save(&state.PluginState{
+				SchemaVersion: state.CurrentSchemaVersion,
+				AllocatedAt:   map[string]time.Time{"b2": allocatedAt},
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			wal, err := state.OpenWAL(t.TempDir())
+			if err != nil {
+				t.Fatal(err)
+			}
+			t.Cleanup(func() { wal.Close() })
+
+			s, _ := newTestServer(t, WithStateWAL(wal, 0), WithStateStore(store))
+			s.restoreState()
+
+			s.allocMu.Lock()
+			restored := len(s.allocated) == 1 && s.allocated["b2"]
+			s.allocMu.Unlock()
+			if !restored {
+				t.Errorf("restored allocations = %v, want b2", s.allocated)
+			}
+			// expected: the WAL records the restored allocation
+			if got := wal.State().AllocatedAt["b2"]; !got.Equal(allocatedAt) {
+				t.Errorf("WAL allocation time of b2 = %v, want %v", got, allocatedAt)
+			}
+		})
+	}
+}
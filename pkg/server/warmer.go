@@ -0,0 +1,74 @@
+package server
+
+import (
+	"context"
+
+	deviceapi "k8s.io/kubelet/pkg/apis/deviceplugin/v1beta1"
+)
+
+// DeviceWarmer pre-stages a newly discovered device, e.g. loads its
+// firmware, before the device is advertised to kubelet
+type DeviceWarmer interface {
+	Warm(ctx context.Context, device *MicroDevice) error
+}
+
+// NoOpWarmer is the default warmer, devices are warm on discovery
+type NoOpWarmer struct{}
+
+// Warm does nothing
+func (NoOpWarmer) Warm(context.Context, *MicroDevice) error {
+	return nil
+}
+
+// warmState is the state of a device not yet warm
+type warmState int
+
+const (
+	warmPending warmState = iota
+	warmFailed
+)
+
+// startWarm reports the new device unhealthy and warms it in the
+// background, it must be called with s.mu held
+func (s *MicroDeviceServer) startWarm(dev *MicroDevice) {
+	if _, noop := s.warmer.(NoOpWarmer); noop {
+		return
+	}
+	health := dev.Health
+	dev.Health = deviceapi.Unhealthy
+	s.warmStates[dev.Name] = warmPending
+	snapshot := *dev
+	s.SafeGo("warm", func() { s.warm(&snapshot, health) })
+}
+
+// warm warms the device and advertises it with its discovered health
+func (s *MicroDeviceServer) warm(dev *MicroDevice, health string) {
+	s.logger.Info("warming device", "name", dev.Name)
+	err := s.warmer.Warm(s.ctx, dev)
+
+	s.mu.Lock()
+	current, ok := s.devices[dev.Name]
+	if !ok || s.warmStates[dev.Name] != warmPending {
+		s.mu.Unlock()
+		return
+	}
+	if err != nil {
+		s.warmStates[dev.Name] = warmFailed
+		s.mu.Unlock()
+		s.logger.Error("warm device failed, device stays unhealthy", "name", dev.Name, "err", err)
+		return
+	}
+	delete(s.warmStates, dev.Name)
+	current.Health = health
+	s.mu.Unlock()
+	s.logger.Info("device warm", "name", dev.Name)
+	s.updateGRPCHealth()
+	s.notifyChange()
+}
+
+// isWarm reports whether the device finished warming, it must be called
+// with s.mu held
+func (s *MicroDeviceServer) isWarm(name string) bool {
+	_, ok := s.warmStates[name]
+	return !ok
+}
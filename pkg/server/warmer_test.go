@@ -0,0 +1,84 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	deviceapi "k8s.io/kubelet/pkg/apis/deviceplugin/v1beta1"
+
+	"github.com/kelein/micro-device-plugin/pkg/testing/assert"
+	"github.com/kelein/micro-device-plugin/pkg/testutil"
+)
+
+// mockWarmer warms devices after delay, failing with err if set
+type mockWarmer struct {
+	delay time.Duration
+	err   error
+}
+
+func (w mockWarmer) Warm(ctx context.Context, dev *MicroDevice) error {
+	select {
+	case <-time.After(w.delay):
+		return w.err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func TestWarmDevice(t *testing.T) {
+	s, _ := newTestServer(t, WithWarmer(mockWarmer{delay: 100 * time.Millisecond}))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	stream := testutil.NewMockListAndWatchServer(ctx)
+	go s.ListAndWatch(&deviceapi.Empty{}, stream)
+	if !stream.WaitForSends(1, time.Second) {
+		t.Fatal("ListAndWatch sent no device list")
+	}
+
+	id := s.addDevice(&MicroDevice{Name: "micro0"})
+	assert.AssertDeviceUnhealthy(t, s, "micro0")
+	_, err := s.PreStartContainer(context.Background(), &deviceapi.PreStartContainerRequest{DevicesIDs: []string{id}})
+	if status.Code(err) != codes.FailedPrecondition {
+		t.Errorf("PreStartContainer() while warming error = %v, want FailedPrecondition", err)
+	}
+
+	if !stream.WaitForSends(2, 5*time.Second) {
+		t.Fatal("ListAndWatch not notified after warming")
+	}
+	assert.AssertListAndWatchContains(t, stream.Responses(), id, deviceapi.Healthy)
+	if _, err := s.PreStartContainer(context.Background(), &deviceapi.PreStartContainerRequest{DevicesIDs: []string{id}}); err != nil {
+		t.Errorf("PreStartContainer() after warming error = %v", err)
+	}
+}
+
+func TestWarmDeviceFailed(t *testing.T) {
+	s, _ := newTestServer(t, WithWarmer(mockWarmer{err: errors.New("firmware missing")}))
+
+	s.addDevice(&MicroDevice{Name: "micro0"})
+	deadline := time.Now().Add(time.Second)
+	for {
+		s.mu.RLock()
+		state, ok := s.warmStates["micro0"]
+		s.mu.RUnlock()
+		if ok && state == warmFailed {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("warm failure not recorded")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	assert.AssertDeviceUnhealthy(t, s, "micro0")
+}
+
+func TestNoOpWarmer(t *testing.T) {
+	s, _ := newTestServer(t)
+
+	s.addDevice(&MicroDevice{Name: "micro0"})
+	assert.AssertDeviceHealthy(t, s, "micro0")
+}
@@ -0,0 +1,49 @@
+package server
+
+import (
+	"time"
+)
+
+// startWatchdog arms the watchdog which re-registers the plugin if
+// kubelet does not call ListAndWatch within the watchdog timeout
+func (s *MicroDeviceServer) startWatchdog() {
+	if s.watchdogTimeout <= 0 {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.watchdog == nil {
+		s.watchdog = time.AfterFunc(s.watchdogTimeout, s.watchdogFired)
+		return
+	}
+	s.watchdog.Reset(s.watchdogTimeout)
+}
+
+// pauseWatchdog disarms the watchdog while a ListAndWatch stream is
+// active and records the connection time
+func (s *MicroDeviceServer) pauseWatchdog() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.lastListAndWatch = time.Now()
+	if s.watchdog != nil {
+		s.watchdog.Stop()
+	}
+}
+
+func (s *MicroDeviceServer) watchdogFired() {
+	if s.ctx.Err() != nil {
+		return
+	}
+	s.logger.Error("ListAndWatch not called by kubelet in time, re-registering", "timeout", s.watchdogTimeout)
+
+	s.mu.Lock()
+	s.registered = false
+	s.mu.Unlock()
+	s.updateGRPCHealth()
+
+	if err := s.RegisterToKubelet(); err != nil {
+		s.logger.Error("watchdog re-register failed", "err", err)
+		s.startWatchdog()
+	}
+}
@@ -0,0 +1,78 @@
+//go:build integration
+
+package server
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	deviceapi "k8s.io/kubelet/pkg/apis/deviceplugin/v1beta1"
+
+	"github.com/kelein/micro-device-plugin/pkg/testutil"
+)
+
+// waitRequests waits until the kubelet received n registration requests
+func waitRequests(kubelet *testutil.FakeKubelet, n int, timeout time.Duration) bool {
+	deadline := time.Now().Add(timeout)
+	for time.Now().Before(deadline) {
+		if len(kubelet.Requests()) >= n {
+			return true
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	return false
+}
+
+func TestWatchdog(t *testing.T) {
+	tests := []struct {
+		name         string
+		listAndWatch bool // precondition: kubelet opens a ListAndWatch stream
+		wantRequests int
+	}{
+		{name: "ListAndWatch not called", wantRequests: 2},
+		{name: "ListAndWatch active", listAndWatch: true, wantRequests: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			kubelet, err := testutil.NewFakeKubelet(dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			t.Cleanup(kubelet.Stop)
+			s, _ := newTestServer(t, WithPluginPath(dir), WithWatchdogTimeout(100*time.Millisecond))
+
+			if err := s.RegisterToKubelet(); err != nil {
+				t.Fatal(err)
+			}
+			if tt.listAndWatch {
+				ctx, cancel := context.WithCancel(context.Background())
+				t.Cleanup(cancel)
+				srv := testutil.NewMockListAndWatchServer(ctx)
+				go s.ListAndWatch(&deviceapi.Empty{}, srv)
+				if !srv.WaitForSends(1, time.Second) {
+					t.Fatal("ListAndWatch sent no devices")
+				}
+				if s.Status().LastListAndWatch == "" {
+					t.Error("status has no last ListAndWatch time")
+				}
+			}
+
+			// action: wait well past the watchdog timeout
+			fired := waitRequests(kubelet, 2, 500*time.Millisecond)
+			if fired != (tt.wantRequests == 2) {
+				t.Errorf("kubelet requests = %d, want %d", len(kubelet.Requests()), tt.wantRequests)
+			}
+			// the plugin records the registration once kubelet replied
+			registered := false
+			for deadline := time.Now().Add(time.Second); !registered && time.Now().Before(deadline); {
+				registered = s.Status().RegisteredWithKubelet
+				time.Sleep(10 * time.Millisecond)
+			}
+			if !registered {
+				t.Error("plugin is not registered after the watchdog")
+			}
+		})
+	}
+}
@@ -0,0 +1,68 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	deviceapi "k8s.io/kubelet/pkg/apis/deviceplugin/v1beta1"
+)
+
+// AllocationReview is the response of the allocation webhook
+type AllocationReview struct {
+	Approved bool   `json:"approved"`
+	Reason   string `json:"reason,omitempty"`
+}
+
+// AllocationWebhook asks an external policy service to approve the
+// Allocate requests, the request is POSTed as JSON to the URL which
+// answers with an AllocationReview
+type AllocationWebhook struct {
+	URL     string
+	Timeout time.Duration
+
+	client *http.Client
+}
+
+// NewAllocationWebhook creates a webhook of the URL, a review taking
+// longer than timeout fails
+func NewAllocationWebhook(url string, timeout time.Duration) *AllocationWebhook {
+	return &AllocationWebhook{URL: url, Timeout: timeout, client: &http.Client{}}
+}
+
+// Review posts the request to the webhook and returns its decision
+func (w *AllocationWebhook) Review(ctx context.Context, req *deviceapi.AllocateRequest) (approved bool, reason string, err error) {
+	body, err := json.Marshal(req)
+	if err != nil {
+		return false, "", fmt.Errorf("encode allocate request: %w", err)
+	}
+	if w.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, w.Timeout)
+		defer cancel()
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, w.URL, bytes.NewReader(body))
+	if err != nil {
+		return false, "", err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	resp, err := w.client.Do(httpReq)
+	if err != nil {
+		return false, "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return false, "", fmt.Errorf("unexpected webhook status %s", resp.Status)
+	}
+
+	var review AllocationReview
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&review); err != nil {
+		return false, "", fmt.Errorf("decode allocation review: %w", err)
+	}
+	return review.Approved, review.Reason, nil
+}
@@ -0,0 +1,78 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	deviceapi "k8s.io/kubelet/pkg/apis/deviceplugin/v1beta1"
+
+	"github.com/kelein/micro-device-plugin/pkg/testutil"
+)
+
+// policyServer approves the requests of the allowed device only
+func policyServer(t *testing.T, allowed string, delay time.Duration) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(delay)
+		var req deviceapi.AllocateRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		review := AllocationReview{Approved: true}
+		for _, c := range req.ContainerRequests {
+			for _, id := range c.DevicesIDs {
+				if id != allowed {
+					review = AllocationReview{Reason: "device " + id + " not allowed"}
+				}
+			}
+		}
+		json.NewEncoder(w).Encode(review)
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestAllocationWebhookReview(t *testing.T) {
+	srv := policyServer(t, "micro0", 0)
+	w := NewAllocationWebhook(srv.URL, time.Second)
+
+	approved, reason, err := w.Review(context.Background(), testutil.NewMockAllocateRequest().WithDeviceIDs("micro0").Build())
+	if err != nil || !approved {
+		t.Errorf("Review(micro0) = %v, %q, %v, want approved", approved, reason, err)
+	}
+	approved, reason, err = w.Review(context.Background(), testutil.NewMockAllocateRequest().WithDeviceIDs("micro1").Build())
+	if err != nil || approved || reason != "device micro1 not allowed" {
+		t.Errorf("Review(micro1) = %v, %q, %v, want denied", approved, reason, err)
+	}
+}
+
+func TestAllocateWebhook(t *testing.T) {
+	tests := []struct {
+		name  string
+		delay time.Duration
+		id    string
+		code  codes.Code
+	}{
+		{name: "approved", id: "micro0", code: codes.OK},
+		{name: "denied", id: "micro1", code: codes.PermissionDenied},
+		{name: "timeout", delay: 500 * time.Millisecond, id: "micro0", code: codes.Unavailable},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := policyServer(t, "micro0", tt.delay)
+			s, _ := newTestServer(t, WithAllocationWebhook(NewAllocationWebhook(srv.URL, 100*time.Millisecond)))
+
+			_, err := s.Allocate(context.Background(), testutil.NewMockAllocateRequest().WithDeviceIDs(tt.id).Build())
+			if got := status.Code(err); got != tt.code {
+				t.Errorf("Allocate(%s) code = %v, want %v (err %v)", tt.id, got, tt.code, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,120 @@
+package server
+
+import (
+	"bytes"
+	"errors"
+	"sort"
+	"strings"
+
+	"golang.org/x/sys/unix"
+)
+
+const (
+	xattrUserPrefix       = "user."
+	xattrAnnotationPrefix = "micro.xattr/"
+	xattrEnvPrefix        = "MICRO_XATTR_"
+)
+
+// XattrReader reads user extended attributes of device files
+type XattrReader struct{}
+
+// Read returns the user extended attributes of the file at path keyed
+// by the attribute name without the `user.` prefix. Filesystems without
+// xattr support yield an empty result instead of an error.
+func (XattrReader) Read(path string) (map[string]string, error) {
+	attrs := make(map[string]string)
+	size, err := unix.Llistxattr(path, nil)
+	if err != nil {
+		if isXattrUnsupported(err) {
+			return attrs, nil
+		}
+		return nil, err
+	}
+	if size == 0 {
+		return attrs, nil
+	}
+
+	buf := make([]byte, size)
+	size, err = unix.Llistxattr(path, buf)
+	if err != nil {
+		return nil, err
+	}
+	for _, name := range bytes.Split(buf[:size], []byte{0}) {
+		key := string(name)
+		if !strings.HasPrefix(key, xattrUserPrefix) {
+			continue
+		}
+		value, err := lgetxattr(path, key)
+		if err != nil {
+			if isXattrUnsupported(err) {
+				continue
+			}
+			return nil, err
+		}
+		attrs[strings.TrimPrefix(key, xattrUserPrefix)] = value
+	}
+	return attrs, nil
+}
+
+func lgetxattr(path, name string) (string, error) {
+	size, err := unix.Lgetxattr(path, name, nil)
+	if err != nil {
+		return "", err
+	}
+	buf := make([]byte, size)
+	size, err = unix.Lgetxattr(path, name, buf)
+	if err != nil {
+		return "", err
+	}
+	return string(buf[:size]), nil
+}
+
+func isXattrUnsupported(err error) bool {
+	return errors.Is(err, unix.ENOTSUP) || errors.Is(err, unix.ENODATA)
+}
+
+// xattrAnnotations converts extended attributes into device annotations
+func xattrAnnotations(attrs map[string]string) map[string]string {
+	annotations := make(map[string]string, len(attrs))
+	for k, v := range attrs {
+		annotations[xattrAnnotationPrefix+k] = v
+	}
+	return annotations
+}
+
+// xattrEnvs converts device annotations into container env vars, values
+// of the same attribute on multiple devices are joined by comma
+func xattrEnvs(annotations []map[string]string) map[string]string {
+	values := make(map[string][]string)
+	for _, anno := range annotations {
+		for k, v := range anno {
+			if !strings.HasPrefix(k, xattrAnnotationPrefix) {
+				continue
+			}
+			name := xattrEnvName(strings.TrimPrefix(k, xattrAnnotationPrefix))
+			values[name] = append(values[name], v)
+		}
+	}
+
+	envs := make(map[string]string, len(values))
+	for name, vals := range values {
+		sort.Strings(vals)
+		envs[name] = strings.Join(vals, ",")
+	}
+	return envs
+}
+
+// xattrEnvName builds env name like MICRO_XATTR_MICRO_TIER
+func xattrEnvName(key string) string {
+	name := strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z':
+			return r - 'a' + 'A'
+		case r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+			return r
+		default:
+			return '_'
+		}
+	}, key)
+	return xattrEnvPrefix + name
+}
@@ -0,0 +1,93 @@
+//go:build linux
+
+package server
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"golang.org/x/sys/unix"
+
+	"github.com/kelein/micro-device-plugin/pkg/config"
+	"github.com/kelein/micro-device-plugin/pkg/testing/assert"
+	"github.com/kelein/micro-device-plugin/pkg/testutil"
+)
+
+// xattrDevice creates the device file name on tmpfs with the user
+// extended attributes, the test is skipped without tmpfs xattr support
+func xattrDevice(t *testing.T, name string, attrs map[string]string) string {
+	t.Helper()
+	dir, err := os.MkdirTemp("/dev/shm", "micro-xattr-")
+	if err != nil {
+		t.Skipf("tmpfs is not available: %v", err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+	for k, v := range attrs {
+		if err := unix.Setxattr(path, xattrUserPrefix+k, []byte(v), 0); err != nil {
+			if errors.Is(err, unix.ENOTSUP) || errors.Is(err, unix.EPERM) {
+				t.Skipf("tmpfs does not support user xattrs: %v", err)
+			}
+			t.Fatal(err)
+		}
+	}
+	return path
+}
+
+func TestXattrReader(t *testing.T) {
+	want := map[string]string{"tier": "gold", "micro.zone": "a"}
+	path := xattrDevice(t, "micro0", want)
+
+	got, err := XattrReader{}.Read(path)
+	if err != nil {
+		t.Fatalf("Read() error = %v", err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Read() = %v, want %v", got, want)
+	}
+
+	// a file without attributes yields an empty result
+	bare := filepath.Join(filepath.Dir(path), "micro1")
+	if err := os.WriteFile(bare, nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := (XattrReader{}).Read(bare); err != nil || len(got) != 0 {
+		t.Errorf("Read() of a file without xattrs = %v, %v, want empty", got, err)
+	}
+}
+
+func TestAddDeviceXattr(t *testing.T) {
+	// the device file lives outside the device directory, the attributes
+	// are read from the path of the discovered device
+	path := xattrDevice(t, "micro0", map[string]string{"tier": "gold"})
+	s, _ := newTestServer(t)
+	id := s.addDevice(&MicroDevice{Name: "micro0", Path: path})
+
+	resp, err := s.Allocate(context.Background(), testutil.NewMockAllocateRequest().WithDeviceIDs(id).Build())
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.AssertAllocateResponse(t, resp, map[string]string{"MICRO_XATTR_TIER": "gold"}, nil)
+}
+
+func TestAddDeviceXattrGateDisabled(t *testing.T) {
+	path := xattrDevice(t, "micro0", map[string]string{"tier": "gold"})
+	s := gatedServer(t, config.FeatureGates{config.XattrMetadata: false})
+	id := s.addDevice(&MicroDevice{Name: "micro0", Path: path})
+
+	resp, err := s.Allocate(context.Background(), testutil.NewMockAllocateRequest().WithDeviceIDs(id).Build())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v, ok := resp.ContainerResponses[0].Envs["MICRO_XATTR_TIER"]; ok {
+		t.Errorf("MICRO_XATTR_TIER = %q injected with the XattrMetadata gate disabled", v)
+	}
+}
@@ -0,0 +1,172 @@
+package state
+
+import (
+	"fmt"
+	"sort"
+
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/protoadapt"
+	deviceapi "k8s.io/kubelet/pkg/apis/deviceplugin/v1beta1"
+
+	"github.com/kelein/micro-device-plugin/pkg/discovery"
+)
+
+// Field numbers of the serialized device map, the wire format is
+//
+//	message Devices { repeated Entry devices = 1; }
+//	message Entry {
+//	  v1beta1.Device device = 1;
+//	  string name = 2;
+//	  string path = 3;
+//	  map<string, string> annotations = 4;
+//	}
+const (
+	devicesField     protowire.Number = 1
+	deviceField      protowire.Number = 1
+	nameField        protowire.Number = 2
+	pathField        protowire.Number = 3
+	annotationsField protowire.Number = 4
+	mapKeyField      protowire.Number = 1
+	mapValueField    protowire.Number = 2
+)
+
+// SerializeDevices encodes the device map to protobuf, the ID and health
+// are encoded as the kubelet deviceapi.Device, the entries are sorted by
+// device name
+func SerializeDevices(devices map[string]*discovery.MicroDevice) ([]byte, error) {
+	names := make([]string, 0, len(devices))
+	for name := range devices {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var b []byte
+	for _, name := range names {
+		entry, err := marshalEntry(devices[name])
+		if err != nil {
+			return nil, fmt.Errorf("serialize device %s: %w", name, err)
+		}
+		b = protowire.AppendTag(b, devicesField, protowire.BytesType)
+		b = protowire.AppendBytes(b, entry)
+	}
+	return b, nil
+}
+
+func marshalEntry(dev *discovery.MicroDevice) ([]byte, error) {
+	apiDevice, err := proto.Marshal(protoadapt.MessageV2Of(dev.APIDevice()))
+	if err != nil {
+		return nil, err
+	}
+	var b []byte
+	b = protowire.AppendTag(b, deviceField, protowire.BytesType)
+	b = protowire.AppendBytes(b, apiDevice)
+	b = protowire.AppendTag(b, nameField, protowire.BytesType)
+	b = protowire.AppendString(b, dev.Name)
+	b = protowire.AppendTag(b, pathField, protowire.BytesType)
+	b = protowire.AppendString(b, dev.Path)
+
+	keys := make([]string, 0, len(dev.Annotations))
+	for k := range dev.Annotations {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		var kv []byte
+		kv = protowire.AppendTag(kv, mapKeyField, protowire.BytesType)
+		kv = protowire.AppendString(kv, k)
+		kv = protowire.AppendTag(kv, mapValueField, protowire.BytesType)
+		kv = protowire.AppendString(kv, dev.Annotations[k])
+		b = protowire.AppendTag(b, annotationsField, protowire.BytesType)
+		b = protowire.AppendBytes(b, kv)
+	}
+	return b, nil
+}
+
+// DeserializeDevices decodes a device map encoded by SerializeDevices,
+// unknown fields are skipped
+func DeserializeDevices(data []byte) (map[string]*discovery.MicroDevice, error) {
+	devices := make(map[string]*discovery.MicroDevice)
+	err := walkFields(data, func(num protowire.Number, value []byte) error {
+		if num != devicesField {
+			return nil
+		}
+		dev, err := unmarshalEntry(value)
+		if err != nil {
+			return err
+		}
+		if _, ok := devices[dev.Name]; ok {
+			return fmt.Errorf("duplicate device %s", dev.Name)
+		}
+		devices[dev.Name] = dev
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("deserialize devices: %w", err)
+	}
+	return devices, nil
+}
+
+func unmarshalEntry(data []byte) (*discovery.MicroDevice, error) {
+	dev := &discovery.MicroDevice{Annotations: make(map[string]string)}
+	err := walkFields(data, func(num protowire.Number, value []byte) error {
+		switch num {
+		case deviceField:
+			var apiDevice deviceapi.Device
+			if err := proto.Unmarshal(value, protoadapt.MessageV2Of(&apiDevice)); err != nil {
+				return err
+			}
+			dev.ID, dev.Health = apiDevice.ID, apiDevice.Health
+		case nameField:
+			dev.Name = string(value)
+		case pathField:
+			dev.Path = string(value)
+		case annotationsField:
+			var k, v string
+			err := walkFields(value, func(num protowire.Number, value []byte) error {
+				switch num {
+				case mapKeyField:
+					k = string(value)
+				case mapValueField:
+					v = string(value)
+				}
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+			dev.Annotations[k] = v
+		}
+		return nil
+	})
+	return dev, err
+}
+
+// walkFields calls fn with the number and value of every length-delimited
+// field of the message, fields of other wire types are skipped
+func walkFields(data []byte, fn func(protowire.Number, []byte) error) error {
+	for len(data) > 0 {
+		num, typ, n := protowire.ConsumeTag(data)
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		data = data[n:]
+		if typ != protowire.BytesType {
+			n = protowire.ConsumeFieldValue(num, typ, data)
+			if n < 0 {
+				return protowire.ParseError(n)
+			}
+			data = data[n:]
+			continue
+		}
+		value, n := protowire.ConsumeBytes(data)
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		data = data[n:]
+		if err := fn(num, value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
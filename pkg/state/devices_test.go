@@ -0,0 +1,68 @@
+package state
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	deviceapi "k8s.io/kubelet/pkg/apis/deviceplugin/v1beta1"
+
+	"github.com/kelein/micro-device-plugin/pkg/discovery"
+)
+
+func TestSerializeDevicesRoundTrip(t *testing.T) {
+	devices := make(map[string]*discovery.MicroDevice)
+	for i := range 100 {
+		name := fmt.Sprintf("micro%d", i)
+		health := deviceapi.Healthy
+		if i%3 == 0 {
+			health = deviceapi.Unhealthy
+		}
+		devices[name] = &discovery.MicroDevice{
+			Name:   name,
+			Path:   "/etc/micro/" + name,
+			ID:     fmt.Sprintf("%08x", i*7919),
+			Health: health,
+			Annotations: map[string]string{
+				discovery.NUMAAnnotation: fmt.Sprint(i % 2),
+				"micro.plugin/index":     fmt.Sprint(i),
+			},
+		}
+	}
+
+	data, err := SerializeDevices(devices)
+	if err != nil {
+		t.Fatalf("SerializeDevices() = %v", err)
+	}
+	got, err := DeserializeDevices(data)
+	if err != nil {
+		t.Fatalf("DeserializeDevices() = %v", err)
+	}
+	if !reflect.DeepEqual(got, devices) {
+		t.Errorf("round trip changed the devices")
+		for name, want := range devices {
+			if !reflect.DeepEqual(got[name], want) {
+				t.Errorf("device %s = %+v, want %+v", name, got[name], want)
+				break
+			}
+		}
+	}
+
+	again, err := SerializeDevices(got)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(again) != string(data) {
+		t.Error("serialization is not deterministic")
+	}
+}
+
+func TestDeserializeDevicesRejectsGarbage(t *testing.T) {
+	if _, err := DeserializeDevices([]byte{0x0a, 0xff}); err == nil {
+		t.Error("DeserializeDevices() error = nil, want error on truncated data")
+	}
+	devices, err := DeserializeDevices(nil)
+	if err != nil || len(devices) != 0 {
+		t.Errorf("DeserializeDevices(nil) = %v, %v, want an empty map", devices, err)
+	}
+}
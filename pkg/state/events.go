@@ -0,0 +1,203 @@
+package state
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/kelein/micro-device-plugin/pkg/discovery"
+)
+
+// Event types of the device events changing the device map, the other
+// logged events are kept for the history only
+const (
+	EventDeviceAdded     = "DeviceAdded"
+	EventDeviceRemoved   = "DeviceRemoved"
+	EventDeviceHealthy   = "DeviceHealthy"
+	EventDeviceUnhealthy = "DeviceUnhealthy"
+)
+
+// Event is an immutable plugin event of the event log
+type Event struct {
+	// Seq is assigned by Append, increasing by one per event
+	Seq     uint64          `json:"seq"`
+	Time    time.Time       `json:"time"`
+	Type    string          `json:"type"`
+	Payload json.RawMessage `json:"payload,omitempty"`
+}
+
+// EventPayload is the payload of the plugin events, Device is set for
+// device events, DeviceIDs and RequestID for allocation events
+type EventPayload struct {
+	Device    *discovery.MicroDevice `json:"device,omitempty"`
+	DeviceIDs []string               `json:"deviceIDs,omitempty"`
+	RequestID string                 `json:"requestID,omitempty"`
+}
+
+// EventStore is an append-only log of the plugin events stored as JSON
+// lines, the events of the file are loaded when the store is opened and
+// an event torn by a crash mid-write is discarded
+type EventStore struct {
+	mu     sync.Mutex
+	path   string
+	file   *os.File
+	events []Event
+}
+
+// OpenEventStore opens the event log file path and loads its events
+func OpenEventStore(path string) (*EventStore, error) {
+	s := &EventStore{path: path}
+	valid, err := s.load()
+	if err != nil {
+		return nil, err
+	}
+	// drop a torn trailing event so that new events start on a new line
+	if err := os.Truncate(path, valid); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("truncate event log: %w", err)
+	}
+
+	s.file, err = os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND|os.O_SYNC, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("open event log: %w", err)
+	}
+	return s, nil
+}
+
+// load reads the complete events of the file and returns the size of the
+// file up to the last complete event
+func (s *EventStore) load() (int64, error) {
+	f, err := os.Open(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("open event log: %w", err)
+	}
+	defer f.Close()
+
+	var valid int64
+	r := bufio.NewReader(f)
+	for {
+		line, err := r.ReadBytes('\n')
+		if errors.Is(err, io.EOF) {
+			// a line without newline is an event torn by a crash
+			return valid, nil
+		}
+		if err != nil {
+			return 0, fmt.Errorf("read event log: %w", err)
+		}
+
+		var event Event
+		if err := json.Unmarshal(bytes.TrimSpace(line), &event); err != nil {
+			return 0, fmt.Errorf("decode event at offset %d: %w", valid, err)
+		}
+		if want := s.lastSeq() + 1; event.Seq != want {
+			return 0, fmt.Errorf("event %d follows event %d", event.Seq, want-1)
+		}
+		valid += int64(len(line))
+		s.events = append(s.events, event)
+	}
+}
+
+// lastSeq returns the sequence number of the last event, 0 if empty
+func (s *EventStore) lastSeq() uint64 {
+	if len(s.events) == 0 {
+		return 0
+	}
+	return s.events[len(s.events)-1].Seq
+}
+
+// Append encodes payload to JSON and writes it as the next event of
+// eventType
+func (s *EventStore) Append(eventType string, payload any) (Event, error) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return Event{}, fmt.Errorf("encode %s event payload: %w", eventType, err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	event := Event{
+		Seq:     s.lastSeq() + 1,
+		Time:    time.Now(),
+		Type:    eventType,
+		Payload: data,
+	}
+	line, err := json.Marshal(event)
+	if err != nil {
+		return Event{}, err
+	}
+	if _, err := s.file.Write(append(line, '\n')); err != nil {
+		return Event{}, fmt.Errorf("append event %d: %w", event.Seq, err)
+	}
+	s.events = append(s.events, event)
+	return event, nil
+}
+
+// Replay calls handler with the events in sequence order
+func (s *EventStore) Replay(handler func(Event) error) error {
+	for _, event := range s.Since(time.Time{}) {
+		if err := handler(event); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Since returns the events recorded at or after t in sequence order
+func (s *EventStore) Since(t time.Time) []Event {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var events []Event
+	for _, event := range s.events {
+		if !event.Time.Before(t) {
+			events = append(events, event)
+		}
+	}
+	return events
+}
+
+// Close closes the event log file
+func (s *EventStore) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.file.Close()
+}
+
+// ReplayDevices reconstructs the device map keyed by device name from the
+// device events
+func ReplayDevices(events []Event) (map[string]*discovery.MicroDevice, error) {
+	devices := make(map[string]*discovery.MicroDevice)
+	for _, event := range events {
+		switch event.Type {
+		case EventDeviceAdded, EventDeviceRemoved, EventDeviceHealthy, EventDeviceUnhealthy:
+		default:
+			continue
+		}
+		var payload EventPayload
+		if err := json.Unmarshal(event.Payload, &payload); err != nil {
+			return nil, fmt.Errorf("decode event %d payload: %w", event.Seq, err)
+		}
+		dev := payload.Device
+		if dev == nil {
+			return nil, fmt.Errorf("%s event %d has no device", event.Type, event.Seq)
+		}
+		switch event.Type {
+		case EventDeviceAdded:
+			devices[dev.Name] = dev
+		case EventDeviceRemoved:
+			delete(devices, dev.Name)
+		default:
+			if known, ok := devices[dev.Name]; ok {
+				known.Health = dev.Health
+			}
+		}
+	}
+	return devices, nil
+}
@@ -0,0 +1,131 @@
+package state
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	deviceapi "k8s.io/kubelet/pkg/apis/deviceplugin/v1beta1"
+
+	"github.com/kelein/micro-device-plugin/pkg/discovery"
+)
+
+func TestEventStoreReplay(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "events.jsonl")
+	s, err := OpenEventStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// mutate the device map directly while logging every mutation
+	direct := make(map[string]*discovery.MicroDevice)
+	add := func(name string) {
+		dev := &discovery.MicroDevice{Name: name, Path: "/dev/" + name, ID: name + "-id", Health: deviceapi.Healthy}
+		direct[name] = dev
+		if _, err := s.Append(EventDeviceAdded, EventPayload{Device: dev}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	add("a")
+	add("b")
+	add("c")
+	delete(direct, "b")
+	if _, err := s.Append(EventDeviceRemoved, EventPayload{Device: &discovery.MicroDevice{Name: "b"}}); err != nil {
+		t.Fatal(err)
+	}
+	direct["c"].Health = deviceapi.Unhealthy
+	if _, err := s.Append(EventDeviceUnhealthy, EventPayload{Device: direct["c"]}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Append("AllocationStarted", EventPayload{DeviceIDs: []string{"a-id"}}); err != nil {
+		t.Fatal(err)
+	}
+	s.Close()
+
+	s, err = OpenEventStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	var seqs []uint64
+	if err := s.Replay(func(event Event) error {
+		seqs = append(seqs, event.Seq)
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(seqs, []uint64{1, 2, 3, 4, 5, 6}) {
+		t.Errorf("replayed seqs = %v, want 1 to 6", seqs)
+	}
+
+	replayed, err := ReplayDevices(s.Since(time.Time{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(replayed, direct) {
+		t.Errorf("ReplayDevices() = %v, want %v", replayed, direct)
+	}
+}
+
+func TestEventStoreTornEvent(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "events.jsonl")
+	s, err := OpenEventStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Append(EventDeviceAdded, EventPayload{Device: &discovery.MicroDevice{Name: "a"}}); err != nil {
+		t.Fatal(err)
+	}
+	s.Close()
+
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteString(`{"seq":2,"type":"DeviceAdd`)
+	f.Close()
+
+	s, err = OpenEventStore(path)
+	if err != nil {
+		t.Fatalf("OpenEventStore() with torn event error = %v", err)
+	}
+	defer s.Close()
+	event, err := s.Append(EventDeviceRemoved, EventPayload{Device: &discovery.MicroDevice{Name: "a"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if event.Seq != 2 {
+		t.Errorf("Append() after torn event seq = %d, want 2", event.Seq)
+	}
+	if events := s.Since(time.Time{}); len(events) != 2 {
+		t.Errorf("Since() = %d events, want 2", len(events))
+	}
+}
+
+func TestEventStoreSince(t *testing.T) {
+	s, err := OpenEventStore(filepath.Join(t.TempDir(), "events.jsonl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	first, err := s.Append(EventDeviceAdded, EventPayload{Device: &discovery.MicroDevice{Name: "a"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := s.Append(EventDeviceAdded, EventPayload{Device: &discovery.MicroDevice{Name: "b"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if events := s.Since(second.Time); len(events) != 1 || events[0].Seq != second.Seq {
+		t.Errorf("Since(second) = %+v, want the second event", events)
+	}
+	if events := s.Since(first.Time.Add(-time.Second)); len(events) != 2 {
+		t.Errorf("Since(before first) = %d events, want 2", len(events))
+	}
+	if events := s.Since(time.Now().Add(time.Hour)); len(events) != 0 {
+		t.Errorf("Since(future) = %d events, want none", len(events))
+	}
+}
@@ -0,0 +1,91 @@
+package state
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sort"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// GarbageCollector removes the allocations of pods that no longer exist
+// from the state, e.g. after a node reset or a kubelet state loss.
+// Allocations without a known pod are kept.
+type GarbageCollector struct {
+	wal    *WAL
+	client kubernetes.Interface
+
+	// Release is called with the devices of the removed allocations to
+	// release them in the plugin
+	Release func(deviceIDs []string)
+
+	// Cleaned counts the orphaned allocations removed, if set
+	Cleaned prometheus.Counter
+}
+
+// NewGarbageCollector creates a garbage collector of the WAL state
+// checking the pods with client
+func NewGarbageCollector(w *WAL, client kubernetes.Interface) *GarbageCollector {
+	return &GarbageCollector{wal: w, client: client}
+}
+
+// Collect runs a collection pass every interval until ctx is done
+func (gc *GarbageCollector) Collect(ctx context.Context, interval time.Duration) error {
+	if interval <= 0 {
+		return fmt.Errorf("invalid garbage collection interval %v", interval)
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			if _, err := gc.CollectOnce(ctx); err != nil {
+				slog.Error("state garbage collection failed", "err", err)
+			}
+		}
+	}
+}
+
+// CollectOnce lists the pods of all namespaces and deallocates the
+// devices owned by missing pods, it returns the number of removed
+// device allocations
+func (gc *GarbageCollector) CollectOnce(ctx context.Context) (int, error) {
+	pods, err := gc.client.CoreV1().Pods(metav1.NamespaceAll).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return 0, fmt.Errorf("list pods: %w", err)
+	}
+	live := make(map[string]bool, len(pods.Items))
+	for _, pod := range pods.Items {
+		live[string(pod.UID)] = true
+	}
+
+	orphans := make(map[string][]string)
+	for id, uid := range gc.wal.State().Owners {
+		if !live[uid] {
+			orphans[uid] = append(orphans[uid], id)
+		}
+	}
+
+	cleaned := 0
+	for uid, ids := range orphans {
+		sort.Strings(ids)
+		if err := gc.wal.Append(WALEntry{Op: OpDealloc, DeviceIDs: ids, PodUID: uid}); err != nil {
+			return cleaned, err
+		}
+		if gc.Release != nil {
+			gc.Release(ids)
+		}
+		cleaned += len(ids)
+		if gc.Cleaned != nil {
+			gc.Cleaned.Add(float64(len(ids)))
+		}
+		slog.Info("orphaned allocation removed", "pod", uid, "devices", ids)
+	}
+	return cleaned, nil
+}
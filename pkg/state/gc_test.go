@@ -0,0 +1,79 @@
+package state
+
+import (
+	"context"
+	"reflect"
+	"slices"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"github.com/kelein/micro-device-plugin/pkg/testing/assert"
+)
+
+func TestGarbageCollector(t *testing.T) {
+	dir := t.TempDir()
+	w, err := OpenWAL(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	appendEntries(t, w,
+		WALEntry{Op: OpAlloc, DeviceIDs: []string{"a1"}, PodUID: "uid-live"},
+		WALEntry{Op: OpAlloc, DeviceIDs: []string{"b2", "c3"}},
+		WALEntry{Op: OpAssign, DeviceIDs: []string{"b2", "c3"}, PodUID: "uid-deleted"},
+		WALEntry{Op: OpAlloc, DeviceIDs: []string{"d4"}},
+	)
+
+	client := fake.NewClientset(&corev1.Pod{ObjectMeta: metav1.ObjectMeta{
+		Name: "live", Namespace: "team-a", UID: types.UID("uid-live"),
+	}})
+	gc := NewGarbageCollector(w, client)
+	var released []string
+	gc.Release = func(ids []string) { released = append(released, ids...) }
+	gc.Cleaned = prometheus.NewCounter(prometheus.CounterOpts{Name: "gc_cleaned_allocations_total"})
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(gc.Cleaned)
+
+	cleaned, err := gc.CollectOnce(context.Background())
+	if err != nil {
+		t.Fatalf("CollectOnce() error = %v", err)
+	}
+	if cleaned != 2 {
+		t.Errorf("CollectOnce() = %d, want 2", cleaned)
+	}
+	assert.AssertMetricValue(t, reg, "gc_cleaned_allocations_total", nil, 2)
+	if want := []string{"b2", "c3"}; !reflect.DeepEqual(released, want) {
+		t.Errorf("released devices = %v, want %v", released, want)
+	}
+
+	// the removal is persisted, the live and unowned allocations are kept
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	w, err = OpenWAL(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+	state := w.State()
+	var allocated []string
+	for id := range state.AllocatedAt {
+		allocated = append(allocated, id)
+	}
+	slices.Sort(allocated)
+	if want := []string{"a1", "d4"}; !reflect.DeepEqual(allocated, want) {
+		t.Errorf("allocated devices = %v, want %v", allocated, want)
+	}
+	if want := map[string]string{"a1": "uid-live"}; !reflect.DeepEqual(state.Owners, want) {
+		t.Errorf("owners = %v, want %v", state.Owners, want)
+	}
+
+	cleaned, err = NewGarbageCollector(w, client).CollectOnce(context.Background())
+	if err != nil || cleaned != 0 {
+		t.Errorf("second CollectOnce() = %d, %v, want 0", cleaned, err)
+	}
+}
@@ -0,0 +1,91 @@
+package state
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// CurrentSchemaVersion is the state file schema version written by the plugin
+const CurrentSchemaVersion = 2
+
+// PluginState is the persisted plugin state
+type PluginState struct {
+	SchemaVersion int `json:"schemaVersion"`
+
+	// DeviceIDs lists the known devices, since version 1
+	DeviceIDs []string `json:"deviceIDs"`
+
+	// AllocatedAt maps the allocated device IDs to their last allocation
+	// time, since version 2
+	AllocatedAt map[string]time.Time `json:"allocatedAt"`
+}
+
+// MigrationFunc migrates the decoded state of one schema version to the
+// next version
+type MigrationFunc func(map[string]interface{}) (map[string]interface{}, error)
+
+// migrations holds the migration from schema version i+1 to i+2 at index i
+var migrations = []MigrationFunc{
+	migrateV1ToV2,
+}
+
+// MigrateState decodes a state file of any known schema version and
+// migrates it to the current version, state files without a schema
+// version are version 1
+func MigrateState(raw []byte) (*PluginState, error) {
+	var data map[string]interface{}
+	if err := json.Unmarshal(raw, &data); err != nil {
+		return nil, fmt.Errorf("decode state: %w", err)
+	}
+
+	version, err := schemaVersion(data)
+	if err != nil {
+		return nil, err
+	}
+	if version > CurrentSchemaVersion {
+		return nil, fmt.Errorf("state schema version %d is newer than supported version %d", version, CurrentSchemaVersion)
+	}
+
+	for v := version; v < CurrentSchemaVersion; v++ {
+		data, err = migrations[v-1](data)
+		if err != nil {
+			return nil, fmt.Errorf("migrate state from version %d: %w", v, err)
+		}
+		data["schemaVersion"] = v + 1
+	}
+
+	migrated, err := json.Marshal(data)
+	if err != nil {
+		return nil, err
+	}
+	state := &PluginState{}
+	if err := json.Unmarshal(migrated, state); err != nil {
+		return nil, fmt.Errorf("decode state version %d: %w", CurrentSchemaVersion, err)
+	}
+	return state, nil
+}
+
+// schemaVersion returns the schema version of the decoded state
+func schemaVersion(data map[string]interface{}) (int, error) {
+	raw, ok := data["schemaVersion"]
+	if !ok {
+		return 1, nil
+	}
+	version, ok := raw.(float64)
+	if !ok || version != float64(int(version)) || version < 1 {
+		return 0, fmt.Errorf("invalid state schema version %v", raw)
+	}
+	return int(version), nil
+}
+
+// migrateV1ToV2 adds the empty device allocation times
+func migrateV1ToV2(data map[string]interface{}) (map[string]interface{}, error) {
+	if _, ok := data["deviceIDs"]; !ok {
+		data["deviceIDs"] = []interface{}{}
+	}
+	if _, ok := data["allocatedAt"]; !ok {
+		data["allocatedAt"] = map[string]interface{}{}
+	}
+	return data, nil
+}
@@ -0,0 +1,41 @@
+package state
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestMigrateStateV1(t *testing.T) {
+	for _, raw := range []string{
+		`{"schemaVersion": 1, "deviceIDs": ["a1", "b2"]}`,
+		`{"deviceIDs": ["a1", "b2"]}`,
+	} {
+		state, err := MigrateState([]byte(raw))
+		if err != nil {
+			t.Fatalf("MigrateState(%s) error = %v", raw, err)
+		}
+		if state.SchemaVersion != CurrentSchemaVersion {
+			t.Errorf("SchemaVersion = %d, want %d", state.SchemaVersion, CurrentSchemaVersion)
+		}
+		if want := []string{"a1", "b2"}; !reflect.DeepEqual(state.DeviceIDs, want) {
+			t.Errorf("DeviceIDs = %v, want %v", state.DeviceIDs, want)
+		}
+		if state.AllocatedAt == nil || len(state.AllocatedAt) != 0 {
+			t.Errorf("AllocatedAt = %v, want empty map", state.AllocatedAt)
+		}
+	}
+}
+
+func TestMigrateStateRejects(t *testing.T) {
+	for _, raw := range []string{
+		`not json`,
+		`{"schemaVersion": 0}`,
+		`{"schemaVersion": 1.5}`,
+		`{"schemaVersion": "2"}`,
+		`{"schemaVersion": 99}`,
+	} {
+		if _, err := MigrateState([]byte(raw)); err == nil {
+			t.Errorf("MigrateState(%s) error = nil, want error", raw)
+		}
+	}
+}
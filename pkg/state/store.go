@@ -0,0 +1,126 @@
+package state
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// State file formats
+const (
+	FormatJSON = "json"
+	FormatGob  = "gob"
+)
+
+// StateStore loads and saves the plugin state file
+type StateStore interface {
+	Load() (*PluginState, error)
+	Save(state *PluginState) error
+}
+
+// Compile-time checks of the StateStore implementations
+var (
+	_ StateStore = (*JSONStateStore)(nil)
+	_ StateStore = (*GobStateStore)(nil)
+)
+
+// NewStateStore returns the store of the format saving the state file
+// `state.<format>` of the state directory
+func NewStateStore(format, dir string) (StateStore, error) {
+	switch format {
+	case FormatJSON:
+		return &JSONStateStore{Path: filepath.Join(dir, "state.json")}, nil
+	case FormatGob:
+		return &GobStateStore{Path: filepath.Join(dir, "state.gob")}, nil
+	default:
+		return nil, fmt.Errorf("unknown state format %q, must be json or gob", format)
+	}
+}
+
+// JSONStateStore stores the plugin state as JSON, state files of older
+// schema versions are migrated on load
+type JSONStateStore struct {
+	Path string
+}
+
+// Load implements StateStore
+func (s *JSONStateStore) Load() (*PluginState, error) {
+	raw, err := os.ReadFile(s.Path)
+	if err != nil {
+		return nil, err
+	}
+	state, err := MigrateState(raw)
+	if err != nil {
+		return nil, err
+	}
+	return normalize(state), nil
+}
+
+// Save implements StateStore
+func (s *JSONStateStore) Save(state *PluginState) error {
+	data, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+	return replaceFile(s.Path, data)
+}
+
+// GobStateStore stores the plugin state in the compact gob encoding,
+// only the current schema version is supported
+type GobStateStore struct {
+	Path string
+}
+
+// Load implements StateStore
+func (s *GobStateStore) Load() (*PluginState, error) {
+	raw, err := os.ReadFile(s.Path)
+	if err != nil {
+		return nil, err
+	}
+	state := &PluginState{}
+	if err := gob.NewDecoder(bytes.NewReader(raw)).Decode(state); err != nil {
+		return nil, fmt.Errorf("decode state: %w", err)
+	}
+	if state.SchemaVersion != CurrentSchemaVersion {
+		return nil, fmt.Errorf("gob state schema version %d is not the supported version %d", state.SchemaVersion, CurrentSchemaVersion)
+	}
+	return normalize(state), nil
+}
+
+// Save implements StateStore
+func (s *GobStateStore) Save(state *PluginState) error {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(state); err != nil {
+		return fmt.Errorf("encode state: %w", err)
+	}
+	return replaceFile(s.Path, buf.Bytes())
+}
+
+// normalize replaces the nil collections left by the decoders, gob
+// omits empty slices and maps
+func normalize(state *PluginState) *PluginState {
+	if state.DeviceIDs == nil {
+		state.DeviceIDs = []string{}
+	}
+	if state.AllocatedAt == nil {
+		state.AllocatedAt = make(map[string]time.Time)
+	}
+	return state
+}
+
+// replaceFile writes data to a temporary file flushed to disk and
+// renames it over path
+func replaceFile(path string, data []byte) error {
+	tmp := path + ".tmp"
+	if err := writeSync(tmp, data); err != nil {
+		return fmt.Errorf("write state: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("replace state: %w", err)
+	}
+	return nil
+}
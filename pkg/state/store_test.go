@@ -0,0 +1,108 @@
+package state
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func testState() *PluginState {
+	now := time.Date(2024, 5, 1, 12, 30, 0, 123456789, time.UTC)
+	return &PluginState{
+		SchemaVersion: CurrentSchemaVersion,
+		DeviceIDs:     []string{"a1", "b2", "c3"},
+		AllocatedAt: map[string]time.Time{
+			"a1": now,
+			"c3": now.Add(-time.Hour),
+		},
+	}
+}
+
+// roundTrip saves the state to the store and loads it back
+func roundTrip(t *testing.T, store StateStore, state *PluginState) []byte {
+	t.Helper()
+	if err := store.Save(state); err != nil {
+		t.Fatalf("Save() = %v", err)
+	}
+	loaded, err := store.Load()
+	if err != nil {
+		t.Fatalf("Load() = %v", err)
+	}
+	data, err := json.Marshal(loaded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func TestGobStateStoreMatchesJSON(t *testing.T) {
+	for name, state := range map[string]*PluginState{
+		"allocated": testState(),
+		"empty":     {SchemaVersion: CurrentSchemaVersion},
+	} {
+		t.Run(name, func(t *testing.T) {
+			dir := t.TempDir()
+			jsonStore, err := NewStateStore(FormatJSON, dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			gobStore, err := NewStateStore(FormatGob, dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			fromJSON := roundTrip(t, jsonStore, state)
+			fromGob := roundTrip(t, gobStore, state)
+			if string(fromGob) != string(fromJSON) {
+				t.Errorf("gob state %s, want the JSON state %s", fromGob, fromJSON)
+			}
+		})
+	}
+}
+
+func TestGobStateStoreSmaller(t *testing.T) {
+	state := &PluginState{SchemaVersion: CurrentSchemaVersion, AllocatedAt: make(map[string]time.Time)}
+	for i := range 256 {
+		id := fmt.Sprintf("micro-%03d", i)
+		state.DeviceIDs = append(state.DeviceIDs, id)
+		state.AllocatedAt[id] = time.Unix(int64(i), 0).UTC()
+	}
+
+	dir := t.TempDir()
+	jsonStore := &JSONStateStore{Path: filepath.Join(dir, "state.json")}
+	gobStore := &GobStateStore{Path: filepath.Join(dir, "state.gob")}
+	if err := jsonStore.Save(state); err != nil {
+		t.Fatal(err)
+	}
+	if err := gobStore.Save(state); err != nil {
+		t.Fatal(err)
+	}
+	jsonInfo, _ := os.Stat(jsonStore.Path)
+	gobInfo, _ := os.Stat(gobStore.Path)
+	if gobInfo.Size() >= jsonInfo.Size() {
+		t.Errorf("gob state is %d bytes, want less than the %d bytes JSON state", gobInfo.Size(), jsonInfo.Size())
+	}
+}
+
+func TestJSONStateStoreMigrates(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+	if err := os.WriteFile(path, []byte(`{"deviceIDs": ["a1"]}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+	state, err := (&JSONStateStore{Path: path}).Load()
+	if err != nil {
+		t.Fatalf("Load() = %v", err)
+	}
+	if state.SchemaVersion != CurrentSchemaVersion || len(state.DeviceIDs) != 1 {
+		t.Errorf("Load() = %+v, want the migrated version 1 state", state)
+	}
+}
+
+func TestNewStateStoreRejectsFormat(t *testing.T) {
+	if _, err := NewStateStore("xml", t.TempDir()); err == nil {
+		t.Error("NewStateStore(xml) error = nil, want error")
+	}
+}
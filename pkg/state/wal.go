@@ -0,0 +1,333 @@
+package state
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// WAL and snapshot file names in the state directory
+const (
+	WALFile      = "state.wal"
+	SnapshotFile = "state.snapshot"
+)
+
+// Op is the operation of a WAL entry
+type Op string
+
+// WAL entry operations
+const (
+	OpAlloc        Op = "alloc"
+	OpDealloc      Op = "dealloc"
+	OpHealthChange Op = "health-change"
+	OpAssign       Op = "assign"
+)
+
+// WALEntry is a state change recorded in the WAL, PodUID is the pod
+// owning the devices of alloc and assign entries if known
+type WALEntry struct {
+	// Seq is assigned by Append, increasing by one per entry
+	Seq       uint64    `json:"seq"`
+	Op        Op        `json:"op"`
+	DeviceIDs []string  `json:"deviceIDs"`
+	Health    string    `json:"health,omitempty"`
+	PodUID    string    `json:"podUID,omitempty"`
+	Time      time.Time `json:"time"`
+}
+
+// Snapshot is the state reconstructed from the WAL entries up to Seq
+type Snapshot struct {
+	Seq uint64 `json:"seq"`
+
+	// AllocatedAt maps the allocated device IDs to their allocation time
+	AllocatedAt map[string]time.Time `json:"allocatedAt"`
+
+	// Health maps the device IDs to their last reported health
+	Health map[string]string `json:"health"`
+
+	// Owners maps the allocated device IDs to the UID of their pod if
+	// known
+	Owners map[string]string `json:"owners,omitempty"`
+}
+
+// NewSnapshot returns an empty snapshot
+func NewSnapshot() *Snapshot {
+	return &Snapshot{
+		AllocatedAt: make(map[string]time.Time),
+		Health:      make(map[string]string),
+		Owners:      make(map[string]string),
+	}
+}
+
+// Apply applies the entry to the snapshot
+func (s *Snapshot) Apply(entry WALEntry) error {
+	for _, id := range entry.DeviceIDs {
+		switch entry.Op {
+		case OpAlloc:
+			s.AllocatedAt[id] = entry.Time
+			if entry.PodUID != "" {
+				s.Owners[id] = entry.PodUID
+			}
+		case OpDealloc:
+			delete(s.AllocatedAt, id)
+			delete(s.Owners, id)
+		case OpAssign:
+			if _, ok := s.AllocatedAt[id]; ok {
+				s.Owners[id] = entry.PodUID
+			}
+		case OpHealthChange:
+			s.Health[id] = entry.Health
+		default:
+			return fmt.Errorf("unknown WAL operation %q", entry.Op)
+		}
+	}
+	s.Seq = entry.Seq
+	return nil
+}
+
+// clone returns a deep copy of the snapshot
+func (s *Snapshot) clone() *Snapshot {
+	c := &Snapshot{
+		Seq:         s.Seq,
+		AllocatedAt: make(map[string]time.Time, len(s.AllocatedAt)),
+		Health:      make(map[string]string, len(s.Health)),
+		Owners:      make(map[string]string, len(s.Owners)),
+	}
+	for id, t := range s.AllocatedAt {
+		c.AllocatedAt[id] = t
+	}
+	for id, h := range s.Health {
+		c.Health[id] = h
+	}
+	for id, uid := range s.Owners {
+		c.Owners[id] = uid
+	}
+	return c
+}
+
+// WAL is a write-ahead log of state changes compacted to a snapshot file.
+// Every entry is a JSON line written with O_SYNC, an entry torn by a
+// crash mid-write is discarded when the WAL is opened.
+type WAL struct {
+	mu           sync.Mutex
+	path         string
+	snapshotPath string
+	file         *os.File
+	state        *Snapshot
+	// snapshotSeq is the sequence number covered by the snapshot file
+	snapshotSeq uint64
+}
+
+// OpenWAL opens the WAL of the state directory and reconstructs the state
+// from the snapshot and the WAL entries following it
+func OpenWAL(dir string) (*WAL, error) {
+	w := &WAL{
+		path:         filepath.Join(dir, WALFile),
+		snapshotPath: filepath.Join(dir, SnapshotFile),
+	}
+
+	state, err := w.loadSnapshot()
+	if err != nil {
+		return nil, err
+	}
+	w.state = state
+	w.snapshotSeq = state.Seq
+
+	valid, err := w.replay(func(entry WALEntry) error { return w.state.Apply(entry) })
+	if err != nil {
+		return nil, err
+	}
+	// drop a torn trailing entry so that new entries start on a new line
+	if err := os.Truncate(w.path, valid); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("truncate WAL: %w", err)
+	}
+
+	w.file, err = os.OpenFile(w.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND|os.O_SYNC, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("open WAL: %w", err)
+	}
+	return w, nil
+}
+
+// Append assigns the next sequence number to the entry and writes it
+func (w *WAL) Append(entry WALEntry) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	switch entry.Op {
+	case OpAlloc, OpDealloc, OpHealthChange, OpAssign:
+	default:
+		return fmt.Errorf("unknown WAL operation %q", entry.Op)
+	}
+	entry.Seq = w.state.Seq + 1
+	if entry.Time.IsZero() {
+		entry.Time = time.Now()
+	}
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	if _, err := w.file.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("append WAL entry %d: %w", entry.Seq, err)
+	}
+	return w.state.Apply(entry)
+}
+
+// Replay calls handler with the WAL entries following the snapshot in
+// sequence order, a torn trailing entry is skipped
+func (w *WAL) Replay(handler func(WALEntry) error) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	_, err := w.replay(handler)
+	return err
+}
+
+// replay calls handler with the complete entries newer than the snapshot
+// and returns the size of the WAL up to the last complete entry
+func (w *WAL) replay(handler func(WALEntry) error) (int64, error) {
+	f, err := os.Open(w.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("open WAL: %w", err)
+	}
+	defer f.Close()
+
+	var valid int64
+	seq := w.snapshotSeq
+	r := bufio.NewReader(f)
+	for {
+		line, err := r.ReadBytes('\n')
+		if errors.Is(err, io.EOF) {
+			// a line without newline is an entry torn by a crash
+			return valid, nil
+		}
+		if err != nil {
+			return 0, fmt.Errorf("read WAL: %w", err)
+		}
+
+		var entry WALEntry
+		if err := json.Unmarshal(bytes.TrimSpace(line), &entry); err != nil {
+			return 0, fmt.Errorf("decode WAL entry at offset %d: %w", valid, err)
+		}
+		valid += int64(len(line))
+		if entry.Seq <= seq {
+			continue
+		}
+		if entry.Seq != seq+1 {
+			return 0, fmt.Errorf("WAL entry %d follows entry %d", entry.Seq, seq)
+		}
+		if err := handler(entry); err != nil {
+			return 0, err
+		}
+		seq = entry.Seq
+	}
+}
+
+// State returns a copy of the current state
+func (w *WAL) State() *Snapshot {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.state.clone()
+}
+
+// Compact writes the current state to the snapshot file and truncates
+// the WAL, the snapshot is replaced atomically
+func (w *WAL) Compact() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	data, err := json.Marshal(w.state)
+	if err != nil {
+		return err
+	}
+	tmp := w.snapshotPath + ".tmp"
+	if err := writeSync(tmp, data); err != nil {
+		return fmt.Errorf("write snapshot: %w", err)
+	}
+	if err := os.Rename(tmp, w.snapshotPath); err != nil {
+		return fmt.Errorf("replace snapshot: %w", err)
+	}
+	// the snapshot covers every entry, a crash before the truncation
+	// only leaves entries skipped by the next replay
+	if err := w.file.Truncate(0); err != nil {
+		return fmt.Errorf("truncate WAL: %w", err)
+	}
+	w.snapshotSeq = w.state.Seq
+	return nil
+}
+
+// RunCompaction compacts the WAL every interval until ctx is done
+func (w *WAL) RunCompaction(ctx context.Context, interval time.Duration, onError func(error)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := w.Compact(); err != nil && onError != nil {
+				onError(err)
+			}
+		}
+	}
+}
+
+// Close closes the WAL file
+func (w *WAL) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.file.Close()
+}
+
+// loadSnapshot reads the snapshot file, an empty snapshot is returned if
+// the file does not exist
+func (w *WAL) loadSnapshot() (*Snapshot, error) {
+	data, err := os.ReadFile(w.snapshotPath)
+	if errors.Is(err, os.ErrNotExist) {
+		return NewSnapshot(), nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read snapshot: %w", err)
+	}
+	snapshot := NewSnapshot()
+	if err := json.Unmarshal(data, snapshot); err != nil {
+		return nil, fmt.Errorf("decode snapshot: %w", err)
+	}
+	if snapshot.AllocatedAt == nil {
+		snapshot.AllocatedAt = make(map[string]time.Time)
+	}
+	if snapshot.Health == nil {
+		snapshot.Health = make(map[string]string)
+	}
+	if snapshot.Owners == nil {
+		snapshot.Owners = make(map[string]string)
+	}
+	return snapshot, nil
+}
+
+// writeSync writes data to path and flushes it to disk
+func writeSync(path string, data []byte) error {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,158 @@
+package state
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"testing"
+
+	deviceapi "k8s.io/kubelet/pkg/apis/deviceplugin/v1beta1"
+)
+
+func appendEntries(t *testing.T, w *WAL, entries ...WALEntry) {
+	t.Helper()
+	for _, entry := range entries {
+		if err := w.Append(entry); err != nil {
+			t.Fatalf("Append(%v) error = %v", entry, err)
+		}
+	}
+}
+
+func TestWALReplay(t *testing.T) {
+	dir := t.TempDir()
+	w, err := OpenWAL(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	appendEntries(t, w,
+		WALEntry{Op: OpAlloc, DeviceIDs: []string{"a1", "b2"}},
+		WALEntry{Op: OpHealthChange, DeviceIDs: []string{"c3"}, Health: deviceapi.Unhealthy},
+		WALEntry{Op: OpDealloc, DeviceIDs: []string{"a1"}},
+	)
+	if err := w.Append(WALEntry{Op: "bogus"}); err == nil {
+		t.Error("Append() with unknown op error = nil, want error")
+	}
+	want := w.State()
+	w.Close()
+
+	w, err = OpenWAL(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+	got := w.State()
+	if !reflect.DeepEqual(sortedKeys(got.AllocatedAt), []string{"b2"}) {
+		t.Errorf("AllocatedAt = %v, want b2", got.AllocatedAt)
+	}
+	if got.Seq != 3 || got.Health["c3"] != deviceapi.Unhealthy {
+		t.Errorf("State() = %+v, want seq 3 and c3 unhealthy", got)
+	}
+	if !got.AllocatedAt["b2"].Equal(want.AllocatedAt["b2"]) {
+		t.Errorf("AllocatedAt[b2] = %v, want %v", got.AllocatedAt["b2"], want.AllocatedAt["b2"])
+	}
+
+	var seqs []uint64
+	if err := w.Replay(func(entry WALEntry) error {
+		seqs = append(seqs, entry.Seq)
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(seqs, []uint64{1, 2, 3}) {
+		t.Errorf("replayed sequence numbers = %v, want [1 2 3]", seqs)
+	}
+}
+
+func TestWALTornWrite(t *testing.T) {
+	dir := t.TempDir()
+	w, err := OpenWAL(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	appendEntries(t, w,
+		WALEntry{Op: OpAlloc, DeviceIDs: []string{"a1"}},
+		WALEntry{Op: OpAlloc, DeviceIDs: []string{"b2"}},
+	)
+	w.Close()
+
+	// simulate a crash in the middle of writing the second entry
+	path := filepath.Join(dir, WALFile)
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Truncate(path, info.Size()-10); err != nil {
+		t.Fatal(err)
+	}
+
+	w, err = OpenWAL(dir)
+	if err != nil {
+		t.Fatalf("OpenWAL() after torn write error = %v", err)
+	}
+	got := w.State()
+	if got.Seq != 1 || !reflect.DeepEqual(sortedKeys(got.AllocatedAt), []string{"a1"}) {
+		t.Fatalf("State() = %+v, want seq 1 with a1 allocated", got)
+	}
+
+	// new entries continue after the last complete entry
+	appendEntries(t, w, WALEntry{Op: OpAlloc, DeviceIDs: []string{"c3"}})
+	w.Close()
+	w, err = OpenWAL(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+	got = w.State()
+	if got.Seq != 2 || !reflect.DeepEqual(sortedKeys(got.AllocatedAt), []string{"a1", "c3"}) {
+		t.Errorf("State() = %+v, want seq 2 with a1 and c3 allocated", got)
+	}
+}
+
+func TestWALCompact(t *testing.T) {
+	dir := t.TempDir()
+	w, err := OpenWAL(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	appendEntries(t, w,
+		WALEntry{Op: OpAlloc, DeviceIDs: []string{"a1", "b2"}},
+		WALEntry{Op: OpDealloc, DeviceIDs: []string{"b2"}},
+	)
+	if err := w.Compact(); err != nil {
+		t.Fatal(err)
+	}
+	if info, err := os.Stat(filepath.Join(dir, WALFile)); err != nil || info.Size() != 0 {
+		t.Errorf("WAL after compaction = %v, %v, want empty file", info, err)
+	}
+	appendEntries(t, w, WALEntry{Op: OpAlloc, DeviceIDs: []string{"c3"}})
+	w.Close()
+
+	w, err = OpenWAL(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+	got := w.State()
+	if got.Seq != 3 || !reflect.DeepEqual(sortedKeys(got.AllocatedAt), []string{"a1", "c3"}) {
+		t.Errorf("State() = %+v, want seq 3 with a1 and c3 allocated", got)
+	}
+
+	var seqs []uint64
+	w.Replay(func(entry WALEntry) error {
+		seqs = append(seqs, entry.Seq)
+		return nil
+	})
+	if !reflect.DeepEqual(seqs, []uint64{3}) {
+		t.Errorf("replayed sequence numbers after compaction = %v, want [3]", seqs)
+	}
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	return keys
+}
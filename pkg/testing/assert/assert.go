@@ -0,0 +1,190 @@
+// Package assert provides the device plugin assertion helpers of the
+// tests, every helper reports a descriptive failure with t.Errorf and
+// returns whether the assertion passed.
+package assert
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	deviceapi "k8s.io/kubelet/pkg/apis/deviceplugin/v1beta1"
+)
+
+// HealthReporter reports the health of the devices advertised to kubelet
+type HealthReporter interface {
+	DeviceHealth(name string) (health string, ok bool)
+}
+
+// AssertDeviceHealthy checks the named device is advertised healthy
+func AssertDeviceHealthy(t testing.TB, server HealthReporter, deviceName string) bool {
+	t.Helper()
+	return assertDeviceHealth(t, server, deviceName, deviceapi.Healthy)
+}
+
+// AssertDeviceUnhealthy checks the named device is advertised unhealthy
+func AssertDeviceUnhealthy(t testing.TB, server HealthReporter, deviceName string) bool {
+	t.Helper()
+	return assertDeviceHealth(t, server, deviceName, deviceapi.Unhealthy)
+}
+
+func assertDeviceHealth(t testing.TB, server HealthReporter, deviceName, want string) bool {
+	t.Helper()
+	health, ok := server.DeviceHealth(deviceName)
+	if !ok {
+		t.Errorf("device %s not found, want %s", deviceName, want)
+		return false
+	}
+	if health != want {
+		t.Errorf("device %s health = %s, want %s", deviceName, health, want)
+		return false
+	}
+	return true
+}
+
+// AssertMetricValue checks the value of the counter, gauge or untyped
+// series of metricName whose labels include labels
+func AssertMetricValue(t testing.TB, gatherer prometheus.Gatherer, metricName string, labels map[string]string, expectedValue float64) bool {
+	t.Helper()
+	families, err := gatherer.Gather()
+	if err != nil {
+		t.Errorf("gather metrics: %v", err)
+		return false
+	}
+
+	var series []string
+	for _, family := range families {
+		if family.GetName() != metricName {
+			continue
+		}
+		for _, m := range family.Metric {
+			value, ok := metricValue(m)
+			if !ok {
+				t.Errorf("metric %s has unsupported type %s", metricName, family.GetType())
+				return false
+			}
+			if !hasLabels(m, labels) {
+				series = append(series, fmt.Sprintf("%s = %v", formatLabels(m), value))
+				continue
+			}
+			if value != expectedValue {
+				t.Errorf("metric %s%s = %v, want %v", metricName, formatLabels(m), value, expectedValue)
+				return false
+			}
+			return true
+		}
+	}
+	if len(series) == 0 {
+		t.Errorf("metric %s not found, want %v", metricName, expectedValue)
+	} else {
+		t.Errorf("metric %s has no series with labels %v, found %s", metricName, labels, strings.Join(series, ", "))
+	}
+	return false
+}
+
+func metricValue(m *dto.Metric) (float64, bool) {
+	switch {
+	case m.Counter != nil:
+		return m.Counter.GetValue(), true
+	case m.Gauge != nil:
+		return m.Gauge.GetValue(), true
+	case m.Untyped != nil:
+		return m.Untyped.GetValue(), true
+	}
+	return 0, false
+}
+
+func hasLabels(m *dto.Metric, labels map[string]string) bool {
+	matched := 0
+	for _, pair := range m.Label {
+		if v, ok := labels[pair.GetName()]; ok {
+			if v != pair.GetValue() {
+				return false
+			}
+			matched++
+		}
+	}
+	return matched == len(labels)
+}
+
+func formatLabels(m *dto.Metric) string {
+	pairs := make([]string, 0, len(m.Label))
+	for _, pair := range m.Label {
+		pairs = append(pairs, fmt.Sprintf("%s=%q", pair.GetName(), pair.GetValue()))
+	}
+	sort.Strings(pairs)
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+// AssertAllocateResponse checks every container response of resp has the
+// expected env vars and mounts, other env vars and mounts are ignored
+func AssertAllocateResponse(t testing.TB, resp *deviceapi.AllocateResponse, expectedEnvVars map[string]string, expectedMounts []*deviceapi.Mount) bool {
+	t.Helper()
+	if resp == nil || len(resp.ContainerResponses) == 0 {
+		t.Errorf("allocate response has no container responses")
+		return false
+	}
+
+	passed := true
+	for i, c := range resp.ContainerResponses {
+		for key, want := range expectedEnvVars {
+			got, ok := c.Envs[key]
+			switch {
+			case !ok:
+				t.Errorf("container %d env %s is missing, want %q", i, key, want)
+				passed = false
+			case got != want:
+				t.Errorf("container %d env %s = %q, want %q", i, key, got, want)
+				passed = false
+			}
+		}
+		for _, want := range expectedMounts {
+			if !hasMount(c.Mounts, want) {
+				t.Errorf("container %d mount %s:%s (read only %v) is missing, got %v",
+					i, want.HostPath, want.ContainerPath, want.ReadOnly, c.Mounts)
+				passed = false
+			}
+		}
+	}
+	return passed
+}
+
+func hasMount(mounts []*deviceapi.Mount, want *deviceapi.Mount) bool {
+	for _, m := range mounts {
+		if m.ContainerPath == want.ContainerPath && m.HostPath == want.HostPath && m.ReadOnly == want.ReadOnly {
+			return true
+		}
+	}
+	return false
+}
+
+// AssertListAndWatchContains checks the latest of the ListAndWatch
+// responses advertises the device with the health
+func AssertListAndWatchContains(t testing.TB, responses []*deviceapi.ListAndWatchResponse, deviceID, health string) bool {
+	t.Helper()
+	if len(responses) == 0 {
+		t.Errorf("no ListAndWatch responses, want device %s %s", deviceID, health)
+		return false
+	}
+
+	latest := responses[len(responses)-1]
+	for _, dev := range latest.Devices {
+		if dev.ID != deviceID {
+			continue
+		}
+		if dev.Health != health {
+			t.Errorf("ListAndWatch device %s health = %s, want %s", deviceID, dev.Health, health)
+			return false
+		}
+		return true
+	}
+	ids := make([]string, len(latest.Devices))
+	for i, dev := range latest.Devices {
+		ids[i] = dev.ID
+	}
+	t.Errorf("ListAndWatch response has no device %s, got %v", deviceID, ids)
+	return false
+}
@@ -0,0 +1,144 @@
+package assert
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	deviceapi "k8s.io/kubelet/pkg/apis/deviceplugin/v1beta1"
+)
+
+// recorder records the failures of the helpers instead of failing the test
+type recorder struct {
+	testing.TB
+	errors []string
+}
+
+func (r *recorder) Helper() {}
+
+func (r *recorder) Errorf(format string, args ...any) {
+	r.errors = append(r.errors, fmt.Sprintf(format, args...))
+}
+
+// check verifies the helper result and its failure message
+func check(t *testing.T, name string, r *recorder, passed bool, wantMsg string) {
+	t.Helper()
+	if wantMsg == "" {
+		if !passed || len(r.errors) > 0 {
+			t.Errorf("%s failed: %v", name, r.errors)
+		}
+		return
+	}
+	if passed || len(r.errors) != 1 || !strings.Contains(r.errors[0], wantMsg) {
+		t.Errorf("%s = %v with failures %q, want failure containing %q", name, passed, r.errors, wantMsg)
+	}
+}
+
+type healthMap map[string]string
+
+func (m healthMap) DeviceHealth(name string) (string, bool) {
+	health, ok := m[name]
+	return health, ok
+}
+
+func TestAssertDeviceHealth(t *testing.T) {
+	server := healthMap{"micro0": deviceapi.Healthy, "micro1": deviceapi.Unhealthy}
+	tests := []struct {
+		name    string
+		assert  func(testing.TB, HealthReporter, string) bool
+		device  string
+		wantMsg string
+	}{
+		{"healthy", AssertDeviceHealthy, "micro0", ""},
+		{"healthy unhealthy device", AssertDeviceHealthy, "micro1", "device micro1 health = Unhealthy, want Healthy"},
+		{"unhealthy", AssertDeviceUnhealthy, "micro1", ""},
+		{"unhealthy healthy device", AssertDeviceUnhealthy, "micro0", "device micro0 health = Healthy, want Unhealthy"},
+		{"missing device", AssertDeviceHealthy, "micro2", "device micro2 not found"},
+	}
+	for _, tt := range tests {
+		r := &recorder{TB: t}
+		check(t, tt.name, r, tt.assert(r, server, tt.device), tt.wantMsg)
+	}
+}
+
+func TestAssertMetricValue(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	counter := prometheus.NewCounterVec(prometheus.CounterOpts{Name: "test_total", Help: "test"}, []string{"node", "kind"})
+	gauge := prometheus.NewGauge(prometheus.GaugeOpts{Name: "test_gauge", Help: "test"})
+	summary := prometheus.NewSummary(prometheus.SummaryOpts{Name: "test_summary", Help: "test"})
+	reg.MustRegister(counter, gauge, summary)
+	counter.WithLabelValues("a", "x").Add(2)
+	counter.WithLabelValues("b", "x").Add(3)
+	gauge.Set(7)
+	summary.Observe(1)
+
+	tests := []struct {
+		name    string
+		metric  string
+		labels  map[string]string
+		want    float64
+		wantMsg string
+	}{
+		{"counter", "test_total", map[string]string{"node": "b"}, 3, ""},
+		{"gauge", "test_gauge", nil, 7, ""},
+		{"wrong value", "test_total", map[string]string{"node": "a", "kind": "x"}, 1, `test_total{kind="x",node="a"} = 2, want 1`},
+		{"no series", "test_total", map[string]string{"node": "c"}, 1, "no series with labels map[node:c]"},
+		{"missing metric", "test_missing", nil, 1, "metric test_missing not found"},
+		{"unsupported type", "test_summary", nil, 1, "unsupported type SUMMARY"},
+	}
+	for _, tt := range tests {
+		r := &recorder{TB: t}
+		check(t, tt.name, r, AssertMetricValue(r, reg, tt.metric, tt.labels, tt.want), tt.wantMsg)
+	}
+}
+
+func TestAssertAllocateResponse(t *testing.T) {
+	resp := &deviceapi.AllocateResponse{
+		ContainerResponses: []*deviceapi.ContainerAllocateResponse{{
+			Envs:   map[string]string{"MICRO_DEVICES": "micro0", "OTHER": "1"},
+			Mounts: []*deviceapi.Mount{{ContainerPath: "/etc/micro", HostPath: "/var/micro", ReadOnly: true}},
+		}},
+	}
+	mount := &deviceapi.Mount{ContainerPath: "/etc/micro", HostPath: "/var/micro", ReadOnly: true}
+	tests := []struct {
+		name    string
+		resp    *deviceapi.AllocateResponse
+		envs    map[string]string
+		mounts  []*deviceapi.Mount
+		wantMsg string
+	}{
+		{"match", resp, map[string]string{"MICRO_DEVICES": "micro0"}, []*deviceapi.Mount{mount}, ""},
+		{"wrong env", resp, map[string]string{"MICRO_DEVICES": "micro1"}, nil, `env MICRO_DEVICES = "micro0", want "micro1"`},
+		{"missing env", resp, map[string]string{"MISSING": "1"}, nil, "env MISSING is missing"},
+		{"missing mount", resp, nil, []*deviceapi.Mount{{ContainerPath: "/etc/micro", HostPath: "/var/micro"}}, "mount /var/micro:/etc/micro (read only false) is missing"},
+		{"no containers", &deviceapi.AllocateResponse{}, nil, nil, "no container responses"},
+	}
+	for _, tt := range tests {
+		r := &recorder{TB: t}
+		check(t, tt.name, r, AssertAllocateResponse(r, tt.resp, tt.envs, tt.mounts), tt.wantMsg)
+	}
+}
+
+func TestAssertListAndWatchContains(t *testing.T) {
+	responses := []*deviceapi.ListAndWatchResponse{
+		{Devices: []*deviceapi.Device{{ID: "id0", Health: deviceapi.Healthy}}},
+		{Devices: []*deviceapi.Device{{ID: "id0", Health: deviceapi.Unhealthy}, {ID: "id1", Health: deviceapi.Healthy}}},
+	}
+	tests := []struct {
+		name      string
+		responses []*deviceapi.ListAndWatchResponse
+		id        string
+		health    string
+		wantMsg   string
+	}{
+		{"latest health", responses, "id0", deviceapi.Unhealthy, ""},
+		{"stale health", responses, "id0", deviceapi.Healthy, "device id0 health = Unhealthy, want Healthy"},
+		{"missing device", responses, "id2", deviceapi.Healthy, "no device id2, got [id0 id1]"},
+		{"no responses", nil, "id0", deviceapi.Healthy, "no ListAndWatch responses"},
+	}
+	for _, tt := range tests {
+		r := &recorder{TB: t}
+		check(t, tt.name, r, AssertListAndWatchContains(r, tt.responses, tt.id, tt.health), tt.wantMsg)
+	}
+}
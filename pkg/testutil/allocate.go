@@ -0,0 +1,70 @@
+package testutil
+
+import (
+	deviceapi "k8s.io/kubelet/pkg/apis/deviceplugin/v1beta1"
+)
+
+// MockAllocateRequestBuilder builds AllocateRequest for tests
+type MockAllocateRequestBuilder struct {
+	containers int
+	deviceIDs  []string
+	envs       map[string]string
+}
+
+// NewMockAllocateRequest creates a builder of a single container request
+func NewMockAllocateRequest() *MockAllocateRequestBuilder {
+	return &MockAllocateRequestBuilder{containers: 1}
+}
+
+// ForContainers sets the number of container requests
+func (b *MockAllocateRequestBuilder) ForContainers(n int) *MockAllocateRequestBuilder {
+	b.containers = n
+	return b
+}
+
+// WithDeviceIDs sets the device IDs requested by every container
+func (b *MockAllocateRequestBuilder) WithDeviceIDs(ids ...string) *MockAllocateRequestBuilder {
+	b.deviceIDs = append(b.deviceIDs, ids...)
+	return b
+}
+
+// WithEnvs sets the env vars expected in every container response.
+// AllocateRequest carries no envs, see ExpectedResponse.
+func (b *MockAllocateRequestBuilder) WithEnvs(envs map[string]string) *MockAllocateRequestBuilder {
+	if b.envs == nil {
+		b.envs = make(map[string]string, len(envs))
+	}
+	for k, v := range envs {
+		b.envs[k] = v
+	}
+	return b
+}
+
+// Build returns the AllocateRequest
+func (b *MockAllocateRequestBuilder) Build() *deviceapi.AllocateRequest {
+	req := &deviceapi.AllocateRequest{}
+	for i := 0; i < b.containers; i++ {
+		ids := make([]string, len(b.deviceIDs))
+		copy(ids, b.deviceIDs)
+		req.ContainerRequests = append(req.ContainerRequests, &deviceapi.ContainerAllocateRequest{
+			DevicesIDs: ids,
+		})
+	}
+	return req
+}
+
+// ExpectedResponse returns the AllocateResponse holding the envs
+// expected for each container request
+func (b *MockAllocateRequestBuilder) ExpectedResponse() *deviceapi.AllocateResponse {
+	resp := &deviceapi.AllocateResponse{}
+	for i := 0; i < b.containers; i++ {
+		envs := make(map[string]string, len(b.envs))
+		for k, v := range b.envs {
+			envs[k] = v
+		}
+		resp.ContainerResponses = append(resp.ContainerResponses, &deviceapi.ContainerAllocateResponse{
+			Envs: envs,
+		})
+	}
+	return resp
+}
@@ -0,0 +1,58 @@
+package testutil
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestMockAllocateRequest(t *testing.T) {
+	req := NewMockAllocateRequest().Build()
+	if len(req.ContainerRequests) != 1 {
+		t.Fatalf("container requests = %d, want 1", len(req.ContainerRequests))
+	}
+	if ids := req.ContainerRequests[0].DevicesIDs; len(ids) != 0 {
+		t.Errorf("device IDs = %v, want none", ids)
+	}
+}
+
+func TestMockAllocateRequestContainers(t *testing.T) {
+	req := NewMockAllocateRequest().ForContainers(3).WithDeviceIDs("a", "b").WithDeviceIDs("c").Build()
+	if len(req.ContainerRequests) != 3 {
+		t.Fatalf("container requests = %d, want 3", len(req.ContainerRequests))
+	}
+	for i, c := range req.ContainerRequests {
+		if want := []string{"a", "b", "c"}; !reflect.DeepEqual(c.DevicesIDs, want) {
+			t.Errorf("container %d device IDs = %v, want %v", i, c.DevicesIDs, want)
+		}
+	}
+
+	// every container owns its device IDs
+	req.ContainerRequests[0].DevicesIDs[0] = "changed"
+	if got := req.ContainerRequests[1].DevicesIDs[0]; got != "a" {
+		t.Errorf("container 1 device ID = %s after changing container 0, want a", got)
+	}
+}
+
+func TestMockAllocateRequestExpectedResponse(t *testing.T) {
+	b := NewMockAllocateRequest().ForContainers(2).
+		WithEnvs(map[string]string{"MICRO_DEVICES": "a"}).
+		WithEnvs(map[string]string{"MICRO_NODE_ARCH": "amd64"})
+
+	resp := b.ExpectedResponse()
+	if len(resp.ContainerResponses) != 2 {
+		t.Fatalf("container responses = %d, want 2", len(resp.ContainerResponses))
+	}
+	want := map[string]string{"MICRO_DEVICES": "a", "MICRO_NODE_ARCH": "amd64"}
+	for i, c := range resp.ContainerResponses {
+		if !reflect.DeepEqual(c.Envs, want) {
+			t.Errorf("container %d envs = %v, want %v", i, c.Envs, want)
+		}
+	}
+	resp.ContainerResponses[0].Envs["MICRO_DEVICES"] = "changed"
+	if got := resp.ContainerResponses[1].Envs["MICRO_DEVICES"]; got != "a" {
+		t.Errorf("container 1 MICRO_DEVICES = %s after changing container 0, want a", got)
+	}
+	if len(b.Build().ContainerRequests) != 2 {
+		t.Error("ExpectedResponse changed the built request")
+	}
+}
@@ -0,0 +1,88 @@
+package testutil
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"path/filepath"
+	"slices"
+	"sync"
+
+	"google.golang.org/grpc"
+	deviceapi "k8s.io/kubelet/pkg/apis/deviceplugin/v1beta1"
+)
+
+// FakeKubelet serves the kubelet device plugin registration service on
+// a unix socket and records the received register requests
+type FakeKubelet struct {
+	socket string
+	serv   *grpc.Server
+
+	mu       sync.Mutex
+	requests []*deviceapi.RegisterRequest
+	err      error
+	versions []string
+}
+
+// NewFakeKubelet starts a fake kubelet listening on `kubelet.sock`
+// under the plugin directory dir
+func NewFakeKubelet(dir string) (*FakeKubelet, error) {
+	k := &FakeKubelet{
+		socket: filepath.Join(dir, "kubelet.sock"),
+		serv:   grpc.NewServer(),
+	}
+	listener, err := net.Listen("unix", k.socket)
+	if err != nil {
+		return nil, err
+	}
+	deviceapi.RegisterRegistrationServer(k.serv, k)
+	go k.serv.Serve(listener)
+	return k, nil
+}
+
+// Register records the request and returns the configured error
+func (k *FakeKubelet) Register(ctx context.Context, req *deviceapi.RegisterRequest) (*deviceapi.Empty, error) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	k.requests = append(k.requests, req)
+	if k.err != nil {
+		return nil, k.err
+	}
+	if k.versions != nil && !slices.Contains(k.versions, req.Version) {
+		// the rejection message of the kubelet device manager
+		return nil, fmt.Errorf("requested API version %q is not supported by kubelet. Supported versions are %q", req.Version, k.versions)
+	}
+	return &deviceapi.Empty{}, nil
+}
+
+// SetSupportedVersions makes the following Register calls of other API
+// versions fail like kubelet does, all versions are accepted by default
+func (k *FakeKubelet) SetSupportedVersions(versions ...string) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	k.versions = versions
+}
+
+// SetError makes the following Register calls fail with err
+func (k *FakeKubelet) SetError(err error) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	k.err = err
+}
+
+// Requests returns the received register requests
+func (k *FakeKubelet) Requests() []*deviceapi.RegisterRequest {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	return append([]*deviceapi.RegisterRequest(nil), k.requests...)
+}
+
+// Socket returns the kubelet socket path
+func (k *FakeKubelet) Socket() string {
+	return k.socket
+}
+
+// Stop stops the fake kubelet
+func (k *FakeKubelet) Stop() {
+	k.serv.Stop()
+}
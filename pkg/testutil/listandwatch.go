@@ -0,0 +1,105 @@
+package testutil
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc/metadata"
+	deviceapi "k8s.io/kubelet/pkg/apis/deviceplugin/v1beta1"
+)
+
+// MockListAndWatchServer implements DevicePlugin_ListAndWatchServer
+// and collects the sent responses for later assertion
+type MockListAndWatchServer struct {
+	ctx context.Context
+
+	mu        sync.Mutex
+	responses []*deviceapi.ListAndWatchResponse
+	sent      chan struct{}
+	calls     int
+	failOn    int
+	failErr   error
+}
+
+// NewMockListAndWatchServer creates a mock stream bound to ctx
+func NewMockListAndWatchServer(ctx context.Context) *MockListAndWatchServer {
+	return &MockListAndWatchServer{
+		ctx:  ctx,
+		sent: make(chan struct{}, 1),
+	}
+}
+
+// FailOnSend makes the n-th Send call, counting from 1, return err
+// without recording the response
+func (m *MockListAndWatchServer) FailOnSend(n int, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.failOn = n
+	m.failErr = err
+}
+
+// Send records the response or fails if configured by FailOnSend
+func (m *MockListAndWatchServer) Send(resp *deviceapi.ListAndWatchResponse) error {
+	m.mu.Lock()
+	m.calls++
+	if m.calls == m.failOn {
+		m.mu.Unlock()
+		return m.failErr
+	}
+	m.responses = append(m.responses, resp)
+	m.mu.Unlock()
+	select {
+	case m.sent <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// Responses returns a copy of the sent responses
+func (m *MockListAndWatchServer) Responses() []*deviceapi.ListAndWatchResponse {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	responses := make([]*deviceapi.ListAndWatchResponse, len(m.responses))
+	copy(responses, m.responses)
+	return responses
+}
+
+// WaitForSends blocks until n responses are sent or timeout expires,
+// it reports whether n responses were sent
+func (m *MockListAndWatchServer) WaitForSends(n int, timeout time.Duration) bool {
+	deadline := time.After(timeout)
+	for {
+		m.mu.Lock()
+		count := len(m.responses)
+		m.mu.Unlock()
+		if count >= n {
+			return true
+		}
+		select {
+		case <-m.sent:
+		case <-deadline:
+			return false
+		}
+	}
+}
+
+// Context returns the stream context
+func (m *MockListAndWatchServer) Context() context.Context { return m.ctx }
+
+// SetHeader is a no-op
+func (m *MockListAndWatchServer) SetHeader(metadata.MD) error { return nil }
+
+// SendHeader is a no-op
+func (m *MockListAndWatchServer) SendHeader(metadata.MD) error { return nil }
+
+// SetTrailer is a no-op
+func (m *MockListAndWatchServer) SetTrailer(metadata.MD) {}
+
+// SendMsg is a no-op
+func (m *MockListAndWatchServer) SendMsg(any) error { return nil }
+
+// RecvMsg is a no-op
+func (m *MockListAndWatchServer) RecvMsg(any) error { return nil }
+
+var _ deviceapi.DevicePlugin_ListAndWatchServer = (*MockListAndWatchServer)(nil)
@@ -0,0 +1,82 @@
+package testutil
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	deviceapi "k8s.io/kubelet/pkg/apis/deviceplugin/v1beta1"
+
+	"github.com/kelein/micro-device-plugin/pkg/testing/assert"
+)
+
+// listResponse returns a ListAndWatch response of the device
+func listResponse(id, health string) *deviceapi.ListAndWatchResponse {
+	return &deviceapi.ListAndWatchResponse{Devices: []*deviceapi.Device{{ID: id, Health: health}}}
+}
+
+func TestMockListAndWatchServerResponses(t *testing.T) {
+	srv := NewMockListAndWatchServer(context.Background())
+	for _, health := range []string{deviceapi.Healthy, deviceapi.Unhealthy} {
+		if err := srv.Send(listResponse("a", health)); err != nil {
+			t.Fatalf("Send() = %v", err)
+		}
+	}
+
+	responses := srv.Responses()
+	if len(responses) != 2 {
+		t.Fatalf("responses = %d, want 2", len(responses))
+	}
+	assert.AssertListAndWatchContains(t, responses, "a", deviceapi.Unhealthy)
+
+	// the returned slice is a copy
+	responses[0] = nil
+	if srv.Responses()[0] == nil {
+		t.Error("Responses() returned the recorded slice")
+	}
+}
+
+func TestMockListAndWatchServerFailOnSend(t *testing.T) {
+	sendErr := errors.New("stream closed")
+	srv := NewMockListAndWatchServer(context.Background())
+	srv.FailOnSend(2, sendErr)
+
+	for i, want := range []error{nil, sendErr, nil} {
+		if err := srv.Send(listResponse("a", deviceapi.Healthy)); !errors.Is(err, want) {
+			t.Errorf("Send() call %d = %v, want %v", i+1, err, want)
+		}
+	}
+	if got := len(srv.Responses()); got != 2 {
+		t.Errorf("responses = %d, want the 2 successful sends", got)
+	}
+}
+
+func TestMockListAndWatchServerWaitForSends(t *testing.T) {
+	srv := NewMockListAndWatchServer(context.Background())
+	go func() {
+		for range 2 {
+			time.Sleep(10 * time.Millisecond)
+			srv.Send(listResponse("a", deviceapi.Healthy))
+		}
+	}()
+
+	if !srv.WaitForSends(2, time.Second) {
+		t.Fatal("WaitForSends(2) = false, want true")
+	}
+	if srv.WaitForSends(3, 20*time.Millisecond) {
+		t.Error("WaitForSends(3) = true with 2 responses sent")
+	}
+}
+
+func TestMockListAndWatchServerContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	srv := NewMockListAndWatchServer(ctx)
+	cancel()
+
+	select {
+	case <-srv.Context().Done():
+	default:
+		t.Error("Context() is not the stream context")
+	}
+}
@@ -0,0 +1,398 @@
+package testutil
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	deviceapi "k8s.io/kubelet/pkg/apis/deviceplugin/v1beta1"
+	"sigs.k8s.io/yaml"
+)
+
+// Scenario actions replaying the kubelet interactions with the plugin
+const (
+	// ActionRegister waits for the plugin to register with the kubelet
+	ActionRegister = "register"
+	// ActionListAndWatch receives the next ListAndWatch device list,
+	// opening the stream on the first call after a registration
+	ActionListAndWatch = "listAndWatch"
+	// ActionAllocate calls Allocate for one container
+	ActionAllocate = "allocate"
+	// ActionPreStartContainer calls PreStartContainer
+	ActionPreStartContainer = "preStartContainer"
+	// ActionAddDevice creates a device file
+	ActionAddDevice = "addDevice"
+	// ActionRemoveDevice removes a device file
+	ActionRemoveDevice = "removeDevice"
+	// ActionRestartKubelet restarts the kubelet, removing the plugin
+	// sockets like kubelet does on startup
+	ActionRestartKubelet = "restartKubelet"
+)
+
+// defaultStepTimeout bounds the steps without a timeout
+const defaultStepTimeout = 5 * time.Second
+
+// Scenario is a recorded sequence of kubelet interactions
+type Scenario struct {
+	Name string `json:"name"`
+	// Devices are the device files created before the plugin starts
+	Devices []string `json:"devices,omitempty"`
+	Steps   []Step   `json:"steps"`
+}
+
+// Step is a kubelet interaction and its expected outcome
+type Step struct {
+	Action string `json:"action"`
+	// Device is the device file name of addDevice and removeDevice
+	Device string `json:"device,omitempty"`
+	// DeviceIDs are the devices of allocate and preStartContainer
+	DeviceIDs []string `json:"deviceIDs,omitempty"`
+	// Count uses the first healthy devices of the last device list when
+	// no device IDs are given
+	Count int `json:"count,omitempty"`
+	// Delay waits before the step
+	Delay metav1.Duration `json:"delay,omitempty"`
+	// Timeout bounds the step, 5s if unset
+	Timeout metav1.Duration `json:"timeout,omitempty"`
+	Expect  Expectation     `json:"expect,omitempty"`
+}
+
+// Expectation is the expected outcome of a step, unset fields are not
+// checked
+type Expectation struct {
+	// Error expects the step to fail
+	Error bool `json:"error,omitempty"`
+	// ResourceName is the registered resource name
+	ResourceName string `json:"resourceName,omitempty"`
+	// Devices and Healthy are the listed and healthy device counts
+	Devices *int `json:"devices,omitempty"`
+	Healthy *int `json:"healthy,omitempty"`
+	// Envs are environment variables of the allocate response
+	Envs map[string]string `json:"envs,omitempty"`
+	// EnvKeys are environment variables the allocate response sets
+	EnvKeys []string `json:"envKeys,omitempty"`
+}
+
+// LoadScenario reads a YAML or JSON scenario file
+func LoadScenario(path string) (*Scenario, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	sc := &Scenario{}
+	if err := yaml.UnmarshalStrict(data, sc); err != nil {
+		return nil, fmt.Errorf("parse scenario %s: %w", path, err)
+	}
+	return sc, nil
+}
+
+// Replayer acts as the kubelet of a plugin and drives it through the
+// steps of a scenario
+type Replayer struct {
+	pluginPath string
+	devicePath string
+
+	kubelet    *FakeKubelet
+	registered int
+	endpoint   string
+	conn       *grpc.ClientConn
+	stream     deviceapi.DevicePlugin_ListAndWatchClient
+	cancel     context.CancelFunc
+	devices    []*deviceapi.Device
+}
+
+// NewReplayer creates a replayer serving the kubelet in the plugin
+// directory and managing the device files of the device directory
+func NewReplayer(pluginPath, devicePath string) *Replayer {
+	return &Replayer{pluginPath: pluginPath, devicePath: devicePath}
+}
+
+// Replay creates the scenario devices, starts the kubelet, calls start
+// to launch the plugin and replays the steps, it returns the first step
+// not matching its expectation
+func (r *Replayer) Replay(ctx context.Context, sc *Scenario, start func() error) error {
+	for _, name := range sc.Devices {
+		if err := r.writeDevice(name); err != nil {
+			return err
+		}
+	}
+
+	kubelet, err := NewFakeKubelet(r.pluginPath)
+	if err != nil {
+		return fmt.Errorf("start kubelet: %w", err)
+	}
+	r.kubelet = kubelet
+	defer r.stop()
+
+	if err := start(); err != nil {
+		return fmt.Errorf("start plugin: %w", err)
+	}
+
+	for i, step := range sc.Steps {
+		if err := r.replayStep(ctx, step); err != nil {
+			return fmt.Errorf("scenario %q step %d %s: %w", sc.Name, i+1, step.Action, err)
+		}
+	}
+	return nil
+}
+
+func (r *Replayer) replayStep(ctx context.Context, step Step) error {
+	if step.Delay.Duration > 0 {
+		select {
+		case <-time.After(step.Delay.Duration):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	timeout := step.Timeout.Duration
+	if timeout <= 0 {
+		timeout = defaultStepTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	var err error
+	switch step.Action {
+	case ActionRegister:
+		err = r.register(ctx, step.Expect)
+	case ActionListAndWatch:
+		err = r.listAndWatch(ctx, step.Expect)
+	case ActionAllocate:
+		err = r.allocate(ctx, step)
+	case ActionPreStartContainer:
+		err = r.preStartContainer(ctx, step)
+	case ActionAddDevice:
+		err = r.writeDevice(step.Device)
+	case ActionRemoveDevice:
+		err = os.Remove(filepath.Join(r.devicePath, step.Device))
+	case ActionRestartKubelet:
+		err = r.restartKubelet()
+	default:
+		return fmt.Errorf("unknown action %q", step.Action)
+	}
+
+	if step.Expect.Error {
+		if err == nil {
+			return fmt.Errorf("expected error, got none")
+		}
+		return nil
+	}
+	return err
+}
+
+// register waits for the next register request of the plugin
+func (r *Replayer) register(ctx context.Context, expect Expectation) error {
+	ticker := time.NewTicker(10 * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		if reqs := r.kubelet.Requests(); len(reqs) > r.registered {
+			req := reqs[r.registered]
+			r.registered++
+			r.closeStream()
+			r.endpoint = req.Endpoint
+			if expect.ResourceName != "" && req.ResourceName != expect.ResourceName {
+				return fmt.Errorf("registered resource %q, want %q", req.ResourceName, expect.ResourceName)
+			}
+			return nil
+		}
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return fmt.Errorf("plugin did not register: %w", ctx.Err())
+		}
+	}
+}
+
+// listAndWatch receives the next device list of the plugin
+func (r *Replayer) listAndWatch(ctx context.Context, expect Expectation) error {
+	if r.stream == nil {
+		if err := r.openStream(); err != nil {
+			return err
+		}
+	}
+
+	recv := make(chan error, 1)
+	var resp *deviceapi.ListAndWatchResponse
+	go func() {
+		var err error
+		resp, err = r.stream.Recv()
+		recv <- err
+	}()
+	select {
+	case err := <-recv:
+		if err != nil {
+			return err
+		}
+	case <-ctx.Done():
+		return fmt.Errorf("no device list received: %w", ctx.Err())
+	}
+
+	r.devices = resp.Devices
+	healthy := len(r.healthyDevices())
+	if expect.Devices != nil && len(resp.Devices) != *expect.Devices {
+		return fmt.Errorf("listed %d devices, want %d", len(resp.Devices), *expect.Devices)
+	}
+	if expect.Healthy != nil && healthy != *expect.Healthy {
+		return fmt.Errorf("listed %d healthy devices, want %d", healthy, *expect.Healthy)
+	}
+	return nil
+}
+
+func (r *Replayer) allocate(ctx context.Context, step Step) error {
+	client, err := r.client()
+	if err != nil {
+		return err
+	}
+	ids, err := r.deviceIDs(step)
+	if err != nil {
+		return err
+	}
+	resp, err := client.Allocate(ctx, &deviceapi.AllocateRequest{
+		ContainerRequests: []*deviceapi.ContainerAllocateRequest{{DevicesIDs: ids}},
+	})
+	if err != nil {
+		return err
+	}
+	if len(resp.ContainerResponses) != 1 {
+		return fmt.Errorf("got %d container responses, want 1", len(resp.ContainerResponses))
+	}
+
+	envs := resp.ContainerResponses[0].Envs
+	for key, want := range step.Expect.Envs {
+		if got := envs[key]; got != want {
+			return fmt.Errorf("env %s = %q, want %q", key, got, want)
+		}
+	}
+	for _, key := range step.Expect.EnvKeys {
+		if _, ok := envs[key]; !ok {
+			return fmt.Errorf("env %s not set", key)
+		}
+	}
+	return nil
+}
+
+func (r *Replayer) preStartContainer(ctx context.Context, step Step) error {
+	client, err := r.client()
+	if err != nil {
+		return err
+	}
+	ids, err := r.deviceIDs(step)
+	if err != nil {
+		return err
+	}
+	_, err = client.PreStartContainer(ctx, &deviceapi.PreStartContainerRequest{DevicesIDs: ids})
+	return err
+}
+
+// deviceIDs returns the step device IDs, or the first healthy devices
+// of the last device list
+func (r *Replayer) deviceIDs(step Step) ([]string, error) {
+	if len(step.DeviceIDs) > 0 {
+		return step.DeviceIDs, nil
+	}
+	healthy := r.healthyDevices()
+	if step.Count > len(healthy) {
+		return nil, fmt.Errorf("%d devices requested, %d healthy devices listed", step.Count, len(healthy))
+	}
+	ids := make([]string, step.Count)
+	for i, dev := range healthy[:step.Count] {
+		ids[i] = dev.ID
+	}
+	return ids, nil
+}
+
+func (r *Replayer) healthyDevices() []*deviceapi.Device {
+	var healthy []*deviceapi.Device
+	for _, dev := range r.devices {
+		if dev.Health == deviceapi.Healthy {
+			healthy = append(healthy, dev)
+		}
+	}
+	return healthy
+}
+
+// restartKubelet replaces the kubelet and removes the plugin sockets,
+// the plugin is expected to register again
+func (r *Replayer) restartKubelet() error {
+	r.closeStream()
+	r.kubelet.Stop()
+	kubelet, err := NewFakeKubelet(r.pluginPath)
+	if err != nil {
+		return err
+	}
+	r.kubelet = kubelet
+	r.registered = 0
+
+	sockets, err := filepath.Glob(filepath.Join(r.pluginPath, "*.sock"))
+	if err != nil {
+		return err
+	}
+	for _, sock := range sockets {
+		if sock != kubelet.Socket() {
+			os.Remove(sock)
+		}
+	}
+	return nil
+}
+
+// client connects to the registered plugin endpoint
+func (r *Replayer) client() (deviceapi.DevicePluginClient, error) {
+	if r.endpoint == "" {
+		return nil, fmt.Errorf("plugin not registered")
+	}
+	if r.conn == nil {
+		conn, err := grpc.NewClient("unix://"+filepath.Join(r.pluginPath, r.endpoint),
+			grpc.WithTransportCredentials(insecure.NewCredentials()),
+		)
+		if err != nil {
+			return nil, err
+		}
+		r.conn = conn
+	}
+	return deviceapi.NewDevicePluginClient(r.conn), nil
+}
+
+func (r *Replayer) openStream() error {
+	client, err := r.client()
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	stream, err := client.ListAndWatch(ctx, &deviceapi.Empty{})
+	if err != nil {
+		cancel()
+		return err
+	}
+	r.stream = stream
+	r.cancel = cancel
+	return nil
+}
+
+// closeStream drops the plugin connection
+func (r *Replayer) closeStream() {
+	if r.cancel != nil {
+		r.cancel()
+		r.cancel = nil
+	}
+	r.stream = nil
+	if r.conn != nil {
+		r.conn.Close()
+		r.conn = nil
+	}
+}
+
+func (r *Replayer) writeDevice(name string) error {
+	return os.WriteFile(filepath.Join(r.devicePath, name), nil, 0644)
+}
+
+func (r *Replayer) stop() {
+	r.closeStream()
+	if r.kubelet != nil {
+		r.kubelet.Stop()
+	}
+}
@@ -0,0 +1,69 @@
+package version
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"k8s.io/apimachinery/pkg/util/version"
+)
+
+// LatestReleaseURL is the GitHub API endpoint of the latest release
+const LatestReleaseURL = "https://api.github.com/repos/kelein/micro-device-plugin/releases/latest"
+
+// VersionChecker compares the running version with the latest release
+type VersionChecker struct {
+	// URL is the GitHub API endpoint of the latest release
+	URL string
+
+	// Current is the running version, the build Version by default
+	Current string
+
+	client *http.Client
+}
+
+// NewVersionChecker creates a checker of the latest GitHub release
+func NewVersionChecker() *VersionChecker {
+	return &VersionChecker{
+		URL:     LatestReleaseURL,
+		Current: Version,
+		client:  &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// CheckLatest fetches the latest release tag and reports whether the
+// running version is not older than it
+func (c *VersionChecker) CheckLatest(ctx context.Context) (latest string, upToDate bool, err error) {
+	current, err := version.ParseSemantic(c.Current)
+	if err != nil {
+		return "", false, fmt.Errorf("parse current version: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.URL, nil)
+	if err != nil {
+		return "", false, err
+	}
+	req.Header.Set("Accept", "application/vnd.github+json")
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return "", false, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", false, fmt.Errorf("fetch latest release: unexpected status %s", resp.Status)
+	}
+
+	var release struct {
+		TagName string `json:"tag_name"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&release); err != nil {
+		return "", false, fmt.Errorf("decode latest release: %w", err)
+	}
+	latestVersion, err := version.ParseSemantic(release.TagName)
+	if err != nil {
+		return "", false, fmt.Errorf("parse latest version: %w", err)
+	}
+	return release.TagName, current.AtLeast(latestVersion), nil
+}
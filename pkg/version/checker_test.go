@@ -0,0 +1,57 @@
+package version
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestCheckLatest(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"tag_name": "v1.2.3", "name": "v1.2.3"}`))
+	}))
+	defer srv.Close()
+
+	tests := []struct {
+		current  string
+		upToDate bool
+	}{
+		{"v1.2.3", true},
+		{"1.2.3", true},
+		{"v1.2.2", false},
+		{"v1.2.3-rc.1", false},
+		{"v1.3.0", true},
+	}
+	for _, tt := range tests {
+		c := NewVersionChecker()
+		c.URL = srv.URL
+		c.Current = tt.current
+		latest, upToDate, err := c.CheckLatest(context.Background())
+		if err != nil {
+			t.Fatalf("CheckLatest() with %s error = %v", tt.current, err)
+		}
+		if latest != "v1.2.3" {
+			t.Errorf("latest = %s, want v1.2.3", latest)
+		}
+		if upToDate != tt.upToDate {
+			t.Errorf("CheckLatest() with %s upToDate = %v, want %v", tt.current, upToDate, tt.upToDate)
+		}
+	}
+}
+
+func TestCheckLatestErrors(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "rate limited", http.StatusForbidden)
+	}))
+	defer srv.Close()
+
+	for _, current := range []string{"", "dev", "v1.2.3"} {
+		c := NewVersionChecker()
+		c.URL = srv.URL
+		c.Current = current
+		if _, _, err := c.CheckLatest(context.Background()); err == nil {
+			t.Errorf("CheckLatest() with %q error = nil, want error", current)
+		}
+	}
+}